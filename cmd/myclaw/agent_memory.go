@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+// agentMemoryChannel names the CLI in buffered messages and daily events.
+const agentMemoryChannel = "cli"
+
+// agentMemoryScopes keeps the CLI to the shared scope, for recall and the
+// memory tools alike: gateway users' own memories stay out of its prompt.
+var agentMemoryScopes = memory.Scopes{Write: memory.SharedScope, Visible: []string{memory.SharedScope}}
+
+// agentMemory runs the gateway's memory pipeline for the CLI agent: each
+// message is sent with the memories it recalls, and the conversation is
+// buffered for extraction, which is flushed when the agent exits. The CLI
+// has a single user, so everything lives in the shared scope.
+type agentMemory struct {
+	engine     *memory.Engine
+	gate       *memory.RetrievalGate
+	extraction *memory.ExtractionService
+}
+
+// openAgentMemory opens the memory pipeline, or returns nil when
+// memory.enabled is false. A nil llm uses the configured memory model.
+func openAgentMemory(ctx context.Context, cfg *config.Config, llm memory.LLMClient) (*agentMemory, error) {
+	if !cfg.Memory.Enabled {
+		return nil, nil
+	}
+	engine, err := memory.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if llm == nil {
+		llm = memory.NewLLMClient(cfg)
+	}
+	m := &agentMemory{
+		engine:     engine,
+		gate:       memory.NewRetrievalGate(cfg, engine),
+		extraction: memory.NewExtractionService(engine, llm, cfg.Memory.Extraction),
+	}
+	m.extraction.Start(ctx)
+	return m, nil
+}
+
+// prompt returns input prefixed with the memories relevant to it.
+func (m *agentMemory) prompt(input string) string {
+	if m == nil || m.gate.Decide(input, agentMemoryScopes.Visible) == "" {
+		return input
+	}
+	memories, err := m.engine.RetrieveFor(input, agentMemoryScopes.Visible)
+	if err != nil {
+		log.Printf("[memory] retrieve warning: %v", err)
+		return input
+	}
+	memories = m.gate.Filter(input, memories)
+	m.gate.Record(len(memories))
+	if len(memories) == 0 {
+		return input
+	}
+	return memory.WithMemoryContext(memory.FormatMemories(memories), input)
+}
+
+// record buffers one turn of the conversation for extraction.
+func (m *agentMemory) record(role, content string) {
+	if m == nil || strings.TrimSpace(content) == "" {
+		return
+	}
+	m.extraction.BufferMessage(agentMemoryScopes.Write, agentMemoryChannel, agentMemoryChannel, role, content)
+}
+
+// Close flushes the buffered conversation into memory and closes the store.
+func (m *agentMemory) Close() {
+	if m == nil {
+		return
+	}
+	m.extraction.Stop()
+	if err := m.engine.Close(); err != nil {
+		log.Printf("[memory] close warning: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+// stubMemoryLLM extracts one fact per conversation and records what it saw.
+type stubMemoryLLM struct {
+	mu            sync.Mutex
+	conversations []string
+}
+
+func (s *stubMemoryLLM) Extract(conversation string) (*memory.ExtractionResult, error) {
+	s.mu.Lock()
+	s.conversations = append(s.conversations, conversation)
+	s.mu.Unlock()
+	return &memory.ExtractionResult{
+		Facts:   []memory.FactEntry{{Content: "The user's cat is called Miso", Topic: "pets", Category: "identity", Importance: 0.8}},
+		Summary: "talked about the cat",
+	}, nil
+}
+
+func (s *stubMemoryLLM) Compress(prompt, content string) (*memory.CompressionResult, error) {
+	return &memory.CompressionResult{}, nil
+}
+
+func (s *stubMemoryLLM) UpdateProfile(currentProfile, newFacts string) (*memory.ProfileResult, error) {
+	return &memory.ProfileResult{}, nil
+}
+
+func setAgentTestHome(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("USERPROFILE", tmpDir)
+	t.Setenv("MYCLAW_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("MYCLAW_MEMORY_ENABLED", "")
+	return tmpDir
+}
+
+func TestRunAgentWithOptions_MemoryPipeline(t *testing.T) {
+	setAgentTestHome(t)
+	engine, err := memory.NewEngine(filepath.Join(config.ConfigDir(), "data", "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.WriteTier2(memory.FactEntry{Content: "myclaw deploys with docker compose", Project: "myclaw", Topic: "deploy", Category: "decision", Importance: 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.WriteTier2(memory.FactEntry{Content: "alice's docker registry password is hunter2", Project: "myclaw", Topic: "deploy", Category: "credential", Importance: 0.9, Scope: memory.UserScope("telegram", "alice")}); err != nil {
+		t.Fatal(err)
+	}
+	engine.Close()
+
+	mockRt := &mockRuntime{response: &api.Response{Result: &api.Result{Output: "Miso is a lovely name."}}}
+	var gotEngine *memory.Engine
+	factory := func(cfg *config.Config, e *memory.Engine) (Runtime, error) {
+		gotEngine = e
+		return mockRt, nil
+	}
+	llm := &stubMemoryLLM{}
+	stdin := strings.NewReader("how does myclaw deploy with docker?\nmy cat is called Miso\nexit\n")
+
+	oldFlag := messageFlag
+	messageFlag = ""
+	defer func() { messageFlag = oldFlag }()
+	err = runAgentWithOptions(AgentOptions{RuntimeFactory: factory, MemoryLLM: llm, Stdin: stdin, Stdout: &strings.Builder{}})
+	if err != nil {
+		t.Fatalf("runAgentWithOptions error: %v", err)
+	}
+
+	if gotEngine == nil {
+		t.Fatal("runtime factory did not get the memory engine")
+	}
+	if len(mockRt.prompts) != 2 || !strings.Contains(mockRt.prompts[0], "[Relevant Memory]") || !strings.Contains(mockRt.prompts[0], "docker compose") {
+		t.Fatalf("prompts = %q", mockRt.prompts)
+	}
+	// Gateway users' own memories are neither recalled nor open to the tools.
+	if strings.Contains(mockRt.prompts[0], "hunter2") {
+		t.Fatalf("prompt recalls a gateway user's memory: %q", mockRt.prompts[0])
+	}
+	if s := mockRt.scopes[0]; s.Write != memory.SharedScope || s.Allows(memory.UserScope("telegram", "alice")) {
+		t.Fatalf("tool scopes = %+v", s)
+	}
+	if len(llm.conversations) != 1 || !strings.Contains(llm.conversations[0], "my cat is called Miso") || strings.Contains(llm.conversations[0], "[Relevant Memory]") {
+		t.Fatalf("extracted conversations = %q", llm.conversations)
+	}
+
+	engine, err = memory.NewEngine(filepath.Join(config.ConfigDir(), "data", "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	if mems, _ := engine.QueryTier2("_global", "pets", 10); len(mems) != 1 || mems[0].Scope != memory.SharedScope {
+		t.Fatalf("extracted memories = %+v", mems)
+	}
+	if n, _ := engine.BufferTokenCount(); n != 0 {
+		t.Fatalf("buffer not flushed on exit: %d tokens left", n)
+	}
+}
+
+func TestRunAgentWithOptions_MemoryDisabled(t *testing.T) {
+	setAgentTestHome(t)
+	t.Setenv("MYCLAW_MEMORY_ENABLED", "false")
+
+	mockRt := &mockRuntime{response: &api.Response{Result: &api.Result{Output: "ok"}}}
+	factory := func(cfg *config.Config, e *memory.Engine) (Runtime, error) {
+		if e != nil {
+			t.Error("memory engine opened while memory is disabled")
+		}
+		return mockRt, nil
+	}
+
+	oldFlag := messageFlag
+	messageFlag = "what did I tell you about my cat?"
+	defer func() { messageFlag = oldFlag }()
+	if err := runAgentWithOptions(AgentOptions{RuntimeFactory: factory, MemoryLLM: &stubMemoryLLM{}, Stdout: &strings.Builder{}}); err != nil {
+		t.Fatalf("runAgentWithOptions error: %v", err)
+	}
+	if len(mockRt.prompts) != 1 || mockRt.prompts[0] != messageFlag {
+		t.Fatalf("prompts = %q", mockRt.prompts)
+	}
+	if _, err := os.Stat(filepath.Join(config.ConfigDir(), "data", "memory.db")); !os.IsNotExist(err) {
+		t.Fatalf("memory database created while disabled: %v", err)
+	}
+}
@@ -13,7 +13,9 @@ import (
 
 	"github.com/cexll/agentsdk-go/pkg/api"
 	"github.com/cexll/agentsdk-go/pkg/model"
+	"github.com/cexll/agentsdk-go/pkg/tool"
 	"github.com/spf13/cobra"
+	"github.com/stellarlinkco/myclaw/internal/channel"
 	"github.com/stellarlinkco/myclaw/internal/config"
 	"github.com/stellarlinkco/myclaw/internal/gateway"
 	"github.com/stellarlinkco/myclaw/internal/memory"
@@ -38,8 +40,9 @@ func (r *runtimeWrapper) Close() {
 	r.rt.Close()
 }
 
-// RuntimeFactory creates a Runtime instance
-type RuntimeFactory func(cfg *config.Config) (Runtime, error)
+// RuntimeFactory creates a Runtime instance; engine is the memory store, or
+// nil when memory is disabled.
+type RuntimeFactory func(cfg *config.Config, engine *memory.Engine) (Runtime, error)
 
 var newRuntime = api.New
 
@@ -85,32 +88,16 @@ func resolvedVersion() string {
 }
 
 // DefaultRuntimeFactory creates the default agentsdk-go runtime
-func DefaultRuntimeFactory(cfg *config.Config) (Runtime, error) {
+func DefaultRuntimeFactory(cfg *config.Config, engine *memory.Engine) (Runtime, error) {
 	if cfg.Provider.APIKey == "" {
 		return nil, fmt.Errorf("API key not set. Run 'myclaw onboard' or set MYCLAW_API_KEY / ANTHROPIC_API_KEY")
 	}
 
-	dbPath := strings.TrimSpace(cfg.Memory.DBPath)
-	if dbPath == "" {
-		dbPath = filepath.Join(config.ConfigDir(), "data", "memory.db")
-	}
-	engine, err := memory.NewEngine(dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("create memory engine: %w", err)
-	}
-	defer engine.Close()
-
-	empty, err := engine.IsEmpty()
-	if err != nil {
-		return nil, fmt.Errorf("inspect memory engine state: %w", err)
-	}
-	if empty {
-		if err := memory.MigrateFromFiles(cfg.Agent.Workspace, engine); err != nil {
-			return nil, fmt.Errorf("migrate legacy file memory: %w", err)
-		}
-	}
-
 	sysPrompt := buildSystemPrompt(cfg, engine)
+	var tools []tool.Tool
+	if engine != nil {
+		tools = memory.NewTools(engine)
+	}
 
 	var provider api.ModelFactory
 	switch cfg.Provider.Type {
@@ -143,6 +130,7 @@ func DefaultRuntimeFactory(cfg *config.Config) (Runtime, error) {
 			Threshold:     cfg.AutoCompact.Threshold,
 			PreserveCount: cfg.AutoCompact.PreserveCount,
 		},
+		CustomTools: tools,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create runtime: %w", err)
@@ -156,6 +144,9 @@ type AgentOptions struct {
 	Stdin          io.Reader
 	Stdout         io.Writer
 	Stderr         io.Writer
+	// MemoryLLM extracts memories from the conversation; nil uses the
+	// configured memory model.
+	MemoryLLM memory.LLMClient
 }
 
 var rootCmd = &cobra.Command{
@@ -222,7 +213,21 @@ func runAgentWithOptions(opts AgentOptions) error {
 		factory = DefaultRuntimeFactory
 	}
 
-	rt, err := factory(cfg)
+	ctx := memory.WithScopes(context.Background(), agentMemoryScopes)
+
+	mem, err := openAgentMemory(ctx, cfg, opts.MemoryLLM)
+	if err != nil {
+		return err
+	}
+	// Closed after the runtime, so the last turn is buffered before the
+	// conversation is flushed into memory.
+	defer mem.Close()
+
+	var engine *memory.Engine
+	if mem != nil {
+		engine = mem.engine
+	}
+	rt, err := factory(cfg, engine)
 	if err != nil {
 		return err
 	}
@@ -242,18 +247,18 @@ func runAgentWithOptions(opts AgentOptions) error {
 		stderr = os.Stderr
 	}
 
-	ctx := context.Background()
-
 	// Single message mode
 	if messageFlag != "" {
+		mem.record("user", messageFlag)
 		resp, err := rt.Run(ctx, api.Request{
-			Prompt:    messageFlag,
+			Prompt:    mem.prompt(messageFlag),
 			SessionID: "cli",
 		})
 		if err != nil {
 			return fmt.Errorf("agent error: %w", err)
 		}
 		if resp != nil && resp.Result != nil {
+			mem.record("assistant", resp.Result.Output)
 			fmt.Fprintln(stdout, resp.Result.Output)
 		}
 		return nil
@@ -275,8 +280,9 @@ func runAgentWithOptions(opts AgentOptions) error {
 			break
 		}
 
+		mem.record("user", input)
 		resp, err := rt.Run(ctx, api.Request{
-			Prompt:    input,
+			Prompt:    mem.prompt(input),
 			SessionID: "cli-repl",
 		})
 		if err != nil {
@@ -284,6 +290,7 @@ func runAgentWithOptions(opts AgentOptions) error {
 			continue
 		}
 		if resp != nil && resp.Result != nil {
+			mem.record("assistant", resp.Result.Output)
 			fmt.Fprintln(stdout, resp.Result.Output)
 		}
 	}
@@ -368,15 +375,22 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Telegram: enabled=%v\n", cfg.Channels.Telegram.Enabled)
 	fmt.Printf("Feishu: enabled=%v\n", cfg.Channels.Feishu.Enabled)
 	fmt.Printf("WeCom: enabled=%v\n", cfg.Channels.WeCom.Enabled)
+	if cfg.Channels.WhatsApp.Enabled {
+		if state, err := channel.WhatsAppStatus(cfg.Channels.WhatsApp); err != nil {
+			fmt.Printf("WhatsApp: enabled=true (%v)\n", err)
+		} else {
+			fmt.Printf("WhatsApp: enabled=true, %s\n", whatsappStateSummary(state))
+		}
+	} else {
+		fmt.Println("WhatsApp: enabled=false")
+	}
 
 	if _, err := os.Stat(cfg.Agent.Workspace); err != nil {
 		fmt.Println("Workspace: not found (run 'myclaw onboard')")
+	} else if !cfg.Memory.Enabled {
+		fmt.Println("Memory: disabled")
 	} else {
-		dbPath := strings.TrimSpace(cfg.Memory.DBPath)
-		if dbPath == "" {
-			dbPath = filepath.Join(config.ConfigDir(), "data", "memory.db")
-		}
-		engine, err := memory.NewEngine(dbPath)
+		engine, err := memory.NewEngine(memory.DBPath(cfg))
 		if err != nil {
 			fmt.Printf("Memory: error (%v)\n", err)
 			return nil
@@ -414,7 +428,10 @@ func buildSystemPrompt(cfg *config.Config, engine *memory.Engine) string {
 		sb.WriteString("\n\n")
 	}
 
-	if profile, err := engine.LoadTier1(); err == nil && strings.TrimSpace(profile) != "" {
+	if engine == nil {
+		return sb.String()
+	}
+	if profile, err := engine.LoadProfile(agentMemoryScopes.Visible); err == nil && strings.TrimSpace(profile) != "" {
 		sb.WriteString("# Core Memory\n")
 		sb.WriteString(profile)
 		sb.WriteString("\n\n")
@@ -440,8 +457,11 @@ Use them to help the user accomplish tasks.
 ## Guidelines
 - Be concise and helpful
 - Use tools proactively when needed
-- Remember information the user tells you by writing to memory
-- Check your memory context for previously stored information
+- Remember information the user tells you with memory_save
+- Check your memory context for previously stored information, and use
+  memory_search to look further
+- When the user corrects something or asks you to forget it, use
+  memory_update or memory_forget
 `
 
 const defaultSoulMD = `# Soul
@@ -120,12 +120,18 @@ func TestBuildSystemPrompt_WithMemory(t *testing.T) {
 	if err := engine.WriteTier1(memory.ProfileEntry{Content: "Important info", Category: "identity"}); err != nil {
 		t.Fatalf("WriteTier1 error: %v", err)
 	}
+	if err := engine.WriteTier1(memory.ProfileEntry{Content: "Alice's door code is 4711", Category: "credential", Scope: memory.UserScope("telegram", "alice")}); err != nil {
+		t.Fatalf("WriteTier1 error: %v", err)
+	}
 
 	prompt := buildSystemPrompt(cfg, engine)
 
 	if !strings.Contains(prompt, "Important info") {
 		t.Error("missing memory content")
 	}
+	if strings.Contains(prompt, "4711") {
+		t.Error("system prompt shows a gateway user's profile")
+	}
 }
 
 func TestBuildSystemPrompt_NoFiles(t *testing.T) {
@@ -616,9 +622,13 @@ type mockRuntime struct {
 	response *api.Response
 	err      error
 	closed   bool
+	prompts  []string
+	scopes   []memory.Scopes
 }
 
 func (m *mockRuntime) Run(ctx context.Context, req api.Request) (*api.Response, error) {
+	m.prompts = append(m.prompts, req.Prompt)
+	m.scopes = append(m.scopes, memory.ScopesFromContext(ctx))
 	return m.response, m.err
 }
 
@@ -628,7 +638,7 @@ func (m *mockRuntime) Close() {
 
 // mockRuntimeFactory returns a factory that creates mock runtimes
 func mockRuntimeFactory(rt Runtime) RuntimeFactory {
-	return func(cfg *config.Config) (Runtime, error) {
+	return func(cfg *config.Config, engine *memory.Engine) (Runtime, error) {
 		return rt, nil
 	}
 }
@@ -867,7 +877,7 @@ func TestDefaultRuntimeFactory_NoAPIKey(t *testing.T) {
 		},
 	}
 
-	_, err := DefaultRuntimeFactory(cfg)
+	_, err := DefaultRuntimeFactory(cfg, nil)
 	if err == nil {
 		t.Error("expected error when API key is not set")
 	}
@@ -906,7 +916,7 @@ func TestDefaultRuntimeFactory_OpenAIReasoningEffort_Runtime(t *testing.T) {
 		return &api.Runtime{}, nil
 	}
 
-	_, err := DefaultRuntimeFactory(cfg)
+	_, err := DefaultRuntimeFactory(cfg, nil)
 	if err != nil {
 		t.Fatalf("DefaultRuntimeFactory error: %v", err)
 	}
@@ -949,7 +959,7 @@ func TestDefaultRuntimeFactory_AnthropicReasoningEffort_Runtime(t *testing.T) {
 		return &api.Runtime{}, nil
 	}
 
-	_, err := DefaultRuntimeFactory(cfg)
+	_, err := DefaultRuntimeFactory(cfg, nil)
 	if err != nil {
 		t.Fatalf("DefaultRuntimeFactory error: %v", err)
 	}
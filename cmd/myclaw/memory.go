@@ -0,0 +1,487 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Inspect and curate the memory store",
+}
+
+var memoryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List memories, filtered by tier, project, topic, category or archived state",
+	Args:  cobra.NoArgs,
+	RunE:  runMemoryList,
+}
+
+var memorySearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search memories by keyword",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runMemorySearch,
+}
+
+var memoryShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show one memory, archived or not",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMemoryShow,
+}
+
+var memoryEditCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Change the content, project, topic, category or importance of a memory",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMemoryEdit,
+}
+
+var memoryArchiveCmd = &cobra.Command{
+	Use:   "archive <id>...",
+	Short: "Archive memories so they are no longer recalled",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runMemoryArchive,
+}
+
+var memoryRestoreCmd = &cobra.Command{
+	Use:   "restore <id>...",
+	Short: "Bring archived memories back",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runMemoryRestore,
+}
+
+var memoryPinCmd = &cobra.Command{
+	Use:   "pin <id>...",
+	Short: "Raise memories to the highest importance so they are recalled first",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runMemoryPin,
+}
+
+var memoryProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Show the core profile, or add an entry with --add",
+	Args:  cobra.NoArgs,
+	RunE:  runMemoryProfile,
+}
+
+var memoryReembedCmd = &cobra.Command{
+	Use:   "reembed",
+	Short: "Embed memories whose embedding is missing or from another embedding model or dimension",
+	Args:  cobra.NoArgs,
+	RunE:  runMemoryReembed,
+}
+
+var (
+	memoryJSONFlag     bool
+	memoryTierFlag     int
+	memoryProjectFlag  string
+	memoryTopicFlag    string
+	memoryCategoryFlag string
+	memoryScopeFlag    string
+	memoryArchivedFlag bool
+	memoryAllFlag      bool
+	memoryLimitFlag    int
+
+	memoryContentFlag    string
+	memoryImportanceFlag float64
+	memoryProfileAddFlag string
+	memoryBatchFlag      int
+)
+
+func init() {
+	for _, c := range []*cobra.Command{memoryListCmd, memorySearchCmd} {
+		c.Flags().IntVar(&memoryTierFlag, "tier", 0, "Only this tier (1 = core profile, 2 = facts)")
+		c.Flags().StringVar(&memoryProjectFlag, "project", "", "Only this project")
+		c.Flags().StringVar(&memoryTopicFlag, "topic", "", "Only this topic")
+		c.Flags().StringVar(&memoryCategoryFlag, "category", "", "Only this category")
+		c.Flags().StringVar(&memoryScopeFlag, "scope", "", "Only this scope (shared, user:<channel>:<id> or chat:<channel>:<id>)")
+		c.Flags().BoolVar(&memoryArchivedFlag, "archived", false, "Only archived memories")
+		c.Flags().BoolVar(&memoryAllFlag, "all", false, "Active and archived memories")
+		c.Flags().IntVar(&memoryLimitFlag, "limit", 50, "Maximum number of memories")
+	}
+	memoryEditCmd.Flags().StringVar(&memoryContentFlag, "content", "", "New content")
+	memoryEditCmd.Flags().StringVar(&memoryProjectFlag, "project", "", "New project (facts only)")
+	memoryEditCmd.Flags().StringVar(&memoryTopicFlag, "topic", "", "New topic (facts only)")
+	memoryEditCmd.Flags().StringVar(&memoryCategoryFlag, "category", "", "New category")
+	memoryEditCmd.Flags().Float64Var(&memoryImportanceFlag, "importance", 0, "New importance, 0-1 (facts only)")
+	memoryEditCmd.Flags().StringVar(&memoryScopeFlag, "scope", "", "Move to this scope")
+	memoryProfileCmd.Flags().StringVar(&memoryProfileAddFlag, "add", "", "Add this entry to the core profile")
+	memoryProfileCmd.Flags().StringVar(&memoryCategoryFlag, "category", "", "Category of the added entry (default identity)")
+	memoryProfileCmd.Flags().StringVar(&memoryScopeFlag, "scope", "", "Scope of the added entry (default shared)")
+	memoryReembedCmd.Flags().IntVar(&memoryBatchFlag, "batch", 0, "Memories per embedding request (default memory.embedding.batchSize)")
+
+	for _, c := range []*cobra.Command{memoryListCmd, memorySearchCmd, memoryShowCmd, memoryEditCmd, memoryPinCmd, memoryProfileCmd} {
+		c.Flags().BoolVar(&memoryJSONFlag, "json", false, "Print JSON")
+	}
+	memoryCmd.AddCommand(memoryListCmd, memorySearchCmd, memoryShowCmd, memoryEditCmd,
+		memoryArchiveCmd, memoryRestoreCmd, memoryPinCmd, memoryProfileCmd, memoryReembedCmd)
+	rootCmd.AddCommand(memoryCmd)
+}
+
+func openMemoryEngine() (*memory.Engine, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	engine, err := memory.NewEngine(memory.DBPath(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("open memory: %w", err)
+	}
+	return engine, nil
+}
+
+func memoryFilterFromFlags() (memory.MemoryFilter, error) {
+	f := memory.MemoryFilter{
+		Tier:     memoryTierFlag,
+		Project:  memoryProjectFlag,
+		Topic:    memoryTopicFlag,
+		Category: memoryCategoryFlag,
+		Limit:    memoryLimitFlag,
+	}
+	if scope := strings.TrimSpace(memoryScopeFlag); scope != "" {
+		f.Scopes = []string{scope}
+	}
+	if f.Tier != 0 && f.Tier != 1 && f.Tier != 2 {
+		return f, fmt.Errorf("--tier must be 1 or 2, got %d", f.Tier)
+	}
+	switch {
+	case memoryArchivedFlag && memoryAllFlag:
+		return f, errors.New("--archived and --all cannot be combined")
+	case memoryArchivedFlag:
+		f.Archived = memory.ArchivedOnly
+	case memoryAllFlag:
+		f.Archived = memory.ActiveAndArchived
+	}
+	return f, nil
+}
+
+func runMemoryList(cmd *cobra.Command, args []string) error {
+	return listMemories(cmd, "")
+}
+
+func runMemorySearch(cmd *cobra.Command, args []string) error {
+	return listMemories(cmd, strings.Join(args, " "))
+}
+
+func listMemories(cmd *cobra.Command, query string) error {
+	f, err := memoryFilterFromFlags()
+	if err != nil {
+		return err
+	}
+	f.Query = query
+	engine, err := openMemoryEngine()
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	memories, err := engine.ListMemories(f)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if memoryJSONFlag {
+		return writeMemoryJSON(out, memories)
+	}
+	if len(memories) == 0 {
+		fmt.Fprintln(out, "No matching memories.")
+		return nil
+	}
+	printMemoryTable(out, memories)
+	return nil
+}
+
+func runMemoryShow(cmd *cobra.Command, args []string) error {
+	id, err := parseMemoryID(args[0])
+	if err != nil {
+		return err
+	}
+	engine, err := openMemoryEngine()
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	m, err := engine.LookupMemory(id)
+	if err != nil {
+		return err
+	}
+	return printMemory(cmd.OutOrStdout(), m)
+}
+
+func runMemoryEdit(cmd *cobra.Command, args []string) error {
+	id, err := parseMemoryID(args[0])
+	if err != nil {
+		return err
+	}
+	fact := memory.FactEntry{
+		Content:    memoryContentFlag,
+		Project:    memoryProjectFlag,
+		Topic:      memoryTopicFlag,
+		Category:   memoryCategoryFlag,
+		Importance: memoryImportanceFlag,
+		Scope:      strings.TrimSpace(memoryScopeFlag),
+	}
+	if fact == (memory.FactEntry{}) {
+		return errors.New("nothing to change: give --content, --project, --topic, --category, --importance or --scope")
+	}
+	if fact.Importance < 0 || fact.Importance > 1 {
+		return fmt.Errorf("--importance must be between 0 and 1, got %g", fact.Importance)
+	}
+	engine, err := openMemoryEngine()
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	m, err := engine.UpdateMemory(id, fact)
+	if err != nil {
+		return archivedHint(engine, id, err)
+	}
+	return printMemory(cmd.OutOrStdout(), m)
+}
+
+func runMemoryArchive(cmd *cobra.Command, args []string) error {
+	return eachMemory(cmd, args, func(engine *memory.Engine, id int64) error {
+		if _, err := engine.GetMemory(id); err != nil {
+			return archivedHint(engine, id, err)
+		}
+		if err := engine.ArchiveMemory(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Archived #%d\n", id)
+		return nil
+	})
+}
+
+func runMemoryRestore(cmd *cobra.Command, args []string) error {
+	return eachMemory(cmd, args, func(engine *memory.Engine, id int64) error {
+		if err := engine.RestoreMemory(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Restored #%d\n", id)
+		return nil
+	})
+}
+
+func runMemoryPin(cmd *cobra.Command, args []string) error {
+	pinned := []memory.Memory{}
+	err := eachMemory(cmd, args, func(engine *memory.Engine, id int64) error {
+		m, err := engine.UpdateMemory(id, memory.FactEntry{Importance: 1})
+		if err != nil {
+			return archivedHint(engine, id, err)
+		}
+		pinned = append(pinned, m)
+		if !memoryJSONFlag {
+			fmt.Fprintf(cmd.OutOrStdout(), "Pinned #%d\n", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if memoryJSONFlag {
+		return writeMemoryJSON(cmd.OutOrStdout(), pinned)
+	}
+	return nil
+}
+
+func runMemoryProfile(cmd *cobra.Command, args []string) error {
+	engine, err := openMemoryEngine()
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	out := cmd.OutOrStdout()
+	if content := strings.TrimSpace(memoryProfileAddFlag); content != "" {
+		id, err := engine.AddTier1(memory.ProfileEntry{Content: content, Category: memoryCategoryFlag, Scope: strings.TrimSpace(memoryScopeFlag)})
+		if err != nil {
+			return err
+		}
+		m, err := engine.GetMemory(id)
+		if err != nil {
+			return err
+		}
+		return printMemory(out, m)
+	}
+
+	entries, err := engine.ListTier1()
+	if err != nil {
+		return err
+	}
+	if memoryJSONFlag {
+		return writeMemoryJSON(out, entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "The core profile is empty.")
+		return nil
+	}
+	for _, m := range entries {
+		if m.Scope != memory.SharedScope {
+			fmt.Fprintf(out, "#%d [%s, %s] %s\n", m.ID, m.Category, m.Scope, m.Content)
+			continue
+		}
+		fmt.Fprintf(out, "#%d [%s] %s\n", m.ID, m.Category, m.Content)
+	}
+	return nil
+}
+
+func runMemoryReembed(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if !cfg.Memory.Embedding.Enabled {
+		return errors.New("embeddings are disabled (set memory.embedding.enabled)")
+	}
+	engine, err := memory.NewEngine(memory.DBPath(cfg))
+	if err != nil {
+		return fmt.Errorf("open memory: %w", err)
+	}
+	defer engine.Close()
+	engine.SetEmbedder(memory.NewEmbedder(cfg), memory.EmbeddingModel(cfg), cfg.Memory.Embedding.TimeoutMs)
+
+	out := cmd.OutOrStdout()
+	stale, err := engine.MarkStaleEmbeddings(memory.EmbeddingDimension(cfg))
+	if err != nil {
+		return err
+	}
+	pending, err := engine.PendingEmbeddings()
+	if err != nil {
+		return err
+	}
+	if pending == 0 {
+		fmt.Fprintln(out, "All memories have current embeddings.")
+		return nil
+	}
+	fmt.Fprintf(out, "Embedding %d memories (%d stale) with %s\n", pending, stale, memory.EmbeddingModel(cfg))
+
+	batch := memoryBatchFlag
+	if batch <= 0 {
+		batch = cfg.Memory.Embedding.BatchSize
+	}
+	done, err := engine.Reembed(cmd.Context(), batch, func(p memory.ReembedProgress) {
+		if p.Running {
+			fmt.Fprintf(out, "  %d/%d\n", p.Done, p.Total)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("stopped after %d memories (run again to continue): %w", done, err)
+	}
+	if failed := engine.ReembedStatus().Failed; failed > 0 {
+		fmt.Fprintf(out, "Embedded %d memories; %d were rejected by the embedder and skipped (see the log).\n", done, failed)
+		return nil
+	}
+	fmt.Fprintf(out, "Embedded %d memories.\n", done)
+	return nil
+}
+
+// eachMemory opens the store once and applies fn to every ID argument,
+// stopping at the first error.
+func eachMemory(cmd *cobra.Command, args []string, fn func(*memory.Engine, int64) error) error {
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := parseMemoryID(arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	engine, err := openMemoryEngine()
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	for _, id := range ids {
+		if err := fn(engine, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivedHint explains a not-found error for a memory that exists but is
+// archived.
+func archivedHint(engine *memory.Engine, id int64, err error) error {
+	if !errors.Is(err, memory.ErrMemoryNotFound) {
+		return err
+	}
+	if m, lookupErr := engine.LookupMemory(id); lookupErr == nil && m.IsArchived {
+		return fmt.Errorf("memory %d is archived (run 'myclaw memory restore %d' first)", id, id)
+	}
+	return err
+}
+
+// parseMemoryID accepts "12" as well as "#12", the form the listings print.
+func parseMemoryID(s string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(s), "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid memory id %q", s)
+	}
+	return id, nil
+}
+
+func printMemory(out io.Writer, m memory.Memory) error {
+	if memoryJSONFlag {
+		return writeMemoryJSON(out, m)
+	}
+	state := "active"
+	if m.IsArchived {
+		state = "archived"
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", m.ID)
+	fmt.Fprintf(tw, "Tier:\t%d\n", m.Tier)
+	fmt.Fprintf(tw, "State:\t%s\n", state)
+	fmt.Fprintf(tw, "Scope:\t%s\n", m.Scope)
+	fmt.Fprintf(tw, "Project:\t%s\n", m.Project)
+	fmt.Fprintf(tw, "Topic:\t%s\n", m.Topic)
+	fmt.Fprintf(tw, "Category:\t%s\n", m.Category)
+	fmt.Fprintf(tw, "Importance:\t%.2f\n", m.Importance)
+	fmt.Fprintf(tw, "Source:\t%s\n", m.Source)
+	fmt.Fprintf(tw, "Created:\t%s\n", m.CreatedAt)
+	fmt.Fprintf(tw, "Updated:\t%s\n", m.UpdatedAt)
+	fmt.Fprintf(tw, "Last accessed:\t%s (%d times)\n", m.LastAccessed, m.AccessCount)
+	fmt.Fprintf(tw, "Content:\t%s\n", m.Content)
+	return tw.Flush()
+}
+
+func printMemoryTable(out io.Writer, memories []memory.Memory) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIER\tSCOPE\tPROJECT/TOPIC\tCATEGORY\tIMPORTANCE\tCONTENT")
+	for _, m := range memories {
+		id := fmt.Sprintf("#%d", m.ID)
+		if m.IsArchived {
+			id += " (archived)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s/%s\t%s\t%.2f\t%s\n", id, m.Tier, m.Scope, m.Project, m.Topic, m.Category, m.Importance, oneLine(m.Content, 80))
+	}
+	_ = tw.Flush()
+}
+
+// oneLine flattens s and cuts it to max runes for table output.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return s
+}
+
+func writeMemoryJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+// runMemoryCommand runs "myclaw memory ..." with the flag variables reset,
+// since cobra keeps their values between executions.
+func runMemoryCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	memoryJSONFlag, memoryArchivedFlag, memoryAllFlag = false, false, false
+	memoryTierFlag, memoryLimitFlag = 0, 50
+	memoryProjectFlag, memoryTopicFlag, memoryCategoryFlag, memoryScopeFlag = "", "", "", ""
+	memoryContentFlag, memoryProfileAddFlag = "", ""
+	memoryImportanceFlag, memoryBatchFlag = 0, 0
+	return executeRootCommandForTest(t, append([]string{"memory"}, args...)...)
+}
+
+func seedMemoryCLI(t *testing.T) (goID, debugID int64) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("USERPROFILE", tmpDir)
+
+	engine, err := memory.NewEngine(filepath.Join(config.ConfigDir(), "data", "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	if _, err := engine.AddTier1(memory.ProfileEntry{Content: "user is called Sam"}); err != nil {
+		t.Fatal(err)
+	}
+	if goID, err = engine.AddTier2(memory.FactEntry{Content: "myclaw is written in Go", Project: "myclaw", Category: "decision", Importance: 0.6}); err != nil {
+		t.Fatal(err)
+	}
+	if debugID, err = engine.AddTier2(memory.FactEntry{Content: "port 8080 was busy", Project: "home", Category: "debug", Importance: 0.2}); err != nil {
+		t.Fatal(err)
+	}
+	return goID, debugID
+}
+
+func TestMemoryCommand_ListAndSearch(t *testing.T) {
+	goID, _ := seedMemoryCLI(t)
+
+	out, err := runMemoryCommand(t, "list", "--tier", "2", "--project", "myclaw")
+	if err != nil {
+		t.Fatalf("list error: %v", err)
+	}
+	if !strings.Contains(out, "myclaw is written in Go") || strings.Contains(out, "port 8080") || strings.Contains(out, "Sam") {
+		t.Errorf("list output:\n%s", out)
+	}
+
+	out, err = runMemoryCommand(t, "search", "written", "--json")
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	var got []memory.Memory
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("search JSON: %v\n%s", err, out)
+	}
+	if len(got) != 1 || got[0].ID != goID {
+		t.Errorf("search = %+v", got)
+	}
+
+	if _, err := runMemoryCommand(t, "list", "--archived", "--all"); err == nil {
+		t.Error("expected --archived with --all to fail")
+	}
+}
+
+func TestMemoryCommand_Scope(t *testing.T) {
+	goID, _ := seedMemoryCLI(t)
+	alice := memory.UserScope("telegram", "alice")
+
+	if _, err := runMemoryCommand(t, "edit", strconv.FormatInt(goID, 10), "--scope", alice); err != nil {
+		t.Fatalf("edit --scope error: %v", err)
+	}
+	out, err := runMemoryCommand(t, "list", "--scope", alice)
+	if err != nil {
+		t.Fatalf("list --scope error: %v", err)
+	}
+	if !strings.Contains(out, "myclaw is written in Go") || !strings.Contains(out, alice) || strings.Contains(out, "port 8080") {
+		t.Errorf("list --scope output:\n%s", out)
+	}
+	if out, _ := runMemoryCommand(t, "list", "--scope", "shared"); strings.Contains(out, "myclaw is written in Go") {
+		t.Errorf("moved memory still shared:\n%s", out)
+	}
+}
+
+func TestMemoryCommand_Curation(t *testing.T) {
+	goID, debugID := seedMemoryCLI(t)
+	id := func(n int64) string { return "#" + strconv.FormatInt(n, 10) }
+
+	if _, err := runMemoryCommand(t, "edit", id(goID), "--content", "myclaw is written in Go 1.24", "--topic", "stack"); err != nil {
+		t.Fatalf("edit error: %v", err)
+	}
+	if _, err := runMemoryCommand(t, "pin", id(goID)); err != nil {
+		t.Fatalf("pin error: %v", err)
+	}
+	out, err := runMemoryCommand(t, "show", id(goID), "--json")
+	if err != nil {
+		t.Fatalf("show error: %v", err)
+	}
+	var m memory.Memory
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		t.Fatalf("show JSON: %v\n%s", err, out)
+	}
+	if m.Content != "myclaw is written in Go 1.24" || m.Topic != "stack" || m.Importance != 1 {
+		t.Errorf("after edit and pin = %+v", m)
+	}
+
+	if _, err := runMemoryCommand(t, "archive", id(debugID)); err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	if out, _ := runMemoryCommand(t, "list"); strings.Contains(out, "port 8080") {
+		t.Errorf("archived memory still listed:\n%s", out)
+	}
+	if out, _ := runMemoryCommand(t, "list", "--archived"); !strings.Contains(out, "port 8080") {
+		t.Errorf("archived memory missing from --archived:\n%s", out)
+	}
+	if _, err := runMemoryCommand(t, "edit", id(debugID), "--content", "x"); err == nil || !strings.Contains(err.Error(), "restore") {
+		t.Errorf("edit archived err = %v", err)
+	}
+	if _, err := runMemoryCommand(t, "restore", id(debugID)); err != nil {
+		t.Fatalf("restore error: %v", err)
+	}
+	if out, _ := runMemoryCommand(t, "show", id(debugID)); !strings.Contains(out, "active") {
+		t.Errorf("restored memory:\n%s", out)
+	}
+	if _, err := runMemoryCommand(t, "archive", "999"); err == nil {
+		t.Error("expected archiving a missing memory to fail")
+	}
+	if _, err := runMemoryCommand(t, "edit", id(goID)); err == nil {
+		t.Error("expected edit without changes to fail")
+	}
+}
+
+func TestMemoryCommand_Profile(t *testing.T) {
+	seedMemoryCLI(t)
+
+	if _, err := runMemoryCommand(t, "profile", "--add", "user writes in English", "--category", "config"); err != nil {
+		t.Fatalf("profile --add error: %v", err)
+	}
+	out, err := runMemoryCommand(t, "profile")
+	if err != nil {
+		t.Fatalf("profile error: %v", err)
+	}
+	if !strings.Contains(out, "[identity] user is called Sam") || !strings.Contains(out, "[config] user writes in English") {
+		t.Errorf("profile output:\n%s", out)
+	}
+}
+
+func TestMemoryCommand_Reembed(t *testing.T) {
+	seedMemoryCLI(t)
+
+	if _, err := runMemoryCommand(t, "reembed"); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Fatalf("reembed without embeddings error = %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Memory.Embedding.Enabled = true
+	cfg.Memory.Embedding.Provider = "local"
+	cfg.Memory.Embedding.Dimension = 64
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runMemoryCommand(t, "reembed", "--batch", "1")
+	if err != nil {
+		t.Fatalf("reembed error: %v", err)
+	}
+	if !strings.Contains(out, "Embedding 2 memories (0 stale)") || !strings.Contains(out, "1/2") || !strings.Contains(out, "Embedded 2 memories.") {
+		t.Errorf("reembed output:\n%s", out)
+	}
+
+	// A new dimension makes every embedding stale.
+	cfg.Memory.Embedding.Dimension = 32
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = runMemoryCommand(t, "reembed"); err != nil || !strings.Contains(out, "Embedding 2 memories (2 stale)") {
+		t.Fatalf("reembed after dimension change = %v\n%s", err, out)
+	}
+	if out, err = runMemoryCommand(t, "reembed"); err != nil || !strings.Contains(out, "All memories have current embeddings.") {
+		t.Fatalf("reembed when current = %v\n%s", err, out)
+	}
+}
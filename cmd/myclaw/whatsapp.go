@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/stellarlinkco/myclaw/internal/channel"
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+var whatsappCmd = &cobra.Command{
+	Use:   "whatsapp",
+	Short: "Manage the WhatsApp login without starting the gateway",
+}
+
+var whatsappLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Link WhatsApp with a QR code, or a pairing code with --phone",
+	RunE:  runWhatsAppLogin,
+}
+
+var whatsappLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Unlink WhatsApp and remove the local session",
+	RunE:  runWhatsAppLogout,
+}
+
+var whatsappStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the WhatsApp login state and any pending QR or pairing code",
+	RunE:  runWhatsAppStatus,
+}
+
+var whatsappPhoneFlag string
+
+func init() {
+	whatsappLoginCmd.Flags().StringVar(&whatsappPhoneFlag, "phone", "", "Phone number (with country code) to link with a pairing code")
+	whatsappCmd.AddCommand(whatsappLoginCmd, whatsappLogoutCmd, whatsappStatusCmd)
+	rootCmd.AddCommand(whatsappCmd)
+}
+
+func runWhatsAppLogin(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	waCfg := cfg.Channels.WhatsApp
+	if whatsappPhoneFlag != "" {
+		waCfg.PairPhone = whatsappPhoneFlag
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return channel.WhatsAppLogin(ctx, waCfg, cmd.OutOrStdout())
+}
+
+func runWhatsAppLogout(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if err := channel.WhatsAppLogout(context.Background(), cfg.Channels.WhatsApp); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "WhatsApp logged out")
+	return nil
+}
+
+func runWhatsAppStatus(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	state, err := channel.WhatsAppStatus(cfg.Channels.WhatsApp)
+	if err != nil {
+		return err
+	}
+	printWhatsAppState(cmd.OutOrStdout(), state)
+	return nil
+}
+
+// printWhatsAppState writes the state summary, plus the raw QR payload so it
+// can be rendered elsewhere when no terminal QR is available.
+func printWhatsAppState(out io.Writer, state channel.WhatsAppLoginState) {
+	fmt.Fprintf(out, "WhatsApp: %s\n", whatsappStateSummary(state))
+	if state.Status == channel.WhatsAppLoginQR {
+		fmt.Fprintf(out, "QR payload: %s\n", state.QRCode)
+	}
+}
+
+func whatsappStateSummary(state channel.WhatsAppLoginState) string {
+	switch state.Status {
+	case channel.WhatsAppLoginPaired:
+		return "paired as " + state.JID
+	case channel.WhatsAppLoginPairingCode:
+		return fmt.Sprintf("waiting for pairing code %s (updated %s)", state.PairingCode, state.UpdatedAt.Format("15:04:05"))
+	case channel.WhatsAppLoginQR:
+		return fmt.Sprintf("waiting for QR scan (updated %s; also shown in the Web UI)", state.UpdatedAt.Format("15:04:05"))
+	case channel.WhatsAppLoginFailed:
+		return "login failed: " + state.Error
+	default:
+		return "not paired (run 'myclaw whatsapp login')"
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stellarlinkco/myclaw/internal/channel"
+)
+
+func TestRunWhatsAppStatus_NotPaired(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("USERPROFILE", tmpDir)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	if err := runWhatsAppStatus(cmd, nil); err != nil {
+		t.Fatalf("runWhatsAppStatus error: %v", err)
+	}
+	if !strings.Contains(out.String(), "not paired") {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestPrintWhatsAppState(t *testing.T) {
+	updated := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		state channel.WhatsAppLoginState
+		want  []string
+	}{
+		{channel.WhatsAppLoginState{Status: channel.WhatsAppLoginPaired, JID: "8613800138000@s.whatsapp.net"}, []string{"paired as 8613800138000@s.whatsapp.net"}},
+		{channel.WhatsAppLoginState{Status: channel.WhatsAppLoginPairingCode, PairingCode: "ABCD-EFGH", UpdatedAt: updated}, []string{"pairing code ABCD-EFGH", "15:04:05"}},
+		{channel.WhatsAppLoginState{Status: channel.WhatsAppLoginQR, QRCode: "2@abc", UpdatedAt: updated}, []string{"waiting for QR scan", "QR payload: 2@abc"}},
+		{channel.WhatsAppLoginState{Status: channel.WhatsAppLoginFailed, Error: "timeout"}, []string{"login failed: timeout"}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		printWhatsAppState(&out, tt.state)
+		for _, w := range tt.want {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("%s: output %q missing %q", tt.state.Status, out.String(), w)
+			}
+		}
+	}
+}
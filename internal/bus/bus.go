@@ -9,6 +9,9 @@ import (
 type MessageBus struct {
 	Inbound  chan InboundMessage
 	Outbound chan OutboundMessage
+	// Callbacks carries button presses. It is consumed separately from
+	// Inbound so a run blocked on a question can still receive its answer.
+	Callbacks chan CallbackMessage
 
 	mu   sync.RWMutex
 	subs map[string][]func(OutboundMessage)
@@ -19,9 +22,10 @@ func NewMessageBus(bufSize int) *MessageBus {
 		bufSize = 100
 	}
 	return &MessageBus{
-		Inbound:  make(chan InboundMessage, bufSize),
-		Outbound: make(chan OutboundMessage, bufSize),
-		subs:     make(map[string][]func(OutboundMessage)),
+		Inbound:   make(chan InboundMessage, bufSize),
+		Outbound:  make(chan OutboundMessage, bufSize),
+		Callbacks: make(chan CallbackMessage, bufSize),
+		subs:      make(map[string][]func(OutboundMessage)),
 	}
 }
 
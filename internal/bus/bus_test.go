@@ -18,6 +18,9 @@ func TestNewMessageBus(t *testing.T) {
 	if cap(b.Outbound) != 10 {
 		t.Errorf("outbound cap = %d, want 10", cap(b.Outbound))
 	}
+	if cap(b.Callbacks) != 10 {
+		t.Errorf("callbacks cap = %d, want 10", cap(b.Callbacks))
+	}
 }
 
 func TestNewMessageBus_DefaultSize(t *testing.T) {
@@ -34,6 +37,22 @@ func TestInboundMessage_SessionKey(t *testing.T) {
 	}
 }
 
+func TestInboundMessage_IsGroup(t *testing.T) {
+	if (&InboundMessage{}).IsGroup() {
+		t.Error("message without metadata is a group message")
+	}
+	if !(&InboundMessage{Metadata: map[string]any{"is_group": true}}).IsGroup() {
+		t.Error("is_group metadata not honoured")
+	}
+}
+
+func TestCallbackMessage_SessionKey(t *testing.T) {
+	msg := CallbackMessage{Channel: "telegram", ChatID: "12345", Data: "approve:abc"}
+	if msg.SessionKey() != "telegram:12345" {
+		t.Errorf("SessionKey = %q, want telegram:12345", msg.SessionKey())
+	}
+}
+
 func TestSubscribeAndDispatch(t *testing.T) {
 	b := NewMessageBus(10)
 
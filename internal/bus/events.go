@@ -10,6 +10,7 @@ type InboundMessage struct {
 	Channel       string
 	SenderID      string
 	ChatID        string
+	MessageID     string // 平台消息 ID，回复时作为 OutboundMessage.ReplyTo
 	Content       string
 	Timestamp     time.Time
 	Media         []string
@@ -21,6 +22,12 @@ func (m *InboundMessage) SessionKey() string {
 	return m.Channel + ":" + m.ChatID
 }
 
+// IsGroup 报告消息是否来自群聊（渠道在 Metadata["is_group"] 中标记），群聊的会话由所有成员共享
+func (m *InboundMessage) IsGroup() bool {
+	group, _ := m.Metadata["is_group"].(bool)
+	return group
+}
+
 type OutboundMessage struct {
 	Channel       string
 	ChatID        string
@@ -29,4 +36,28 @@ type OutboundMessage struct {
 	Media         []string
 	Metadata      map[string]any
 	ContentBlocks []model.ContentBlock // 多模态内容
+	Buttons       [][]Button           // inline 按钮（按行），仅交互式渠道渲染
+	Partial       bool                 // 流式回复的中间帧，Content 为目前为止的全文；仅流式渠道接收
+	StreamEnd     bool                 // 流式回复的结束帧，用于关闭流；流已失效且 Content 为空时不发送
+}
+
+// Button is an inline choice attached to an outbound message. Data is echoed
+// back in a CallbackMessage when the user presses it.
+type Button struct {
+	Text string
+	Data string
+}
+
+// CallbackMessage reports a button press on an interactive channel.
+type CallbackMessage struct {
+	Channel   string
+	SenderID  string
+	ChatID    string
+	MessageID string // 带按钮的平台消息 ID
+	Data      string
+	Metadata  map[string]any // 渠道自用的附加信息，AckCallback 时原样交回
+}
+
+func (m *CallbackMessage) SessionKey() string {
+	return m.Channel + ":" + m.ChatID
 }
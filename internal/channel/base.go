@@ -2,6 +2,7 @@ package channel
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/stellarlinkco/myclaw/internal/bus"
 )
@@ -13,6 +14,75 @@ type Channel interface {
 	Send(msg bus.OutboundMessage) error
 }
 
+// HTTPChannel is implemented by channels that can serve their endpoints on the
+// gateway HTTP server instead of opening a listener of their own.
+type HTTPChannel interface {
+	Channel
+	Attach(mux *http.ServeMux) error
+}
+
+// InteractiveChannel is implemented by channels that render
+// OutboundMessage.Buttons and report presses on MessageBus.Callbacks.
+// AckCallback is called once the gateway has handled a press; accepted
+// reports whether it answered the prompt or was ignored.
+type InteractiveChannel interface {
+	Channel
+	SupportsButtons() bool
+	AckCallback(msg bus.CallbackMessage, accepted bool)
+}
+
+// StreamingChannel is implemented by channels that render partial replies
+// (OutboundMessage.Partial) progressively.
+type StreamingChannel interface {
+	Channel
+	SupportsStreaming() bool
+}
+
+// RunEndChannel is implemented by channels that show activity while the
+// agent works on a message, such as a typing indicator, and must clear it
+// when the run ends, whether or not it replied.
+type RunEndChannel interface {
+	Channel
+	EndRun(chatID string)
+}
+
+// HistoryEntry is one turn of a persisted conversation.
+type HistoryEntry struct {
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// HistoryFunc returns the persisted turns of an agent session
+// (bus.InboundMessage.SessionKey), oldest first.
+type HistoryFunc func(sessionKey string) ([]HistoryEntry, error)
+
+// HistoryChannel is implemented by channels that replay past turns to
+// reconnecting clients.
+type HistoryChannel interface {
+	Channel
+	SetHistory(fn HistoryFunc)
+}
+
+// ChannelHealth is what a channel reports about its connection.
+type ChannelHealth struct {
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// HealthChannel is implemented by channels that can tell whether they are
+// connected; other channels are healthy while they run.
+type HealthChannel interface {
+	Channel
+	Health() ChannelHealth
+}
+
+// AdminChannel is implemented by channels that serve the gateway admin API
+// (under /api/admin/) behind their own login.
+type AdminChannel interface {
+	Channel
+	SetAdmin(h http.Handler)
+}
+
 type BaseChannel struct {
 	name      string
 	bus       *bus.MessageBus
@@ -326,6 +326,45 @@ func TestChannelManager_StopAll_Error(t *testing.T) {
 	}
 }
 
+type mockHealthChannel struct {
+	mockChannel
+	health ChannelHealth
+}
+
+func (m *mockHealthChannel) Health() ChannelHealth { return m.health }
+
+func TestChannelManager_Status(t *testing.T) {
+	m := &ChannelManager{
+		channels: map[string]Channel{
+			"plain":  &mockChannel{name: "plain"},
+			"broken": &mockChannel{name: "broken", startErr: fmt.Errorf("bad token")},
+			"linked": &mockHealthChannel{mockChannel: mockChannel{name: "linked"}, health: ChannelHealth{Detail: "disconnected"}},
+		},
+		bus: bus.NewMessageBus(10),
+	}
+	_ = m.StartAll(context.Background())
+
+	want := []ChannelStatus{
+		{Name: "broken", Detail: "bad token"},
+		{Name: "linked", Running: true, Detail: "disconnected"},
+		{Name: "plain", Running: true, Healthy: true},
+	}
+	got := m.Status()
+	if len(got) != len(want) {
+		t.Fatalf("Status = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Status[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	_ = m.StopAll()
+	if st := m.Status()[2]; st.Running || st.Healthy || st.Detail != "not running" {
+		t.Errorf("after StopAll = %+v", st)
+	}
+}
+
 func TestTelegramChannel_Send_InvalidChatID(t *testing.T) {
 	b := bus.NewMessageBus(10)
 	ch, _ := NewTelegramChannel(config.TelegramConfig{Token: "fake-token"}, b)
@@ -694,6 +733,270 @@ func TestTelegramChannel_HandleMessage_Document(t *testing.T) {
 	}
 }
 
+type stubTranscriber struct {
+	text     string
+	err      error
+	filename string
+	audio    []byte
+}
+
+func (s *stubTranscriber) Transcribe(ctx context.Context, filename string, audio []byte) (string, error) {
+	s.filename = filename
+	s.audio = audio
+	return s.text, s.err
+}
+
+func newMediaTestChannel(t *testing.T, payload []byte, fileIDs ...string) (*TelegramChannel, *mockTelegramBot, *bus.MessageBus) {
+	t.Helper()
+	b := bus.NewMessageBus(10)
+	ch, _ := NewTelegramChannel(config.TelegramConfig{Token: "fake-token"}, b)
+	mockBot := newMockBot()
+	for _, id := range fileIDs {
+		mockBot.files[id] = tgbotapi.File{FileID: id, FilePath: "files/" + id}
+	}
+	ch.SetBot(mockBot)
+	ch.httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader(payload)),
+			Header:     make(http.Header),
+		}, nil
+	})}
+	return ch, mockBot, b
+}
+
+func lastReplyText(t *testing.T, mockBot *mockTelegramBot) string {
+	t.Helper()
+	if len(mockBot.sentMsgs) == 0 {
+		t.Fatal("expected a reply to be sent")
+	}
+	reply, ok := mockBot.sentMsgs[len(mockBot.sentMsgs)-1].(tgbotapi.MessageConfig)
+	if !ok {
+		t.Fatalf("reply type = %T", mockBot.sentMsgs[len(mockBot.sentMsgs)-1])
+	}
+	return reply.Text
+}
+
+func TestTelegramChannel_HandleMessage_VoiceTranscribed(t *testing.T) {
+	ch, _, b := newMediaTestChannel(t, []byte("OggS"), "voice-1")
+	tr := &stubTranscriber{text: "remind me to buy milk"}
+	ch.SetTranscriber(tr)
+
+	ch.handleMessage(&tgbotapi.Message{
+		From:  &tgbotapi.User{ID: 123},
+		Chat:  &tgbotapi.Chat{ID: 456, Type: "private"},
+		Voice: &tgbotapi.Voice{FileID: "voice-1", Duration: 3},
+	})
+
+	select {
+	case inbound := <-b.Inbound:
+		want := "[Voice message transcript]\nremind me to buy milk"
+		if inbound.Content != want {
+			t.Errorf("content = %q, want %q", inbound.Content, want)
+		}
+	default:
+		t.Fatal("expected inbound message")
+	}
+	if tr.filename != "voice.ogg" || string(tr.audio) != "OggS" {
+		t.Errorf("transcriber got %q (%q)", tr.filename, tr.audio)
+	}
+}
+
+func TestTelegramChannel_HandleMessage_VoiceWithoutTranscriber(t *testing.T) {
+	ch, mockBot, b := newMediaTestChannel(t, []byte("OggS"), "voice-1")
+
+	ch.handleMessage(&tgbotapi.Message{
+		MessageID: 9,
+		From:      &tgbotapi.User{ID: 123},
+		Chat:      &tgbotapi.Chat{ID: 456, Type: "private"},
+		Voice:     &tgbotapi.Voice{FileID: "voice-1"},
+	})
+
+	select {
+	case inbound := <-b.Inbound:
+		t.Fatalf("unexpected inbound: %+v", inbound)
+	default:
+	}
+	if got := lastReplyText(t, mockBot); got != telegramTranscriptionUnavailableReply {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestTelegramChannel_HandleMessage_AudioTranscriptionError(t *testing.T) {
+	ch, mockBot, b := newMediaTestChannel(t, []byte("ID3"), "audio-1")
+	ch.SetTranscriber(&stubTranscriber{err: fmt.Errorf("boom")})
+
+	ch.handleMessage(&tgbotapi.Message{
+		From:  &tgbotapi.User{ID: 123},
+		Chat:  &tgbotapi.Chat{ID: 456, Type: "private"},
+		Audio: &tgbotapi.Audio{FileID: "audio-1", FileName: "song.mp3"},
+	})
+
+	select {
+	case inbound := <-b.Inbound:
+		t.Fatalf("unexpected inbound: %+v", inbound)
+	default:
+	}
+	if got := lastReplyText(t, mockBot); got != telegramTranscriptionFailedReply {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestTelegramChannel_HandleMessage_VideoThumbnail(t *testing.T) {
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xd9}
+	ch, _, b := newMediaTestChannel(t, jpeg, "thumb-1")
+
+	ch.handleMessage(&tgbotapi.Message{
+		From:    &tgbotapi.User{ID: 123},
+		Chat:    &tgbotapi.Chat{ID: 456},
+		Caption: "what is this?",
+		Video: &tgbotapi.Video{
+			FileID: "video-1", Duration: 12, Width: 640, Height: 360,
+			Thumbnail: &tgbotapi.PhotoSize{FileID: "thumb-1"},
+		},
+	})
+
+	select {
+	case inbound := <-b.Inbound:
+		if !strings.HasPrefix(inbound.Content, "[Video] duration=12s 640x360") || !strings.HasSuffix(inbound.Content, "what is this?") {
+			t.Errorf("content = %q", inbound.Content)
+		}
+		if len(inbound.ContentBlocks) != 1 || inbound.ContentBlocks[0].Type != model.ContentBlockImage {
+			t.Fatalf("content blocks = %+v", inbound.ContentBlocks)
+		}
+	default:
+		t.Fatal("expected inbound message")
+	}
+}
+
+func TestTelegramChannel_HandleMessage_AnimationSkipsDocument(t *testing.T) {
+	ch, _, b := newMediaTestChannel(t, []byte{0xff, 0xd8, 0xff, 0xd9}, "anim-thumb")
+
+	ch.handleMessage(&tgbotapi.Message{
+		From:      &tgbotapi.User{ID: 123},
+		Chat:      &tgbotapi.Chat{ID: 456},
+		Animation: &tgbotapi.Animation{FileID: "anim-1", Duration: 2, Thumbnail: &tgbotapi.PhotoSize{FileID: "anim-thumb"}},
+		Document:  &tgbotapi.Document{FileID: "anim-1", MimeType: "video/mp4"},
+	})
+
+	select {
+	case inbound := <-b.Inbound:
+		if len(inbound.ContentBlocks) != 1 || inbound.ContentBlocks[0].MediaType != "image/jpeg" {
+			t.Fatalf("content blocks = %+v", inbound.ContentBlocks)
+		}
+	default:
+		t.Fatal("expected inbound message")
+	}
+}
+
+func TestTelegramChannel_HandleMessage_Sticker(t *testing.T) {
+	webp := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 16)...)
+	ch, _, b := newMediaTestChannel(t, webp, "sticker-1")
+
+	ch.handleMessage(&tgbotapi.Message{
+		From:    &tgbotapi.User{ID: 123},
+		Chat:    &tgbotapi.Chat{ID: 456},
+		Sticker: &tgbotapi.Sticker{FileID: "sticker-1", Emoji: "😀"},
+	})
+
+	select {
+	case inbound := <-b.Inbound:
+		if inbound.Content != "[Sticker] 😀" {
+			t.Errorf("content = %q", inbound.Content)
+		}
+		if len(inbound.ContentBlocks) != 1 || inbound.ContentBlocks[0].MediaType != "image/webp" {
+			t.Fatalf("content blocks = %+v", inbound.ContentBlocks)
+		}
+	default:
+		t.Fatal("expected inbound message")
+	}
+}
+
+func TestTelegramChannel_HandleMessage_StructuredTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  tgbotapi.Message
+		want []string
+	}{
+		{
+			name: "location",
+			msg:  tgbotapi.Message{Location: &tgbotapi.Location{Latitude: 31.2304, Longitude: 121.4737}},
+			want: []string{"[Location]", "latitude: 31.230400", "longitude: 121.473700"},
+		},
+		{
+			name: "venue",
+			msg: tgbotapi.Message{
+				Location: &tgbotapi.Location{Latitude: 1, Longitude: 2},
+				Venue:    &tgbotapi.Venue{Title: "Cafe", Address: "Main St 1", Location: tgbotapi.Location{Latitude: 1, Longitude: 2}},
+			},
+			want: []string{"[Venue] Cafe", "address: Main St 1"},
+		},
+		{
+			name: "contact",
+			msg:  tgbotapi.Message{Contact: &tgbotapi.Contact{FirstName: "Ada", LastName: "L", PhoneNumber: "+100", UserID: 77}},
+			want: []string{"[Contact] Ada L", "phone: +100", "telegram user id: 77"},
+		},
+		{
+			name: "poll",
+			msg: tgbotapi.Message{Poll: &tgbotapi.Poll{Question: "Lunch?", Options: []tgbotapi.PollOption{
+				{Text: "Pizza", VoterCount: 2}, {Text: "Sushi"},
+			}}},
+			want: []string{"[Poll] Lunch?", "- Pizza (2 votes)", "- Sushi (0 votes)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bus.NewMessageBus(10)
+			ch, _ := NewTelegramChannel(config.TelegramConfig{Token: "fake-token"}, b)
+			msg := tt.msg
+			msg.From = &tgbotapi.User{ID: 123}
+			msg.Chat = &tgbotapi.Chat{ID: 456}
+			ch.handleMessage(&msg)
+
+			select {
+			case inbound := <-b.Inbound:
+				for _, want := range tt.want {
+					if !strings.Contains(inbound.Content, want) {
+						t.Errorf("content %q missing %q", inbound.Content, want)
+					}
+				}
+			default:
+				t.Fatal("expected inbound message")
+			}
+		})
+	}
+}
+
+func TestTelegramChannel_HandleMessage_UnsupportedReply(t *testing.T) {
+	ch, mockBot, b := newMediaTestChannel(t, nil)
+
+	ch.handleMessage(&tgbotapi.Message{
+		From: &tgbotapi.User{ID: 123},
+		Chat: &tgbotapi.Chat{ID: 456, Type: "private"},
+		Dice: &tgbotapi.Dice{Emoji: "🎲", Value: 3},
+	})
+	select {
+	case inbound := <-b.Inbound:
+		t.Fatalf("unexpected inbound: %+v", inbound)
+	default:
+	}
+	if got := lastReplyText(t, mockBot); got != telegramUnsupportedReply {
+		t.Errorf("reply = %q", got)
+	}
+
+	// Group service messages stay silent.
+	mockBot.sentMsgs = nil
+	ch.handleMessage(&tgbotapi.Message{
+		From:           &tgbotapi.User{ID: 123},
+		Chat:           &tgbotapi.Chat{ID: -100, Type: "group"},
+		NewChatMembers: []tgbotapi.User{{ID: 5}},
+	})
+	if len(mockBot.sentMsgs) != 0 {
+		t.Errorf("group service message should not get a reply, got %d", len(mockBot.sentMsgs))
+	}
+}
+
 type roundTripFunc func(req *http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -702,13 +1005,18 @@ func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 
 // mockTelegramBot implements TelegramBot interface for testing
 type mockTelegramBot struct {
-	updatesChan chan tgbotapi.Update
-	stopped     bool
-	sentMsgs    []tgbotapi.Chattable
-	sendErr     error
-	getFileErr  error
-	files       map[string]tgbotapi.File
-	self        tgbotapi.User
+	updatesChan    chan tgbotapi.Update
+	stopped        bool
+	sentMsgs       []tgbotapi.Chattable
+	sendErr        error
+	getFileErr     error
+	files          map[string]tgbotapi.File
+	self           tgbotapi.User
+	webhookURL     string
+	webhookSecret  string
+	webhookDeleted bool
+	setWebhookErr  error
+	requests       []tgbotapi.Chattable
 }
 
 func newMockBot() *mockTelegramBot {
@@ -735,6 +1043,11 @@ func (m *mockTelegramBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return tgbotapi.Message{MessageID: 1}, nil
 }
 
+func (m *mockTelegramBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	m.requests = append(m.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
 func (m *mockTelegramBot) GetSelf() tgbotapi.User {
 	return m.self
 }
@@ -750,6 +1063,20 @@ func (m *mockTelegramBot) GetFile(config tgbotapi.FileConfig) (tgbotapi.File, er
 	return file, nil
 }
 
+func (m *mockTelegramBot) SetWebhook(webhookURL, secretToken string) error {
+	if m.setWebhookErr != nil {
+		return m.setWebhookErr
+	}
+	m.webhookURL = webhookURL
+	m.webhookSecret = secretToken
+	return nil
+}
+
+func (m *mockTelegramBot) DeleteWebhook() error {
+	m.webhookDeleted = true
+	return nil
+}
+
 func TestTelegramChannel_InitBot_Success(t *testing.T) {
 	b := bus.NewMessageBus(10)
 	mockBot := newMockBot()
@@ -844,6 +1171,287 @@ func TestTelegramChannel_Start_Success(t *testing.T) {
 	}
 }
 
+func TestTelegramChannel_Start_PollingDeletesWebhook(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	mockBot := newMockBot()
+
+	factory := func(token, apiEndpoint string, client *http.Client) (TelegramBot, error) {
+		return mockBot, nil
+	}
+
+	ch, _ := NewTelegramChannelWithFactory(config.TelegramConfig{Token: "fake-token"}, b, factory)
+	if ch.WebhookEnabled() {
+		t.Fatal("webhook mode should be disabled without webhookUrl")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer ch.Stop()
+
+	if !mockBot.webhookDeleted {
+		t.Error("polling start should delete any stale webhook")
+	}
+	if mockBot.webhookURL != "" {
+		t.Errorf("webhook should not be registered in polling mode, got %q", mockBot.webhookURL)
+	}
+}
+
+func TestTelegramChannel_Webhook(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	mockBot := newMockBot()
+
+	factory := func(token, apiEndpoint string, client *http.Client) (TelegramBot, error) {
+		return mockBot, nil
+	}
+
+	ch, _ := NewTelegramChannelWithFactory(config.TelegramConfig{
+		Token:         "fake-token",
+		WebhookURL:    "https://bot.example.com/",
+		WebhookSecret: "hook-secret",
+	}, b, factory)
+	if !ch.WebhookEnabled() {
+		t.Fatal("webhook mode should be enabled")
+	}
+
+	mux := http.NewServeMux()
+	if err := ch.Attach(mux); err != nil {
+		t.Fatalf("Attach error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	defer ch.Stop()
+
+	if mockBot.webhookURL != "https://bot.example.com/telegram/webhook" {
+		t.Errorf("webhook url = %q", mockBot.webhookURL)
+	}
+	if mockBot.webhookSecret != "hook-secret" {
+		t.Errorf("webhook secret = %q", mockBot.webhookSecret)
+	}
+
+	body := `{"update_id":1,"message":{"message_id":7,"from":{"id":123},"chat":{"id":456},"date":1700000000,"text":"via webhook"}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "wrong")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Telegram retries an update it thinks went unanswered; the retry is dropped.
+	for i := 0; i < 2; i++ {
+		req = httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body))
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "hook-secret")
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+
+	select {
+	case inbound := <-b.Inbound:
+		if inbound.Content != "via webhook" || inbound.ChatID != "456" || inbound.SenderID != "123" {
+			t.Errorf("inbound = %+v", inbound)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected inbound message")
+	}
+	select {
+	case inbound := <-b.Inbound:
+		t.Fatalf("retried update handled twice: %+v", inbound)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ch.Stop()
+	if mockBot.stopped {
+		t.Error("webhook mode should not stop long polling")
+	}
+}
+
+func TestTelegramChannel_Webhook_GeneratesSecret(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	mockBot := newMockBot()
+
+	factory := func(token, apiEndpoint string, client *http.Client) (TelegramBot, error) {
+		return mockBot, nil
+	}
+
+	ch, _ := NewTelegramChannelWithFactory(config.TelegramConfig{
+		Token:      "fake-token",
+		WebhookURL: "https://bot.example.com",
+	}, b, factory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if len(mockBot.webhookSecret) != 64 {
+		t.Errorf("generated secret = %q, want 64 hex chars", mockBot.webhookSecret)
+	}
+
+	mockBot.setWebhookErr = fmt.Errorf("bad url")
+	ch2, _ := NewTelegramChannelWithFactory(config.TelegramConfig{
+		Token:      "fake-token",
+		WebhookURL: "https://bot.example.com",
+	}, b, factory)
+	if err := ch2.Start(ctx); err == nil {
+		t.Error("expected setWebhook error")
+	}
+}
+
+func TestChannelManager_HTTPChannels(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	m, err := NewChannelManagerWithGateway(config.ChannelsConfig{
+		Telegram: config.TelegramConfig{Enabled: true, Token: "fake-token", WebhookURL: "https://bot.example.com"},
+		WebUI:    config.WebUIConfig{Enabled: true},
+	}, config.GatewayConfig{}, b)
+	if err != nil {
+		t.Fatalf("NewChannelManagerWithGateway: %v", err)
+	}
+	if got := len(m.HTTPChannels()); got != 2 {
+		t.Errorf("HTTPChannels = %d, want 2", got)
+	}
+
+	m, err = NewChannelManager(config.ChannelsConfig{
+		Telegram: config.TelegramConfig{Enabled: true, Token: "fake-token"},
+	}, b)
+	if err != nil {
+		t.Fatalf("NewChannelManager: %v", err)
+	}
+	if got := len(m.HTTPChannels()); got != 0 {
+		t.Errorf("polling telegram HTTPChannels = %d, want 0", got)
+	}
+}
+
+func TestChannelManager_SupportsButtons(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	m, err := NewChannelManagerWithGateway(config.ChannelsConfig{
+		Telegram: config.TelegramConfig{Enabled: true, Token: "fake-token"},
+		WebUI:    config.WebUIConfig{Enabled: true},
+	}, config.GatewayConfig{}, b)
+	if err != nil {
+		t.Fatalf("NewChannelManagerWithGateway: %v", err)
+	}
+	if !m.SupportsButtons("telegram") {
+		t.Error("telegram should support buttons")
+	}
+	if m.SupportsButtons("webui") || m.SupportsButtons("missing") {
+		t.Error("webui and unknown channels should not support buttons")
+	}
+}
+
+func TestTelegramChannel_SendButtons(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	ch, _ := NewTelegramChannel(config.TelegramConfig{Token: "fake-token"}, b)
+	mockBot := newMockBot()
+	ch.SetBot(mockBot)
+
+	err := ch.Send(bus.OutboundMessage{
+		ChatID:  "456",
+		Content: "Run rm -rf build?",
+		Buttons: [][]bus.Button{{{Text: "Approve", Data: "approve:abc"}, {Text: "Deny", Data: "deny:abc"}}},
+	})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if len(mockBot.sentMsgs) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(mockBot.sentMsgs))
+	}
+	msg := mockBot.sentMsgs[0].(tgbotapi.MessageConfig)
+	markup, ok := msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok {
+		t.Fatalf("reply markup = %T, want InlineKeyboardMarkup", msg.ReplyMarkup)
+	}
+	if len(markup.InlineKeyboard) != 1 || len(markup.InlineKeyboard[0]) != 2 {
+		t.Fatalf("keyboard = %+v", markup.InlineKeyboard)
+	}
+	if btn := markup.InlineKeyboard[0][1]; btn.Text != "Deny" || btn.CallbackData == nil || *btn.CallbackData != "deny:abc" {
+		t.Errorf("second button = %+v", btn)
+	}
+}
+
+func TestTelegramChannel_HandleCallback(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	ch, _ := NewTelegramChannel(config.TelegramConfig{Token: "fake-token", AllowFrom: []string{"123"}}, b)
+	mockBot := newMockBot()
+	ch.SetBot(mockBot)
+
+	data := "approve:abc"
+	message := &tgbotapi.Message{
+		MessageID: 9,
+		Chat:      &tgbotapi.Chat{ID: 456},
+		Text:      "Run rm -rf build?",
+		ReplyMarkup: &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+			{Text: "Approve", CallbackData: &data},
+		}}},
+	}
+
+	ch.handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID: "cb-1", From: &tgbotapi.User{ID: 999}, Message: message, Data: data,
+	}})
+	select {
+	case cb := <-b.Callbacks:
+		t.Fatalf("unexpected callback from disallowed sender: %+v", cb)
+	default:
+	}
+
+	ch.handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID: "cb-2", From: &tgbotapi.User{ID: 123}, Message: message, Data: data,
+	}})
+	var cb bus.CallbackMessage
+	select {
+	case cb = <-b.Callbacks:
+		if cb.Channel != "telegram" || cb.ChatID != "456" || cb.SenderID != "123" || cb.MessageID != "9" || cb.Data != data {
+			t.Errorf("callback = %+v", cb)
+		}
+	default:
+		t.Fatal("expected callback on bus")
+	}
+	// The prompt is left alone until the gateway has handled the press.
+	if len(mockBot.requests) != 2 {
+		t.Fatalf("requests = %d, want 2 (reject answer, answer)", len(mockBot.requests))
+	}
+
+	// A second press while the first is handled is not forwarded.
+	ch.handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID: "cb-3", From: &tgbotapi.User{ID: 123}, Message: message, Data: data,
+	}})
+	select {
+	case dup := <-b.Callbacks:
+		t.Fatalf("duplicate press forwarded: %+v", dup)
+	default:
+	}
+
+	// An ignored press frees the prompt for the next one.
+	ch.AckCallback(cb, false)
+	ch.handleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID: "cb-4", From: &tgbotapi.User{ID: 123}, Message: message, Data: data,
+	}})
+	select {
+	case cb = <-b.Callbacks:
+	default:
+		t.Fatal("press after an ignored one was not forwarded")
+	}
+
+	ch.AckCallback(cb, true)
+	edit, ok := mockBot.requests[len(mockBot.requests)-1].(tgbotapi.EditMessageTextConfig)
+	if !ok {
+		t.Fatalf("last request = %T, want EditMessageTextConfig", mockBot.requests[len(mockBot.requests)-1])
+	}
+	if edit.MessageID != 9 || edit.Text != "Run rm -rf build?\n\n→ Approve" || edit.ReplyMarkup != nil {
+		t.Errorf("edit = %+v", edit)
+	}
+}
+
 func TestTelegramChannel_Start_InitError(t *testing.T) {
 	b := bus.NewMessageBus(10)
 
@@ -1043,6 +1651,10 @@ func (c *chunkRecordingBot) Send(msg tgbotapi.Chattable) (tgbotapi.Message, erro
 	return tgbotapi.Message{MessageID: 1}, nil
 }
 
+func (c *chunkRecordingBot) Request(msg tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
 func (c *chunkRecordingBot) GetSelf() tgbotapi.User {
 	return c.self
 }
@@ -1051,6 +1663,10 @@ func (c *chunkRecordingBot) GetFile(config tgbotapi.FileConfig) (tgbotapi.File,
 	return tgbotapi.File{}, fmt.Errorf("not implemented")
 }
 
+func (c *chunkRecordingBot) SetWebhook(webhookURL, secretToken string) error { return nil }
+
+func (c *chunkRecordingBot) DeleteWebhook() error { return nil }
+
 type sendCountingBot struct {
 	mockBot   *mockTelegramBot
 	failFirst bool
@@ -1073,6 +1689,10 @@ func (s *sendCountingBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return tgbotapi.Message{MessageID: 1}, nil
 }
 
+func (s *sendCountingBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return s.mockBot.Request(c)
+}
+
 func (s *sendCountingBot) GetSelf() tgbotapi.User {
 	return s.mockBot.self
 }
@@ -1081,6 +1701,14 @@ func (s *sendCountingBot) GetFile(config tgbotapi.FileConfig) (tgbotapi.File, er
 	return s.mockBot.GetFile(config)
 }
 
+func (s *sendCountingBot) SetWebhook(webhookURL, secretToken string) error {
+	return s.mockBot.SetWebhook(webhookURL, secretToken)
+}
+
+func (s *sendCountingBot) DeleteWebhook() error {
+	return s.mockBot.DeleteWebhook()
+}
+
 func TestTelegramChannel_Send_BothFail(t *testing.T) {
 	b := bus.NewMessageBus(10)
 	mockBot := newMockBot()
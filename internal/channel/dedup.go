@@ -0,0 +1,73 @@
+package channel
+
+import (
+	"sync"
+	"time"
+)
+
+// msgDedupGCInterval is also how often the WeCom reply and stream caches
+// drop expired entries.
+const (
+	msgDedupDefaultTTL = 5 * time.Minute
+	msgDedupGCInterval = 1 * time.Minute
+)
+
+// msgDedupCache remembers recently seen message or event IDs so retried
+// deliveries from a platform are processed only once.
+type msgDedupCache struct {
+	mu     sync.Mutex
+	items  map[string]time.Time
+	ttl    time.Duration
+	lastGC time.Time
+}
+
+func newMsgDedupCache(ttl time.Duration) *msgDedupCache {
+	if ttl <= 0 {
+		ttl = msgDedupDefaultTTL
+	}
+	return &msgDedupCache{
+		items: make(map[string]time.Time),
+		ttl:   ttl,
+	}
+}
+
+func (c *msgDedupCache) Seen(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if exp, ok := c.items[key]; ok {
+		if now.Before(exp) {
+			return true
+		}
+		delete(c.items, key)
+	}
+
+	c.items[key] = now.Add(c.ttl)
+	c.gcLocked(now)
+
+	return false
+}
+
+// Forget drops key, so a retry of a delivery that could not be handled is
+// processed.
+func (c *msgDedupCache) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+func (c *msgDedupCache) gcLocked(now time.Time) {
+	if c.lastGC.IsZero() || now.Sub(c.lastGC) >= msgDedupGCInterval {
+		for key, exp := range c.items {
+			if now.After(exp) {
+				delete(c.items, key)
+			}
+		}
+		c.lastGC = now
+	}
+}
@@ -2,13 +2,19 @@ package channel
 
 import (
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,13 +29,33 @@ const feishuChannelName = "feishu"
 const (
 	feishuInboundImageMaxBytes = 10 << 20 // 10MB
 	feishuInboundImageTimeout  = 10 * time.Second
+	feishuWebhookMaxBodyBytes  = 1 << 20 // 1MB
+
+	feishuSignatureHeader = "X-Lark-Signature"
+	feishuTimestampHeader = "X-Lark-Request-Timestamp"
+	feishuNonceHeader     = "X-Lark-Request-Nonce"
+	// feishuMaxRequestAge bounds replays of signed requests.
+	feishuMaxRequestAge = 5 * time.Minute
+	// feishuEventQueueSize bounds the events waiting to be handled.
+	feishuEventQueueSize = 64
 )
 
 type FeishuImageDownloader func(ctx context.Context, tenantAccessToken, imageKey string) (string, string, error)
 
+// FeishuResourceDownloader fetches a file, audio or image attached to a
+// message. resourceType is "file" or "image". It returns the raw bytes and the
+// reported media type.
+type FeishuResourceDownloader func(ctx context.Context, tenantAccessToken, messageID, fileKey, resourceType string) ([]byte, string, error)
+
+// FeishuMessageFetcher loads a message together with its descendants, as used
+// for merged-forward messages.
+type FeishuMessageFetcher func(ctx context.Context, tenantAccessToken, messageID string) ([]FeishuMessageItem, error)
+
 // FeishuClient interface for sending messages (allows mocking)
 type FeishuClient interface {
 	SendMessage(ctx context.Context, chatID, content string) error
+	// SendCard sends an interactive card (msg_type "interactive").
+	SendCard(ctx context.Context, chatID string, card map[string]any) error
 	GetTenantAccessToken(ctx context.Context) (string, error)
 }
 
@@ -93,21 +119,32 @@ func (c *defaultFeishuClient) GetTenantAccessToken(ctx context.Context) (string,
 }
 
 func (c *defaultFeishuClient) SendMessage(ctx context.Context, chatID, content string) error {
-	token, err := c.GetTenantAccessToken(ctx)
-	if err != nil {
-		return err
-	}
-
 	// Use json.Marshal for proper escaping of content
 	textJSON, err := json.Marshal(map[string]string{"text": content})
 	if err != nil {
 		return fmt.Errorf("marshal text content: %w", err)
 	}
+	return c.send(ctx, chatID, "text", string(textJSON))
+}
+
+func (c *defaultFeishuClient) SendCard(ctx context.Context, chatID string, card map[string]any) error {
+	cardJSON, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("marshal card content: %w", err)
+	}
+	return c.send(ctx, chatID, "interactive", string(cardJSON))
+}
+
+func (c *defaultFeishuClient) send(ctx context.Context, chatID, msgType, content string) error {
+	token, err := c.GetTenantAccessToken(ctx)
+	if err != nil {
+		return err
+	}
 
 	payload := map[string]interface{}{
 		"receive_id": chatID,
-		"msg_type":   "text",
-		"content":    string(textJSON),
+		"msg_type":   msgType,
+		"content":    content,
 	}
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -151,12 +188,22 @@ var defaultFeishuClientFactory FeishuClientFactory = func(appID, appSecret strin
 
 type FeishuChannel struct {
 	BaseChannel
-	cfg             config.FeishuConfig
-	client          FeishuClient
-	server          *http.Server
-	cancel          context.CancelFunc
-	clientFactory   FeishuClientFactory
-	imageDownloader FeishuImageDownloader
+	cfg                config.FeishuConfig
+	client             FeishuClient
+	server             *http.Server
+	cancel             context.CancelFunc
+	clientFactory      FeishuClientFactory
+	imageDownloader    FeishuImageDownloader
+	resourceDownloader FeishuResourceDownloader
+	messageFetcher     FeishuMessageFetcher
+	transcriber        Transcriber
+	wsEndpoint         feishuWSEndpointFunc
+	wsBackoff          time.Duration
+	eventCache         *msgDedupCache
+	// Events are handled one at a time, in arrival order, by the worker
+	// started in Start, so acknowledging them never waits on a download.
+	// A full queue turns webhook deliveries away so Feishu retries them.
+	events chan *feishuEvent
 }
 
 func NewFeishuChannel(cfg config.FeishuConfig, b *bus.MessageBus) (*FeishuChannel, error) {
@@ -167,12 +214,23 @@ func NewFeishuChannelWithFactory(cfg config.FeishuConfig, b *bus.MessageBus, fac
 	if cfg.AppID == "" || cfg.AppSecret == "" {
 		return nil, fmt.Errorf("feishu app_id and app_secret are required")
 	}
+	switch cfg.Mode {
+	case "", config.FeishuModeWebhook, config.FeishuModeWebSocket:
+	default:
+		return nil, fmt.Errorf("feishu mode must be %q or %q, got %q", config.FeishuModeWebhook, config.FeishuModeWebSocket, cfg.Mode)
+	}
 
 	ch := &FeishuChannel{
-		BaseChannel:     NewBaseChannel(feishuChannelName, b, cfg.AllowFrom),
-		cfg:             cfg,
-		clientFactory:   factory,
-		imageDownloader: downloadFeishuImageAsBase64,
+		BaseChannel:        NewBaseChannel(feishuChannelName, b, cfg.AllowFrom),
+		cfg:                cfg,
+		clientFactory:      factory,
+		imageDownloader:    downloadFeishuImageAsBase64,
+		resourceDownloader: downloadFeishuMessageResource,
+		messageFetcher:     fetchFeishuMessage,
+		wsEndpoint:         fetchFeishuWSEndpoint,
+		wsBackoff:          feishuWSInitialBackoff,
+		eventCache:         newMsgDedupCache(msgDedupDefaultTTL),
+		events:             make(chan *feishuEvent, feishuEventQueueSize),
 	}
 	return ch, nil
 }
@@ -181,6 +239,12 @@ func (f *FeishuChannel) Start(ctx context.Context) error {
 	f.client = f.clientFactory(f.cfg.AppID, f.cfg.AppSecret)
 
 	ctx, f.cancel = context.WithCancel(ctx)
+	go f.runEventWorker(ctx)
+
+	if f.cfg.Mode == config.FeishuModeWebSocket {
+		go f.runWebSocket(ctx)
+		return nil
+	}
 
 	port := f.cfg.Port
 	if port == 0 {
@@ -225,7 +289,23 @@ func (f *FeishuChannel) Send(msg bus.OutboundMessage) error {
 	if f.client == nil {
 		return fmt.Errorf("feishu client not initialized")
 	}
-	return f.client.SendMessage(context.Background(), msg.ChatID, msg.Content)
+	ctx := context.Background()
+	if looksLikeMarkdown(msg.Content) {
+		card := buildFeishuCard(msg.Content)
+		if data, err := json.Marshal(card); err == nil && len(data) <= feishuCardMaxBytes {
+			err := f.client.SendCard(ctx, msg.ChatID, card)
+			if err == nil {
+				return nil
+			}
+			log.Printf("[feishu] send card failed, falling back to text: %v", err)
+		}
+	}
+	return f.client.SendMessage(ctx, msg.ChatID, msg.Content)
+}
+
+// SetTranscriber sets the speech-to-text backend for audio messages.
+func (f *FeishuChannel) SetTranscriber(tr Transcriber) {
+	f.transcriber = tr
 }
 
 func (f *FeishuChannel) handleWebhook(w http.ResponseWriter, r *http.Request) {
@@ -234,52 +314,114 @@ func (f *FeishuChannel) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, feishuWebhookMaxBodyBytes))
 	if err != nil {
 		http.Error(w, "read body failed", http.StatusBadRequest)
 		return
 	}
 
-	var event struct {
-		Challenge string `json:"challenge"`
-		Type      string `json:"type"`
-		Header    struct {
-			EventType string `json:"event_type"`
-			Token     string `json:"token"`
-		} `json:"header"`
-		Event struct {
-			Sender struct {
-				SenderID struct {
-					OpenID string `json:"open_id"`
-				} `json:"sender_id"`
-			} `json:"sender"`
-			Message struct {
-				ChatID      string `json:"chat_id"`
-				MessageType string `json:"message_type"`
-				Content     string `json:"content"`
-			} `json:"message"`
-		} `json:"event"`
-	}
-
-	if err := json.Unmarshal(body, &event); err != nil {
+	var envelope struct {
+		Encrypt string `json:"encrypt"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	payload := body
+	if envelope.Encrypt != "" {
+		if f.cfg.EncryptKey == "" {
+			http.Error(w, "encrypted event but encryptKey is not configured", http.StatusBadRequest)
+			return
+		}
+		payload, err = decryptFeishuEvent(f.cfg.EncryptKey, envelope.Encrypt)
+		if err != nil {
+			log.Printf("[feishu] decrypt event error: %v", err)
+			http.Error(w, "decrypt failed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var event feishuEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
 
-	// URL verification challenge
+	// URL verification challenge (unsigned; carries the token at top level)
 	if event.Challenge != "" {
+		if f.cfg.VerificationToken != "" && event.Token != f.cfg.VerificationToken {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"challenge": event.Challenge})
 		return
 	}
 
+	if f.cfg.EncryptKey != "" {
+		if err := verifyFeishuSignature(f.cfg.EncryptKey, r.Header, body, time.Now()); err != nil {
+			log.Printf("[feishu] rejected request: %v", err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// Verify token
 	if f.cfg.VerificationToken != "" && event.Header.Token != f.cfg.VerificationToken {
 		http.Error(w, "invalid token", http.StatusUnauthorized)
 		return
 	}
 
+	// Handle the event off the request: downloads and transcription can
+	// outlast the few seconds Feishu waits before redelivering.
+	select {
+	case f.events <- &event:
+	default:
+		http.Error(w, "too many pending events", http.StatusServiceUnavailable)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
+}
+
+// feishuEvent is a v2 event as delivered by webhook or long connection.
+type feishuEvent struct {
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+	Type      string `json:"type"`
+	Header    struct {
+		EventID   string `json:"event_id"`
+		EventType string `json:"event_type"`
+		Token     string `json:"token"`
+	} `json:"header"`
+	Event struct {
+		Sender struct {
+			SenderID struct {
+				OpenID string `json:"open_id"`
+			} `json:"sender_id"`
+		} `json:"sender"`
+		Message feishuInboundMessage `json:"message"`
+	} `json:"event"`
+}
+
+// runEventWorker handles queued events in order until ctx is done.
+func (f *FeishuChannel) runEventWorker(ctx context.Context) {
+	for {
+		select {
+		case event := <-f.events:
+			f.handleEvent(event)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleEvent publishes an authenticated event to the bus.
+func (f *FeishuChannel) handleEvent(event *feishuEvent) {
+	// Feishu redelivers events it considers unacknowledged.
+	if f.eventCache.Seen(event.Header.EventID) {
+		log.Printf("[feishu] duplicate event dropped: %s", event.Header.EventID)
+		return
+	}
 
 	// Only handle message events
 	if event.Header.EventType != "im.message.receive_v1" {
@@ -292,12 +434,7 @@ func (f *FeishuChannel) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	messageType := strings.ToLower(strings.TrimSpace(event.Event.Message.MessageType))
-	content, contentBlocks, messageMetadata, err := f.parseFeishuInboundMessage(
-		context.Background(),
-		messageType,
-		event.Event.Message.Content,
-	)
+	content, contentBlocks, messageMetadata, err := f.parseFeishuInboundMessage(context.Background(), event.Event.Message)
 	if err != nil {
 		log.Printf("[feishu] parse message error: %v", err)
 		return
@@ -306,7 +443,13 @@ func (f *FeishuChannel) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	metadata := map[string]any{"message_type": event.Event.Message.MessageType}
+	metadata := map[string]any{
+		"message_type": event.Event.Message.MessageType,
+		"is_group":     event.Event.Message.ChatType == "group",
+	}
+	if event.Event.Message.MessageID != "" {
+		metadata["message_id"] = event.Event.Message.MessageID
+	}
 	for k, v := range messageMetadata {
 		metadata[k] = v
 	}
@@ -322,7 +465,84 @@ func (f *FeishuChannel) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (f *FeishuChannel) parseFeishuInboundMessage(ctx context.Context, messageType, rawContent string) (string, []model.ContentBlock, map[string]any, error) {
+// decryptFeishuEvent decrypts an {"encrypt": ...} event body: AES-256-CBC
+// keyed with SHA-256(encryptKey), with the IV in the first block.
+func decryptFeishuEvent(encryptKey, encrypted string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode encrypted data: %w", err)
+	}
+	if len(raw) < 2*aes.BlockSize || len(raw)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted block size")
+	}
+
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("new aes cipher: %w", err)
+	}
+
+	iv, cipherData := raw[:aes.BlockSize], raw[aes.BlockSize:]
+	plain := make([]byte, len(cipherData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, cipherData)
+
+	plain, err = pkcs7Unpad(plain, aes.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs7 unpad: %w", err)
+	}
+	return plain, nil
+}
+
+// verifyFeishuSignature checks X-Lark-Signature, which is
+// hex(SHA-256(timestamp + nonce + encryptKey + body)), and rejects requests
+// whose timestamp is more than feishuMaxRequestAge away from now.
+func verifyFeishuSignature(encryptKey string, h http.Header, body []byte, now time.Time) error {
+	timestamp := h.Get(feishuTimestampHeader)
+	nonce := h.Get(feishuNonceHeader)
+	sig := h.Get(feishuSignatureHeader)
+	if timestamp == "" || nonce == "" || sig == "" {
+		return fmt.Errorf("missing signature headers")
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > feishuMaxRequestAge || age < -feishuMaxRequestAge {
+		return fmt.Errorf("stale timestamp %s", timestamp)
+	}
+
+	mac := sha256.New()
+	mac.Write([]byte(timestamp + nonce + encryptKey))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(expected)) != 1 {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// feishuInboundMessage is the message object of an im.message.receive_v1 event.
+type feishuInboundMessage struct {
+	MessageID   string          `json:"message_id"`
+	ChatID      string          `json:"chat_id"`
+	ChatType    string          `json:"chat_type"`
+	MessageType string          `json:"message_type"`
+	Content     string          `json:"content"`
+	Mentions    []feishuMention `json:"mentions"`
+}
+
+type feishuMention struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	ID   struct {
+		OpenID string `json:"open_id"`
+	} `json:"id"`
+}
+
+func (f *FeishuChannel) parseFeishuInboundMessage(ctx context.Context, msg feishuInboundMessage) (string, []model.ContentBlock, map[string]any, error) {
+	messageType := strings.ToLower(strings.TrimSpace(msg.MessageType))
+	rawContent := msg.Content
 	if messageType == "" {
 		return "", nil, nil, nil
 	}
@@ -335,7 +555,7 @@ func (f *FeishuChannel) parseFeishuInboundMessage(ctx context.Context, messageTy
 		if err := json.Unmarshal([]byte(rawContent), &textContent); err != nil {
 			return "", nil, nil, fmt.Errorf("parse text content: %w", err)
 		}
-		content := strings.TrimSpace(textContent.Text)
+		content := strings.TrimSpace(replaceFeishuMentions(textContent.Text, msg.Mentions))
 		if content == "" {
 			return "", nil, nil, nil
 		}
@@ -363,6 +583,21 @@ func (f *FeishuChannel) parseFeishuInboundMessage(ctx context.Context, messageTy
 		}
 		return "[image]", []model.ContentBlock{*block}, map[string]any{"image_key": imageKey}, nil
 
+	case "post":
+		return f.parseFeishuPost(ctx, msg)
+	case "file":
+		return f.parseFeishuFile(ctx, msg)
+	case "audio":
+		return f.parseFeishuAudio(ctx, msg)
+	case "media":
+		return f.parseFeishuMedia(ctx, msg)
+	case "sticker":
+		return "[sticker]", nil, nil, nil
+	case "merge_forward":
+		return f.parseFeishuMergeForward(ctx, msg)
+	case "location":
+		return parseFeishuLocation(rawContent)
+
 	default:
 		return "", nil, nil, nil
 	}
@@ -0,0 +1,159 @@
+package channel
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// feishuCardMaxBytes keeps cards under the 30KB request limit; larger
+	// replies are sent as plain text.
+	feishuCardMaxBytes = 28 << 10
+	// feishuCardCollapseCodeLines is the size above which a fenced code block
+	// is rendered in a collapsed panel.
+	feishuCardCollapseCodeLines = 20
+	feishuCardDefaultPanelTitle = "Details"
+)
+
+var (
+	feishuMarkdownLinePattern = regexp.MustCompile(`(?m)^\s*(#{1,6}\s|[-*+]\s|\d+\.\s|>\s|\|.*\|\s*$)`)
+	feishuSummaryPattern      = regexp.MustCompile(`(?i)<summary>(.*?)</summary>`)
+)
+
+// looksLikeMarkdown reports whether content uses markdown that renders
+// better in a card than as a text message.
+func looksLikeMarkdown(content string) bool {
+	if strings.Contains(content, "```") || strings.Contains(content, "**") ||
+		strings.Contains(content, "](") || strings.Contains(strings.ToLower(content), "<details") {
+		return true
+	}
+	return feishuMarkdownLinePattern.MatchString(content)
+}
+
+// buildFeishuCard renders markdown as a card (JSON 2.0 schema).
+// <details><summary>…</summary>…</details> sections and fenced code blocks
+// longer than feishuCardCollapseCodeLines become collapsed panels.
+func buildFeishuCard(content string) map[string]any {
+	b := &feishuCardBuilder{}
+	var fence []string
+	inFence := false
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inFence {
+			fence = append(fence, line)
+			if strings.HasPrefix(trimmed, "```") {
+				inFence = false
+				b.addCodeBlock(fence)
+				fence = nil
+			}
+			continue
+		}
+
+		lower := strings.ToLower(trimmed)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inFence = true
+			fence = []string{line}
+		case strings.HasPrefix(lower, "<details") && b.panel == nil:
+			b.openPanel()
+			if m := feishuSummaryPattern.FindStringSubmatch(trimmed); m != nil {
+				b.panel.title = strings.TrimSpace(m[1])
+			}
+		case b.panel != nil && b.panel.title == "" && feishuSummaryPattern.MatchString(trimmed):
+			b.panel.title = strings.TrimSpace(feishuSummaryPattern.FindStringSubmatch(trimmed)[1])
+		case strings.HasPrefix(lower, "</details>") && b.panel != nil:
+			b.closePanel()
+		default:
+			b.lines = append(b.lines, line)
+		}
+	}
+	// An unterminated fence is kept as-is.
+	b.lines = append(b.lines, fence...)
+	if b.panel != nil {
+		b.closePanel()
+	}
+	b.flush()
+
+	return map[string]any{
+		"schema": "2.0",
+		"config": map[string]any{"width_mode": "fill"},
+		"body":   map[string]any{"elements": b.elements},
+	}
+}
+
+type feishuCardBuilder struct {
+	elements []any
+	lines    []string
+	panel    *feishuCardPanel
+}
+
+type feishuCardPanel struct {
+	title    string
+	elements []any
+}
+
+// flush emits the pending markdown lines into the open panel or the body.
+func (b *feishuCardBuilder) flush() {
+	text := strings.Trim(strings.Join(b.lines, "\n"), "\n")
+	b.lines = nil
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	el := map[string]any{"tag": "markdown", "content": text}
+	if b.panel != nil {
+		b.panel.elements = append(b.panel.elements, el)
+		return
+	}
+	b.elements = append(b.elements, el)
+}
+
+func (b *feishuCardBuilder) openPanel() {
+	b.flush()
+	b.panel = &feishuCardPanel{}
+}
+
+func (b *feishuCardBuilder) closePanel() {
+	b.flush()
+	p := b.panel
+	b.panel = nil
+	if len(p.elements) == 0 {
+		return
+	}
+	title := p.title
+	if title == "" {
+		title = feishuCardDefaultPanelTitle
+	}
+	b.elements = append(b.elements, feishuCollapsiblePanel(title, p.elements))
+}
+
+// addCodeBlock adds a complete fenced block, collapsing long ones that are
+// not already inside a panel.
+func (b *feishuCardBuilder) addCodeBlock(fence []string) {
+	codeLines := len(fence) - 2
+	if b.panel != nil || codeLines <= feishuCardCollapseCodeLines {
+		b.lines = append(b.lines, fence...)
+		return
+	}
+
+	b.flush()
+	title := fmt.Sprintf("Code (%d lines)", codeLines)
+	if lang := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(fence[0]), "```")); lang != "" {
+		title = fmt.Sprintf("%s code (%d lines)", lang, codeLines)
+	}
+	b.elements = append(b.elements, feishuCollapsiblePanel(title, []any{
+		map[string]any{"tag": "markdown", "content": strings.Join(fence, "\n")},
+	}))
+}
+
+func feishuCollapsiblePanel(title string, elements []any) map[string]any {
+	return map[string]any{
+		"tag":      "collapsible_panel",
+		"expanded": false,
+		"header": map[string]any{
+			"title": map[string]any{"tag": "markdown", "content": title},
+		},
+		"elements": elements,
+	}
+}
@@ -0,0 +1,97 @@
+package channel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLooksLikeMarkdown(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"hello there", false},
+		{"costs 3 * 4 = 12", false},
+		{"# Title", true},
+		{"steps:\n1. build\n2. test", true},
+		{"use `go test`:\n```\ngo test ./...\n```", true},
+		{"this is **important**", true},
+		{"see [docs](https://example.com)", true},
+		{"| a | b |\n|---|---|", true},
+	}
+	for _, tt := range tests {
+		if got := looksLikeMarkdown(tt.content); got != tt.want {
+			t.Errorf("looksLikeMarkdown(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func cardElements(t *testing.T, card map[string]any) []any {
+	t.Helper()
+	if card["schema"] != "2.0" {
+		t.Fatalf("schema = %v", card["schema"])
+	}
+	body, _ := card["body"].(map[string]any)
+	elements, _ := body["elements"].([]any)
+	return elements
+}
+
+func TestBuildFeishuCard_Details(t *testing.T) {
+	card := buildFeishuCard("## Summary\nAll good.\n<details>\n<summary>Raw output</summary>\n\n```\nok\n```\n</details>\nBye")
+	elements := cardElements(t, card)
+	if len(elements) != 3 {
+		t.Fatalf("elements = %+v", elements)
+	}
+
+	first := elements[0].(map[string]any)
+	if first["tag"] != "markdown" || first["content"] != "## Summary\nAll good." {
+		t.Errorf("first = %+v", first)
+	}
+
+	panel := elements[1].(map[string]any)
+	if panel["tag"] != "collapsible_panel" || panel["expanded"] != false {
+		t.Fatalf("panel = %+v", panel)
+	}
+	title := panel["header"].(map[string]any)["title"].(map[string]any)
+	if title["content"] != "Raw output" {
+		t.Errorf("panel title = %v", title["content"])
+	}
+	inner := panel["elements"].([]any)[0].(map[string]any)
+	if inner["content"] != "```\nok\n```" {
+		t.Errorf("panel content = %q", inner["content"])
+	}
+
+	if last := elements[2].(map[string]any); last["content"] != "Bye" {
+		t.Errorf("last = %+v", last)
+	}
+}
+
+func TestBuildFeishuCard_LongCodeCollapsed(t *testing.T) {
+	var code []string
+	for i := 0; i < feishuCardCollapseCodeLines+5; i++ {
+		code = append(code, fmt.Sprintf("line %d", i))
+	}
+	short := "```sh\necho hi\n```"
+	long := "```python\n" + strings.Join(code, "\n") + "\n```"
+
+	elements := cardElements(t, buildFeishuCard("Intro\n"+short+"\n"+long))
+	if len(elements) != 2 {
+		t.Fatalf("elements = %+v", elements)
+	}
+	if got := elements[0].(map[string]any)["content"]; got != "Intro\n"+short {
+		t.Errorf("short code should stay inline, got %q", got)
+	}
+	panel := elements[1].(map[string]any)
+	title := panel["header"].(map[string]any)["title"].(map[string]any)["content"]
+	if panel["tag"] != "collapsible_panel" || title != "python code (25 lines)" {
+		t.Errorf("panel = %+v", panel)
+	}
+}
+
+func TestBuildFeishuCard_UnterminatedFence(t *testing.T) {
+	elements := cardElements(t, buildFeishuCard("```\nno end"))
+	if len(elements) != 1 || elements[0].(map[string]any)["content"] != "```\nno end" {
+		t.Errorf("elements = %+v", elements)
+	}
+}
@@ -0,0 +1,493 @@
+package channel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/model"
+)
+
+const (
+	feishuInboundFileMaxBytes = 20 << 20 // 20MB
+	feishuInboundFileTimeout  = 30 * time.Second
+	feishuTranscribeTimeout   = 60 * time.Second
+	// feishuForwardMaxDepth bounds nesting of merged-forward messages.
+	feishuForwardMaxDepth = 3
+)
+
+// FeishuMessageItem is one message returned by the get-message API.
+type FeishuMessageItem struct {
+	MessageID      string
+	UpperMessageID string
+	MessageType    string
+	Content        string
+	SenderID       string
+}
+
+// feishuPostElement is one inline element of a rich-text post paragraph.
+type feishuPostElement struct {
+	Tag       string `json:"tag"`
+	Text      string `json:"text"`
+	Href      string `json:"href"`
+	UserID    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	ImageKey  string `json:"image_key"`
+	FileKey   string `json:"file_key"`
+	EmojiType string `json:"emoji_type"`
+	Language  string `json:"language"`
+}
+
+type feishuPost struct {
+	Title   string                `json:"title"`
+	Content [][]feishuPostElement `json:"content"`
+}
+
+// replaceFeishuMentions turns "@_user_1" placeholders into "@Name".
+func replaceFeishuMentions(text string, mentions []feishuMention) string {
+	for _, m := range mentions {
+		if m.Key == "" || m.Name == "" {
+			continue
+		}
+		text = strings.ReplaceAll(text, m.Key, "@"+m.Name)
+	}
+	return text
+}
+
+// decodeFeishuPost accepts both the flat {"title","content"} form used in
+// events and the locale-wrapped {"zh_cn": {...}} form.
+func decodeFeishuPost(rawContent string) (feishuPost, error) {
+	var post feishuPost
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(rawContent), &fields); err != nil {
+		return post, err
+	}
+	if _, ok := fields["content"]; ok {
+		err := json.Unmarshal([]byte(rawContent), &post)
+		return post, err
+	}
+
+	locales := make([]string, 0, len(fields))
+	for locale := range fields {
+		locales = append(locales, locale)
+	}
+	sort.Slice(locales, func(i, j int) bool {
+		return feishuLocaleRank(locales[i]) < feishuLocaleRank(locales[j]) ||
+			(feishuLocaleRank(locales[i]) == feishuLocaleRank(locales[j]) && locales[i] < locales[j])
+	})
+	for _, locale := range locales {
+		if err := json.Unmarshal(fields[locale], &post); err == nil && (post.Title != "" || len(post.Content) > 0) {
+			return post, nil
+		}
+	}
+	return feishuPost{}, nil
+}
+
+func feishuLocaleRank(locale string) int {
+	switch locale {
+	case "zh_cn":
+		return 0
+	case "en_us":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (f *FeishuChannel) parseFeishuPost(ctx context.Context, msg feishuInboundMessage) (string, []model.ContentBlock, map[string]any, error) {
+	post, err := decodeFeishuPost(msg.Content)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("parse post content: %w", err)
+	}
+
+	mentionNames := make(map[string]string, len(msg.Mentions))
+	for _, m := range msg.Mentions {
+		mentionNames[m.Key] = m.Name
+	}
+
+	var lines []string
+	if title := strings.TrimSpace(post.Title); title != "" {
+		lines = append(lines, title, "")
+	}
+
+	var blocks []model.ContentBlock
+	var imageKeys []string
+	for _, paragraph := range post.Content {
+		var sb strings.Builder
+		for _, el := range paragraph {
+			switch el.Tag {
+			case "text":
+				sb.WriteString(replaceFeishuMentions(el.Text, msg.Mentions))
+			case "a":
+				if el.Href == "" {
+					sb.WriteString(el.Text)
+				} else {
+					fmt.Fprintf(&sb, "[%s](%s)", el.Text, el.Href)
+				}
+			case "at":
+				name := el.UserName
+				if name == "" {
+					name = mentionNames[el.UserID]
+				}
+				if name == "" {
+					name = el.UserID
+				}
+				sb.WriteString("@" + name)
+			case "img":
+				sb.WriteString("[image]")
+				if el.ImageKey == "" {
+					continue
+				}
+				imageKeys = append(imageKeys, el.ImageKey)
+				block, err := f.messageImageBlock(ctx, msg.MessageID, el.ImageKey)
+				if err != nil {
+					log.Printf("[feishu] post image download warning: %v", err)
+				}
+				if block != nil {
+					blocks = append(blocks, *block)
+				}
+			case "media":
+				sb.WriteString("[video]")
+			case "emotion":
+				fmt.Fprintf(&sb, ":%s:", el.EmojiType)
+			case "code_block":
+				fmt.Fprintf(&sb, "```%s\n%s\n```", strings.ToLower(el.Language), strings.TrimRight(el.Text, "\n"))
+			case "hr":
+				sb.WriteString("---")
+			case "md":
+				sb.WriteString(replaceFeishuMentions(el.Text, msg.Mentions))
+			}
+		}
+		lines = append(lines, sb.String())
+	}
+
+	content := strings.TrimSpace(strings.Join(lines, "\n"))
+	if content == "" && len(blocks) == 0 {
+		return "", nil, nil, nil
+	}
+	var metadata map[string]any
+	if len(imageKeys) > 0 {
+		metadata = map[string]any{"image_keys": imageKeys}
+	}
+	return content, blocks, metadata, nil
+}
+
+func (f *FeishuChannel) parseFeishuFile(ctx context.Context, msg feishuInboundMessage) (string, []model.ContentBlock, map[string]any, error) {
+	var fileContent struct {
+		FileKey  string `json:"file_key"`
+		FileName string `json:"file_name"`
+	}
+	if err := json.Unmarshal([]byte(msg.Content), &fileContent); err != nil {
+		return "", nil, nil, fmt.Errorf("parse file content: %w", err)
+	}
+	if fileContent.FileKey == "" {
+		return "", nil, nil, fmt.Errorf("missing file_key")
+	}
+
+	content := strings.TrimSpace("[file] " + fileContent.FileName)
+	metadata := map[string]any{"file_key": fileContent.FileKey, "file_name": fileContent.FileName}
+
+	data, mediaType, err := f.downloadResource(ctx, msg.MessageID, fileContent.FileKey, "file")
+	if err != nil {
+		log.Printf("[feishu] file download warning: %v", err)
+		return content + " (download failed)", nil, metadata, nil
+	}
+	mediaType = detectFeishuFileMediaType(fileContent.FileName, mediaType, data)
+
+	// Use image block type for image MIME types sent as files
+	blockType := model.ContentBlockDocument
+	if strings.HasPrefix(mediaType, "image/") {
+		blockType = model.ContentBlockImage
+	}
+	return content, []model.ContentBlock{{
+		Type:      blockType,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}}, metadata, nil
+}
+
+// detectFeishuFileMediaType prefers the file extension because the resource
+// API usually reports application/octet-stream.
+func detectFeishuFileMediaType(fileName, reported string, data []byte) string {
+	mediaType := normalizeFeishuMediaType(reported)
+	if mediaType != "" && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if byExt := normalizeFeishuMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName)))); byExt != "" {
+		return byExt
+	}
+	return normalizeFeishuMediaType(http.DetectContentType(data))
+}
+
+func (f *FeishuChannel) parseFeishuAudio(ctx context.Context, msg feishuInboundMessage) (string, []model.ContentBlock, map[string]any, error) {
+	var audioContent struct {
+		FileKey  string `json:"file_key"`
+		Duration int    `json:"duration"`
+	}
+	if err := json.Unmarshal([]byte(msg.Content), &audioContent); err != nil {
+		return "", nil, nil, fmt.Errorf("parse audio content: %w", err)
+	}
+	if audioContent.FileKey == "" {
+		return "", nil, nil, fmt.Errorf("missing file_key")
+	}
+	metadata := map[string]any{"file_key": audioContent.FileKey, "duration_ms": audioContent.Duration}
+
+	if f.transcriber == nil {
+		return "[audio] (no transcript: speech-to-text is not configured)", nil, metadata, nil
+	}
+
+	data, _, err := f.downloadResource(ctx, msg.MessageID, audioContent.FileKey, "file")
+	if err != nil {
+		log.Printf("[feishu] audio download warning: %v", err)
+		return "[audio] (no transcript: download failed)", nil, metadata, nil
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, feishuTranscribeTimeout)
+	defer cancel()
+	text, err := f.transcriber.Transcribe(tctx, "audio.opus", data)
+	if err != nil || strings.TrimSpace(text) == "" {
+		log.Printf("[feishu] transcribe audio %s failed: %v", audioContent.FileKey, err)
+		return "[audio] (no transcript: transcription failed)", nil, metadata, nil
+	}
+	return "[Voice message transcript]\n" + strings.TrimSpace(text), nil, metadata, nil
+}
+
+func (f *FeishuChannel) parseFeishuMedia(ctx context.Context, msg feishuInboundMessage) (string, []model.ContentBlock, map[string]any, error) {
+	var mediaContent struct {
+		FileKey  string `json:"file_key"`
+		ImageKey string `json:"image_key"`
+		FileName string `json:"file_name"`
+		Duration int    `json:"duration"`
+	}
+	if err := json.Unmarshal([]byte(msg.Content), &mediaContent); err != nil {
+		return "", nil, nil, fmt.Errorf("parse media content: %w", err)
+	}
+
+	content := "[video]"
+	if mediaContent.FileName != "" {
+		content += " " + mediaContent.FileName
+	}
+	if mediaContent.Duration > 0 {
+		content += fmt.Sprintf(" (%ds)", mediaContent.Duration/1000)
+	}
+	metadata := map[string]any{"file_key": mediaContent.FileKey}
+
+	if mediaContent.ImageKey == "" {
+		return content, nil, metadata, nil
+	}
+	content += ", thumbnail attached"
+	block, err := f.messageImageBlock(ctx, msg.MessageID, mediaContent.ImageKey)
+	if err != nil {
+		log.Printf("[feishu] video thumbnail download warning: %v", err)
+	}
+	if block == nil {
+		return content, nil, metadata, nil
+	}
+	return content, []model.ContentBlock{*block}, metadata, nil
+}
+
+func parseFeishuLocation(rawContent string) (string, []model.ContentBlock, map[string]any, error) {
+	var location struct {
+		Name      string `json:"name"`
+		Longitude string `json:"longitude"`
+		Latitude  string `json:"latitude"`
+	}
+	if err := json.Unmarshal([]byte(rawContent), &location); err != nil {
+		return "", nil, nil, fmt.Errorf("parse location content: %w", err)
+	}
+	content := strings.TrimSpace(fmt.Sprintf("[Location] %s (%s, %s)", location.Name, location.Latitude, location.Longitude))
+	return content, nil, nil, nil
+}
+
+func (f *FeishuChannel) parseFeishuMergeForward(ctx context.Context, msg feishuInboundMessage) (string, []model.ContentBlock, map[string]any, error) {
+	if msg.MessageID == "" || f.client == nil || f.messageFetcher == nil {
+		return "[forwarded messages]", nil, nil, nil
+	}
+	token, err := f.client.GetTenantAccessToken(ctx)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("get tenant access token: %w", err)
+	}
+	items, err := f.messageFetcher(ctx, token, msg.MessageID)
+	if err != nil {
+		log.Printf("[feishu] fetch forwarded messages warning: %v", err)
+		return "[forwarded messages]", nil, nil, nil
+	}
+
+	body, blocks := f.renderFeishuForward(ctx, items, msg.MessageID, 1)
+	if body == "" {
+		return "[forwarded messages]", blocks, nil, nil
+	}
+	return "[forwarded messages]\n" + body, blocks, nil, nil
+}
+
+// renderFeishuForward renders the children of parentID as quoted blocks.
+func (f *FeishuChannel) renderFeishuForward(ctx context.Context, items []FeishuMessageItem, parentID string, depth int) (string, []model.ContentBlock) {
+	var parts []string
+	var blocks []model.ContentBlock
+	for _, item := range items {
+		if item.UpperMessageID != parentID || item.MessageID == parentID {
+			continue
+		}
+
+		var text string
+		if strings.EqualFold(item.MessageType, "merge_forward") {
+			text = "[forwarded messages]"
+			if depth < feishuForwardMaxDepth {
+				nested, nestedBlocks := f.renderFeishuForward(ctx, items, item.MessageID, depth+1)
+				if nested != "" {
+					text += "\n" + nested
+				}
+				blocks = append(blocks, nestedBlocks...)
+			}
+		} else {
+			content, childBlocks, _, err := f.parseFeishuInboundMessage(ctx, feishuInboundMessage{
+				MessageID:   item.MessageID,
+				MessageType: item.MessageType,
+				Content:     item.Content,
+			})
+			if err != nil {
+				log.Printf("[feishu] parse forwarded message %s warning: %v", item.MessageID, err)
+			}
+			text = content
+			if text == "" {
+				text = "[" + item.MessageType + "]"
+			}
+			blocks = append(blocks, childBlocks...)
+		}
+		parts = append(parts, "> "+strings.ReplaceAll(text, "\n", "\n> "))
+	}
+	return strings.Join(parts, "\n>\n"), blocks
+}
+
+// messageImageBlock downloads an image embedded in a user message. Without a
+// message ID it falls back to the app image API.
+func (f *FeishuChannel) messageImageBlock(ctx context.Context, messageID, imageKey string) (*model.ContentBlock, error) {
+	if messageID == "" {
+		return f.buildFeishuImageContentBlock(ctx, imageKey)
+	}
+	data, mediaType, err := f.downloadResource(ctx, messageID, imageKey, "image")
+	if err != nil {
+		return nil, fmt.Errorf("download image %q: %w", imageKey, err)
+	}
+	mediaType = normalizeFeishuMediaType(mediaType)
+	if !strings.HasPrefix(mediaType, "image/") {
+		mediaType = http.DetectContentType(data)
+	}
+	return &model.ContentBlock{
+		Type:      model.ContentBlockImage,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+func (f *FeishuChannel) downloadResource(ctx context.Context, messageID, fileKey, resourceType string) ([]byte, string, error) {
+	if f.client == nil {
+		return nil, "", fmt.Errorf("feishu client not initialized")
+	}
+	if messageID == "" {
+		return nil, "", fmt.Errorf("missing message_id for resource %q", fileKey)
+	}
+	token, err := f.client.GetTenantAccessToken(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("get tenant access token: %w", err)
+	}
+	downloader := f.resourceDownloader
+	if downloader == nil {
+		downloader = downloadFeishuMessageResource
+	}
+	return downloader(ctx, token, messageID, fileKey, resourceType)
+}
+
+func buildFeishuMessageResourceURL(messageID, fileKey, resourceType string) string {
+	return fmt.Sprintf("https://open.feishu.cn/open-apis/im/v1/messages/%s/resources/%s?type=%s",
+		url.PathEscape(messageID), url.PathEscape(fileKey), url.QueryEscape(resourceType))
+}
+
+func downloadFeishuMessageResource(ctx context.Context, tenantAccessToken, messageID, fileKey, resourceType string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, buildFeishuMessageResourceURL(messageID, fileKey, resourceType), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("create resource request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tenantAccessToken)
+
+	httpClient := &http.Client{Timeout: feishuInboundFileTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("request resource: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, feishuInboundFileMaxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("read resource response: %w", err)
+	}
+	if int64(len(body)) > feishuInboundFileMaxBytes {
+		return nil, "", fmt.Errorf("resource exceeds %d bytes", feishuInboundFileMaxBytes)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("resource request failed with status %d", resp.StatusCode)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+func fetchFeishuMessage(ctx context.Context, tenantAccessToken, messageID string) ([]FeishuMessageItem, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://open.feishu.cn/open-apis/im/v1/messages/"+url.PathEscape(messageID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create message request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tenantAccessToken)
+
+	httpClient := &http.Client{Timeout: feishuInboundFileTimeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Items []struct {
+				MessageID      string `json:"message_id"`
+				UpperMessageID string `json:"upper_message_id"`
+				MsgType        string `json:"msg_type"`
+				Body           struct {
+					Content string `json:"content"`
+				} `json:"body"`
+				Sender struct {
+					ID string `json:"id"`
+				} `json:"sender"`
+			} `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode message response: %w", err)
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("feishu get message error: %s", result.Msg)
+	}
+
+	items := make([]FeishuMessageItem, 0, len(result.Data.Items))
+	for _, it := range result.Data.Items {
+		items = append(items, FeishuMessageItem{
+			MessageID:      it.MessageID,
+			UpperMessageID: it.UpperMessageID,
+			MessageType:    it.MsgType,
+			Content:        it.Body.Content,
+			SenderID:       it.Sender.ID,
+		})
+	}
+	return items, nil
+}
@@ -1,11 +1,19 @@
 package channel
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -18,7 +26,9 @@ import (
 // mockFeishuClient implements FeishuClient for testing
 type mockFeishuClient struct {
 	sentMessages []struct{ chatID, content string }
+	sentCards    []map[string]any
 	sendErr      error
+	cardErr      error
 	token        string
 	tokenErr     error
 }
@@ -28,6 +38,11 @@ func (m *mockFeishuClient) SendMessage(ctx context.Context, chatID, content stri
 	return m.sendErr
 }
 
+func (m *mockFeishuClient) SendCard(ctx context.Context, chatID string, card map[string]any) error {
+	m.sentCards = append(m.sentCards, card)
+	return m.cardErr
+}
+
 func (m *mockFeishuClient) GetTenantAccessToken(ctx context.Context) (string, error) {
 	return m.token, m.tokenErr
 }
@@ -150,6 +165,20 @@ func newTestFeishuChannel(t *testing.T, cfg config.FeishuConfig) (*FeishuChannel
 	return ch, b
 }
 
+// serveFeishuWebhook runs the handler and then the events it queued, so
+// tests see the outcome without starting the worker.
+func serveFeishuWebhook(ch *FeishuChannel, w http.ResponseWriter, r *http.Request) {
+	ch.handleWebhook(w, r)
+	for {
+		select {
+		case event := <-ch.events:
+			ch.handleEvent(event)
+		default:
+			return
+		}
+	}
+}
+
 func TestFeishuWebhook_Challenge(t *testing.T) {
 	ch, _ := newTestFeishuChannel(t, config.FeishuConfig{
 		AppID: "cli_test", AppSecret: "secret",
@@ -159,7 +188,7 @@ func TestFeishuWebhook_Challenge(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -180,7 +209,7 @@ func TestFeishuWebhook_MethodNotAllowed(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/feishu/webhook", nil)
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusMethodNotAllowed {
 		t.Errorf("status = %d, want 405", w.Code)
@@ -195,7 +224,7 @@ func TestFeishuWebhook_InvalidJSON(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader("not json"))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want 400", w.Code)
@@ -213,7 +242,7 @@ func TestFeishuWebhook_InvalidToken(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
@@ -231,7 +260,7 @@ func TestFeishuWebhook_ValidToken(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -266,7 +295,7 @@ func TestFeishuWebhook_MessageReceive(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(string(data)))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -320,7 +349,7 @@ func TestFeishuWebhook_RejectedSender(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(string(data)))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	select {
 	case <-b.Inbound:
@@ -366,7 +395,7 @@ func TestFeishuWebhook_ImageMessage(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(string(data)))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	select {
 	case msg := <-b.Inbound:
@@ -411,7 +440,7 @@ func TestFeishuWebhook_UnsupportedMessageType(t *testing.T) {
 			},
 			"message": map[string]interface{}{
 				"chat_id":      "oc_chat",
-				"message_type": "post",
+				"message_type": "hongbao",
 				"content":      `{}`,
 			},
 		},
@@ -421,7 +450,7 @@ func TestFeishuWebhook_UnsupportedMessageType(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(string(data)))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	select {
 	case <-b.Inbound:
@@ -458,7 +487,7 @@ func TestFeishuWebhook_EmptyText(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(string(data)))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	select {
 	case <-b.Inbound:
@@ -495,7 +524,7 @@ func TestFeishuWebhook_InvalidContent(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(string(data)))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	select {
 	case <-b.Inbound:
@@ -514,7 +543,7 @@ func TestFeishuWebhook_NonMessageEvent(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -528,6 +557,52 @@ func TestFeishuWebhook_NonMessageEvent(t *testing.T) {
 	}
 }
 
+func TestFeishuWebhook_HandlesEventOffRequest(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	release := make(chan struct{})
+	ch.imageDownloader = func(ctx context.Context, tenantAccessToken, imageKey string) (string, string, error) {
+		<-release
+		return "iVBORw0KGgo=", "image/png", nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ch.runEventWorker(ctx)
+
+	body := `{"header":{"event_id":"ev-slow","event_type":"im.message.receive_v1"},` +
+		`"event":{"sender":{"sender_id":{"open_id":"ou_test"}},` +
+		`"message":{"message_id":"om_slow","chat_id":"oc_chat","message_type":"image","content":"{\"image_key\":\"img_xxx\"}"}}}`
+	w := httptest.NewRecorder()
+	// The download is still blocked, yet the request has been answered.
+	ch.handleWebhook(w, httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	close(release)
+
+	select {
+	case msg := <-b.Inbound:
+		if msg.Content != "[image]" {
+			t.Errorf("content = %q, want [image]", msg.Content)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for inbound message")
+	}
+}
+
+func TestFeishuWebhook_QueueFull(t *testing.T) {
+	ch, _ := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	for i := 0; i < feishuEventQueueSize; i++ {
+		ch.events <- &feishuEvent{}
+	}
+
+	body := `{"header":{"event_id":"ev-full","event_type":"im.message.receive_v1"},"event":{}}`
+	w := httptest.NewRecorder()
+	ch.handleWebhook(w, httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
 func TestFeishuWebhook_NoVerificationToken(t *testing.T) {
 	ch, _ := newTestFeishuChannel(t, config.FeishuConfig{
 		AppID:             "cli_test",
@@ -539,13 +614,136 @@ func TestFeishuWebhook_NoVerificationToken(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body))
 	w := httptest.NewRecorder()
 
-	ch.handleWebhook(w, req)
+	serveFeishuWebhook(ch, w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200 (token verification should be skipped)", w.Code)
 	}
 }
 
+func encryptFeishuTestEvent(t *testing.T, key, plaintext string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	iv := bytes.Repeat([]byte{7}, aes.BlockSize)
+	padded := pkcs7Pad([]byte(plaintext), aes.BlockSize)
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+	data, _ := json.Marshal(map[string]string{"encrypt": base64.StdEncoding.EncodeToString(append(iv, out...))})
+	return string(data)
+}
+
+func signedFeishuRequest(key, body string, ts time.Time) *http.Request {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	sum := sha256.Sum256([]byte(timestamp + "nonce-1" + key + body))
+	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body))
+	req.Header.Set("X-Lark-Request-Timestamp", timestamp)
+	req.Header.Set("X-Lark-Request-Nonce", "nonce-1")
+	req.Header.Set("X-Lark-Signature", hex.EncodeToString(sum[:]))
+	return req
+}
+
+const feishuEncryptedTextEvent = `{"schema":"2.0","header":{"event_id":"ev-1","event_type":"im.message.receive_v1","token":"vt"},` +
+	`"event":{"sender":{"sender_id":{"open_id":"ou_1"}},"message":{"chat_id":"oc_1","message_type":"text","content":"{\"text\":\"secret hello\"}"}}}`
+
+func TestFeishuWebhook_EncryptedEvent(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{
+		AppID: "cli_test", AppSecret: "secret", VerificationToken: "vt", EncryptKey: "ek",
+	})
+	body := encryptFeishuTestEvent(t, "ek", feishuEncryptedTextEvent)
+
+	w := httptest.NewRecorder()
+	serveFeishuWebhook(ch, w, signedFeishuRequest("ek", body, time.Now()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", w.Code, w.Body.String())
+	}
+	select {
+	case msg := <-b.Inbound:
+		if msg.Content != "secret hello" || msg.ChatID != "oc_1" || msg.SenderID != "ou_1" {
+			t.Errorf("inbound = %+v", msg)
+		}
+	default:
+		t.Fatal("expected inbound message")
+	}
+
+	// Feishu retries reuse the event_id; the redelivery is acknowledged but dropped.
+	w = httptest.NewRecorder()
+	serveFeishuWebhook(ch, w, signedFeishuRequest("ek", body, time.Now()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry status = %d, want 200", w.Code)
+	}
+	select {
+	case msg := <-b.Inbound:
+		t.Fatalf("duplicate event delivered: %+v", msg)
+	default:
+	}
+}
+
+func TestFeishuWebhook_SignatureRejected(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{
+		AppID: "cli_test", AppSecret: "secret", EncryptKey: "ek",
+	})
+	body := encryptFeishuTestEvent(t, "ek", feishuEncryptedTextEvent)
+
+	tests := map[string]*http.Request{
+		"wrong key":     signedFeishuRequest("other", body, time.Now()),
+		"stale":         signedFeishuRequest("ek", body, time.Now().Add(-10*time.Minute)),
+		"future":        signedFeishuRequest("ek", body, time.Now().Add(10*time.Minute)),
+		"missing":       httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body)),
+		"tampered body": signedFeishuRequest("ek", body, time.Now()),
+	}
+	tests["tampered body"].Body = io.NopCloser(strings.NewReader(encryptFeishuTestEvent(t, "ek", strings.Replace(feishuEncryptedTextEvent, "ev-1", "ev-2", 1))))
+
+	for name, req := range tests {
+		w := httptest.NewRecorder()
+		serveFeishuWebhook(ch, w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, w.Code)
+		}
+	}
+	select {
+	case msg := <-b.Inbound:
+		t.Fatalf("unexpected inbound: %+v", msg)
+	default:
+	}
+}
+
+func TestFeishuWebhook_EncryptedChallenge(t *testing.T) {
+	ch, _ := newTestFeishuChannel(t, config.FeishuConfig{
+		AppID: "cli_test", AppSecret: "secret", VerificationToken: "vt", EncryptKey: "ek",
+	})
+
+	body := encryptFeishuTestEvent(t, "ek", `{"challenge":"c-1","token":"vt","type":"url_verification"}`)
+	w := httptest.NewRecorder()
+	serveFeishuWebhook(ch, w, httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body)))
+	var resp map[string]string
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp["challenge"] != "c-1" {
+		t.Fatalf("status = %d, challenge = %q", w.Code, resp["challenge"])
+	}
+
+	body = encryptFeishuTestEvent(t, "ek", `{"challenge":"c-1","token":"wrong","type":"url_verification"}`)
+	w = httptest.NewRecorder()
+	serveFeishuWebhook(ch, w, httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token status = %d, want 401", w.Code)
+	}
+}
+
+func TestFeishuWebhook_EncryptedWithoutKey(t *testing.T) {
+	ch, _ := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+
+	body := encryptFeishuTestEvent(t, "ek", feishuEncryptedTextEvent)
+	w := httptest.NewRecorder()
+	serveFeishuWebhook(ch, w, httptest.NewRequest(http.MethodPost, "/feishu/webhook", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
 func TestFeishuChannel_StartStop(t *testing.T) {
 	b := bus.NewMessageBus(10)
 	mock := &mockFeishuClient{token: "test-token"}
@@ -605,3 +803,211 @@ func TestChannelManager_FeishuEnabled_MissingConfig(t *testing.T) {
 		t.Error("expected error for missing feishu config")
 	}
 }
+
+// --- Rich message types ---
+
+func deliverFeishuMessage(t *testing.T, ch *FeishuChannel, b *bus.MessageBus, message map[string]interface{}) bus.InboundMessage {
+	t.Helper()
+	message["chat_id"] = "oc_chat"
+	event := map[string]interface{}{
+		"header": map[string]interface{}{"event_type": "im.message.receive_v1"},
+		"event": map[string]interface{}{
+			"sender":  map[string]interface{}{"sender_id": map[string]interface{}{"open_id": "ou_test"}},
+			"message": message,
+		},
+	}
+	data, _ := json.Marshal(event)
+	req := httptest.NewRequest(http.MethodPost, "/feishu/webhook", bytes.NewReader(data))
+	serveFeishuWebhook(ch, httptest.NewRecorder(), req)
+
+	select {
+	case msg := <-b.Inbound:
+		return msg
+	default:
+		t.Fatal("no inbound message")
+		return bus.InboundMessage{}
+	}
+}
+
+func TestFeishuWebhook_TextMentions(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_id":   "om_1",
+		"message_type": "text",
+		"content":      `{"text":"@_user_1 please review"}`,
+		"mentions": []interface{}{map[string]interface{}{
+			"key": "@_user_1", "name": "Alice", "id": map[string]interface{}{"open_id": "ou_alice"},
+		}},
+	})
+	if msg.Content != "@Alice please review" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.Metadata["message_id"] != "om_1" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+}
+
+func TestFeishuWebhook_PostMessage(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	ch.resourceDownloader = func(ctx context.Context, token, messageID, fileKey, resourceType string) ([]byte, string, error) {
+		if messageID != "om_post" || fileKey != "img_post" || resourceType != "image" {
+			t.Fatalf("download(%q, %q, %q)", messageID, fileKey, resourceType)
+		}
+		return []byte("\x89PNG\r\n\x1a\n"), "image/png", nil
+	}
+
+	post := `{"title":"Bug report","content":[` +
+		`[{"tag":"at","user_id":"@_user_1"},{"tag":"text","text":" login fails, see "},{"tag":"a","text":"log","href":"https://example.com/log"}],` +
+		`[{"tag":"img","image_key":"img_post"}],` +
+		`[{"tag":"code_block","language":"GO","text":"panic(err)\n"}]]}`
+	msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_id":   "om_post",
+		"message_type": "post",
+		"content":      post,
+		"mentions":     []interface{}{map[string]interface{}{"key": "@_user_1", "name": "Bot"}},
+	})
+
+	want := "Bug report\n\n@Bot login fails, see [log](https://example.com/log)\n[image]\n```go\npanic(err)\n```"
+	if msg.Content != want {
+		t.Errorf("content = %q, want %q", msg.Content, want)
+	}
+	if len(msg.ContentBlocks) != 1 || msg.ContentBlocks[0].Type != model.ContentBlockImage || msg.ContentBlocks[0].MediaType != "image/png" {
+		t.Fatalf("blocks = %+v", msg.ContentBlocks)
+	}
+}
+
+func TestFeishuWebhook_PostLocaleWrapped(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_type": "post",
+		"content":      `{"en_us":{"title":"","content":[[{"tag":"text","text":"hello"}]]}}`,
+	})
+	if msg.Content != "hello" {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
+
+func TestFeishuWebhook_FileMessage(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	ch.resourceDownloader = func(ctx context.Context, token, messageID, fileKey, resourceType string) ([]byte, string, error) {
+		if token != "test-token" || fileKey != "file_1" || resourceType != "file" {
+			t.Fatalf("download(%q, %q, %q)", token, fileKey, resourceType)
+		}
+		return []byte("%PDF-1.4"), "application/octet-stream", nil
+	}
+
+	msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_id":   "om_file",
+		"message_type": "file",
+		"content":      `{"file_key":"file_1","file_name":"spec.pdf"}`,
+	})
+	if msg.Content != "[file] spec.pdf" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if len(msg.ContentBlocks) != 1 {
+		t.Fatalf("blocks len = %d", len(msg.ContentBlocks))
+	}
+	block := msg.ContentBlocks[0]
+	if block.Type != model.ContentBlockDocument || block.MediaType != "application/pdf" {
+		t.Errorf("block = %s %s", block.Type, block.MediaType)
+	}
+	if block.Data != base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")) {
+		t.Errorf("data = %q", block.Data)
+	}
+}
+
+func TestFeishuWebhook_AudioMessage(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_id":   "om_audio",
+		"message_type": "audio",
+		"content":      `{"file_key":"file_audio","duration":3000}`,
+	})
+	if !strings.Contains(msg.Content, "speech-to-text is not configured") {
+		t.Errorf("content without transcriber = %q", msg.Content)
+	}
+
+	tr := &stubTranscriber{text: "turn off the lights"}
+	ch.SetTranscriber(tr)
+	ch.resourceDownloader = func(ctx context.Context, token, messageID, fileKey, resourceType string) ([]byte, string, error) {
+		return []byte("opus-data"), "audio/opus", nil
+	}
+	msg = deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_id":   "om_audio2",
+		"message_type": "audio",
+		"content":      `{"file_key":"file_audio","duration":3000}`,
+	})
+	if msg.Content != "[Voice message transcript]\nturn off the lights" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if string(tr.audio) != "opus-data" {
+		t.Errorf("transcribed audio = %q", tr.audio)
+	}
+}
+
+func TestFeishuWebhook_StickerAndLocation(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	if msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_type": "sticker",
+		"content":      `{"file_key":"sticker_1"}`,
+	}); msg.Content != "[sticker]" {
+		t.Errorf("sticker content = %q", msg.Content)
+	}
+	if msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_type": "location",
+		"content":      `{"name":"Office","longitude":"116.3","latitude":"39.9"}`,
+	}); msg.Content != "[Location] Office (39.9, 116.3)" {
+		t.Errorf("location content = %q", msg.Content)
+	}
+}
+
+func TestFeishuWebhook_MergeForward(t *testing.T) {
+	ch, b := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	ch.messageFetcher = func(ctx context.Context, token, messageID string) ([]FeishuMessageItem, error) {
+		if messageID != "om_fwd" {
+			t.Fatalf("messageID = %q", messageID)
+		}
+		return []FeishuMessageItem{
+			{MessageID: "om_fwd", MessageType: "merge_forward", Content: `{"content":"Merged and Forwarded Message"}`},
+			{MessageID: "om_a", UpperMessageID: "om_fwd", MessageType: "text", Content: `{"text":"first"}`},
+			{MessageID: "om_b", UpperMessageID: "om_fwd", MessageType: "post", Content: `{"title":"","content":[[{"tag":"text","text":"second"}],[{"tag":"text","text":"line"}]]}`},
+		}, nil
+	}
+
+	msg := deliverFeishuMessage(t, ch, b, map[string]interface{}{
+		"message_id":   "om_fwd",
+		"message_type": "merge_forward",
+		"content":      `{"content":"Merged and Forwarded Message"}`,
+	})
+	want := "[forwarded messages]\n> first\n>\n> second\n> line"
+	if msg.Content != want {
+		t.Errorf("content = %q, want %q", msg.Content, want)
+	}
+}
+
+func TestFeishuChannel_SendMarkdownCard(t *testing.T) {
+	ch, _ := newTestFeishuChannel(t, config.FeishuConfig{AppID: "cli_test", AppSecret: "secret"})
+	mock := ch.client.(*mockFeishuClient)
+
+	if err := ch.Send(bus.OutboundMessage{ChatID: "oc_chat", Content: "## Result\n\n- done"}); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if len(mock.sentCards) != 1 || len(mock.sentMessages) != 0 {
+		t.Fatalf("cards = %d, texts = %d", len(mock.sentCards), len(mock.sentMessages))
+	}
+
+	if err := ch.Send(bus.OutboundMessage{ChatID: "oc_chat", Content: "plain reply"}); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if len(mock.sentMessages) != 1 {
+		t.Fatalf("plain text should be sent as text, texts = %d", len(mock.sentMessages))
+	}
+
+	mock.cardErr = fmt.Errorf("card rejected")
+	if err := ch.Send(bus.OutboundMessage{ChatID: "oc_chat", Content: "**bold**"}); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if len(mock.sentMessages) != 2 || mock.sentMessages[1].content != "**bold**" {
+		t.Errorf("card failure should fall back to text: %+v", mock.sentMessages)
+	}
+}
@@ -0,0 +1,437 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	feishuWSEndpointURL = "https://open.feishu.cn/callback/ws/endpoint"
+
+	feishuWSInitialBackoff = 1 * time.Second
+	feishuWSMaxBackoff     = 2 * time.Minute
+	feishuWSPingInterval   = 2 * time.Minute
+	feishuWSReadLimit      = 8 << 20 // 8MB, events are far smaller
+
+	// Frame methods of the long-connection protocol.
+	feishuFrameControl = 0
+	feishuFrameData    = 1
+)
+
+// feishuWSClientConfig is the server-provided connection tuning, in seconds.
+type feishuWSClientConfig struct {
+	ReconnectCount    int `json:"ReconnectCount"`
+	ReconnectInterval int `json:"ReconnectInterval"`
+	ReconnectNonce    int `json:"ReconnectNonce"`
+	PingInterval      int `json:"PingInterval"`
+}
+
+type feishuWSEndpoint struct {
+	URL          string               `json:"URL"`
+	ClientConfig feishuWSClientConfig `json:"ClientConfig"`
+}
+
+type feishuWSEndpointFunc func(ctx context.Context, appID, appSecret string) (*feishuWSEndpoint, error)
+
+// fetchFeishuWSEndpoint asks the open platform for a long-connection URL.
+func fetchFeishuWSEndpoint(ctx context.Context, appID, appSecret string) (*feishuWSEndpoint, error) {
+	body, err := json.Marshal(map[string]string{"AppID": appID, "AppSecret": appSecret})
+	if err != nil {
+		return nil, fmt.Errorf("marshal endpoint request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, feishuWSEndpointURL, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("create endpoint request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("locale", "zh")
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int              `json:"code"`
+		Msg  string           `json:"msg"`
+		Data feishuWSEndpoint `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode endpoint response: %w", err)
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("feishu endpoint error %d: %s", result.Code, result.Msg)
+	}
+	if result.Data.URL == "" {
+		return nil, fmt.Errorf("feishu endpoint response has no URL")
+	}
+	return &result.Data, nil
+}
+
+// runWebSocket keeps a long connection open until ctx is done. Reconnects
+// follow the ReconnectCount and ReconnectInterval the server hands out with
+// the endpoint, falling back to exponential backoff when it sends none.
+func (f *FeishuChannel) runWebSocket(ctx context.Context) {
+	backoff := f.wsBackoff
+	if backoff <= 0 {
+		backoff = feishuWSInitialBackoff
+	}
+	delay := backoff
+	var clientCfg feishuWSClientConfig
+	attempts := 0
+
+	for {
+		connected, err := f.serveWebSocket(ctx, &clientCfg)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = backoff
+			attempts = 0
+		}
+		attempts++
+		if clientCfg.ReconnectCount > 0 && attempts > clientCfg.ReconnectCount {
+			log.Printf("[feishu] long connection closed: %v; giving up after %d reconnect attempts", err, clientCfg.ReconnectCount)
+			return
+		}
+		wait := feishuReconnectDelay(clientCfg, attempts, delay)
+		log.Printf("[feishu] long connection closed: %v; reconnecting in %s", err, wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		delay *= 2
+		if delay > feishuWSMaxBackoff {
+			delay = feishuWSMaxBackoff
+		}
+	}
+}
+
+// feishuReconnectDelay returns the wait before reconnect attempt n (from 1).
+// Like the official SDK, the first attempt is spread over ReconnectNonce
+// seconds and later ones wait ReconnectInterval.
+func feishuReconnectDelay(cfg feishuWSClientConfig, attempt int, backoff time.Duration) time.Duration {
+	if attempt == 1 && cfg.ReconnectNonce > 0 {
+		return time.Duration(rand.Int64N(int64(cfg.ReconnectNonce) * int64(time.Second)))
+	}
+	if cfg.ReconnectInterval > 0 {
+		return time.Duration(cfg.ReconnectInterval) * time.Second
+	}
+	return backoff
+}
+
+// serveWebSocket runs one connection, recording the endpoint's client config
+// in clientCfg. connected reports whether the dial succeeded, so a stable
+// connection resets the backoff.
+func (f *FeishuChannel) serveWebSocket(ctx context.Context, clientCfg *feishuWSClientConfig) (connected bool, err error) {
+	endpoint, err := f.wsEndpoint(ctx, f.cfg.AppID, f.cfg.AppSecret)
+	if err != nil {
+		return false, err
+	}
+	*clientCfg = endpoint.ClientConfig
+	serviceID := int32(0)
+	if u, err := url.Parse(endpoint.URL); err == nil {
+		if id, err := strconv.ParseInt(u.Query().Get("service_id"), 10, 32); err == nil {
+			serviceID = int32(id)
+		}
+	}
+
+	conn, _, err := websocket.Dial(ctx, endpoint.URL, nil)
+	if err != nil {
+		return false, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(feishuWSReadLimit)
+	log.Printf("[feishu] long connection established")
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var pingInterval atomic.Int64
+	pingInterval.Store(int64(feishuWSPingInterval))
+	if endpoint.ClientConfig.PingInterval > 0 {
+		pingInterval.Store(int64(time.Duration(endpoint.ClientConfig.PingInterval) * time.Second))
+	}
+	go f.pingWebSocket(connCtx, conn, serviceID, &pingInterval)
+
+	fragments := make(map[string][][]byte)
+	for {
+		_, data, err := conn.Read(connCtx)
+		if err != nil {
+			return true, err
+		}
+		frame, err := decodeFeishuFrame(data)
+		if err != nil {
+			log.Printf("[feishu] bad frame: %v", err)
+			continue
+		}
+
+		switch frame.Method {
+		case feishuFrameControl:
+			if frame.header("type") == "pong" && len(frame.Payload) > 0 {
+				var cfg feishuWSClientConfig
+				if err := json.Unmarshal(frame.Payload, &cfg); err == nil && cfg.PingInterval > 0 {
+					pingInterval.Store(int64(time.Duration(cfg.PingInterval) * time.Second))
+				}
+			}
+		case feishuFrameData:
+			payload, complete := assembleFeishuFrame(fragments, frame)
+			if !complete {
+				continue
+			}
+			start := time.Now()
+			var event *feishuEvent
+			if frame.header("type") == "event" {
+				event = &feishuEvent{}
+				if err := json.Unmarshal(payload, event); err != nil {
+					log.Printf("[feishu] invalid event payload: %v", err)
+					event = nil
+				}
+			}
+			// Ack before handling: the server redelivers events not
+			// acknowledged within a few seconds.
+			if err := f.ackFeishuFrame(connCtx, conn, frame, time.Since(start)); err != nil {
+				return true, fmt.Errorf("ack frame: %w", err)
+			}
+			if event != nil {
+				select {
+				case f.events <- event:
+				case <-connCtx.Done():
+					return true, connCtx.Err()
+				}
+			}
+		}
+	}
+}
+
+func (f *FeishuChannel) pingWebSocket(ctx context.Context, conn *websocket.Conn, serviceID int32, interval *atomic.Int64) {
+	for {
+		timer := time.NewTimer(time.Duration(interval.Load()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		ping := feishuFrame{
+			Service: serviceID,
+			Method:  feishuFrameControl,
+			Headers: []feishuFrameHeader{{Key: "type", Value: "ping"}},
+		}
+		if err := conn.Write(ctx, websocket.MessageBinary, ping.encode()); err != nil {
+			log.Printf("[feishu] ping failed: %v", err)
+			return
+		}
+	}
+}
+
+func (f *FeishuChannel) ackFeishuFrame(ctx context.Context, conn *websocket.Conn, frame *feishuFrame, elapsed time.Duration) error {
+	ack := *frame
+	ack.Headers = append(append([]feishuFrameHeader(nil), frame.Headers...),
+		feishuFrameHeader{Key: "biz_rt", Value: strconv.FormatInt(elapsed.Milliseconds(), 10)})
+	ack.Payload = []byte(`{"code":200}`)
+	return conn.Write(ctx, websocket.MessageBinary, ack.encode())
+}
+
+// assembleFeishuFrame joins a payload split over "sum" frames sharing a
+// message_id. It returns the payload once all parts have arrived.
+func assembleFeishuFrame(fragments map[string][][]byte, frame *feishuFrame) ([]byte, bool) {
+	sum, _ := strconv.Atoi(frame.header("sum"))
+	if sum <= 1 {
+		return frame.Payload, true
+	}
+	seq, err := strconv.Atoi(frame.header("seq"))
+	if err != nil || seq < 0 || seq >= sum {
+		return nil, false
+	}
+
+	id := frame.header("message_id")
+	parts := fragments[id]
+	if len(parts) != sum {
+		parts = make([][]byte, sum)
+		fragments[id] = parts
+	}
+	parts[seq] = frame.Payload
+
+	var payload []byte
+	for _, p := range parts {
+		if p == nil {
+			return nil, false
+		}
+		payload = append(payload, p...)
+	}
+	delete(fragments, id)
+	return payload, true
+}
+
+// feishuFrame is the protobuf envelope (pbbp2.Frame) of the long-connection
+// protocol.
+type feishuFrame struct {
+	SeqID           uint64
+	LogID           uint64
+	Service         int32
+	Method          int32
+	Headers         []feishuFrameHeader
+	PayloadEncoding string
+	PayloadType     string
+	Payload         []byte
+	LogIDNew        string
+}
+
+type feishuFrameHeader struct {
+	Key   string
+	Value string
+}
+
+func (fr *feishuFrame) header(key string) string {
+	for _, h := range fr.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func (fr *feishuFrame) encode() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, fr.SeqID)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, fr.LogID)
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(int64(fr.Service)))
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(int64(fr.Method)))
+	for _, h := range fr.Headers {
+		var hb []byte
+		hb = protowire.AppendTag(hb, 1, protowire.BytesType)
+		hb = protowire.AppendString(hb, h.Key)
+		hb = protowire.AppendTag(hb, 2, protowire.BytesType)
+		hb = protowire.AppendString(hb, h.Value)
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, hb)
+	}
+	if fr.PayloadEncoding != "" {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendString(b, fr.PayloadEncoding)
+	}
+	if fr.PayloadType != "" {
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendString(b, fr.PayloadType)
+	}
+	if fr.Payload != nil {
+		b = protowire.AppendTag(b, 8, protowire.BytesType)
+		b = protowire.AppendBytes(b, fr.Payload)
+	}
+	if fr.LogIDNew != "" {
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendString(b, fr.LogIDNew)
+	}
+	return b
+}
+
+func decodeFeishuFrame(b []byte) (*feishuFrame, error) {
+	fr := &feishuFrame{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			switch num {
+			case 1:
+				fr.SeqID = v
+			case 2:
+				fr.LogID = v
+			case 3:
+				fr.Service = int32(v)
+			case 4:
+				fr.Method = int32(v)
+			}
+		case typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			switch num {
+			case 5:
+				h, err := decodeFeishuFrameHeader(v)
+				if err != nil {
+					return nil, err
+				}
+				fr.Headers = append(fr.Headers, h)
+			case 6:
+				fr.PayloadEncoding = string(v)
+			case 7:
+				fr.PayloadType = string(v)
+			case 8:
+				fr.Payload = append([]byte(nil), v...)
+			case 9:
+				fr.LogIDNew = string(v)
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return fr, nil
+}
+
+func decodeFeishuFrameHeader(b []byte) (feishuFrameHeader, error) {
+	var h feishuFrameHeader
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return h, protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return h, protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return h, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			h.Key = string(v)
+		case 2:
+			h.Value = string(v)
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,273 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+func TestFeishuFrame_RoundTrip(t *testing.T) {
+	in := feishuFrame{
+		SeqID:   7,
+		LogID:   9,
+		Service: 42,
+		Method:  feishuFrameData,
+		Headers: []feishuFrameHeader{{Key: "type", Value: "event"}, {Key: "message_id", Value: "m1"}},
+		Payload: []byte(`{"a":1}`),
+	}
+	out, err := decodeFeishuFrame(in.encode())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.SeqID != 7 || out.LogID != 9 || out.Service != 42 || out.Method != feishuFrameData {
+		t.Errorf("frame = %+v", out)
+	}
+	if out.header("message_id") != "m1" || string(out.Payload) != `{"a":1}` {
+		t.Errorf("headers = %+v, payload = %q", out.Headers, out.Payload)
+	}
+	if _, err := decodeFeishuFrame([]byte{0x0a, 0xff}); err == nil {
+		t.Error("expected error for truncated frame")
+	}
+}
+
+func TestNewFeishuChannel_InvalidMode(t *testing.T) {
+	_, err := NewFeishuChannel(config.FeishuConfig{AppID: "cli_test", AppSecret: "secret", Mode: "poll"}, bus.NewMessageBus(1))
+	if err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestFeishuChannel_WebSocketMode(t *testing.T) {
+	event := `{"header":{"event_id":"ev-ws","event_type":"im.message.receive_v1"},` +
+		`"event":{"sender":{"sender_id":{"open_id":"ou_ws"}},` +
+		`"message":{"message_id":"om_ws","chat_id":"oc_ws","message_type":"text","content":"{\"text\":\"over websocket\"}"}}}`
+	split := len(event) / 2
+
+	var conns atomic.Int32
+	acks := make(chan *feishuFrame, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+
+		// The first connection drops immediately to exercise reconnect.
+		if conns.Add(1) == 1 {
+			conn.Close(websocket.StatusGoingAway, "restart")
+			return
+		}
+
+		ctx := r.Context()
+		for i, part := range []string{event[:split], event[split:]} {
+			frame := feishuFrame{
+				Service: 3,
+				Method:  feishuFrameData,
+				Headers: []feishuFrameHeader{
+					{Key: "type", Value: "event"},
+					{Key: "message_id", Value: "frame-1"},
+					{Key: "sum", Value: "2"},
+					{Key: "seq", Value: string(rune('0' + i))},
+				},
+				Payload: []byte(part),
+			}
+			if err := conn.Write(ctx, websocket.MessageBinary, frame.encode()); err != nil {
+				return
+			}
+		}
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		ack, err := decodeFeishuFrame(data)
+		if err == nil {
+			acks <- ack
+		}
+		<-ctx.Done()
+	}))
+	defer srv.Close()
+
+	b := bus.NewMessageBus(10)
+	mock := &mockFeishuClient{token: "test-token"}
+	ch, err := NewFeishuChannelWithFactory(config.FeishuConfig{
+		AppID: "cli_test", AppSecret: "secret", Mode: config.FeishuModeWebSocket,
+	}, b, mockFeishuClientFactory(mock))
+	if err != nil {
+		t.Fatalf("NewFeishuChannelWithFactory: %v", err)
+	}
+	ch.wsBackoff = 10 * time.Millisecond
+	ch.wsEndpoint = func(ctx context.Context, appID, appSecret string) (*feishuWSEndpoint, error) {
+		return &feishuWSEndpoint{URL: "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?service_id=3"}, nil
+	}
+
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ch.Stop()
+
+	select {
+	case msg := <-b.Inbound:
+		if msg.Content != "over websocket" || msg.SenderID != "ou_ws" || msg.ChatID != "oc_ws" {
+			t.Errorf("inbound = %+v", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for inbound message")
+	}
+
+	select {
+	case ack := <-acks:
+		if ack.header("message_id") != "frame-1" || ack.header("biz_rt") == "" || string(ack.Payload) != `{"code":200}` {
+			t.Errorf("ack = %+v payload %q", ack.Headers, ack.Payload)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for ack")
+	}
+	if n := conns.Load(); n != 2 {
+		t.Errorf("connections = %d, want 2", n)
+	}
+}
+
+func TestFeishuChannel_WebSocketKeepsEventOrder(t *testing.T) {
+	events := []string{
+		`{"header":{"event_id":"ev-1","event_type":"im.message.receive_v1"},` +
+			`"event":{"sender":{"sender_id":{"open_id":"ou_ws"}},` +
+			`"message":{"message_id":"om_1","chat_id":"oc_ws","message_type":"image","content":"{\"image_key\":\"img_slow\"}"}}}`,
+		`{"header":{"event_id":"ev-2","event_type":"im.message.receive_v1"},` +
+			`"event":{"sender":{"sender_id":{"open_id":"ou_ws"}},` +
+			`"message":{"message_id":"om_2","chat_id":"oc_ws","message_type":"text","content":"{\"text\":\"second\"}"}}}`,
+	}
+
+	acked := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		ctx := r.Context()
+		for i, event := range events {
+			frame := feishuFrame{
+				Method:  feishuFrameData,
+				Headers: []feishuFrameHeader{{Key: "type", Value: "event"}, {Key: "message_id", Value: string(rune('a' + i))}},
+				Payload: []byte(event),
+			}
+			if err := conn.Write(ctx, websocket.MessageBinary, frame.encode()); err != nil {
+				return
+			}
+		}
+		for range events {
+			if _, _, err := conn.Read(ctx); err != nil {
+				return
+			}
+		}
+		close(acked)
+		<-ctx.Done()
+	}))
+	defer srv.Close()
+
+	b := bus.NewMessageBus(10)
+	ch, err := NewFeishuChannelWithFactory(config.FeishuConfig{
+		AppID: "cli_test", AppSecret: "secret", Mode: config.FeishuModeWebSocket,
+	}, b, mockFeishuClientFactory(&mockFeishuClient{token: "test-token"}))
+	if err != nil {
+		t.Fatalf("NewFeishuChannelWithFactory: %v", err)
+	}
+	release := make(chan struct{})
+	ch.imageDownloader = func(ctx context.Context, tenantAccessToken, imageKey string) (string, string, error) {
+		<-release
+		return "iVBORw0KGgo=", "image/png", nil
+	}
+	ch.wsEndpoint = func(ctx context.Context, appID, appSecret string) (*feishuWSEndpoint, error) {
+		return &feishuWSEndpoint{URL: "ws" + strings.TrimPrefix(srv.URL, "http")}, nil
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ch.Stop()
+
+	// Both events are acknowledged while the first is still being handled.
+	select {
+	case <-acked:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for acks")
+	}
+	close(release)
+
+	for _, want := range []string{"[image]", "second"} {
+		select {
+		case msg := <-b.Inbound:
+			if msg.Content != want {
+				t.Fatalf("inbound content = %q, want %q", msg.Content, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
+
+func TestFeishuChannel_WebSocketReconnectCount(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close(websocket.StatusGoingAway, "restart")
+	}))
+	defer srv.Close()
+
+	ch, err := NewFeishuChannelWithFactory(config.FeishuConfig{
+		AppID: "cli_test", AppSecret: "secret", Mode: config.FeishuModeWebSocket,
+	}, bus.NewMessageBus(1), mockFeishuClientFactory(&mockFeishuClient{}))
+	if err != nil {
+		t.Fatalf("NewFeishuChannelWithFactory: %v", err)
+	}
+	ch.wsBackoff = time.Millisecond
+	// Only the first endpoint request succeeds; every reconnect then fails.
+	var calls atomic.Int32
+	ch.wsEndpoint = func(ctx context.Context, appID, appSecret string) (*feishuWSEndpoint, error) {
+		if calls.Add(1) > 1 {
+			return nil, errors.New("endpoint unavailable")
+		}
+		return &feishuWSEndpoint{
+			URL:          "ws" + strings.TrimPrefix(srv.URL, "http"),
+			ClientConfig: feishuWSClientConfig{ReconnectCount: 2},
+		}, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ch.runWebSocket(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("runWebSocket kept reconnecting past ReconnectCount")
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("endpoint requests = %d, want 3", n)
+	}
+}
+
+func TestFeishuReconnectDelay(t *testing.T) {
+	backoff := 5 * time.Millisecond
+	if got := feishuReconnectDelay(feishuWSClientConfig{}, 1, backoff); got != backoff {
+		t.Errorf("no server config: delay = %s, want %s", got, backoff)
+	}
+	cfg := feishuWSClientConfig{ReconnectInterval: 120, ReconnectNonce: 30}
+	if got := feishuReconnectDelay(cfg, 1, backoff); got < 0 || got >= 30*time.Second {
+		t.Errorf("first attempt: delay = %s, want within the 30s nonce", got)
+	}
+	if got := feishuReconnectDelay(cfg, 2, backoff); got != 120*time.Second {
+		t.Errorf("later attempt: delay = %s, want 2m0s", got)
+	}
+}
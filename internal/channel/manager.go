@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"sort"
 	"sync"
 
 	"github.com/stellarlinkco/myclaw/internal/bus"
@@ -11,8 +13,21 @@ import (
 )
 
 type ChannelManager struct {
-	channels map[string]Channel
-	bus      *bus.MessageBus
+	channels     map[string]Channel
+	httpChannels []HTTPChannel
+	bus          *bus.MessageBus
+
+	mu      sync.Mutex
+	running map[string]bool
+	errors  map[string]string // last Start error per channel
+}
+
+// ChannelStatus is a channel's state as shown on the admin dashboard.
+type ChannelStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
 }
 
 func NewChannelManager(cfg config.ChannelsConfig, b *bus.MessageBus) (*ChannelManager, error) {
@@ -26,7 +41,11 @@ func NewChannelManager(cfg config.ChannelsConfig, b *bus.MessageBus) (*ChannelMa
 		if err != nil {
 			return nil, fmt.Errorf("init telegram channel: %w", err)
 		}
+		ch.SetTranscriber(NewTranscriber(cfg.Transcription))
 		m.channels[ch.Name()] = ch
+		if ch.WebhookEnabled() {
+			m.httpChannels = append(m.httpChannels, ch)
+		}
 		b.SubscribeOutbound(ch.Name(), func(msg bus.OutboundMessage) {
 			if err := ch.Send(msg); err != nil {
 				log.Printf("[channel-mgr] send to %s failed: %v", ch.Name(), err)
@@ -39,6 +58,7 @@ func NewChannelManager(cfg config.ChannelsConfig, b *bus.MessageBus) (*ChannelMa
 		if err != nil {
 			return nil, fmt.Errorf("init feishu channel: %w", err)
 		}
+		ch.SetTranscriber(NewTranscriber(cfg.Transcription))
 		m.channels[ch.Name()] = ch
 		b.SubscribeOutbound(ch.Name(), func(msg bus.OutboundMessage) {
 			if err := ch.Send(msg); err != nil {
@@ -65,6 +85,7 @@ func NewChannelManager(cfg config.ChannelsConfig, b *bus.MessageBus) (*ChannelMa
 		if err != nil {
 			return nil, fmt.Errorf("create whatsapp channel: %w", err)
 		}
+		ch.SetTranscriber(NewTranscriber(cfg.Transcription))
 		m.channels[ch.Name()] = ch
 		b.SubscribeOutbound(ch.Name(), func(msg bus.OutboundMessage) {
 			if err := ch.Send(msg); err != nil {
@@ -87,7 +108,11 @@ func NewChannelManagerWithGateway(cfg config.ChannelsConfig, gwCfg config.Gatewa
 		if err != nil {
 			return nil, fmt.Errorf("init webui channel: %w", err)
 		}
+		if wa, ok := m.channels[whatsappChannelName].(*WhatsAppChannel); ok {
+			ch.SetWhatsAppLogin(wa.LoginState)
+		}
 		m.channels[ch.Name()] = ch
+		m.httpChannels = append(m.httpChannels, ch)
 		b.SubscribeOutbound(ch.Name(), func(msg bus.OutboundMessage) {
 			if err := ch.Send(msg); err != nil {
 				log.Printf("[channel-mgr] send to %s failed: %v", ch.Name(), err)
@@ -107,7 +132,9 @@ func (m *ChannelManager) StartAll(ctx context.Context) error {
 		go func(name string, ch Channel) {
 			defer wg.Done()
 			log.Printf("[channel-mgr] starting %s", name)
-			if err := ch.Start(ctx); err != nil {
+			err := ch.Start(ctx)
+			m.setRunning(name, err)
+			if err != nil {
 				errCh <- fmt.Errorf("%s: %w", name, err)
 			}
 		}(name, ch)
@@ -128,10 +155,101 @@ func (m *ChannelManager) StopAll() error {
 		if err := ch.Stop(); err != nil {
 			log.Printf("[channel-mgr] error stopping %s: %v", name, err)
 		}
+		m.mu.Lock()
+		delete(m.running, name)
+		m.mu.Unlock()
 	}
 	return nil
 }
 
+func (m *ChannelManager) setRunning(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running == nil {
+		m.running = make(map[string]bool)
+		m.errors = make(map[string]string)
+	}
+	m.running[name] = err == nil
+	if err != nil {
+		m.errors[name] = err.Error()
+	} else {
+		delete(m.errors, name)
+	}
+}
+
+// Status reports every enabled channel, sorted by name.
+func (m *ChannelManager) Status() []ChannelStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]ChannelStatus, 0, len(m.channels))
+	for name, ch := range m.channels {
+		st := ChannelStatus{Name: name, Running: m.running[name], Detail: m.errors[name]}
+		switch hc, ok := ch.(HealthChannel); {
+		case !st.Running:
+			if st.Detail == "" {
+				st.Detail = "not running"
+			}
+		case ok:
+			h := hc.Health()
+			st.Healthy, st.Detail = h.Healthy, h.Detail
+		default:
+			st.Healthy = true
+		}
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// HTTPChannels returns the channels that serve endpoints on the gateway HTTP server.
+func (m *ChannelManager) HTTPChannels() []HTTPChannel {
+	return m.httpChannels
+}
+
+// SupportsButtons reports whether the named channel can render inline buttons.
+func (m *ChannelManager) SupportsButtons(name string) bool {
+	ch, ok := m.channels[name].(InteractiveChannel)
+	return ok && ch.SupportsButtons()
+}
+
+// AckCallback tells the channel a press came from whether the gateway accepted it.
+func (m *ChannelManager) AckCallback(msg bus.CallbackMessage, accepted bool) {
+	if ch, ok := m.channels[msg.Channel].(InteractiveChannel); ok {
+		ch.AckCallback(msg, accepted)
+	}
+}
+
+// SupportsStreaming reports whether the named channel renders partial replies.
+func (m *ChannelManager) SupportsStreaming(name string) bool {
+	ch, ok := m.channels[name].(StreamingChannel)
+	return ok && ch.SupportsStreaming()
+}
+
+// EndRun tells the named channel that the agent run for chatID has ended.
+func (m *ChannelManager) EndRun(name, chatID string) {
+	if ch, ok := m.channels[name].(RunEndChannel); ok {
+		ch.EndRun(chatID)
+	}
+}
+
+// SetHistory lets channels that replay conversations read the agent history.
+func (m *ChannelManager) SetHistory(fn HistoryFunc) {
+	for _, ch := range m.channels {
+		if hc, ok := ch.(HistoryChannel); ok {
+			hc.SetHistory(fn)
+		}
+	}
+}
+
+// SetAdmin hands the admin API to the channels that serve it.
+func (m *ChannelManager) SetAdmin(h http.Handler) {
+	for _, ch := range m.channels {
+		if ac, ok := ch.(AdminChannel); ok {
+			ac.SetAdmin(h)
+		}
+	}
+}
+
 func (m *ChannelManager) EnabledChannels() []string {
 	names := make([]string, 0, len(m.channels))
 	for name := range m.channels {
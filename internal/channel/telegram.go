@@ -2,7 +2,11 @@ package channel
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -24,6 +28,14 @@ const (
 	telegramLongPollTimeoutSeconds = 30
 	telegramHTTPTimeout            = 70 * time.Second
 	telegramFileDownloadTimeout    = 30 * time.Second
+	telegramTranscribeTimeout      = 90 * time.Second
+
+	telegramWebhookPath         = "/telegram/webhook"
+	telegramSecretTokenHeader   = "X-Telegram-Bot-Api-Secret-Token"
+	telegramWebhookMaxBodyBytes = 1 << 20 // 1MB
+	// telegramWebhookQueueSize bounds the updates waiting to be handled;
+	// Telegram retries the ones refused while it is full.
+	telegramWebhookQueueSize = 64
 )
 
 // TelegramBot interface for mocking telegram bot API
@@ -31,8 +43,11 @@ type TelegramBot interface {
 	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
 	StopReceivingUpdates()
 	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
 	GetSelf() tgbotapi.User
 	GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error)
+	SetWebhook(webhookURL, secretToken string) error
+	DeleteWebhook() error
 }
 
 // tgBotWrapper wraps tgbotapi.BotAPI to implement TelegramBot interface
@@ -52,6 +67,10 @@ func (w *tgBotWrapper) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return w.bot.Send(c)
 }
 
+func (w *tgBotWrapper) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return w.bot.Request(c)
+}
+
 func (w *tgBotWrapper) GetSelf() tgbotapi.User {
 	return w.bot.Self
 }
@@ -60,6 +79,19 @@ func (w *tgBotWrapper) GetFile(config tgbotapi.FileConfig) (tgbotapi.File, error
 	return w.bot.GetFile(config)
 }
 
+// SetWebhook calls setWebhook directly: tgbotapi v5.5.1 has no secret_token field.
+func (w *tgBotWrapper) SetWebhook(webhookURL, secretToken string) error {
+	params := tgbotapi.Params{"url": webhookURL}
+	params.AddNonEmpty("secret_token", secretToken)
+	_, err := w.bot.MakeRequest("setWebhook", params)
+	return err
+}
+
+func (w *tgBotWrapper) DeleteWebhook() error {
+	_, err := w.bot.Request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
+
 // BotFactory creates TelegramBot instances (allows mocking)
 type BotFactory func(token, apiEndpoint string, client *http.Client) (TelegramBot, error)
 
@@ -74,12 +106,22 @@ var defaultBotFactory BotFactory = func(token, apiEndpoint string, client *http.
 
 type TelegramChannel struct {
 	BaseChannel
-	token      string
-	bot        TelegramBot
-	proxy      string
-	httpClient *http.Client
-	cancel     context.CancelFunc
-	botFactory BotFactory
+	token         string
+	bot           TelegramBot
+	proxy         string
+	webhookURL    string
+	webhookSecret string
+	transcriber   Transcriber
+	httpClient    *http.Client
+	cancel        context.CancelFunc
+	botFactory    BotFactory
+	// Webhook updates are handled off the request, which Telegram retries
+	// if it is not answered quickly; seenUpdates drops the retries.
+	webhookUpdates chan tgbotapi.Update
+	seenUpdates    *msgDedupCache
+	// pressedPrompts holds "chatID:messageID" of prompts with a press in
+	// flight or accepted, so further presses on them are not forwarded.
+	pressedPrompts *msgDedupCache
 }
 
 func NewTelegramChannel(cfg config.TelegramConfig, b *bus.MessageBus) (*TelegramChannel, error) {
@@ -93,13 +135,18 @@ func NewTelegramChannelWithFactory(cfg config.TelegramConfig, b *bus.MessageBus,
 	}
 
 	ch := &TelegramChannel{
-		BaseChannel: NewBaseChannel(telegramChannelName, b, cfg.AllowFrom),
-		token:       cfg.Token,
-		proxy:       cfg.Proxy,
+		BaseChannel:   NewBaseChannel(telegramChannelName, b, cfg.AllowFrom),
+		token:         cfg.Token,
+		proxy:         cfg.Proxy,
+		webhookURL:    strings.TrimSpace(cfg.WebhookURL),
+		webhookSecret: strings.TrimSpace(cfg.WebhookSecret),
 		httpClient: &http.Client{
 			Timeout: telegramHTTPTimeout,
 		},
-		botFactory: factory,
+		botFactory:     factory,
+		webhookUpdates: make(chan tgbotapi.Update, telegramWebhookQueueSize),
+		seenUpdates:    newMsgDedupCache(msgDedupDefaultTTL),
+		pressedPrompts: newMsgDedupCache(msgDedupDefaultTTL),
 	}
 	return ch, nil
 }
@@ -136,6 +183,15 @@ func (t *TelegramChannel) Start(ctx context.Context) error {
 
 	ctx, t.cancel = context.WithCancel(ctx)
 
+	if t.WebhookEnabled() {
+		return t.startWebhook(ctx)
+	}
+
+	// A webhook left over from an earlier webhook-mode run blocks getUpdates.
+	if err := t.bot.DeleteWebhook(); err != nil {
+		log.Printf("[telegram] delete webhook warning: %v", err)
+	}
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = telegramLongPollTimeoutSeconds
 	updates := t.bot.GetUpdatesChan(u)
@@ -144,10 +200,7 @@ func (t *TelegramChannel) Start(ctx context.Context) error {
 		for {
 			select {
 			case update := <-updates:
-				if update.Message == nil {
-					continue
-				}
-				t.handleMessage(update.Message)
+				t.handleUpdate(update)
 			case <-ctx.Done():
 				return
 			}
@@ -158,6 +211,172 @@ func (t *TelegramChannel) Start(ctx context.Context) error {
 	return nil
 }
 
+// WebhookEnabled reports whether updates are received via webhook instead of polling.
+func (t *TelegramChannel) WebhookEnabled() bool {
+	return t.webhookURL != ""
+}
+
+// Attach registers the webhook endpoint on the gateway HTTP server.
+func (t *TelegramChannel) Attach(mux *http.ServeMux) error {
+	mux.HandleFunc(telegramWebhookPath, t.handleWebhook)
+	return nil
+}
+
+func (t *TelegramChannel) startWebhook(ctx context.Context) error {
+	if t.webhookSecret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("generate webhook secret: %w", err)
+		}
+		t.webhookSecret = hex.EncodeToString(buf)
+	}
+
+	hookURL := strings.TrimRight(t.webhookURL, "/") + telegramWebhookPath
+	if err := t.bot.SetWebhook(hookURL, t.webhookSecret); err != nil {
+		return fmt.Errorf("set telegram webhook: %w", err)
+	}
+	log.Printf("[telegram] webhook registered at %s", hookURL)
+
+	go func() {
+		for {
+			select {
+			case update := <-t.webhookUpdates:
+				t.handleUpdate(update)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (t *TelegramChannel) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if t.bot == nil || t.webhookSecret == "" {
+		http.Error(w, "telegram channel not started", http.StatusServiceUnavailable)
+		return
+	}
+
+	got := r.Header.Get(telegramSecretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(t.webhookSecret)) != 1 {
+		http.Error(w, "invalid secret token", http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, telegramWebhookMaxBodyBytes)).Decode(&update); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	key := strconv.Itoa(update.UpdateID)
+	if t.seenUpdates.Seen(key) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	select {
+	case t.webhookUpdates <- update:
+	default:
+		t.seenUpdates.Forget(key)
+		http.Error(w, "too many pending updates", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *TelegramChannel) handleUpdate(update tgbotapi.Update) {
+	switch {
+	case update.Message != nil:
+		t.handleMessage(update.Message)
+	case update.CallbackQuery != nil:
+		t.handleCallback(update.CallbackQuery)
+	}
+}
+
+// SupportsButtons reports that outbound Buttons are rendered as an inline keyboard.
+func (t *TelegramChannel) SupportsButtons() bool {
+	return true
+}
+
+// handleCallback acknowledges an inline keyboard press and forwards it. Only
+// one press per prompt is forwarded at a time; the gateway's AckCallback
+// either marks the prompt answered or frees it for another press.
+func (t *TelegramChannel) handleCallback(q *tgbotapi.CallbackQuery) {
+	if q.From == nil || q.Message == nil || q.Message.Chat == nil {
+		log.Printf("[telegram] dropped invalid callback: missing sender or message")
+		return
+	}
+
+	senderID := strconv.FormatInt(q.From.ID, 10)
+	if !t.IsAllowed(senderID) {
+		log.Printf("[telegram] rejected callback from %s (%s)", senderID, q.From.UserName)
+		t.answerCallback(q.ID, telegramCallbackRejectedReply)
+		return
+	}
+	chatID := strconv.FormatInt(q.Message.Chat.ID, 10)
+	messageID := strconv.Itoa(q.Message.MessageID)
+	if t.pressedPrompts.Seen(chatID + ":" + messageID) {
+		t.answerCallback(q.ID, telegramCallbackAnsweredReply)
+		return
+	}
+	t.answerCallback(q.ID, "")
+
+	label := q.Data
+	if markup := q.Message.ReplyMarkup; markup != nil {
+		for _, row := range markup.InlineKeyboard {
+			for _, btn := range row {
+				if btn.CallbackData != nil && *btn.CallbackData == q.Data {
+					label = btn.Text
+				}
+			}
+		}
+	}
+
+	t.bus.Callbacks <- bus.CallbackMessage{
+		Channel:   telegramChannelName,
+		SenderID:  senderID,
+		ChatID:    chatID,
+		MessageID: messageID,
+		Data:      q.Data,
+		Metadata:  map[string]any{"text": q.Message.Text, "label": label},
+	}
+}
+
+// AckCallback replaces the keyboard of an answered prompt with the chosen
+// label. An ignored press frees the prompt for the next one.
+func (t *TelegramChannel) AckCallback(msg bus.CallbackMessage, accepted bool) {
+	if !accepted {
+		t.pressedPrompts.Forget(msg.ChatID + ":" + msg.MessageID)
+		return
+	}
+	chatID, err := strconv.ParseInt(msg.ChatID, 10, 64)
+	if err != nil {
+		return
+	}
+	messageID, err := strconv.Atoi(msg.MessageID)
+	if err != nil || t.bot == nil {
+		return
+	}
+	text, _ := msg.Metadata["text"].(string)
+	label, _ := msg.Metadata["label"].(string)
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text+"\n\n→ "+label)
+	if _, err := t.bot.Request(edit); err != nil {
+		log.Printf("[telegram] edit callback message failed: %v", err)
+	}
+}
+
+func (t *TelegramChannel) answerCallback(id, text string) {
+	if t.bot == nil {
+		return
+	}
+	if _, err := t.bot.Request(tgbotapi.NewCallback(id, text)); err != nil {
+		log.Printf("[telegram] answer callback failed: %v", err)
+	}
+}
+
 func (t *TelegramChannel) handleMessage(msg *tgbotapi.Message) {
 	if msg == nil || msg.From == nil || msg.Chat == nil {
 		log.Printf("[telegram] dropped invalid message: missing sender or chat")
@@ -177,26 +396,19 @@ func (t *TelegramChannel) handleMessage(msg *tgbotapi.Message) {
 	}
 
 	contentBlocks := make([]model.ContentBlock, 0, 2)
+	var notes []string
 
 	if len(msg.Photo) > 0 {
 		photo := msg.Photo[len(msg.Photo)-1]
-		data, err := t.downloadFileData(photo.FileID)
-		if err != nil {
+		if block, err := t.imageBlock(photo.FileID); err != nil {
 			log.Printf("[telegram] download photo %s failed: %v", photo.FileID, err)
 		} else {
-			mediaType := http.DetectContentType(data)
-			if mediaType == "application/octet-stream" {
-				mediaType = "image/jpeg"
-			}
-			contentBlocks = append(contentBlocks, model.ContentBlock{
-				Type:      model.ContentBlockImage,
-				MediaType: mediaType,
-				Data:      base64.StdEncoding.EncodeToString(data),
-			})
+			contentBlocks = append(contentBlocks, block)
 		}
 	}
 
-	if msg.Document != nil {
+	// Telegram sets Document alongside Animation; the animation branch handles both.
+	if msg.Document != nil && msg.Animation == nil {
 		data, err := t.downloadFileData(msg.Document.FileID)
 		if err != nil {
 			log.Printf("[telegram] download document %s failed: %v", msg.Document.FileID, err)
@@ -221,7 +433,55 @@ func (t *TelegramChannel) handleMessage(msg *tgbotapi.Message) {
 		}
 	}
 
+	if msg.Voice != nil || msg.Audio != nil {
+		transcript, err := t.transcribeAudio(msg)
+		if err != nil {
+			log.Printf("[telegram] transcribe audio from %s failed: %v", senderID, err)
+			t.reply(msg, telegramTranscriptionFailedReply)
+			return
+		}
+		if transcript == "" {
+			t.reply(msg, telegramTranscriptionUnavailableReply)
+			return
+		}
+		notes = append(notes, "[Voice message transcript]\n"+transcript)
+	}
+
+	if msg.Video != nil || msg.VideoNote != nil || msg.Animation != nil {
+		note, thumb := describeTelegramVideo(msg)
+		notes = append(notes, note)
+		if thumb != nil {
+			if block, err := t.imageBlock(thumb.FileID); err != nil {
+				log.Printf("[telegram] download video thumbnail %s failed: %v", thumb.FileID, err)
+			} else {
+				contentBlocks = append(contentBlocks, block)
+			}
+		}
+	}
+
+	if msg.Sticker != nil {
+		notes = append(notes, strings.TrimSpace("[Sticker] "+msg.Sticker.Emoji))
+		if block, ok := t.stickerBlock(msg.Sticker); ok {
+			contentBlocks = append(contentBlocks, block)
+		}
+	}
+
+	if note := describeTelegramStructured(msg); note != "" {
+		notes = append(notes, note)
+	}
+
+	if len(notes) > 0 {
+		if content != "" {
+			notes = append(notes, content)
+		}
+		content = strings.Join(notes, "\n\n")
+	}
+
 	if content == "" && len(contentBlocks) == 0 {
+		// Service messages in groups are expected to be silent; only answer direct chats.
+		if msg.Chat.IsPrivate() {
+			t.reply(msg, telegramUnsupportedReply)
+		}
 		return
 	}
 
@@ -238,10 +498,159 @@ func (t *TelegramChannel) handleMessage(msg *tgbotapi.Message) {
 			"username":   msg.From.UserName,
 			"first_name": msg.From.FirstName,
 			"message_id": msg.MessageID,
+			"is_group":   !msg.Chat.IsPrivate(),
 		},
 	}
 }
 
+const (
+	telegramUnsupportedReply              = "Sorry, I can't handle this type of message yet. Please send text, photos, documents, voice notes or locations."
+	telegramTranscriptionUnavailableReply = "Sorry, voice messages need speech-to-text, which is not configured. Please send text instead."
+	telegramTranscriptionFailedReply      = "Sorry, I couldn't transcribe that voice message. Please try again or send text."
+	telegramCallbackRejectedReply         = "You are not allowed to answer this."
+	telegramCallbackAnsweredReply         = "This has already been answered."
+)
+
+// SetTranscriber sets the speech-to-text backend for voice and audio messages.
+func (t *TelegramChannel) SetTranscriber(tr Transcriber) {
+	t.transcriber = tr
+}
+
+// transcribeAudio returns "" without error when no transcriber is configured.
+func (t *TelegramChannel) transcribeAudio(msg *tgbotapi.Message) (string, error) {
+	if t.transcriber == nil {
+		return "", nil
+	}
+
+	fileID, filename := "", ""
+	if msg.Voice != nil {
+		fileID, filename = msg.Voice.FileID, "voice.ogg"
+	} else {
+		fileID, filename = msg.Audio.FileID, msg.Audio.FileName
+		if filename == "" {
+			filename = "audio.mp3"
+		}
+	}
+
+	data, err := t.downloadFileData(fileID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), telegramTranscribeTimeout)
+	defer cancel()
+	text, err := t.transcriber.Transcribe(ctx, filename, data)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "", fmt.Errorf("empty transcript")
+	}
+	return text, nil
+}
+
+func (t *TelegramChannel) imageBlock(fileID string) (model.ContentBlock, error) {
+	data, err := t.downloadFileData(fileID)
+	if err != nil {
+		return model.ContentBlock{}, err
+	}
+	mediaType := http.DetectContentType(data)
+	if mediaType == "application/octet-stream" {
+		mediaType = "image/jpeg"
+	}
+	return model.ContentBlock{
+		Type:      model.ContentBlockImage,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// stickerBlock uses the sticker itself when it is a static image, otherwise its thumbnail.
+func (t *TelegramChannel) stickerBlock(sticker *tgbotapi.Sticker) (model.ContentBlock, bool) {
+	if !sticker.IsAnimated {
+		data, err := t.downloadFileData(sticker.FileID)
+		if err != nil {
+			log.Printf("[telegram] download sticker %s failed: %v", sticker.FileID, err)
+		} else if mediaType := http.DetectContentType(data); strings.HasPrefix(mediaType, "image/") {
+			return model.ContentBlock{
+				Type:      model.ContentBlockImage,
+				MediaType: mediaType,
+				Data:      base64.StdEncoding.EncodeToString(data),
+			}, true
+		}
+	}
+	if sticker.Thumbnail == nil {
+		return model.ContentBlock{}, false
+	}
+	block, err := t.imageBlock(sticker.Thumbnail.FileID)
+	if err != nil {
+		log.Printf("[telegram] download sticker thumbnail %s failed: %v", sticker.Thumbnail.FileID, err)
+		return model.ContentBlock{}, false
+	}
+	return block, true
+}
+
+// describeTelegramVideo returns a text note and the thumbnail frame to attach, if any.
+func describeTelegramVideo(msg *tgbotapi.Message) (string, *tgbotapi.PhotoSize) {
+	switch {
+	case msg.Video != nil:
+		return fmt.Sprintf("[Video] duration=%ds %dx%d (thumbnail frame attached when available)", msg.Video.Duration, msg.Video.Width, msg.Video.Height), msg.Video.Thumbnail
+	case msg.VideoNote != nil:
+		return fmt.Sprintf("[Video note] duration=%ds (thumbnail frame attached when available)", msg.VideoNote.Duration), msg.VideoNote.Thumbnail
+	default:
+		return fmt.Sprintf("[Animation] duration=%ds (thumbnail frame attached when available)", msg.Animation.Duration), msg.Animation.Thumbnail
+	}
+}
+
+// describeTelegramStructured renders locations, venues, contacts and polls as text.
+func describeTelegramStructured(msg *tgbotapi.Message) string {
+	switch {
+	case msg.Venue != nil:
+		v := msg.Venue
+		return fmt.Sprintf("[Venue] %s\naddress: %s\nlatitude: %.6f\nlongitude: %.6f", v.Title, v.Address, v.Location.Latitude, v.Location.Longitude)
+	case msg.Location != nil:
+		l := msg.Location
+		text := fmt.Sprintf("[Location]\nlatitude: %.6f\nlongitude: %.6f", l.Latitude, l.Longitude)
+		if l.HorizontalAccuracy > 0 {
+			text += fmt.Sprintf("\naccuracy: %.0fm", l.HorizontalAccuracy)
+		}
+		if l.LivePeriod > 0 {
+			text += fmt.Sprintf("\nlive for: %ds", l.LivePeriod)
+		}
+		return text
+	case msg.Contact != nil:
+		c := msg.Contact
+		text := "[Contact] " + strings.TrimSpace(c.FirstName+" "+c.LastName)
+		if c.PhoneNumber != "" {
+			text += "\nphone: " + c.PhoneNumber
+		}
+		if c.UserID != 0 {
+			text += "\ntelegram user id: " + strconv.FormatInt(c.UserID, 10)
+		}
+		return text
+	case msg.Poll != nil:
+		p := msg.Poll
+		var sb strings.Builder
+		sb.WriteString("[Poll] " + p.Question)
+		for _, opt := range p.Options {
+			fmt.Fprintf(&sb, "\n- %s (%d votes)", opt.Text, opt.VoterCount)
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+func (t *TelegramChannel) reply(msg *tgbotapi.Message, text string) {
+	if t.bot == nil {
+		return
+	}
+	reply := tgbotapi.NewMessage(msg.Chat.ID, text)
+	reply.ReplyToMessageID = msg.MessageID
+	if _, err := t.bot.Send(reply); err != nil {
+		log.Printf("[telegram] send reply failed: %v", err)
+	}
+}
+
 func (t *TelegramChannel) downloadFileData(fileID string) ([]byte, error) {
 	if t.bot == nil {
 		return nil, fmt.Errorf("telegram bot not initialized")
@@ -291,7 +700,7 @@ func (t *TelegramChannel) Stop() error {
 	if t.cancel != nil {
 		t.cancel()
 	}
-	if t.bot != nil {
+	if t.bot != nil && !t.WebhookEnabled() {
 		t.bot.StopReceivingUpdates()
 	}
 	log.Printf("[telegram] stopped")
@@ -332,6 +741,9 @@ func (t *TelegramChannel) Send(msg bus.OutboundMessage) error {
 
 		tgMsg := tgbotapi.NewMessage(chatID, chunk)
 		tgMsg.ParseMode = tgbotapi.ModeHTML
+		if content == "" && len(msg.Buttons) > 0 {
+			tgMsg.ReplyMarkup = telegramKeyboard(msg.Buttons)
+		}
 		if _, err := t.bot.Send(tgMsg); err != nil {
 			// Retry without HTML parse mode
 			tgMsg.ParseMode = ""
@@ -345,6 +757,18 @@ func (t *TelegramChannel) Send(msg bus.OutboundMessage) error {
 	return nil
 }
 
+func telegramKeyboard(rows [][]bus.Button) tgbotapi.InlineKeyboardMarkup {
+	keyboard := make([][]tgbotapi.InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(b.Text, b.Data))
+		}
+		keyboard = append(keyboard, buttons)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
 // toTelegramHTML converts basic markdown to Telegram HTML.
 func toTelegramHTML(s string) string {
 	// Escape HTML entities first
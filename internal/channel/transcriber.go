@@ -0,0 +1,114 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+const (
+	defaultTranscriptionBaseURL = "https://api.openai.com"
+	defaultTranscriptionModel   = "whisper-1"
+)
+
+// Transcriber turns audio into text for channels that receive voice messages.
+type Transcriber interface {
+	Transcribe(ctx context.Context, filename string, audio []byte) (string, error)
+}
+
+// openAITranscriber calls an OpenAI-compatible /v1/audio/transcriptions endpoint.
+type openAITranscriber struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	language   string
+	httpClient *http.Client
+}
+
+// NewTranscriber returns nil when transcription is disabled.
+func NewTranscriber(cfg config.TranscriptionConfig) Transcriber {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	t := &openAITranscriber{
+		baseURL:    strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/"),
+		apiKey:     strings.TrimSpace(cfg.APIKey),
+		model:      strings.TrimSpace(cfg.Model),
+		language:   strings.TrimSpace(cfg.Language),
+		httpClient: &http.Client{Timeout: time.Duration(config.DefaultTranscriptionTimeoutMs) * time.Millisecond},
+	}
+	if t.baseURL == "" {
+		t.baseURL = defaultTranscriptionBaseURL
+	}
+	if t.model == "" {
+		t.model = defaultTranscriptionModel
+	}
+	if cfg.TimeoutMs > 0 {
+		t.httpClient.Timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	return t
+}
+
+func (t *openAITranscriber) Transcribe(ctx context.Context, filename string, audio []byte) (string, error) {
+	if len(audio) == 0 {
+		return "", fmt.Errorf("transcribe: empty audio")
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: create form file: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", fmt.Errorf("transcribe: write audio: %w", err)
+	}
+	_ = mw.WriteField("model", t.model)
+	_ = mw.WriteField("response_format", "json")
+	if t.language != "" {
+		_ = mw.WriteField("language", t.language)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("transcribe: close form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/v1/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("transcribe: http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var decoded struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &decoded); err != nil {
+		return "", fmt.Errorf("transcribe: decode response: %w", err)
+	}
+	return strings.TrimSpace(decoded.Text), nil
+}
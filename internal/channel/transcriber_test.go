@@ -0,0 +1,81 @@
+package channel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+func TestNewTranscriber_Disabled(t *testing.T) {
+	if tr := NewTranscriber(config.TranscriptionConfig{}); tr != nil {
+		t.Fatalf("NewTranscriber(disabled) = %#v, want nil", tr)
+	}
+}
+
+func TestOpenAITranscriber_Transcribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer stt-key" {
+			t.Errorf("authorization = %q", got)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("parse multipart: %v", err)
+		}
+		if r.FormValue("model") != "whisper-1" {
+			t.Errorf("model = %q, want whisper-1", r.FormValue("model"))
+		}
+		if r.FormValue("language") != "zh" {
+			t.Errorf("language = %q, want zh", r.FormValue("language"))
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("form file: %v", err)
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		if header.Filename != "voice.ogg" || string(data) != "OggS-audio" {
+			t.Errorf("file = %q (%q)", header.Filename, data)
+		}
+		_, _ = w.Write([]byte(`{"text":"  hello there  "}`))
+	}))
+	defer srv.Close()
+
+	tr := NewTranscriber(config.TranscriptionConfig{
+		Enabled:  true,
+		BaseURL:  srv.URL + "/",
+		APIKey:   "stt-key",
+		Language: "zh",
+	})
+
+	text, err := tr.Transcribe(context.Background(), "voice.ogg", []byte("OggS-audio"))
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if text != "hello there" {
+		t.Errorf("text = %q, want 'hello there'", text)
+	}
+}
+
+func TestOpenAITranscriber_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	tr := NewTranscriber(config.TranscriptionConfig{Enabled: true, BaseURL: srv.URL})
+
+	if _, err := tr.Transcribe(context.Background(), "a.ogg", nil); err == nil {
+		t.Error("expected error for empty audio")
+	}
+	_, err := tr.Transcribe(context.Background(), "a.ogg", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "http 429") {
+		t.Errorf("err = %v, want http 429", err)
+	}
+}
@@ -2,12 +2,16 @@ package channel
 
 import (
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +19,7 @@ import (
 	"github.com/coder/websocket"
 	"github.com/stellarlinkco/myclaw/internal/bus"
 	"github.com/stellarlinkco/myclaw/internal/config"
+	"rsc.io/qr"
 )
 
 //go:embed static
@@ -22,22 +27,67 @@ var staticFiles embed.FS
 
 const webUIChannelName = "webui"
 
+// wsMessage is a websocket frame. The browser sends "message", "open",
+// "rename" and "delete"; the server sends "message", "history" and
+// "conversations".
 type wsMessage struct {
 	Type    string `json:"type"`
 	Content string `json:"content,omitempty"`
+	// Session is the conversation the frame belongs to.
+	Session       string              `json:"session,omitempty"`
+	Title         string              `json:"title,omitempty"`
+	Messages      []HistoryEntry      `json:"messages,omitempty"`
+	Conversations []webUIConversation `json:"conversations,omitempty"`
+	// Attachments are upload IDs returned by POST /api/uploads.
+	Attachments []string `json:"attachments,omitempty"`
 }
 
 type wsClient struct {
 	conn *websocket.Conn
 	id   string
+	// owner is the user's ID, or the browser's client ID when auth is off.
+	owner string
+
+	mu           sync.Mutex
+	conversation string
+}
+
+func (c *wsClient) currentConversation() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conversation
+}
+
+func (c *wsClient) chatID() string {
+	return webUIChatID(c.owner, c.currentConversation())
 }
 
+func (c *wsClient) setConversation(id string) {
+	c.mu.Lock()
+	c.conversation = id
+	c.mu.Unlock()
+}
+
+type webUIIdentityKey struct{}
+
 type WebUIChannel struct {
 	BaseChannel
-	port    int
-	server  *http.Server
-	clients sync.Map
-	nextID  atomic.Int64
+	port     int
+	server   *http.Server
+	attached bool // routes served by the gateway HTTP server
+	clients  sync.Map
+	nextID   atomic.Int64
+	auth     *webUIAuth // nil when the Web UI is open to anyone
+	// conversations lists each user's named chats; history replays their turns.
+	conversations *webUIConversationStore
+	history       HistoryFunc
+	uploads       *webUIUploadStore
+	maxUpload     int64 // bytes per file
+	// admin serves /api/admin/ to logged-in admins; admins empty means every allowed user.
+	admin  http.Handler
+	admins map[string]bool
+	// whatsappLogin reports the WhatsApp login progress when that channel is enabled.
+	whatsappLogin func() WhatsAppLoginState
 }
 
 const (
@@ -53,22 +103,221 @@ func NewWebUIChannel(cfg config.WebUIConfig, gwCfg config.GatewayConfig, b *bus.
 		port = config.DefaultPort
 	}
 
+	auth, err := newWebUIAuth(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	convPath := filepath.Join(config.ConfigDir(), "webui-conversations.json")
+	conversations, err := newWebUIConversationStore(convPath)
+	if err != nil {
+		log.Printf("[webui] %v; starting with an empty conversation list", err)
+		conversations = &webUIConversationStore{path: convPath, byOwner: map[string][]webUIConversation{}, now: time.Now}
+	}
+
+	maxUploadMB := cfg.MaxUploadMB
+	if maxUploadMB <= 0 {
+		maxUploadMB = webUIDefaultMaxUploadMB
+	}
+
 	ch := &WebUIChannel{
-		BaseChannel: NewBaseChannel(webUIChannelName, b, cfg.AllowFrom),
-		port:        port,
+		BaseChannel:   NewBaseChannel(webUIChannelName, b, cfg.AllowFrom),
+		port:          port,
+		auth:          auth,
+		conversations: conversations,
+		uploads:       newWebUIUploadStore(),
+		maxUpload:     int64(maxUploadMB) << 20,
+		admins:        make(map[string]bool, len(cfg.Admins)),
+	}
+	for _, id := range cfg.Admins {
+		ch.admins[id] = true
+	}
+	if auth != nil {
+		auth.allow = ch.allowed
+	} else {
+		log.Printf("[webui] no auth configured; anyone who can reach the gateway can chat")
 	}
 	return ch, nil
 }
 
-func (w *WebUIChannel) Start(ctx context.Context) error {
+// Attach registers the WebUI routes on a shared mux; Start then skips its own listener.
+func (w *WebUIChannel) Attach(mux *http.ServeMux) error {
+	if err := w.registerRoutes(mux); err != nil {
+		return err
+	}
+	w.attached = true
+	return nil
+}
+
+func (w *WebUIChannel) registerRoutes(mux *http.ServeMux) error {
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
 		return fmt.Errorf("embed static fs: %w", err)
 	}
+	mux.Handle("/", w.issueClient(http.FileServer(http.FS(staticFS))))
+	mux.HandleFunc("/ws", w.requireAuth(w.handleWS))
+	mux.HandleFunc("/api/auth/me", w.handleAuthMe)
+	mux.HandleFunc("/api/uploads", w.requireAuth(w.handleUpload))
+	mux.HandleFunc("/api/admin/", w.requireAuth(w.handleAdmin))
+	mux.HandleFunc("/api/whatsapp/login", w.requireAuth(w.handleWhatsAppLogin))
+	mux.HandleFunc("/api/whatsapp/qr.png", w.requireAuth(w.handleWhatsAppQR))
+	if w.auth != nil {
+		w.auth.registerRoutes(mux)
+	}
+	return nil
+}
+
+// allowed applies AllowFrom to the user ID, or to a verified OIDC email.
+func (w *WebUIChannel) allowed(id webUIIdentity) bool {
+	return w.IsAllowed(id.ID) || (id.Email != "" && w.IsAllowed(id.Email))
+}
+
+// requireAuth rejects requests without a valid session when auth is on and
+// passes the user on in the request context.
+func (w *WebUIChannel) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(wr http.ResponseWriter, r *http.Request) {
+		if w.auth == nil {
+			next(wr, r)
+			return
+		}
+		id, ok := w.auth.identify(r)
+		if !ok {
+			http.Error(wr, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !w.allowed(id) {
+			http.Error(wr, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(wr, r.WithContext(context.WithValue(r.Context(), webUIIdentityKey{}, id)))
+	}
+}
+
+// SetAdmin mounts the gateway admin API. It is only served when auth is on.
+func (w *WebUIChannel) SetAdmin(h http.Handler) {
+	w.admin = h
+}
+
+func (w *WebUIChannel) isAdmin(id webUIIdentity) bool {
+	if w.auth == nil || w.admin == nil {
+		return false
+	}
+	return len(w.admins) == 0 || w.admins[id.ID] || (id.Email != "" && w.admins[id.Email])
+}
+
+func (w *WebUIChannel) handleAdmin(wr http.ResponseWriter, r *http.Request) {
+	switch {
+	case w.admin == nil:
+		http.NotFound(wr, r)
+	case w.auth == nil:
+		// Without a login anyone on the network would control the gateway.
+		http.Error(wr, "admin API requires webui auth", http.StatusForbidden)
+	default:
+		id, _ := r.Context().Value(webUIIdentityKey{}).(webUIIdentity)
+		if !w.isAdmin(id) {
+			http.Error(wr, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.admin.ServeHTTP(wr, r)
+	}
+}
+
+// Health reports the number of connected browser tabs.
+func (w *WebUIChannel) Health() ChannelHealth {
+	n := 0
+	w.clients.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return ChannelHealth{Healthy: true, Detail: fmt.Sprintf("%d client(s) connected", n)}
+}
+
+func (w *WebUIChannel) handleAuthMe(wr http.ResponseWriter, r *http.Request) {
+	resp := struct {
+		AuthRequired bool           `json:"authRequired"`
+		Methods      []string       `json:"methods,omitempty"`
+		User         *webUIIdentity `json:"user,omitempty"`
+		Admin        bool           `json:"admin,omitempty"`
+	}{}
+	if w.auth != nil {
+		resp.AuthRequired = true
+		resp.Methods = w.auth.methods()
+		if id, ok := w.auth.identify(r); ok && w.allowed(id) {
+			resp.User = &id
+			resp.Admin = w.isAdmin(id)
+		}
+	}
+	writeWebUIJSON(wr, http.StatusOK, resp)
+}
+
+// SetWhatsAppLogin exposes the WhatsApp QR or pairing code in the Web UI, for
+// gateways running without a terminal.
+func (w *WebUIChannel) SetWhatsAppLogin(fn func() WhatsAppLoginState) {
+	w.whatsappLogin = fn
+}
+
+// whatsappLoginAllowed lets signed-in admins, or any signed-in user when no
+// admins are listed, see the WhatsApp QR or pairing code. Whoever sees it
+// can link their own account as the bot, so it is refused without auth.
+func (w *WebUIChannel) whatsappLoginAllowed(wr http.ResponseWriter, r *http.Request) bool {
+	if w.auth == nil {
+		http.Error(wr, "whatsapp login requires webui auth", http.StatusForbidden)
+		return false
+	}
+	id, _ := r.Context().Value(webUIIdentityKey{}).(webUIIdentity)
+	if len(w.admins) > 0 && !w.admins[id.ID] && (id.Email == "" || !w.admins[id.Email]) {
+		http.Error(wr, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (w *WebUIChannel) handleWhatsAppLogin(wr http.ResponseWriter, r *http.Request) {
+	if w.whatsappLogin == nil {
+		http.NotFound(wr, r)
+		return
+	}
+	if !w.whatsappLoginAllowed(wr, r) {
+		return
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	wr.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(wr).Encode(w.whatsappLogin())
+}
+
+func (w *WebUIChannel) handleWhatsAppQR(wr http.ResponseWriter, r *http.Request) {
+	if w.whatsappLogin == nil {
+		http.NotFound(wr, r)
+		return
+	}
+	if !w.whatsappLoginAllowed(wr, r) {
+		return
+	}
+	state := w.whatsappLogin()
+	if state.Status != WhatsAppLoginQR || state.QRCode == "" {
+		http.NotFound(wr, r)
+		return
+	}
+	code, err := qr.Encode(state.QRCode, qr.L)
+	if err != nil {
+		http.Error(wr, "encode qr code", http.StatusInternalServerError)
+		return
+	}
+	code.Scale = 6
+	wr.Header().Set("Content-Type", "image/png")
+	wr.Header().Set("Cache-Control", "no-store")
+	_, _ = wr.Write(code.PNG())
+}
+
+func (w *WebUIChannel) Start(ctx context.Context) error {
+	if w.attached {
+		return nil
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.FS(staticFS)))
-	mux.HandleFunc("/ws", w.handleWS)
+	if err := w.registerRoutes(mux); err != nil {
+		return err
+	}
 
 	w.server = &http.Server{
 		Addr:              fmt.Sprintf(":%d", w.port),
@@ -89,7 +338,16 @@ func (w *WebUIChannel) Start(ctx context.Context) error {
 	return nil
 }
 
+// SetHistory enables replaying a conversation when the browser reopens it.
+func (w *WebUIChannel) SetHistory(fn HistoryFunc) {
+	w.history = fn
+}
+
 func (w *WebUIChannel) handleWS(wr http.ResponseWriter, r *http.Request) {
+	user, authed := r.Context().Value(webUIIdentityKey{}).(webUIIdentity)
+	if !authed {
+		user = webUIIdentity{ID: w.clientOwner(wr, r)}
+	}
 	conn, err := websocket.Accept(wr, r, nil)
 	if err != nil {
 		log.Printf("[webui] websocket accept error: %v", err)
@@ -97,9 +355,9 @@ func (w *WebUIChannel) handleWS(wr http.ResponseWriter, r *http.Request) {
 	}
 
 	clientID := fmt.Sprintf("webui-%d", w.nextID.Add(1))
-	client := &wsClient{conn: conn, id: clientID}
+	client := &wsClient{conn: conn, id: clientID, owner: user.ID, conversation: webUIDefaultConversation}
 	w.clients.Store(clientID, client)
-	log.Printf("[webui] client connected: %s", clientID)
+	log.Printf("[webui] client connected: %s (%s)", clientID, user.ID)
 
 	defer func() {
 		w.clients.Delete(clientID)
@@ -107,6 +365,8 @@ func (w *WebUIChannel) handleWS(wr http.ResponseWriter, r *http.Request) {
 		log.Printf("[webui] client disconnected: %s", clientID)
 	}()
 
+	w.write(client, wsMessage{Type: "conversations", Conversations: w.conversations.list(user.ID)})
+
 	for {
 		_, data, err := conn.Read(r.Context())
 		if err != nil {
@@ -117,52 +377,177 @@ func (w *WebUIChannel) handleWS(wr http.ResponseWriter, r *http.Request) {
 		if err := json.Unmarshal(data, &msg); err != nil {
 			continue
 		}
-
-		if msg.Type != "message" || msg.Content == "" {
+		if msg.Session != "" && !validConversationID(msg.Session) {
 			continue
 		}
 
-		if !w.IsAllowed(clientID) {
-			log.Printf("[webui] rejected message from %s", clientID)
-			continue
+		switch msg.Type {
+		case "open":
+			if msg.Session == "" {
+				continue
+			}
+			client.setConversation(msg.Session)
+			w.replay(client, msg.Session)
+		case "rename":
+			if err := w.conversations.rename(user.ID, msg.Session, msg.Title); err != nil {
+				log.Printf("[webui] rename conversation: %v", err)
+				continue
+			}
+			w.pushConversations(user.ID)
+		case "delete":
+			if err := w.conversations.remove(user.ID, msg.Session); err != nil {
+				log.Printf("[webui] delete conversation: %v", err)
+			}
+			w.pushConversations(user.ID)
+		case "message":
+			if msg.Content == "" && len(msg.Attachments) == 0 {
+				continue
+			}
+			if !w.allowed(user) {
+				log.Printf("[webui] rejected message from %s", user.ID)
+				continue
+			}
+			content, blocks := uploadContent(msg.Content, w.uploads.take(user.ID, msg.Attachments))
+			if content == "" {
+				continue
+			}
+			if msg.Session != "" {
+				client.setConversation(msg.Session)
+			}
+			conversation := client.currentConversation()
+			if err := w.conversations.touch(user.ID, conversation, content); err != nil {
+				log.Printf("[webui] save conversation: %v", err)
+			}
+			w.pushConversations(user.ID)
+
+			w.bus.Inbound <- bus.InboundMessage{
+				Channel:       webUIChannelName,
+				SenderID:      user.ID,
+				ChatID:        webUIChatID(user.ID, conversation),
+				Content:       content,
+				ContentBlocks: blocks,
+				Timestamp:     time.Now(),
+			}
 		}
+	}
+}
+
+// requestOwner returns the logged-in user, or without auth the browser that
+// holds the client cookie, so a reload resumes the same conversations.
+func (w *WebUIChannel) requestOwner(r *http.Request) (string, bool) {
+	if id, ok := r.Context().Value(webUIIdentityKey{}).(webUIIdentity); ok {
+		return id.ID, true
+	}
+	if w.auth != nil {
+		return "", false
+	}
+	if c, err := r.Cookie(webUIClientCookie); err == nil && len(c.Value) >= webUIClientTokenMin {
+		return webUIClientOwner(c.Value), true
+	}
+	return "", false
+}
 
-		w.bus.Inbound <- bus.InboundMessage{
-			Channel:   webUIChannelName,
-			SenderID:  clientID,
-			ChatID:    clientID,
-			Content:   msg.Content,
-			Timestamp: time.Now(),
+// clientOwner is requestOwner for a browser without a login, issuing a new
+// client cookie to a browser that has none. Call it before the response
+// header is written.
+func (w *WebUIChannel) clientOwner(wr http.ResponseWriter, r *http.Request) string {
+	if owner, ok := w.requestOwner(r); ok {
+		return owner
+	}
+	token := randomToken()
+	http.SetCookie(wr, &http.Cookie{
+		Name:     webUIClientCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(webUIClientCookieTTL / time.Second),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return webUIClientOwner(token)
+}
+
+// issueClient hands the page its client cookie when there is no login.
+func (w *WebUIChannel) issueClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if w.auth == nil {
+			w.clientOwner(wr, r)
+		}
+		next.ServeHTTP(wr, r)
+	})
+}
+
+// webUIClientOwner derives the owner from the client token. Only the hash
+// shows up in chat IDs, logs and the admin API, so none of them can be used
+// to open another browser's conversations.
+func webUIClientOwner(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "webui-" + hex.EncodeToString(sum[:12])
+}
+
+// replay sends the persisted turns of the conversation the client opened.
+func (w *WebUIChannel) replay(c *wsClient, conversation string) {
+	var entries []HistoryEntry
+	if w.history != nil {
+		key := (&bus.InboundMessage{Channel: webUIChannelName, ChatID: webUIChatID(c.owner, conversation)}).SessionKey()
+		var err error
+		if entries, err = w.history(key); err != nil {
+			log.Printf("[webui] load history for %s: %v", key, err)
 		}
 	}
+	w.write(c, wsMessage{Type: "history", Session: conversation, Messages: entries})
 }
 
-func (w *WebUIChannel) Send(msg bus.OutboundMessage) error {
-	data, err := json.Marshal(wsMessage{
-		Type:    "message",
-		Content: msg.Content,
+// pushConversations refreshes the conversation list in every tab of the owner.
+func (w *WebUIChannel) pushConversations(owner string) {
+	frame := wsMessage{Type: "conversations", Conversations: w.conversations.list(owner)}
+	w.clients.Range(func(key, value any) bool {
+		if c := value.(*wsClient); c.owner == owner {
+			w.write(c, frame)
+		}
+		return true
 	})
+}
+
+func (w *WebUIChannel) write(c *wsClient, frame wsMessage) error {
+	data, err := json.Marshal(frame)
 	if err != nil {
 		return err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.conn.Write(ctx, websocket.MessageText, data)
+}
 
-	client, ok := w.clients.Load(msg.ChatID)
-	if !ok {
-		// Broadcast to all clients if no specific target
-		w.clients.Range(func(key, value any) bool {
-			c := value.(*wsClient)
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			_ = c.conn.Write(ctx, websocket.MessageText, data)
-			return true
-		})
+// Send delivers a reply to the tabs showing its conversation. A ChatID
+// without a conversation ("alice") reaches every tab of that user. Replies
+// nobody is watching are not broadcast: the browser replays them from the
+// agent history when the conversation is reopened.
+func (w *WebUIChannel) Send(msg bus.OutboundMessage) error {
+	frame := wsMessage{Type: "message", Content: msg.Content}
+	if i := strings.LastIndex(msg.ChatID, "/"); i >= 0 {
+		frame.Session = unescapeChatPart(msg.ChatID[i+1:])
+	}
+
+	var targets []*wsClient
+	w.clients.Range(func(key, value any) bool {
+		if c := value.(*wsClient); c.chatID() == msg.ChatID || c.owner == msg.ChatID {
+			targets = append(targets, c)
+		}
+		return true
+	})
+	if len(targets) == 0 {
+		log.Printf("[webui] %s is not open in any tab; reply kept in history only", msg.ChatID)
 		return nil
 	}
 
-	c := client.(*wsClient)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return c.conn.Write(ctx, websocket.MessageText, data)
+	var firstErr error
+	for _, c := range targets {
+		if err := w.write(c, frame); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 func (w *WebUIChannel) Stop() error {
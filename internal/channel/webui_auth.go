@@ -0,0 +1,611 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	webUISessionCookie     = "myclaw_session"
+	webUIOIDCCookie        = "myclaw_oidc"
+	webUIDefaultSessionTTL = 7 * 24 * time.Hour
+	webUIOIDCStateTTL      = 10 * time.Minute
+	webUIOIDCTimeout       = 15 * time.Second
+	webUIOIDCCallbackPath  = "/api/auth/oidc/callback"
+	// webUIOIDCIDPrefix keeps provider subjects apart from local user IDs.
+	webUIOIDCIDPrefix = "oidc:"
+	// webUIClientCookie identifies a browser when auth is off; the server
+	// issues it, so a browser cannot pick another's ID.
+	webUIClientCookie    = "myclaw_client"
+	webUIClientCookieTTL = 365 * 24 * time.Hour
+	webUIClientTokenMin  = 32
+)
+
+// webUIIdentity is a logged-in Web UI user. ID is the SenderID and ChatID of
+// its messages, so it must stay the same across logins and devices.
+type webUIIdentity struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// webUISession is the payload of the signed session cookie.
+type webUISession struct {
+	webUIIdentity
+	Expires int64 `json:"exp"`
+}
+
+// webUIOIDCState is the payload of the signed cookie that carries the OIDC
+// state and PKCE verifier from the login redirect to the callback.
+type webUIOIDCState struct {
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	Expires  int64  `json:"exp"`
+}
+
+// webUIAuth authenticates Web UI requests with local tokens and passwords,
+// an optional OIDC provider, and HMAC-signed session cookies.
+type webUIAuth struct {
+	users  []config.WebUIUser
+	oidc   *webUIOIDC
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+	// allow applies the channel allowlist at login.
+	allow func(webUIIdentity) bool
+}
+
+// newWebUIAuth returns nil when no login method is configured.
+func newWebUIAuth(cfg config.WebUIAuthConfig) (*webUIAuth, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	for _, u := range cfg.Users {
+		if strings.TrimSpace(u.ID) == "" {
+			return nil, fmt.Errorf("webui auth: user without id")
+		}
+		if strings.HasPrefix(u.ID, webUIOIDCIDPrefix) {
+			return nil, fmt.Errorf("webui auth: user id %q must not start with %q", u.ID, webUIOIDCIDPrefix)
+		}
+		if u.Token == "" && u.Password == "" {
+			return nil, fmt.Errorf("webui auth: user %q has no token or password", u.ID)
+		}
+	}
+
+	secret, err := webUISessionSecret(cfg.SessionSecret)
+	if err != nil {
+		return nil, err
+	}
+	ttl := webUIDefaultSessionTTL
+	if cfg.SessionTTLHours > 0 {
+		ttl = time.Duration(cfg.SessionTTLHours) * time.Hour
+	}
+
+	a := &webUIAuth{
+		users:  cfg.Users,
+		secret: secret,
+		ttl:    ttl,
+		now:    time.Now,
+		allow:  func(webUIIdentity) bool { return true },
+	}
+	if strings.TrimSpace(cfg.OIDC.Issuer) != "" {
+		if cfg.OIDC.ClientID == "" {
+			return nil, fmt.Errorf("webui auth: oidc clientId is required")
+		}
+		a.oidc = newWebUIOIDC(cfg.OIDC)
+	}
+	return a, nil
+}
+
+// webUISessionSecret returns the configured signing key, or a random key kept
+// in the config dir so sessions survive restarts.
+func webUISessionSecret(configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+	path := filepath.Join(config.ConfigDir(), "webui-session.key")
+	if data, err := os.ReadFile(path); err == nil {
+		if key := bytes.TrimSpace(data); len(key) > 0 {
+			return key, nil
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generate webui session secret: %w", err)
+	}
+	key := []byte(hex.EncodeToString(raw))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("write webui session secret: %w", err)
+	}
+	return key, nil
+}
+
+func (a *webUIAuth) registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/auth/login", a.handleLogin)
+	mux.HandleFunc("/api/auth/logout", a.handleLogout)
+	mux.HandleFunc("/api/auth/oidc/login", a.handleOIDCLogin)
+	mux.HandleFunc(webUIOIDCCallbackPath, a.handleOIDCCallback)
+}
+
+// methods lists the login methods the Web UI should offer.
+func (a *webUIAuth) methods() []string {
+	var hasToken, hasPassword bool
+	for _, u := range a.users {
+		hasToken = hasToken || u.Token != ""
+		hasPassword = hasPassword || u.Password != ""
+	}
+	var methods []string
+	if hasToken {
+		methods = append(methods, "token")
+	}
+	if hasPassword {
+		methods = append(methods, "password")
+	}
+	if a.oidc != nil {
+		methods = append(methods, "oidc")
+	}
+	return methods
+}
+
+// identify returns the user of a request from its bearer token or session cookie.
+func (a *webUIAuth) identify(r *http.Request) (webUIIdentity, bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if u, ok := a.userByToken(strings.TrimSpace(token)); ok {
+			return localIdentity(u), true
+		}
+		return webUIIdentity{}, false
+	}
+
+	c, err := r.Cookie(webUISessionCookie)
+	if err != nil {
+		return webUIIdentity{}, false
+	}
+	var s webUISession
+	if !a.verify(c.Value, &s) || s.ID == "" || a.now().Unix() >= s.Expires {
+		return webUIIdentity{}, false
+	}
+	if !strings.HasPrefix(s.ID, webUIOIDCIDPrefix) {
+		// Removing a local user from the config ends its sessions.
+		if _, ok := a.userByID(s.ID); !ok {
+			return webUIIdentity{}, false
+		}
+	}
+	return s.webUIIdentity, true
+}
+
+func localIdentity(u config.WebUIUser) webUIIdentity {
+	name := u.Name
+	if name == "" {
+		name = u.ID
+	}
+	return webUIIdentity{ID: u.ID, Name: name}
+}
+
+func (a *webUIAuth) userByID(id string) (config.WebUIUser, bool) {
+	for _, u := range a.users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return config.WebUIUser{}, false
+}
+
+func (a *webUIAuth) userByToken(token string) (config.WebUIUser, bool) {
+	if token == "" {
+		return config.WebUIUser{}, false
+	}
+	for _, u := range a.users {
+		if u.Token != "" && subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+			return u, true
+		}
+	}
+	return config.WebUIUser{}, false
+}
+
+// checkWebUIPassword accepts bcrypt hashes ("$2a$...") or plain text.
+func checkWebUIPassword(stored, given string) bool {
+	if stored == "" || given == "" {
+		return false
+	}
+	if strings.HasPrefix(stored, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(given)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+}
+
+func (a *webUIAuth) handleLogin(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Token    string `json:"token"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+		writeWebUIJSON(wr, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		return
+	}
+
+	var (
+		user config.WebUIUser
+		ok   bool
+	)
+	if req.Token != "" {
+		user, ok = a.userByToken(req.Token)
+	} else if u, found := a.userByID(strings.TrimSpace(req.Username)); found && checkWebUIPassword(u.Password, req.Password) {
+		user, ok = u, true
+	}
+	if !ok {
+		log.Printf("[webui] failed login from %s", r.RemoteAddr)
+		writeWebUIJSON(wr, http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
+		return
+	}
+
+	id := localIdentity(user)
+	if !a.allow(id) {
+		log.Printf("[webui] rejected login for %s: not in allowFrom", id.ID)
+		writeWebUIJSON(wr, http.StatusForbidden, map[string]string{"error": "not allowed"})
+		return
+	}
+	if err := a.startSession(wr, r, id); err != nil {
+		http.Error(wr, "create session", http.StatusInternalServerError)
+		return
+	}
+	writeWebUIJSON(wr, http.StatusOK, id)
+}
+
+func (a *webUIAuth) handleLogout(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.SetCookie(wr, &http.Cookie{
+		Name:     webUISessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+func (a *webUIAuth) startSession(wr http.ResponseWriter, r *http.Request, id webUIIdentity) error {
+	expires := a.now().Add(a.ttl)
+	value, err := a.sign(webUISession{webUIIdentity: id, Expires: expires.Unix()})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(wr, &http.Cookie{
+		Name:     webUISessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(a.ttl / time.Second),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Printf("[webui] %s logged in", id.ID)
+	return nil
+}
+
+// sign encodes v as base64url(JSON) "." base64url(HMAC-SHA256).
+func (a *webUIAuth) sign(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(a.mac(body)), nil
+}
+
+func (a *webUIAuth) verify(value string, v any) bool {
+	body, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, a.mac(body)) {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(payload, v) == nil
+}
+
+func (a *webUIAuth) mac(body string) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write([]byte(body))
+	return h.Sum(nil)
+}
+
+func (a *webUIAuth) handleOIDCLogin(wr http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		http.NotFound(wr, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), webUIOIDCTimeout)
+	defer cancel()
+	disc, err := a.oidc.discover(ctx)
+	if err != nil {
+		log.Printf("[webui] oidc discovery failed: %v", err)
+		http.Error(wr, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	st := webUIOIDCState{
+		State:    randomToken(),
+		Verifier: randomToken(),
+		Redirect: a.oidc.redirectURL(r),
+		Expires:  a.now().Add(webUIOIDCStateTTL).Unix(),
+	}
+	value, err := a.sign(st)
+	if err != nil {
+		http.Error(wr, "create login state", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(wr, &http.Cookie{
+		Name:     webUIOIDCCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(webUIOIDCStateTTL / time.Second),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	challenge := sha256.Sum256([]byte(st.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.oidc.cfg.ClientID},
+		"redirect_uri":          {st.Redirect},
+		"scope":                 {strings.Join(a.oidc.scopes(), " ")},
+		"state":                 {st.State},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	http.Redirect(wr, r, appendQuery(disc.AuthorizationEndpoint, q), http.StatusFound)
+}
+
+func (a *webUIAuth) handleOIDCCallback(wr http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		http.NotFound(wr, r)
+		return
+	}
+	http.SetCookie(wr, &http.Cookie{Name: webUIOIDCCookie, Value: "", Path: "/api/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		http.Error(wr, "login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+	c, err := r.Cookie(webUIOIDCCookie)
+	var st webUIOIDCState
+	if err != nil || !a.verify(c.Value, &st) || a.now().Unix() >= st.Expires ||
+		subtle.ConstantTimeCompare([]byte(st.State), []byte(r.URL.Query().Get("state"))) != 1 {
+		http.Error(wr, "login state expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), webUIOIDCTimeout)
+	defer cancel()
+	id, err := a.oidc.exchange(ctx, r.URL.Query().Get("code"), st)
+	if err != nil {
+		log.Printf("[webui] oidc login failed: %v", err)
+		http.Error(wr, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if !a.allow(id) {
+		log.Printf("[webui] rejected login for %s: not in allowFrom", id.ID)
+		http.Error(wr, "not allowed", http.StatusForbidden)
+		return
+	}
+	if err := a.startSession(wr, r, id); err != nil {
+		http.Error(wr, "create session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(wr, r, "/", http.StatusFound)
+}
+
+// webUIOIDC is an OpenID Connect relying party using the authorization code
+// flow with PKCE. The user is read from the userinfo endpoint with the access
+// token obtained directly from the provider, so no ID token checks are needed.
+type webUIOIDC struct {
+	cfg    config.WebUIOIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *webUIOIDCDiscovery
+}
+
+type webUIOIDCDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+func newWebUIOIDC(cfg config.WebUIOIDCConfig) *webUIOIDC {
+	return &webUIOIDC{cfg: cfg, client: &http.Client{Timeout: webUIOIDCTimeout}}
+}
+
+func (o *webUIOIDC) scopes() []string {
+	if len(o.cfg.Scopes) > 0 {
+		return o.cfg.Scopes
+	}
+	return []string{"openid", "profile", "email"}
+}
+
+func (o *webUIOIDC) redirectURL(r *http.Request) string {
+	if o.cfg.RedirectURL != "" {
+		return o.cfg.RedirectURL
+	}
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + webUIOIDCCallbackPath
+}
+
+// discover fetches and caches the provider metadata.
+func (o *webUIOIDC) discover(ctx context.Context) (*webUIOIDCDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	var disc webUIOIDCDiscovery
+	endpoint := strings.TrimRight(o.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.doJSON(req, &disc); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("discovery: provider metadata lacks authorization, token or userinfo endpoint")
+	}
+	o.discovery = &disc
+	return o.discovery, nil
+}
+
+// exchange trades the authorization code for an access token and reads the user.
+func (o *webUIOIDC) exchange(ctx context.Context, code string, st webUIOIDCState) (webUIIdentity, error) {
+	if code == "" {
+		return webUIIdentity{}, fmt.Errorf("missing authorization code")
+	}
+	disc, err := o.discover(ctx)
+	if err != nil {
+		return webUIIdentity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {st.Redirect},
+		"client_id":     {o.cfg.ClientID},
+		"code_verifier": {st.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return webUIIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := o.doJSON(req, &tok); err != nil {
+		return webUIIdentity{}, fmt.Errorf("token: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return webUIIdentity{}, fmt.Errorf("token: no access_token in response")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, disc.UserinfoEndpoint, nil)
+	if err != nil {
+		return webUIIdentity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	var info struct {
+		Sub               string `json:"sub"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+	}
+	if err := o.doJSON(req, &info); err != nil {
+		return webUIIdentity{}, fmt.Errorf("userinfo: %w", err)
+	}
+	if info.Sub == "" {
+		return webUIIdentity{}, fmt.Errorf("userinfo: no subject")
+	}
+
+	id := webUIIdentity{ID: webUIOIDCIDPrefix + info.Sub, Name: info.Name}
+	// Only verified emails may match allowFrom entries.
+	if info.EmailVerified {
+		id.Email = info.Email
+	}
+	for _, name := range []string{info.PreferredUsername, id.Email, info.Sub} {
+		if id.Name == "" {
+			id.Name = name
+		}
+	}
+	return id, nil
+}
+
+func (o *webUIOIDC) doJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func appendQuery(endpoint string, q url.Values) string {
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + q.Encode()
+}
+
+// isHTTPS also honours TLS terminated by a reverse proxy.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func writeWebUIJSON(wr http.ResponseWriter, status int, v any) {
+	wr.Header().Set("Content-Type", "application/json")
+	wr.Header().Set("Cache-Control", "no-store")
+	wr.WriteHeader(status)
+	_ = json.NewEncoder(wr).Encode(v)
+}
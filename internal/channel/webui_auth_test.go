@@ -0,0 +1,440 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuthedWebUI(t *testing.T, cfg config.WebUIConfig) (*WebUIChannel, *bus.MessageBus, *httptest.Server) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg.Enabled = true
+	if cfg.Auth.SessionSecret == "" {
+		cfg.Auth.SessionSecret = "test-secret"
+	}
+	b := bus.NewMessageBus(10)
+	ch, err := NewWebUIChannel(cfg, config.GatewayConfig{}, b)
+	if err != nil {
+		t.Fatalf("NewWebUIChannel: %v", err)
+	}
+	mux := http.NewServeMux()
+	if err := ch.Attach(mux); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return ch, b, srv
+}
+
+func postLogin(t *testing.T, client *http.Client, srv *httptest.Server, body string) *http.Response {
+	t.Helper()
+	resp, err := client.Post(srv.URL+"/api/auth/login", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func newJarClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+func TestWebUIAuth_TokenLoginIdentity(t *testing.T) {
+	_, b, srv := newAuthedWebUI(t, config.WebUIConfig{Auth: config.WebUIAuthConfig{
+		Users: []config.WebUIUser{{ID: "alice", Name: "Alice", Token: "alice-token"}},
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	// Without a session the websocket is refused.
+	_, resp, err := websocket.Dial(ctx, wsURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous dial: err=%v resp=%v, want 401", err, resp)
+	}
+
+	client := newJarClient(t)
+	if resp := postLogin(t, client, srv, `{"token":"wrong"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad token status = %d, want 401", resp.StatusCode)
+	}
+	if resp := postLogin(t, client, srv, `{"token":"alice-token"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("login status = %d, want 200", resp.StatusCode)
+	}
+
+	meResp, err := client.Get(srv.URL + "/api/auth/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var me struct {
+		AuthRequired bool           `json:"authRequired"`
+		Methods      []string       `json:"methods"`
+		User         *webUIIdentity `json:"user"`
+	}
+	_ = json.NewDecoder(meResp.Body).Decode(&me)
+	meResp.Body.Close()
+	if !me.AuthRequired || me.User == nil || me.User.ID != "alice" || len(me.Methods) != 1 || me.Methods[0] != "token" {
+		t.Fatalf("me = %+v", me)
+	}
+
+	// Two tabs of the same user share the identity and both get the reply.
+	u, _ := url.Parse(srv.URL)
+	header := http.Header{"Cookie": {webUISessionCookie + "=" + client.Jar.Cookies(u)[0].Value}}
+	conns := make([]*websocket.Conn, 2)
+	for i := range conns {
+		conn, _, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{HTTPHeader: header})
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		defer conn.CloseNow()
+		conns[i] = conn
+	}
+
+	data, _ := json.Marshal(wsMessage{Type: "message", Content: "hi"})
+	if err := conns[0].Write(ctx, websocket.MessageText, data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case in := <-b.Inbound:
+		if in.SenderID != "alice" || in.ChatID != "alice/default" {
+			t.Fatalf("sender=%q chat=%q, want alice and alice/default", in.SenderID, in.ChatID)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for inbound message")
+	}
+}
+
+func TestWebUIAuth_SendDoesNotBroadcast(t *testing.T) {
+	ch, _, srv := newAuthedWebUI(t, config.WebUIConfig{Auth: config.WebUIAuthConfig{
+		Users: []config.WebUIUser{{ID: "alice", Token: "a"}, {ID: "bob", Token: "b"}},
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	dial := func(token string) *websocket.Conn {
+		conn, _, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{
+			HTTPHeader: http.Header{"Authorization": {"Bearer " + token}},
+		})
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { conn.CloseNow() })
+		return conn
+	}
+	alice, bob := dial("a"), dial("b")
+	time.Sleep(50 * time.Millisecond)
+
+	if err := ch.Send(bus.OutboundMessage{Channel: "webui", ChatID: "carol", Content: "lost"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := ch.Send(bus.OutboundMessage{Channel: "webui", ChatID: "bob/default", Content: "for bob"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if msg := readWSFrame(t, ctx, bob, "message"); msg.Content != "for bob" {
+		t.Fatalf("bob got %+v", msg)
+	}
+	readWSFrame(t, ctx, alice, "conversations")
+	readCtx, readCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer readCancel()
+	if _, data, err := alice.Read(readCtx); err == nil {
+		t.Fatalf("alice received %s, want nothing", data)
+	}
+}
+
+func TestWebUIAuth_PasswordLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, srv := newAuthedWebUI(t, config.WebUIConfig{Auth: config.WebUIAuthConfig{
+		Users: []config.WebUIUser{
+			{ID: "alice", Password: "plain-pass"},
+			{ID: "bob", Password: string(hash)},
+		},
+	}})
+	client := newJarClient(t)
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"username":"alice","password":"plain-pass"}`, http.StatusOK},
+		{`{"username":"alice","password":"nope"}`, http.StatusUnauthorized},
+		{`{"username":"bob","password":"hunter2"}`, http.StatusOK},
+		{`{"username":"bob","password":""}`, http.StatusUnauthorized},
+		{`{"username":"carol","password":"plain-pass"}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if resp := postLogin(t, client, srv, tt.body); resp.StatusCode != tt.want {
+			t.Errorf("login %s = %d, want %d", tt.body, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestWebUIAuth_AllowFrom(t *testing.T) {
+	_, _, srv := newAuthedWebUI(t, config.WebUIConfig{
+		AllowFrom: []string{"alice"},
+		Auth: config.WebUIAuthConfig{
+			Users: []config.WebUIUser{{ID: "alice", Token: "a"}, {ID: "bob", Token: "b"}},
+		},
+	})
+	client := newJarClient(t)
+	if resp := postLogin(t, client, srv, `{"token":"b"}`); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("bob login = %d, want 403", resp.StatusCode)
+	}
+	if resp := postLogin(t, client, srv, `{"token":"a"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("alice login = %d, want 200", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set("Authorization", "Bearer b")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("bob ws = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestWebUIAuth_SessionCookie(t *testing.T) {
+	auth, err := newWebUIAuth(config.WebUIAuthConfig{
+		SessionSecret: "k",
+		Users:         []config.WebUIUser{{ID: "alice", Token: "a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	if err := auth.startSession(rec, httptest.NewRequest(http.MethodPost, "/", nil), webUIIdentity{ID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	cookie := rec.Result().Cookies()[0]
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie flags = %+v", cookie)
+	}
+
+	identify := func(value string) bool {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: webUISessionCookie, Value: value})
+		_, ok := auth.identify(r)
+		return ok
+	}
+	if !identify(cookie.Value) {
+		t.Fatal("valid session rejected")
+	}
+	body, sig, _ := strings.Cut(cookie.Value, ".")
+	if identify(body[:len(body)-2]+"xx."+sig) || identify(body) {
+		t.Fatal("tampered session accepted")
+	}
+
+	auth.now = func() time.Time { return now.Add(webUIDefaultSessionTTL) }
+	if identify(cookie.Value) {
+		t.Fatal("expired session accepted")
+	}
+
+	auth.now = func() time.Time { return now }
+	auth.users = nil
+	if identify(cookie.Value) {
+		t.Fatal("session of a removed user accepted")
+	}
+}
+
+func TestNewWebUIAuth_Validation(t *testing.T) {
+	if a, err := newWebUIAuth(config.WebUIAuthConfig{}); a != nil || err != nil {
+		t.Fatalf("disabled auth = %v, %v; want nil, nil", a, err)
+	}
+	bad := []config.WebUIAuthConfig{
+		{Users: []config.WebUIUser{{Token: "t"}}},
+		{Users: []config.WebUIUser{{ID: "alice"}}},
+		{Users: []config.WebUIUser{{ID: "oidc:x", Token: "t"}}},
+		{OIDC: config.WebUIOIDCConfig{Issuer: "http://idp"}},
+	}
+	for _, cfg := range bad {
+		cfg.SessionSecret = "k"
+		if _, err := newWebUIAuth(cfg); err == nil {
+			t.Errorf("newWebUIAuth(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestWebUIAuth_OIDCLogin(t *testing.T) {
+	var idp *httptest.Server
+	var gotVerifier, gotAuth string
+	idpMux := http.NewServeMux()
+	idpMux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"userinfo_endpoint":      idp.URL + "/userinfo",
+		})
+	})
+	idpMux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotVerifier = r.PostForm.Get("code_verifier")
+		gotAuth = r.Header.Get("Authorization")
+		if r.PostForm.Get("code") != "the-code" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer"})
+	})
+	idpMux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"sub": "1234", "preferred_username": "dave", "email": "dave@example.com", "email_verified": true,
+		})
+	})
+	idp = httptest.NewServer(idpMux)
+	defer idp.Close()
+
+	_, _, srv := newAuthedWebUI(t, config.WebUIConfig{
+		AllowFrom: []string{"dave@example.com"},
+		Auth: config.WebUIAuthConfig{OIDC: config.WebUIOIDCConfig{
+			Issuer: idp.URL, ClientID: "myclaw", ClientSecret: "s3cret",
+		}},
+	})
+	client := newJarClient(t)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	resp, err := client.Get(srv.URL + "/api/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound || !strings.HasPrefix(loc.String(), idp.URL+"/authorize") {
+		t.Fatalf("login redirect = %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("client_id") != "myclaw" || q.Get("code_challenge_method") != "S256" || q.Get("redirect_uri") != srv.URL+webUIOIDCCallbackPath {
+		t.Fatalf("authorize query = %v", q)
+	}
+
+	// A forged state is rejected.
+	resp, err = client.Get(srv.URL + webUIOIDCCallbackPath + "?code=the-code&state=forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("forged state = %d, want 400", resp.StatusCode)
+	}
+
+	resp, err = client.Get(srv.URL + "/api/auth/oidc/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, _ = url.Parse(resp.Header.Get("Location"))
+	resp, err = client.Get(srv.URL + webUIOIDCCallbackPath + "?code=the-code&state=" + url.QueryEscape(loc.Query().Get("state")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/" {
+		t.Fatalf("callback = %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if gotVerifier == "" || !strings.HasPrefix(gotAuth, "Basic ") {
+		t.Errorf("token request verifier=%q auth=%q", gotVerifier, gotAuth)
+	}
+
+	meResp, err := client.Get(srv.URL + "/api/auth/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer meResp.Body.Close()
+	var me struct {
+		User *webUIIdentity `json:"user"`
+	}
+	_ = json.NewDecoder(meResp.Body).Decode(&me)
+	if me.User == nil || me.User.ID != "oidc:1234" || me.User.Name != "dave" || me.User.Email != "dave@example.com" {
+		t.Fatalf("me.user = %+v", me.User)
+	}
+}
+
+func TestWebUIAuth_AdminAPI(t *testing.T) {
+	ch, _, srv := newAuthedWebUI(t, config.WebUIConfig{
+		Admins: []string{"alice"},
+		Auth: config.WebUIAuthConfig{Users: []config.WebUIUser{
+			{ID: "alice", Token: "alice-token"},
+			{ID: "bob", Token: "bob-token"},
+		}},
+	})
+	get := func(path, token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get("/api/admin/status", "alice-token"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("without an admin handler = %d, want 404", resp.StatusCode)
+	}
+	ch.SetAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Admin-Path", r.URL.Path)
+	}))
+
+	if resp := get("/api/admin/status", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous = %d, want 401", resp.StatusCode)
+	}
+	if resp := get("/api/admin/status", "bob-token"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin = %d, want 403", resp.StatusCode)
+	}
+	if resp := get("/api/admin/status", "alice-token"); resp.StatusCode != http.StatusOK || resp.Header.Get("X-Admin-Path") != "/api/admin/status" {
+		t.Errorf("admin = %d %q", resp.StatusCode, resp.Header.Get("X-Admin-Path"))
+	}
+	if !ch.isAdmin(webUIIdentity{ID: "alice"}) || ch.isAdmin(webUIIdentity{ID: "bob"}) {
+		t.Error("isAdmin does not follow the admins list")
+	}
+}
+
+func TestWebUI_AdminAPIRequiresAuth(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ch, err := NewWebUIChannel(config.WebUIConfig{Enabled: true}, config.GatewayConfig{}, bus.NewMessageBus(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.SetAdmin(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	ch.requireAuth(ch.handleAdmin)(rec, httptest.NewRequest(http.MethodGet, "/api/admin/status", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("admin API without auth = %d, want 403", rec.Code)
+	}
+	if ch.isAdmin(webUIIdentity{ID: "webui-1"}) {
+		t.Error("no one is an admin without auth")
+	}
+}
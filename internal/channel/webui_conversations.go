@@ -0,0 +1,189 @@
+package channel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	webUIDefaultConversation = "default"
+	webUIConversationIDMax   = 64
+	webUITitleMaxRunes       = 60
+)
+
+// webUIConversation is a named chat of one Web UI user. Its agent session is
+// keyed by the chat ID "<owner>/<id>".
+type webUIConversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// validConversationID accepts the IDs the browser generates: 1-64 letters,
+// digits, '-' or '_'.
+func validConversationID(id string) bool {
+	if id == "" || len(id) > webUIConversationIDMax {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// webUIChatID escapes both parts, so two chats never share an agent session
+// file once agentsdk-go folds the key into a file name ("alice/x-y" and
+// "alice-x/y" would both become "alice-x-y").
+func webUIChatID(owner, conversation string) string {
+	return escapeChatPart(owner) + "/" + escapeChatPart(conversation)
+}
+
+// escapeChatPart keeps ASCII letters and digits and writes every other byte
+// as _XX, which the file-name folding leaves alone.
+func escapeChatPart(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "_%02x", c)
+	}
+	return b.String()
+}
+
+func unescapeChatPart(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func conversationTitle(text string) string {
+	title := strings.Join(strings.Fields(text), " ")
+	if r := []rune(title); len(r) > webUITitleMaxRunes {
+		title = string(r[:webUITitleMaxRunes]) + "…"
+	}
+	return title
+}
+
+// webUIConversationStore keeps the conversation list of every user in a JSON
+// file; the turns themselves live in the agent history.
+type webUIConversationStore struct {
+	mu      sync.Mutex
+	path    string
+	byOwner map[string][]webUIConversation
+	now     func() time.Time
+}
+
+func newWebUIConversationStore(path string) (*webUIConversationStore, error) {
+	s := &webUIConversationStore{path: path, byOwner: map[string][]webUIConversation{}, now: time.Now}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read webui conversations: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.byOwner); err != nil {
+		return nil, fmt.Errorf("decode webui conversations: %w", err)
+	}
+	return s, nil
+}
+
+// list returns the owner's conversations, most recent first.
+func (s *webUIConversationStore) list(owner string) []webUIConversation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append([]webUIConversation(nil), s.byOwner[owner]...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out
+}
+
+// touch records activity in a conversation, creating it with a title taken
+// from its first message.
+func (s *webUIConversationStore) touch(owner, id, firstMessage string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	convs := s.byOwner[owner]
+	for i := range convs {
+		if convs[i].ID == id {
+			convs[i].UpdatedAt = s.now()
+			return s.saveLocked()
+		}
+	}
+	title := conversationTitle(firstMessage)
+	if title == "" {
+		title = "New conversation"
+	}
+	s.byOwner[owner] = append(convs, webUIConversation{ID: id, Title: title, UpdatedAt: s.now()})
+	return s.saveLocked()
+}
+
+func (s *webUIConversationStore) rename(owner, id, title string) error {
+	title = conversationTitle(title)
+	if title == "" {
+		return fmt.Errorf("empty title")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.byOwner[owner] {
+		if c.ID == id {
+			s.byOwner[owner][i].Title = title
+			return s.saveLocked()
+		}
+	}
+	return fmt.Errorf("conversation %q not found", id)
+}
+
+// remove drops a conversation from the list; its agent history expires with
+// the runtime's history retention.
+func (s *webUIConversationStore) remove(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	convs := s.byOwner[owner]
+	for i, c := range convs {
+		if c.ID == id {
+			s.byOwner[owner] = append(convs[:i:i], convs[i+1:]...)
+			if len(s.byOwner[owner]) == 0 {
+				delete(s.byOwner, owner)
+			}
+			return s.saveLocked()
+		}
+	}
+	return nil
+}
+
+func (s *webUIConversationStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.byOwner, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
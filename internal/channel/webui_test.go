@@ -3,7 +3,9 @@ package channel
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -60,7 +62,118 @@ func TestWebUIChannel_StartStop(t *testing.T) {
 	}
 }
 
+// readWSFrame returns the next frame of the given type, skipping the others
+// (conversation list updates).
+func readWSFrame(t *testing.T, ctx context.Context, conn *websocket.Conn, typ string) wsMessage {
+	t.Helper()
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("ws read: %v", err)
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+// testClientToken stands in for the client cookie the server issues.
+var testClientToken = strings.Repeat("a", webUIClientTokenMin)
+
+func webUIClientHeader(token string) http.Header {
+	h := http.Header{}
+	if token != "" {
+		h.Set("Cookie", webUIClientCookie+"="+token)
+	}
+	return h
+}
+
+func TestWebUIChannel_ClientCookie(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	b := bus.NewMessageBus(10)
+	ch, err := NewWebUIChannel(config.WebUIConfig{Enabled: true}, config.GatewayConfig{}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	if err := ch.Attach(mux); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The page hands out the cookie.
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var issued *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == webUIClientCookie {
+			issued = c
+		}
+	}
+	if issued == nil || len(issued.Value) < webUIClientTokenMin || !issued.HttpOnly {
+		t.Fatalf("client cookie = %+v", issued)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	say := func(query string, header http.Header) bus.InboundMessage {
+		t.Helper()
+		conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws"+query, &websocket.DialOptions{HTTPHeader: header})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.CloseNow()
+		readWSFrame(t, ctx, conn, "conversations")
+		data, _ := json.Marshal(wsMessage{Type: "message", Content: "hi"})
+		if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case in := <-b.Inbound:
+			return in
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for inbound message")
+		}
+		return bus.InboundMessage{}
+	}
+
+	mine := say("", webUIClientHeader(issued.Value))
+	if mine.SenderID != webUIClientOwner(issued.Value) {
+		t.Fatalf("sender = %q, want the cookie's owner", mine.SenderID)
+	}
+	// Neither ?client= nor the owner ID seen in a chat ID opens that browser's
+	// conversations.
+	for _, spoof := range []http.Header{nil, webUIClientHeader(mine.SenderID + strings.Repeat("x", webUIClientTokenMin))} {
+		if in := say("?client="+issued.Value, spoof); in.SenderID == mine.SenderID {
+			t.Fatalf("spoofed connection got owner %q", in.SenderID)
+		}
+	}
+}
+
+func TestWebUIChatIDIsInjective(t *testing.T) {
+	a := webUIChatID("alice", "x-y")
+	b := webUIChatID("alice-x", "y")
+	if a == b || strings.ContainsAny(a+b, "-:") {
+		t.Fatalf("chat IDs %q and %q", a, b)
+	}
+	if got := webUIChatID("alice", "default"); got != "alice/default" {
+		t.Fatalf("plain chat ID = %q", got)
+	}
+	if got := unescapeChatPart(escapeChatPart("c_1-x")); got != "c_1-x" {
+		t.Fatalf("round trip = %q", got)
+	}
+}
+
 func TestWebUIChannel_WebSocket(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	b := bus.NewMessageBus(10)
 	cfg := config.WebUIConfig{Enabled: true}
 	gwCfg := config.GatewayConfig{Port: 19877}
@@ -100,8 +213,8 @@ func TestWebUIChannel_WebSocket(t *testing.T) {
 		if inbound.Content != "hello from test" {
 			t.Errorf("content = %q, want %q", inbound.Content, "hello from test")
 		}
-		if !strings.HasPrefix(inbound.ChatID, "webui-") {
-			t.Errorf("chatID = %q, want prefix %q", inbound.ChatID, "webui-")
+		if !strings.HasPrefix(inbound.SenderID, "webui-") || inbound.ChatID != webUIChatID(inbound.SenderID, "default") {
+			t.Errorf("sender = %q, chatID = %q, want a webui- client and its default chat", inbound.SenderID, inbound.ChatID)
 		}
 
 		if err := ch.Send(bus.OutboundMessage{
@@ -112,16 +225,9 @@ func TestWebUIChannel_WebSocket(t *testing.T) {
 			t.Fatalf("Send: %v", err)
 		}
 
-		_, respData, err := conn.Read(ctx)
-		if err != nil {
-			t.Fatalf("ws read: %v", err)
-		}
-		var resp wsMessage
-		if err := json.Unmarshal(respData, &resp); err != nil {
-			t.Fatalf("unmarshal: %v", err)
-		}
-		if resp.Type != "message" {
-			t.Errorf("resp type = %q, want %q", resp.Type, "message")
+		resp := readWSFrame(t, ctx, conn, "message")
+		if resp.Session != "default" {
+			t.Errorf("resp session = %q, want default", resp.Session)
 		}
 		if resp.Content != "reply from bot" {
 			t.Errorf("resp content = %q, want %q", resp.Content, "reply from bot")
@@ -132,7 +238,8 @@ func TestWebUIChannel_WebSocket(t *testing.T) {
 	}
 }
 
-func TestWebUIChannel_SendBroadcast(t *testing.T) {
+func TestWebUIChannel_SendPerConversation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	b := bus.NewMessageBus(10)
 	cfg := config.WebUIConfig{Enabled: true}
 	gwCfg := config.GatewayConfig{Port: 19878}
@@ -152,42 +259,112 @@ func TestWebUIChannel_SendBroadcast(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 
-	conn1, _, err := websocket.Dial(ctx, "ws://localhost:19878/ws", nil)
-	if err != nil {
-		t.Fatal(err)
+	// Two tabs of the same browser, showing different conversations.
+	dial := func(conversation string) *websocket.Conn {
+		conn, _, err := websocket.Dial(ctx, "ws://localhost:19878/ws", &websocket.DialOptions{HTTPHeader: webUIClientHeader(testClientToken)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		readWSFrame(t, ctx, conn, "conversations")
+		data, _ := json.Marshal(wsMessage{Type: "open", Session: conversation})
+		if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+			t.Fatal(err)
+		}
+		if h := readWSFrame(t, ctx, conn, "history"); h.Session != conversation {
+			t.Fatalf("history session = %q, want %q", h.Session, conversation)
+		}
+		return conn
 	}
+	conn1 := dial("c1")
 	defer conn1.CloseNow()
+	conn2 := dial("c2")
+	defer conn2.CloseNow()
 
-	conn2, _, err := websocket.Dial(ctx, "ws://localhost:19878/ws", nil)
+	target := webUIChatID(webUIClientOwner(testClientToken), "c2")
+	for _, chatID := range []string{"unknown-id", target} {
+		if err := ch.Send(bus.OutboundMessage{Channel: "webui", ChatID: chatID, Content: "to " + chatID}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	if msg := readWSFrame(t, ctx, conn2, "message"); msg.Content != "to "+target || msg.Session != "c2" {
+		t.Errorf("tab 2 got %+v", msg)
+	}
+	readCtx, readCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer readCancel()
+	if _, data, err := conn1.Read(readCtx); err == nil {
+		t.Errorf("tab 1 received %s, want nothing", data)
+	}
+}
+
+func TestWebUIChannel_ConversationsAndReplay(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	b := bus.NewMessageBus(10)
+	ch, err := NewWebUIChannel(config.WebUIConfig{Enabled: true}, config.GatewayConfig{}, b)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer conn2.CloseNow()
+	var gotKey string
+	ch.SetHistory(func(sessionKey string) ([]HistoryEntry, error) {
+		gotKey = sessionKey
+		return []HistoryEntry{{Role: "user", Content: "earlier"}, {Role: "assistant", Content: "answer"}}, nil
+	})
+	mux := http.NewServeMux()
+	if err := ch.Attach(mux); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
 
-	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	owner := webUIClientOwner(testClientToken)
+	dialOpts := &websocket.DialOptions{HTTPHeader: webUIClientHeader(testClientToken)}
+	send := func(conn *websocket.Conn, msg wsMessage) {
+		data, _ := json.Marshal(msg)
+		if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+			t.Fatal(err)
+		}
+	}
 
-	if err := ch.Send(bus.OutboundMessage{
-		Channel: "webui",
-		ChatID:  "unknown-id",
-		Content: "broadcast msg",
-	}); err != nil {
-		t.Fatalf("Send: %v", err)
+	conn, _, err := websocket.Dial(ctx, wsURL, dialOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readWSFrame(t, ctx, conn, "conversations")
+	send(conn, wsMessage{Type: "message", Session: "trip", Content: "Plan a trip to Kyoto"})
+	if in := <-b.Inbound; in.ChatID != webUIChatID(owner, "trip") || in.SenderID != owner {
+		t.Fatalf("inbound chat=%q sender=%q", in.ChatID, in.SenderID)
 	}
+	list := readWSFrame(t, ctx, conn, "conversations")
+	if len(list.Conversations) != 1 || list.Conversations[0].ID != "trip" || list.Conversations[0].Title != "Plan a trip to Kyoto" {
+		t.Fatalf("conversations = %+v", list.Conversations)
+	}
+	send(conn, wsMessage{Type: "rename", Session: "trip", Title: "Kyoto"})
+	if list := readWSFrame(t, ctx, conn, "conversations"); list.Conversations[0].Title != "Kyoto" {
+		t.Fatalf("renamed = %+v", list.Conversations)
+	}
+	conn.CloseNow()
 
-	for i, conn := range []*websocket.Conn{conn1, conn2} {
-		readCtx, readCancel := context.WithTimeout(ctx, 3*time.Second)
-		_, data, err := conn.Read(readCtx)
-		readCancel()
-		if err != nil {
-			t.Fatalf("client %d read: %v", i+1, err)
-		}
-		var msg wsMessage
-		if err := json.Unmarshal(data, &msg); err != nil {
-			t.Fatalf("client %d unmarshal: %v", i+1, err)
-		}
-		if msg.Content != "broadcast msg" {
-			t.Errorf("client %d content = %q, want %q", i+1, msg.Content, "broadcast msg")
-		}
+	// A reload keeps the browser ID, lists the conversation and replays it.
+	conn, _, err = websocket.Dial(ctx, wsURL, dialOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	if list := readWSFrame(t, ctx, conn, "conversations"); len(list.Conversations) != 1 {
+		t.Fatalf("conversations after reconnect = %+v", list.Conversations)
+	}
+	send(conn, wsMessage{Type: "open", Session: "trip"})
+	h := readWSFrame(t, ctx, conn, "history")
+	if gotKey != "webui:"+webUIChatID(owner, "trip") || len(h.Messages) != 2 || h.Messages[1].Content != "answer" {
+		t.Fatalf("history key=%q frame=%+v", gotKey, h)
+	}
+
+	send(conn, wsMessage{Type: "delete", Session: "trip"})
+	if list := readWSFrame(t, ctx, conn, "conversations"); len(list.Conversations) != 0 {
+		t.Fatalf("conversations after delete = %+v", list.Conversations)
 	}
 }
 
@@ -224,3 +401,82 @@ func TestWebUIChannel_WebSocket_RejectsCrossOrigin(t *testing.T) {
 		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
 	}
 }
+
+func TestWebUIChannel_WhatsAppLoginRequiresAuth(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ch, err := NewWebUIChannel(config.WebUIConfig{Enabled: true}, config.GatewayConfig{}, bus.NewMessageBus(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	if err := ch.Attach(mux); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	ch.SetWhatsAppLogin(func() WhatsAppLoginState {
+		return WhatsAppLoginState{Status: WhatsAppLoginQR, QRCode: "2@abc,def,ghi"}
+	})
+	for _, path := range []string{"/api/whatsapp/login", "/api/whatsapp/qr.png"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s without auth = %d, want 403", path, rec.Code)
+		}
+	}
+}
+
+func TestWebUIChannel_WhatsAppLogin(t *testing.T) {
+	ch, _, srv := newAuthedWebUI(t, config.WebUIConfig{
+		Admins: []string{"alice"},
+		Auth: config.WebUIAuthConfig{Users: []config.WebUIUser{
+			{ID: "alice", Token: "alice-token"},
+			{ID: "bob", Token: "bob-token"},
+		}},
+	})
+	getAs := func(path, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		rec := httptest.NewRecorder()
+		rec.Code = resp.StatusCode
+		for k, v := range resp.Header {
+			rec.Header()[k] = v
+		}
+		_, _ = io.Copy(rec.Body, resp.Body)
+		return rec
+	}
+	get := func(path string) *httptest.ResponseRecorder { return getAs(path, "alice-token") }
+
+	// Without a WhatsApp channel the endpoints do not exist.
+	if rec := get("/api/whatsapp/login"); rec.Code != http.StatusNotFound {
+		t.Fatalf("login status without whatsapp = %d, want 404", rec.Code)
+	}
+
+	state := WhatsAppLoginState{Status: WhatsAppLoginQR, QRCode: "2@abc,def,ghi"}
+	ch.SetWhatsAppLogin(func() WhatsAppLoginState { return state })
+
+	rec := get("/api/whatsapp/login")
+	var got WhatsAppLoginState
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Status != WhatsAppLoginQR {
+		t.Fatalf("login = %s (%v)", rec.Body.String(), err)
+	}
+	rec = get("/api/whatsapp/qr.png")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(rec.Body.String(), "\x89PNG") {
+		t.Fatalf("qr.png status=%d type=%q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	if rec := getAs("/api/whatsapp/qr.png", "bob-token"); rec.Code != http.StatusForbidden {
+		t.Fatalf("qr.png as non-admin = %d, want 403", rec.Code)
+	}
+
+	state = WhatsAppLoginState{Status: WhatsAppLoginPaired, JID: "8613800138000@s.whatsapp.net"}
+	if rec := get("/api/whatsapp/qr.png"); rec.Code != http.StatusNotFound {
+		t.Fatalf("qr.png when paired = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,205 @@
+package channel
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cexll/agentsdk-go/pkg/model"
+)
+
+const (
+	webUIDefaultMaxUploadMB = 10
+	// webUIUploadTTL is how long an upload waits for the message that uses it.
+	webUIUploadTTL = 30 * time.Minute
+	// webUIUploadsPerOwner bounds the pending uploads kept per user.
+	webUIUploadsPerOwner = 10
+	// webUITextUploadMax bounds the text of a file inlined into the prompt.
+	webUITextUploadMax = 100 << 10
+)
+
+// webUIUpload is a file waiting to be attached to a message by its ID.
+type webUIUpload struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	MediaType string `json:"mediaType"`
+	Size      int    `json:"size"`
+
+	owner   string
+	data    []byte
+	expires time.Time
+}
+
+// webUIUploadStore keeps uploads in memory until a message claims them.
+type webUIUploadStore struct {
+	mu   sync.Mutex
+	byID map[string]*webUIUpload
+	now  func() time.Time
+}
+
+func newWebUIUploadStore() *webUIUploadStore {
+	return &webUIUploadStore{byID: map[string]*webUIUpload{}, now: time.Now}
+}
+
+func (s *webUIUploadStore) put(u *webUIUpload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	u.expires = now.Add(webUIUploadTTL)
+
+	var owned []*webUIUpload
+	for id, other := range s.byID {
+		switch {
+		case now.After(other.expires):
+			delete(s.byID, id)
+		case other.owner == u.owner:
+			owned = append(owned, other)
+		}
+	}
+	if extra := len(owned) - (webUIUploadsPerOwner - 1); extra > 0 {
+		sort.Slice(owned, func(i, j int) bool { return owned[i].expires.Before(owned[j].expires) })
+		for _, old := range owned[:extra] {
+			delete(s.byID, old.ID)
+		}
+	}
+	s.byID[u.ID] = u
+}
+
+// take removes and returns the owner's unexpired uploads, in the given order.
+func (s *webUIUploadStore) take(owner string, ids []string) []*webUIUpload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*webUIUpload
+	for _, id := range ids {
+		u, ok := s.byID[id]
+		if !ok || u.owner != owner {
+			log.Printf("[webui] unknown upload %q from %s", id, owner)
+			continue
+		}
+		delete(s.byID, id)
+		if s.now().After(u.expires) {
+			continue
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// webUIUploadType sniffs the content, ignoring the name and the type the
+// browser reports. Images and PDFs go to the model as content blocks; UTF-8
+// text is inlined into the prompt.
+func webUIUploadType(data []byte) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case mediaType == "image/jpeg", mediaType == "image/png", mediaType == "image/gif", mediaType == "image/webp":
+		return mediaType, true
+	case mediaType == "application/pdf":
+		return mediaType, true
+	case strings.HasPrefix(mediaType, "text/") && utf8.Valid(data):
+		return "text/plain", true
+	}
+	return mediaType, false
+}
+
+// handleUpload stores one multipart "file" part and returns its ID, which
+// the browser then lists in the "attachments" of a message frame.
+func (w *WebUIChannel) handleUpload(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	owner, ok := w.requestOwner(r)
+	if !ok {
+		writeWebUIJSON(wr, http.StatusBadRequest, map[string]string{"error": "missing client id"})
+		return
+	}
+
+	// Leave room for the multipart headers around the file.
+	r.Body = http.MaxBytesReader(wr, r.Body, w.maxUpload+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeWebUIJSON(wr, http.StatusBadRequest, map[string]string{"error": "expected multipart/form-data"})
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			writeWebUIJSON(wr, http.StatusBadRequest, map[string]string{"error": "no file"})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, w.maxUpload+1))
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) || int64(len(data)) > w.maxUpload {
+			writeWebUIJSON(wr, http.StatusRequestEntityTooLarge, map[string]string{"error": "file too large"})
+			return
+		}
+		if err != nil || len(data) == 0 {
+			writeWebUIJSON(wr, http.StatusBadRequest, map[string]string{"error": "empty file"})
+			return
+		}
+
+		mediaType, ok := webUIUploadType(data)
+		if !ok {
+			writeWebUIJSON(wr, http.StatusUnsupportedMediaType, map[string]string{"error": "unsupported file type " + mediaType})
+			return
+		}
+		name := filepath.Base(strings.ReplaceAll(part.FileName(), "\\", "/"))
+		if name == "." || name == "/" {
+			name = "file"
+		}
+
+		u := &webUIUpload{ID: randomToken(), Name: name, MediaType: mediaType, Size: len(data), owner: owner, data: data}
+		w.uploads.put(u)
+		log.Printf("[webui] %s uploaded %s (%s, %d bytes)", owner, name, mediaType, len(data))
+		writeWebUIJSON(wr, http.StatusOK, u)
+		return
+	}
+}
+
+// uploadContent adds the uploads to a message: images and PDFs as content
+// blocks with a note naming them, text files inline.
+func uploadContent(content string, uploads []*webUIUpload) (string, []model.ContentBlock) {
+	var (
+		notes  []string
+		blocks []model.ContentBlock
+	)
+	for _, u := range uploads {
+		switch {
+		case strings.HasPrefix(u.MediaType, "image/"):
+			notes = append(notes, "[Image] "+u.Name)
+			blocks = append(blocks, model.ContentBlock{Type: model.ContentBlockImage, MediaType: u.MediaType, Data: base64.StdEncoding.EncodeToString(u.data)})
+		case u.MediaType == "application/pdf":
+			notes = append(notes, "[Document] "+u.Name)
+			blocks = append(blocks, model.ContentBlock{Type: model.ContentBlockDocument, MediaType: u.MediaType, Data: base64.StdEncoding.EncodeToString(u.data)})
+		default:
+			text := string(u.data)
+			if len(text) > webUITextUploadMax {
+				text = strings.ToValidUTF8(text[:webUITextUploadMax], "") + "\n[truncated]"
+			}
+			notes = append(notes, "[File] "+u.Name+"\n"+text)
+		}
+	}
+	if len(notes) == 0 {
+		return content, nil
+	}
+	if content != "" {
+		notes = append(notes, content)
+	}
+	return strings.Join(notes, "\n\n"), blocks
+}
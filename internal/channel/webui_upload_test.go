@@ -0,0 +1,187 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/model"
+	"github.com/coder/websocket"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+func uploadFile(t *testing.T, url, client, name string, data []byte) (*http.Response, webUIUpload) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, url, &body)
+	req.Header = webUIClientHeader(client)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+	var u webUIUpload
+	_ = json.NewDecoder(resp.Body).Decode(&u)
+	return resp, u
+}
+
+func newUploadTestWebUI(t *testing.T, cfg config.WebUIConfig) (*bus.MessageBus, *httptest.Server) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg.Enabled = true
+	b := bus.NewMessageBus(10)
+	ch, err := NewWebUIChannel(cfg, config.GatewayConfig{}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	if err := ch.Attach(mux); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return b, srv
+}
+
+func TestWebUIUpload_AttachToMessage(t *testing.T) {
+	b, srv := newUploadTestWebUI(t, config.WebUIConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Uploads without a client cookie are refused when there is no login,
+	// whatever ?client= says.
+	if resp, _ := uploadFile(t, srv.URL+"/api/uploads?client=browser1", "", "cat.png", testPNG); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("upload without client = %d, want 400", resp.StatusCode)
+	}
+
+	url := srv.URL + "/api/uploads"
+	resp, img := uploadFile(t, url, testClientToken, `C:\pics\cat.png`, testPNG)
+	if resp.StatusCode != http.StatusOK || img.ID == "" || img.Name != "cat.png" || img.MediaType != "image/png" {
+		t.Fatalf("image upload = %d %+v", resp.StatusCode, img)
+	}
+	_, notes := uploadFile(t, url, testClientToken, "notes.md", []byte("# Notes\nbuy milk"))
+	if notes.MediaType != "text/plain" {
+		t.Fatalf("text upload = %+v", notes)
+	}
+	_, foreign := uploadFile(t, url, strings.Repeat("b", webUIClientTokenMin), "other.png", testPNG)
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", &websocket.DialOptions{HTTPHeader: webUIClientHeader(testClientToken)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	data, _ := json.Marshal(wsMessage{Type: "message", Content: "what is this?", Attachments: []string{img.ID, notes.ID, foreign.ID}})
+	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case in := <-b.Inbound:
+		want := "[Image] cat.png\n\n[File] notes.md\n# Notes\nbuy milk\n\nwhat is this?"
+		if in.Content != want {
+			t.Errorf("content = %q, want %q", in.Content, want)
+		}
+		if len(in.ContentBlocks) != 1 || in.ContentBlocks[0].Type != model.ContentBlockImage ||
+			in.ContentBlocks[0].MediaType != "image/png" || in.ContentBlocks[0].Data != base64.StdEncoding.EncodeToString(testPNG) {
+			t.Errorf("blocks = %+v", in.ContentBlocks)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for inbound message")
+	}
+
+	// An upload is used once.
+	data, _ = json.Marshal(wsMessage{Type: "message", Attachments: []string{img.ID}})
+	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case in := <-b.Inbound:
+		t.Fatalf("reused upload produced %+v", in)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebUIUpload_Rejects(t *testing.T) {
+	_, srv := newUploadTestWebUI(t, config.WebUIConfig{MaxUploadMB: 1})
+	url := srv.URL + "/api/uploads"
+
+	big := append(append([]byte{}, testPNG...), make([]byte, 1<<20)...)
+	if resp, _ := uploadFile(t, url, testClientToken, "big.png", big); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload = %d, want 413", resp.StatusCode)
+	}
+	// The name and browser type do not matter: a zip is a zip.
+	if resp, _ := uploadFile(t, url, testClientToken, "photo.png", []byte("PK\x03\x04\x14\x00\x00\x00")); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("zip upload = %d, want 415", resp.StatusCode)
+	}
+	if resp, _ := uploadFile(t, url, testClientToken, "empty.txt", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("empty upload = %d, want 400", resp.StatusCode)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestWebUIUploadType(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+		ok   bool
+	}{
+		{testPNG, "image/png", true},
+		{[]byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg", true},
+		{[]byte("%PDF-1.7\n"), "application/pdf", true},
+		{[]byte(`{"a": 1}`), "text/plain", true},
+		{[]byte("\x00\x01\x02\x03"), "application/octet-stream", false},
+	}
+	for _, tt := range tests {
+		got, ok := webUIUploadType(tt.data)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("webUIUploadType(%q) = %q, %v; want %q, %v", tt.data, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWebUIUploadStore_Limits(t *testing.T) {
+	s := newWebUIUploadStore()
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < webUIUploadsPerOwner+2; i++ {
+		s.put(&webUIUpload{ID: fmt.Sprintf("u%d", i), owner: "alice"})
+		now = now.Add(time.Second)
+	}
+	if got := s.take("alice", []string{"u0", "u1", "u2"}); len(got) != 1 || got[0].ID != "u2" {
+		t.Fatalf("oldest uploads not evicted: %+v", got)
+	}
+
+	s.put(&webUIUpload{ID: "late", owner: "bob"})
+	now = now.Add(webUIUploadTTL + time.Second)
+	if got := s.take("bob", []string{"late"}); len(got) != 0 {
+		t.Fatalf("expired upload returned: %+v", got)
+	}
+}
@@ -30,8 +30,6 @@ const wecomChannelName = "wecom"
 
 const (
 	wecomDefaultPort          = 9886
-	wecomDefaultMsgCacheTTL   = 5 * time.Minute
-	wecomDefaultMsgCacheScan  = 1 * time.Minute
 	wecomDefaultReplyCacheTTL = 1 * time.Hour
 	wecomMarkdownMaxBytes     = 20480
 	wecomInboundImageMaxBytes = 10 << 20 // 10MB
@@ -41,6 +39,9 @@ const (
 
 type WeComClient interface {
 	SendMessage(ctx context.Context, responseURL string, msg bus.OutboundMessage) error
+	// SendProactive delivers without a response_url, via the app message API
+	// or the group robot webhook.
+	SendProactive(ctx context.Context, chatID string, msg bus.OutboundMessage) error
 	Close()
 }
 
@@ -48,6 +49,18 @@ type WeComClientFactory func(cfg config.WeComConfig) WeComClient
 
 type defaultWeComClient struct {
 	httpClient *http.Client
+
+	corpID     string
+	corpSecret string
+	agentID    int64
+	webhookURL string
+	// webhookChats are the group chats the robot webhook posts to.
+	webhookChats map[string]bool
+	apiBase      string
+
+	tokenMu  sync.Mutex
+	token    string
+	tokenExp time.Time
 }
 
 type weComSendResponse struct {
@@ -79,7 +92,13 @@ func (e *weComHTTPStatusError) Error() string {
 
 func newDefaultWeComClient(cfg config.WeComConfig) WeComClient {
 	return &defaultWeComClient{
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		corpID:       strings.TrimSpace(cfg.CorpID),
+		corpSecret:   strings.TrimSpace(cfg.CorpSecret),
+		agentID:      cfg.AgentID,
+		webhookURL:   strings.TrimSpace(cfg.WebhookURL),
+		webhookChats: weComWebhookChats(cfg),
+		apiBase:      wecomAPIBase,
 	}
 }
 
@@ -206,56 +225,6 @@ func truncateUTF8ByByteLimit(text string, maxBytes int) string {
 	return text
 }
 
-type weComMsgCache struct {
-	mu     sync.Mutex
-	items  map[string]time.Time
-	ttl    time.Duration
-	lastGC time.Time
-}
-
-func newWeComMsgCache(ttl time.Duration) *weComMsgCache {
-	if ttl <= 0 {
-		ttl = wecomDefaultMsgCacheTTL
-	}
-	return &weComMsgCache{
-		items: make(map[string]time.Time),
-		ttl:   ttl,
-	}
-}
-
-func (c *weComMsgCache) Seen(key string) bool {
-	if key == "" {
-		return false
-	}
-
-	now := time.Now()
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if exp, ok := c.items[key]; ok {
-		if now.Before(exp) {
-			return true
-		}
-		delete(c.items, key)
-	}
-
-	c.items[key] = now.Add(c.ttl)
-	c.gcLocked(now)
-
-	return false
-}
-
-func (c *weComMsgCache) gcLocked(now time.Time) {
-	if c.lastGC.IsZero() || now.Sub(c.lastGC) >= wecomDefaultMsgCacheScan {
-		for messageID, exp := range c.items {
-			if now.After(exp) {
-				delete(c.items, messageID)
-			}
-		}
-		c.lastGC = now
-	}
-}
-
 type weComReplyTarget struct {
 	ResponseURL string
 	ExpiresAt   time.Time
@@ -319,7 +288,7 @@ func (c *weComReplyCache) Get(chatID string) (string, bool) {
 }
 
 func (c *weComReplyCache) gcLocked(now time.Time) {
-	if c.lastGC.IsZero() || now.Sub(c.lastGC) >= wecomDefaultMsgCacheScan {
+	if c.lastGC.IsZero() || now.Sub(c.lastGC) >= msgDedupGCInterval {
 		for chatID, target := range c.items {
 			if now.After(target.ExpiresAt) {
 				delete(c.items, chatID)
@@ -337,9 +306,11 @@ type WeComChannel struct {
 	client           WeComClient
 	clientFactory    WeComClientFactory
 	allowlistEnabled bool
-	msgCache         *weComMsgCache
+	msgCache         *msgDedupCache
 	replyCache       *weComReplyCache
 	receiveID        string
+	proactiveEnabled bool
+	streams          *weComStreamStore
 }
 
 var defaultWeComClientFactory WeComClientFactory = func(cfg config.WeComConfig) WeComClient {
@@ -357,6 +328,9 @@ func NewWeComChannelWithFactory(cfg config.WeComConfig, b *bus.MessageBus, facto
 	if len(strings.TrimSpace(cfg.EncodingAESKey)) != 43 {
 		return nil, fmt.Errorf("wecom encodingAESKey must be 43 chars")
 	}
+	if (strings.TrimSpace(cfg.CorpID) != "" || strings.TrimSpace(cfg.CorpSecret) != "") && !weComAppConfigured(cfg) {
+		return nil, fmt.Errorf("wecom corpId, corpSecret and agentId must be set together")
+	}
 
 	if factory == nil {
 		factory = defaultWeComClientFactory
@@ -369,9 +343,11 @@ func NewWeComChannelWithFactory(cfg config.WeComConfig, b *bus.MessageBus, facto
 		cfg:              cfg,
 		clientFactory:    factory,
 		allowlistEnabled: len(cfg.AllowFrom) > 0,
-		msgCache:         newWeComMsgCache(wecomDefaultMsgCacheTTL),
+		msgCache:         newMsgDedupCache(msgDedupDefaultTTL),
 		replyCache:       newWeComReplyCache(wecomDefaultReplyCacheTTL),
 		receiveID:        receiveID,
+		proactiveEnabled: weComAppConfigured(cfg) || len(weComWebhookChats(cfg)) > 0,
+		streams:          newWeComStreamStore(),
 	}
 
 	return ch, nil
@@ -433,12 +409,27 @@ func (w *WeComChannel) Send(msg bus.OutboundMessage) error {
 		return fmt.Errorf("wecom chat id is required")
 	}
 
+	if w.sendStream(msg) {
+		return nil
+	}
+
+	ctx := context.Background()
 	responseURL, ok := w.replyCache.Get(chatID)
 	if !ok {
-		return fmt.Errorf("wecom response_url not found or expired for chat id %q", chatID)
+		if !w.proactiveEnabled {
+			return fmt.Errorf("wecom response_url not found or expired for chat id %q (configure corpId/corpSecret/agentId, or webhookUrl and webhookChatIds, for proactive delivery)", chatID)
+		}
+		return w.client.SendProactive(ctx, chatID, msg)
 	}
 
-	return w.client.SendMessage(context.Background(), responseURL, msg)
+	err := w.client.SendMessage(ctx, responseURL, msg)
+	if err != nil && w.proactiveEnabled {
+		// response_url is short-lived and single-use; later replies in the
+		// same turn go out proactively.
+		log.Printf("[wecom] response_url send failed, sending proactively: %v", err)
+		return w.client.SendProactive(ctx, chatID, msg)
+	}
+	return err
 }
 
 type weComEncryptedEnvelope struct {
@@ -512,6 +503,9 @@ type weComInboundMessage struct {
 	Mixed       weComMixed `json:"mixed"`
 	Voice       weComVoice `json:"voice"`
 	Image       weComImage `json:"image"`
+	Stream      struct {
+		ID string `json:"id"`
+	} `json:"stream"`
 }
 
 type weComReplyEnvelope struct {
@@ -595,7 +589,16 @@ func (w *WeComChannel) handleIncomingMessage(resp http.ResponseWriter, req *http
 		return
 	}
 
-	replyBody, err := w.buildEncryptedReply(timestamp, nonce, receiveID, "success")
+	var reply any = "success"
+	process := true
+	if w.cfg.Stream {
+		var message weComInboundMessage
+		if err := json.Unmarshal([]byte(plaintext), &message); err == nil {
+			reply, process = w.streamReply(message)
+		}
+	}
+
+	replyBody, err := w.buildEncryptedReply(timestamp, nonce, receiveID, reply)
 	if err != nil {
 		http.Error(resp, "encrypt reply failed", http.StatusInternalServerError)
 		return
@@ -605,7 +608,9 @@ func (w *WeComChannel) handleIncomingMessage(resp http.ResponseWriter, req *http
 	resp.WriteHeader(http.StatusOK)
 	_, _ = resp.Write(replyBody)
 
-	go w.processDecryptedMessage(plaintext)
+	if process {
+		go w.processDecryptedMessage(plaintext)
+	}
 }
 
 func (w *WeComChannel) buildEncryptedReply(timestamp, nonce, receiveID string, payload any) ([]byte, error) {
@@ -685,6 +690,7 @@ func (w *WeComChannel) processDecryptedMessage(plaintext string) {
 		ChatID:        chatID,
 		Content:       content,
 		Timestamp:     time.Now(),
+		MessageID:     messageID,
 		ContentBlocks: contentBlocks,
 		Metadata: map[string]any{
 			"msg_id":         messageID,
@@ -695,6 +701,7 @@ func (w *WeComChannel) processDecryptedMessage(plaintext string) {
 			"image_url":      message.Image.URLValue(),
 			"image_media_id": strings.TrimSpace(message.Image.MediaID),
 			"response_url":   responseURL,
+			"is_group":       strings.EqualFold(strings.TrimSpace(message.ChatType), "group"),
 		},
 	}
 }
@@ -0,0 +1,191 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+const (
+	wecomAPIBase = "https://qyapi.weixin.qq.com"
+	// wecomTokenEarlyRefresh renews the access token before it expires.
+	wecomTokenEarlyRefresh = 5 * time.Minute
+)
+
+// WeCom errcodes for an invalid or expired access_token.
+var wecomTokenErrCodes = map[int]bool{40014: true, 42001: true}
+
+func weComAppConfigured(cfg config.WeComConfig) bool {
+	return strings.TrimSpace(cfg.CorpID) != "" && strings.TrimSpace(cfg.CorpSecret) != "" && cfg.AgentID != 0
+}
+
+// weComWebhookChats returns the chats the robot webhook may post to: none
+// without a webhook URL.
+func weComWebhookChats(cfg config.WeComConfig) map[string]bool {
+	chats := make(map[string]bool)
+	if strings.TrimSpace(cfg.WebhookURL) == "" {
+		return chats
+	}
+	for _, id := range cfg.WebhookChatIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			chats[id] = true
+		}
+	}
+	return chats
+}
+
+// SendProactive sends to a group chat listed in webhookChatIds through the
+// group robot webhook, and to any other chat, which is a user ID, through
+// the app message API. The robot webhook posts into the one group it was
+// added to, so it is never used for other chats, even when the app API
+// fails.
+func (c *defaultWeComClient) SendProactive(ctx context.Context, chatID string, msg bus.OutboundMessage) error {
+	content := truncateUTF8ByByteLimit(msg.Content, wecomMarkdownMaxBytes)
+
+	if c.webhookChats[chatID] {
+		if err := c.sendTextWithRetry(ctx, c.webhookURL, content); err != nil {
+			return fmt.Errorf("robot webhook: %w", err)
+		}
+		return nil
+	}
+	if c.corpID != "" && c.corpSecret != "" && c.agentID != 0 {
+		if err := c.sendAppMessage(ctx, chatID, content); err != nil {
+			return fmt.Errorf("app message: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("wecom proactive delivery to chat %q is not configured", chatID)
+}
+
+func (c *defaultWeComClient) sendAppMessage(ctx context.Context, userID, content string) error {
+	payload := map[string]any{
+		"touser":  userID,
+		"msgtype": "markdown",
+		"agentid": c.agentID,
+		"markdown": map[string]string{
+			"content": content,
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal wecom app message: %w", err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		err = c.postAppMessage(ctx, token, body)
+		var apiErr *weComAPIError
+		if attempt == 0 && errors.As(err, &apiErr) && wecomTokenErrCodes[apiErr.Code] {
+			c.invalidateToken(token)
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *defaultWeComClient) postAppMessage(ctx context.Context, token string, body []byte) error {
+	endpoint := c.baseURL() + "/cgi-bin/message/send?access_token=" + url.QueryEscape(token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create wecom app message request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send wecom app message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &weComHTTPStatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(raw))}
+	}
+
+	var result struct {
+		weComSendResponse
+		InvalidUser string `json:"invaliduser"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("decode wecom app message response: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return &weComAPIError{Code: result.ErrCode, Msg: result.ErrMsg}
+	}
+	if result.InvalidUser != "" {
+		return fmt.Errorf("wecom app message: invalid user %q", result.InvalidUser)
+	}
+	return nil
+}
+
+// accessToken returns the cached app access_token, fetching a new one via
+// gettoken when it is missing or about to expire.
+func (c *defaultWeComClient) accessToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExp) {
+		return c.token, nil
+	}
+
+	endpoint := fmt.Sprintf("%s/cgi-bin/gettoken?corpid=%s&corpsecret=%s",
+		c.baseURL(), url.QueryEscape(c.corpID), url.QueryEscape(c.corpSecret))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("create wecom token request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("get wecom access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		weComSendResponse
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode wecom token response: %w", err)
+	}
+	if result.ErrCode != 0 || result.AccessToken == "" {
+		return "", fmt.Errorf("wecom token error: %d %s", result.ErrCode, result.ErrMsg)
+	}
+
+	ttl := time.Duration(result.ExpiresIn)*time.Second - wecomTokenEarlyRefresh
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	c.token = result.AccessToken
+	c.tokenExp = time.Now().Add(ttl)
+	return c.token, nil
+}
+
+func (c *defaultWeComClient) invalidateToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *defaultWeComClient) baseURL() string {
+	if c.apiBase != "" {
+		return strings.TrimRight(c.apiBase, "/")
+	}
+	return wecomAPIBase
+}
@@ -0,0 +1,165 @@
+package channel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellarlinkco/myclaw/internal/bus"
+)
+
+const (
+	// wecomStreamTTL is how long WeCom keeps polling a stream; a reply that
+	// finishes later is delivered through the regular Send path.
+	wecomStreamTTL = 6 * time.Minute
+	// wecomStreamRetention keeps finished streams answerable for late polls.
+	wecomStreamRetention = 10 * time.Minute
+)
+
+// weComStream is the reply state of one inbound message. WeCom polls it with
+// msgtype "stream" callbacks and renders the full content of every answer.
+type weComStream struct {
+	id       string
+	msgID    string
+	content  string
+	finished bool
+	created  time.Time
+}
+
+// weComStreamStore tracks streams by stream ID and by inbound msg_id.
+type weComStreamStore struct {
+	mu      sync.Mutex
+	byID    map[string]*weComStream
+	byMsgID map[string]*weComStream
+	lastGC  time.Time
+}
+
+func newWeComStreamStore() *weComStreamStore {
+	return &weComStreamStore{
+		byID:    make(map[string]*weComStream),
+		byMsgID: make(map[string]*weComStream),
+	}
+}
+
+// Open returns the stream for msgID, creating it on first sight so a
+// redelivered callback gets the same stream ID.
+func (s *weComStreamStore) Open(msgID string) (*weComStream, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gcLocked(now)
+	if st, ok := s.byMsgID[msgID]; ok {
+		return st, nil
+	}
+
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	st := &weComStream{id: hex.EncodeToString(b[:]), msgID: msgID, created: now}
+	s.byID[st.id] = st
+	if msgID != "" {
+		s.byMsgID[msgID] = st
+	}
+	return st, nil
+}
+
+// Snapshot returns the current content of a stream and whether it is done.
+// Unknown streams report finished so WeCom stops polling.
+func (s *weComStreamStore) Snapshot(id string) (content string, finished bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.byID[id]
+	if !ok {
+		return "", true
+	}
+	return st.content, st.finished
+}
+
+// Update applies an outbound message to the stream opened for msgID. It
+// reports false when there is no live stream, so the caller sends normally.
+func (s *weComStreamStore) Update(msgID, content string, final bool) bool {
+	if msgID == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.byMsgID[msgID]
+	if !ok || st.finished || time.Since(st.created) > wecomStreamTTL {
+		return false
+	}
+	st.content = truncateUTF8ByByteLimit(content, wecomMarkdownMaxBytes)
+	st.finished = final
+	return true
+}
+
+func (s *weComStreamStore) gcLocked(now time.Time) {
+	if !s.lastGC.IsZero() && now.Sub(s.lastGC) < msgDedupGCInterval {
+		return
+	}
+	for id, st := range s.byID {
+		if now.Sub(st.created) > wecomStreamRetention {
+			delete(s.byID, id)
+			delete(s.byMsgID, st.msgID)
+		}
+	}
+	s.lastGC = now
+}
+
+func weComStreamReply(id, content string, finished bool) map[string]any {
+	return map[string]any{
+		"msgtype": "stream",
+		"stream": map[string]any{
+			"id":      id,
+			"finish":  finished,
+			"content": content,
+		},
+	}
+}
+
+// SupportsStreaming reports whether replies are sent as stream messages.
+func (w *WeComChannel) SupportsStreaming() bool {
+	return w.cfg.Stream
+}
+
+// streamReply builds the passive reply for a decrypted callback in stream
+// mode. process is false for stream refresh polls, which carry no user input.
+func (w *WeComChannel) streamReply(message weComInboundMessage) (reply any, process bool) {
+	if strings.EqualFold(strings.TrimSpace(message.MsgType), "stream") {
+		id := strings.TrimSpace(message.Stream.ID)
+		content, finished := w.streams.Snapshot(id)
+		return weComStreamReply(id, content, finished), false
+	}
+
+	// Events and rejected senders never get a reply, so no stream is opened.
+	senderID := w.resolveSenderID(message)
+	if strings.EqualFold(strings.TrimSpace(message.MsgType), "event") || senderID == "" || !w.allowMessageFrom(senderID) {
+		return "success", true
+	}
+	st, err := w.streams.Open(strings.TrimSpace(message.MsgID))
+	if err != nil {
+		log.Printf("[wecom] open stream failed: %v", err)
+		return "success", true
+	}
+	return weComStreamReply(st.id, "", false), true
+}
+
+// sendStream routes a reply into its stream. It reports false when the
+// message must go out through response_url or proactive delivery instead.
+func (w *WeComChannel) sendStream(msg bus.OutboundMessage) bool {
+	if !w.cfg.Stream {
+		return false
+	}
+	if w.streams.Update(strings.TrimSpace(msg.ReplyTo), msg.Content, !msg.Partial) {
+		return true
+	}
+	// Partial frames only make sense inside a live stream, and an empty
+	// final frame only closes one.
+	return msg.Partial || (msg.StreamEnd && strings.TrimSpace(msg.Content) == "")
+}
@@ -28,8 +28,10 @@ type mockWeComSend struct {
 }
 
 type mockWeComClient struct {
-	sent []mockWeComSend
-	err  error
+	sent         []mockWeComSend
+	err          error
+	proactive    []bus.OutboundMessage
+	proactiveErr error
 }
 
 func (m *mockWeComClient) SendMessage(ctx context.Context, responseURL string, msg bus.OutboundMessage) error {
@@ -37,6 +39,11 @@ func (m *mockWeComClient) SendMessage(ctx context.Context, responseURL string, m
 	return m.err
 }
 
+func (m *mockWeComClient) SendProactive(ctx context.Context, chatID string, msg bus.OutboundMessage) error {
+	m.proactive = append(m.proactive, msg)
+	return m.proactiveErr
+}
+
 func (m *mockWeComClient) Close() {}
 
 func mockWeComClientFactory(client *mockWeComClient) WeComClientFactory {
@@ -195,6 +202,9 @@ func TestWeComCallback_ReceiveTextMessage_OK(t *testing.T) {
 		if msg.Metadata["response_url"] != "https://example.com/resp" {
 			t.Errorf("response_url = %v, want https://example.com/resp", msg.Metadata["response_url"])
 		}
+		if msg.IsGroup() {
+			t.Error("single chat reported as a group")
+		}
 	case <-time.After(time.Second):
 		t.Fatal("expected inbound message")
 	}
@@ -620,3 +630,299 @@ func TestWeComClient_Send_NoRetryOnPayloadErrcode(t *testing.T) {
 		t.Fatalf("send calls = %d, want 1", sendCalls)
 	}
 }
+
+func TestWeComChannel_Send_Proactive(t *testing.T) {
+	b := bus.NewMessageBus(10)
+	mock := &mockWeComClient{err: fmt.Errorf("response_url already used")}
+
+	ch, err := NewWeComChannelWithFactory(config.WeComConfig{
+		Token:          "verify-token",
+		EncodingAESKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+		CorpID:         "ww-corp",
+		CorpSecret:     "corp-secret",
+		AgentID:        1,
+	}, b, mockWeComClientFactory(mock))
+	if err != nil {
+		t.Fatalf("new channel error: %v", err)
+	}
+	ch.client = mock
+
+	// No cached response_url: delivered proactively.
+	if err := ch.Send(bus.OutboundMessage{ChatID: "zhangsan", Content: "cron report"}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if len(mock.sent) != 0 || len(mock.proactive) != 1 || mock.proactive[0].Content != "cron report" {
+		t.Fatalf("sent = %+v, proactive = %+v", mock.sent, mock.proactive)
+	}
+
+	// A failing response_url falls back to proactive delivery.
+	ch.replyCache.Set("zhangsan", "https://example.com/response-url")
+	if err := ch.Send(bus.OutboundMessage{ChatID: "zhangsan", Content: "second reply"}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if len(mock.sent) != 1 || len(mock.proactive) != 2 {
+		t.Fatalf("sent = %d, proactive = %d, want 1 and 2", len(mock.sent), len(mock.proactive))
+	}
+}
+
+func TestNewWeComChannel_PartialAppConfig(t *testing.T) {
+	_, err := NewWeComChannel(config.WeComConfig{
+		Token:          "verify-token",
+		EncodingAESKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+		CorpID:         "ww-corp",
+	}, bus.NewMessageBus(1))
+	if err == nil {
+		t.Fatal("expected error when corpSecret/agentId are missing")
+	}
+}
+
+func TestWeComClient_SendProactive_AppMessage(t *testing.T) {
+	tokenCalls, sendCalls := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			tokenCalls++
+			if r.URL.Query().Get("corpid") != "ww-corp" || r.URL.Query().Get("corpsecret") != "corp-secret" {
+				t.Errorf("gettoken query = %s", r.URL.RawQuery)
+			}
+			fmt.Fprintf(w, `{"errcode":0,"access_token":"tok-%d","expires_in":7200}`, tokenCalls)
+		case "/cgi-bin/message/send":
+			sendCalls++
+			// The first token is reported as expired once.
+			if r.URL.Query().Get("access_token") == "tok-1" && sendCalls == 1 {
+				io.WriteString(w, `{"errcode":42001,"errmsg":"access_token expired"}`)
+				return
+			}
+			var payload map[string]any
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Fatalf("invalid payload: %v", err)
+			}
+			if payload["touser"] != "zhangsan" || payload["agentid"] != float64(1000002) || payload["msgtype"] != "markdown" {
+				t.Errorf("payload = %v", payload)
+			}
+			io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	client := newDefaultWeComClient(config.WeComConfig{CorpID: "ww-corp", CorpSecret: "corp-secret", AgentID: 1000002}).(*defaultWeComClient)
+	client.apiBase = ts.URL
+
+	for i := 0; i < 2; i++ {
+		if err := client.SendProactive(context.Background(), "zhangsan", bus.OutboundMessage{Content: "daily report"}); err != nil {
+			t.Fatalf("SendProactive: %v", err)
+		}
+	}
+	if tokenCalls != 2 {
+		t.Errorf("token calls = %d, want 2 (initial + refresh after expiry)", tokenCalls)
+	}
+	if sendCalls != 3 {
+		t.Errorf("send calls = %d, want 3", sendCalls)
+	}
+}
+
+func TestWeComClient_SendProactive_WebhookOnlyForItsGroup(t *testing.T) {
+	var robotContent string
+	robotCalls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			io.WriteString(w, `{"errcode":0,"access_token":"tok","expires_in":7200}`)
+		case "/cgi-bin/message/send":
+			io.WriteString(w, `{"errcode":81013,"errmsg":"user & party & tag all invalid"}`)
+		case "/robot":
+			var payload struct {
+				Markdown struct {
+					Content string `json:"content"`
+				} `json:"markdown"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			robotCalls++
+			robotContent = payload.Markdown.Content
+			io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+		}
+	}))
+	defer ts.Close()
+
+	client := newDefaultWeComClient(config.WeComConfig{
+		CorpID: "ww-corp", CorpSecret: "corp-secret", AgentID: 1,
+		WebhookURL: ts.URL + "/robot", WebhookChatIDs: []string{"wrGroupChat"},
+	}).(*defaultWeComClient)
+	client.apiBase = ts.URL
+
+	if err := client.SendProactive(context.Background(), "wrGroupChat", bus.OutboundMessage{Content: "to the group"}); err != nil {
+		t.Fatalf("SendProactive: %v", err)
+	}
+	if robotCalls != 1 || robotContent != "to the group" {
+		t.Errorf("robot calls = %d, content = %q", robotCalls, robotContent)
+	}
+
+	// A private chat the app API rejects must not end up in the group.
+	if err := client.SendProactive(context.Background(), "zhangsan", bus.OutboundMessage{Content: "private"}); err == nil {
+		t.Error("expected app message error for private chat")
+	}
+	if err := client.SendProactive(context.Background(), "wrOtherGroup", bus.OutboundMessage{Content: "other group"}); err == nil {
+		t.Error("expected error for a group the webhook does not belong to")
+	}
+	if robotCalls != 1 {
+		t.Errorf("robot calls = %d, want 1: only the webhook's own group may use it", robotCalls)
+	}
+
+	webhookOnly := newDefaultWeComClient(config.WeComConfig{WebhookURL: ts.URL + "/robot"})
+	if err := webhookOnly.SendProactive(context.Background(), "zhangsan", bus.OutboundMessage{Content: "private"}); err == nil || robotCalls != 1 {
+		t.Errorf("webhook without chat IDs: err = %v, robot calls = %d", err, robotCalls)
+	}
+
+	unconfigured := newDefaultWeComClient(config.WeComConfig{})
+	if err := unconfigured.SendProactive(context.Background(), "zhangsan", bus.OutboundMessage{Content: "x"}); err == nil {
+		t.Error("expected error without proactive config")
+	}
+}
+
+// postWeComCallback delivers plaintext as an encrypted callback and returns
+// the decrypted passive reply.
+func postWeComCallback(t *testing.T, ch *WeComChannel, plaintext string) string {
+	t.Helper()
+	timestamp, nonce := "1739000100", "nonce-stream"
+	encrypt := testWeComEncrypt(t, ch.cfg.EncodingAESKey, ch.receiveID, plaintext)
+	req := httptest.NewRequest(http.MethodPost, "/wecom/bot", strings.NewReader(testWeComEncryptedRequestBody(t, encrypt)))
+	q := req.URL.Query()
+	q.Set("msg_signature", testWeComSignature(ch.cfg.Token, timestamp, nonce, encrypt))
+	q.Set("timestamp", timestamp)
+	q.Set("nonce", nonce)
+	req.URL.RawQuery = q.Encode()
+	w := httptest.NewRecorder()
+
+	ch.handleCallback(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var reply weComReplyEnvelope
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decode callback response: %v", err)
+	}
+	return testWeComDecrypt(t, ch.cfg.EncodingAESKey, ch.receiveID, reply.Encrypt)
+}
+
+type weComStreamPayload struct {
+	MsgType string `json:"msgtype"`
+	Stream  struct {
+		ID      string `json:"id"`
+		Finish  bool   `json:"finish"`
+		Content string `json:"content"`
+	} `json:"stream"`
+}
+
+func decodeWeComStreamReply(t *testing.T, plain string) weComStreamPayload {
+	t.Helper()
+	var p weComStreamPayload
+	if err := json.Unmarshal([]byte(plain), &p); err != nil {
+		t.Fatalf("decode stream reply %q: %v", plain, err)
+	}
+	if p.MsgType != "stream" || p.Stream.ID == "" {
+		t.Fatalf("reply = %q, want stream message", plain)
+	}
+	return p
+}
+
+func TestWeComCallback_StreamReply(t *testing.T) {
+	ch, b := newTestWeComChannel(t, config.WeComConfig{
+		Token:          "verify-token",
+		EncodingAESKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+		Stream:         true,
+	})
+	if !ch.SupportsStreaming() {
+		t.Fatal("SupportsStreaming() = false, want true")
+	}
+
+	userMsg := `{"msgid":"20001","chattype":"single","from":{"userid":"zhangsan"},"response_url":"https://example.com/resp","msgtype":"text","text":{"content":"hi"}}`
+	first := decodeWeComStreamReply(t, postWeComCallback(t, ch, userMsg))
+	if first.Stream.Finish || first.Stream.Content != "" {
+		t.Fatalf("first frame = %+v, want empty unfinished", first.Stream)
+	}
+
+	var inbound bus.InboundMessage
+	select {
+	case inbound = <-b.Inbound:
+	case <-time.After(time.Second):
+		t.Fatal("expected inbound message")
+	}
+	if inbound.MessageID != "20001" {
+		t.Fatalf("MessageID = %q, want 20001", inbound.MessageID)
+	}
+
+	// A redelivered callback gets the same stream.
+	again := decodeWeComStreamReply(t, postWeComCallback(t, ch, userMsg))
+	if again.Stream.ID != first.Stream.ID {
+		t.Fatalf("redelivery stream id = %q, want %q", again.Stream.ID, first.Stream.ID)
+	}
+
+	refresh := `{"msgid":"20002","chattype":"single","from":{"userid":"zhangsan"},"msgtype":"stream","stream":{"id":"` + first.Stream.ID + `"}}`
+
+	if err := ch.Send(bus.OutboundMessage{Channel: "wecom", ChatID: "zhangsan", Content: "Hel", ReplyTo: "20001", Partial: true}); err != nil {
+		t.Fatalf("Send partial: %v", err)
+	}
+	frame := decodeWeComStreamReply(t, postWeComCallback(t, ch, refresh))
+	if frame.Stream.Content != "Hel" || frame.Stream.Finish {
+		t.Fatalf("partial frame = %+v", frame.Stream)
+	}
+
+	if err := ch.Send(bus.OutboundMessage{Channel: "wecom", ChatID: "zhangsan", Content: "Hello", ReplyTo: "20001"}); err != nil {
+		t.Fatalf("Send final: %v", err)
+	}
+	frame = decodeWeComStreamReply(t, postWeComCallback(t, ch, refresh))
+	if frame.Stream.Content != "Hello" || !frame.Stream.Finish {
+		t.Fatalf("final frame = %+v", frame.Stream)
+	}
+
+	mock := ch.client.(*mockWeComClient)
+	if len(mock.sent) != 0 || len(mock.proactive) != 0 {
+		t.Fatalf("stream reply also sent via response_url/proactive: %+v %+v", mock.sent, mock.proactive)
+	}
+	select {
+	case msg := <-b.Inbound:
+		t.Fatalf("stream refresh should not be processed: %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWeComChannel_Send_StreamFallback(t *testing.T) {
+	ch, _ := newTestWeComChannel(t, config.WeComConfig{
+		Token:          "verify-token",
+		EncodingAESKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+		Stream:         true,
+	})
+	ch.replyCache.Set("zhangsan", "https://example.com/resp")
+	mock := ch.client.(*mockWeComClient)
+
+	// Partial frames without a live stream are dropped.
+	if err := ch.Send(bus.OutboundMessage{ChatID: "zhangsan", Content: "x", ReplyTo: "unknown", Partial: true}); err != nil {
+		t.Fatalf("Send partial: %v", err)
+	}
+	if len(mock.sent) != 0 {
+		t.Fatalf("partial without stream was sent: %+v", mock.sent)
+	}
+
+	// Finished or expired streams fall back to response_url.
+	st, err := ch.streams.Open("30001")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	st.created = time.Now().Add(-wecomStreamTTL - time.Second)
+	// An empty final frame only closes a stream; there is nothing to fall back with.
+	if err := ch.Send(bus.OutboundMessage{ChatID: "zhangsan", Content: "", ReplyTo: "30001", StreamEnd: true}); err != nil {
+		t.Fatalf("Send empty final: %v", err)
+	}
+	if len(mock.sent) != 0 || len(mock.proactive) != 0 {
+		t.Fatalf("empty final frame was sent: %+v %+v", mock.sent, mock.proactive)
+	}
+	if err := ch.Send(bus.OutboundMessage{ChatID: "zhangsan", Content: "late", ReplyTo: "30001", StreamEnd: true}); err != nil {
+		t.Fatalf("Send final: %v", err)
+	}
+	if len(mock.sent) != 1 || mock.sent[0].Message.Content != "late" {
+		t.Fatalf("sent = %+v, want fallback via response_url", mock.sent)
+	}
+}
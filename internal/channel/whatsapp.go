@@ -2,17 +2,13 @@ package channel
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/cexll/agentsdk-go/pkg/model"
-	qrterminal "github.com/mdp/qrterminal/v3"
 	"github.com/stellarlinkco/myclaw/internal/bus"
 	"github.com/stellarlinkco/myclaw/internal/config"
 	"go.mau.fi/whatsmeow"
@@ -31,37 +27,46 @@ const whatsappChannelName = "whatsapp"
 const (
 	whatsappInboundImageTimeout = 20 * time.Second
 	whatsappSendTimeout         = 30 * time.Second
+	whatsappTranscribeTimeout   = 60 * time.Second
+	// WhatsApp clears "typing…" after about 25s, so it is refreshed while
+	// the agent runs, up to whatsappTypingMax.
+	whatsappTypingRefresh = 10 * time.Second
+	whatsappTypingMax     = 5 * time.Minute
 )
 
+// whatsAppClient is the part of *whatsmeow.Client used to handle and send
+// messages (allows mocking in tests).
+type whatsAppClient interface {
+	Download(ctx context.Context, msg whatsmeow.DownloadableMessage) ([]byte, error)
+	Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error)
+	SendMessage(ctx context.Context, to types.JID, message *waE2E.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error)
+	MarkRead(ctx context.Context, ids []types.MessageID, timestamp time.Time, chat, sender types.JID, receiptTypeExtra ...types.ReceiptType) error
+	SendChatPresence(ctx context.Context, jid types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error
+}
+
 type WhatsAppChannel struct {
 	BaseChannel
 	cfg            config.WhatsAppConfig
 	client         *whatsmeow.Client
+	api            whatsAppClient
 	storeContainer *sqlstore.Container
 	cancel         context.CancelFunc
 	handlerID      uint32
-}
-
-func NewWhatsApp(cfg config.WhatsAppConfig, msgBus *bus.MessageBus) (*WhatsAppChannel, error) {
-	storePath := strings.TrimSpace(cfg.StorePath)
-	if storePath == "" {
-		storePath = filepath.Join(config.ConfigDir(), "whatsapp-store.db")
-	}
+	transcriber    Transcriber
+	// ownJIDs returns the bot's phone and LID JIDs, used to detect mentions.
+	ownJIDs func() []types.JID
 
-	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
-		return nil, fmt.Errorf("create whatsapp store dir: %w", err)
-	}
+	typingMu sync.Mutex
+	typing   map[string]context.CancelFunc
 
-	storeDSN := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)", filepath.ToSlash(storePath))
-	container, err := sqlstore.New(context.Background(), "sqlite", storeDSN, waLog.Noop)
-	if err != nil {
-		return nil, fmt.Errorf("init whatsapp session store: %w", err)
-	}
+	loginMu sync.RWMutex
+	login   WhatsAppLoginState
+}
 
-	deviceStore, err := container.GetFirstDevice(context.Background())
+func NewWhatsApp(cfg config.WhatsAppConfig, msgBus *bus.MessageBus) (*WhatsAppChannel, error) {
+	container, deviceStore, err := openWhatsAppStore(cfg)
 	if err != nil {
-		_ = container.Close()
-		return nil, fmt.Errorf("get whatsapp device: %w", err)
+		return nil, err
 	}
 
 	client := whatsmeow.NewClient(deviceStore, waLog.Noop)
@@ -70,8 +75,19 @@ func NewWhatsApp(cfg config.WhatsAppConfig, msgBus *bus.MessageBus) (*WhatsAppCh
 		BaseChannel:    NewBaseChannel(whatsappChannelName, msgBus, cfg.AllowFrom),
 		cfg:            cfg,
 		client:         client,
+		api:            client,
 		storeContainer: container,
 	}
+	ch.ownJIDs = func() []types.JID {
+		var jids []types.JID
+		if id := client.Store.ID; id != nil {
+			jids = append(jids, *id)
+		}
+		if !client.Store.LID.IsEmpty() {
+			jids = append(jids, client.Store.LID)
+		}
+		return jids
+	}
 	ch.handlerID = ch.client.AddEventHandler(ch.handleEvent)
 
 	return ch, nil
@@ -88,18 +104,24 @@ func (w *WhatsAppChannel) Start(ctx context.Context) error {
 
 	ctx, w.cancel = context.WithCancel(ctx)
 
-	if w.client.Store.ID == nil {
+	if w.client.Store.ID != nil {
+		w.setLoginState(WhatsAppLoginState{Status: WhatsAppLoginPaired, JID: w.client.Store.ID.String(), UpdatedAt: time.Now()})
+		if err := w.client.Connect(); err != nil {
+			w.cancel()
+			return fmt.Errorf("connect whatsapp: %w", err)
+		}
+		log.Printf("[whatsapp] connected")
+	} else {
 		qrChan, err := w.client.GetQRChannel(ctx)
 		if err != nil {
 			w.cancel()
 			return fmt.Errorf("get whatsapp qr channel: %w", err)
 		}
-		go w.consumeQR(ctx, qrChan)
-	}
-
-	if err := w.client.Connect(); err != nil {
-		w.cancel()
-		return fmt.Errorf("connect whatsapp: %w", err)
+		if err := w.client.Connect(); err != nil {
+			w.cancel()
+			return fmt.Errorf("connect whatsapp: %w", err)
+		}
+		go w.loginLoop(ctx, qrChan)
 	}
 
 	go func() {
@@ -107,15 +129,103 @@ func (w *WhatsAppChannel) Start(ctx context.Context) error {
 		w.client.Disconnect()
 	}()
 
-	log.Printf("[whatsapp] connected")
 	return nil
 }
 
+// loginLoop keeps offering QR or pairing codes until a phone links the
+// device, starting a new login whenever the previous one times out.
+func (w *WhatsAppChannel) loginLoop(ctx context.Context, qrChan <-chan whatsmeow.QRChannelItem) {
+	for {
+		login := newWhatsAppLogin(w.client, w.cfg.PairPhone, w.publishLogin)
+		err := login.run(ctx, qrChan)
+		if err == nil {
+			log.Printf("[whatsapp] connected")
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[whatsapp] %v; retrying in %s", err, whatsappLoginRetryDelay)
+		w.client.Disconnect()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(whatsappLoginRetryDelay):
+		}
+		if qrChan, err = w.client.GetQRChannel(ctx); err != nil {
+			log.Printf("[whatsapp] get qr channel failed: %v", err)
+			continue
+		}
+		if err := w.client.Connect(); err != nil {
+			log.Printf("[whatsapp] connect failed: %v", err)
+		}
+	}
+}
+
+// LoginState returns the current login progress for the Web UI.
+func (w *WhatsAppChannel) LoginState() WhatsAppLoginState {
+	w.loginMu.RLock()
+	defer w.loginMu.RUnlock()
+	if w.login.Status == "" {
+		return WhatsAppLoginState{Status: WhatsAppLoginUnpaired}
+	}
+	return w.login
+}
+
+// Health reports whether the device is linked and connected.
+func (w *WhatsAppChannel) Health() ChannelHealth {
+	state := w.LoginState()
+	if state.Status != WhatsAppLoginPaired {
+		detail := "not linked (" + state.Status + ")"
+		if state.Error != "" {
+			detail += ": " + state.Error
+		}
+		return ChannelHealth{Detail: detail}
+	}
+	if w.client == nil || !w.client.IsConnected() {
+		return ChannelHealth{Detail: "disconnected"}
+	}
+	return ChannelHealth{Healthy: true, Detail: "connected as " + state.JID}
+}
+
+func (w *WhatsAppChannel) setLoginState(state WhatsAppLoginState) {
+	w.loginMu.Lock()
+	w.login = state
+	w.loginMu.Unlock()
+	if err := writeWhatsAppLoginState(w.cfg, state); err != nil {
+		log.Printf("[whatsapp] save login state failed: %v", err)
+	}
+}
+
+// publishLogin records a login step and shows it on the terminal and log.
+func (w *WhatsAppChannel) publishLogin(state WhatsAppLoginState) {
+	w.setLoginState(state)
+	switch state.Status {
+	case WhatsAppLoginQR:
+		log.Printf("[whatsapp] waiting for QR scan (also shown in the Web UI and 'myclaw whatsapp status')")
+		printWhatsAppLoginState(os.Stdout, state)
+	case WhatsAppLoginPairingCode:
+		log.Printf("[whatsapp] pairing code: %s (WhatsApp > Linked devices > Link with phone number)", state.PairingCode)
+	case WhatsAppLoginPaired:
+		log.Printf("[whatsapp] paired as %s", state.JID)
+	case WhatsAppLoginFailed:
+		log.Printf("[whatsapp] login failed: %s", state.Error)
+	}
+}
+
 func (w *WhatsAppChannel) Stop() error {
 	if w.cancel != nil {
 		w.cancel()
 	}
 
+	w.typingMu.Lock()
+	for chat, cancel := range w.typing {
+		cancel()
+		delete(w.typing, chat)
+	}
+	w.typingMu.Unlock()
+
 	if w.client != nil {
 		if w.handlerID != 0 {
 			w.client.RemoveEventHandler(w.handlerID)
@@ -136,7 +246,7 @@ func (w *WhatsAppChannel) Stop() error {
 }
 
 func (w *WhatsAppChannel) Send(msg bus.OutboundMessage) error {
-	if w.client == nil {
+	if w.api == nil {
 		return fmt.Errorf("whatsapp client not initialized")
 	}
 
@@ -152,54 +262,35 @@ func (w *WhatsAppChannel) Send(msg bus.OutboundMessage) error {
 	if err != nil {
 		return fmt.Errorf("parse whatsapp chat id %q: %w", chatID, err)
 	}
-
-	content := strings.TrimSpace(msg.Content)
-	if content == "" {
-		return nil
-	}
+	w.stopTyping(chatJID)
 
 	ctx, cancel := context.WithTimeout(context.Background(), whatsappSendTimeout)
 	defer cancel()
 
-	_, err = w.client.SendMessage(ctx, chatJID, &waE2E.Message{
-		Conversation: proto.String(content),
-	})
-	if err != nil {
-		return fmt.Errorf("send whatsapp message: %w", err)
+	if content := strings.TrimSpace(msg.Content); content != "" {
+		_, err = w.api.SendMessage(ctx, chatJID, &waE2E.Message{
+			Conversation: proto.String(content),
+		})
+		if err != nil {
+			return fmt.Errorf("send whatsapp message: %w", err)
+		}
 	}
 
-	return nil
+	return w.sendAttachments(ctx, chatJID, msg)
 }
 
-func (w *WhatsAppChannel) consumeQR(ctx context.Context, qrChan <-chan whatsmeow.QRChannelItem) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case evt, ok := <-qrChan:
-			if !ok {
-				return
-			}
-
-			switch evt.Event {
-			case whatsmeow.QRChannelEventCode:
-				log.Printf("[whatsapp] scan the QR code below to login")
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-			default:
-				if evt.Error != nil {
-					log.Printf("[whatsapp] login event=%s error=%v", evt.Event, evt.Error)
-				} else {
-					log.Printf("[whatsapp] login event=%s", evt.Event)
-				}
-			}
-		}
-	}
+// SetTranscriber sets the speech-to-text backend for voice notes and audio.
+func (w *WhatsAppChannel) SetTranscriber(tr Transcriber) {
+	w.transcriber = tr
 }
 
 func (w *WhatsAppChannel) handleEvent(evt interface{}) {
 	switch e := evt.(type) {
 	case *events.Message:
 		w.handleMessage(e)
+	case *events.LoggedOut:
+		log.Printf("[whatsapp] logged out (reason %v); run 'myclaw whatsapp login' or restart the gateway to link again", e.Reason)
+		w.setLoginState(WhatsAppLoginState{Status: WhatsAppLoginUnpaired, UpdatedAt: time.Now()})
 	}
 }
 
@@ -215,15 +306,28 @@ func (w *WhatsAppChannel) handleMessage(evt *events.Message) {
 		return
 	}
 
-	content, blocks := w.extractContent(evt)
+	if evt.Info.IsGroup && !w.cfg.GroupAllMessages && !w.addressedToBot(evt.Message) {
+		return
+	}
+
+	w.markRead(evt)
+
+	content, blocks, reply := w.extractContent(evt)
+	if reply != "" {
+		w.replyText(evt.Info.Chat, reply)
+		return
+	}
 	if content == "" && len(blocks) == 0 {
 		return
 	}
 
+	w.startTyping(evt.Info.Chat)
+
 	w.bus.Inbound <- bus.InboundMessage{
 		Channel:       whatsappChannelName,
 		SenderID:      sender,
 		ChatID:        evt.Info.Chat.String(),
+		MessageID:     evt.Info.ID,
 		Content:       content,
 		Timestamp:     evt.Info.Timestamp,
 		ContentBlocks: blocks,
@@ -232,46 +336,122 @@ func (w *WhatsAppChannel) handleMessage(evt *events.Message) {
 			"chat_jid":   evt.Info.Chat.String(),
 			"sender_jid": evt.Info.Sender.String(),
 			"push_name":  evt.Info.PushName,
+			"is_group":   evt.Info.IsGroup,
 		},
 	}
 }
 
-func (w *WhatsAppChannel) extractContent(evt *events.Message) (string, []model.ContentBlock) {
-	msg := evt.Message
-	content := strings.TrimSpace(msg.GetConversation())
-	if content == "" && msg.GetExtendedTextMessage() != nil {
-		content = strings.TrimSpace(msg.GetExtendedTextMessage().GetText())
+// addressedToBot reports whether a group message mentions the bot or
+// replies to one of its messages.
+func (w *WhatsAppChannel) addressedToBot(msg *waE2E.Message) bool {
+	if w.ownJIDs == nil {
+		return false
+	}
+	own := w.ownJIDs()
+	isOwn := func(raw string) bool {
+		jid, err := types.ParseJID(raw)
+		if err != nil {
+			return false
+		}
+		for _, o := range own {
+			if jid.User == o.User && jid.Server == o.Server {
+				return true
+			}
+		}
+		return false
 	}
 
-	contentBlocks := make([]model.ContentBlock, 0, 1)
-	if image := msg.GetImageMessage(); image != nil {
-		if content == "" {
-			content = strings.TrimSpace(image.GetCaption())
+	info := whatsappContextInfo(msg)
+	for _, raw := range info.GetMentionedJID() {
+		if isOwn(raw) {
+			return true
 		}
+	}
+	return info.GetQuotedMessage() != nil && isOwn(info.GetParticipant())
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), whatsappInboundImageTimeout)
-		data, err := w.client.Download(ctx, image)
-		cancel()
-		if err != nil {
-			log.Printf("[whatsapp] download image failed: %v", err)
-		} else if len(data) > 0 {
-			mediaType := strings.TrimSpace(image.GetMimetype())
-			if mediaType == "" {
-				mediaType = http.DetectContentType(data)
+func (w *WhatsAppChannel) markRead(evt *events.Message) {
+	if w.api == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), whatsappSendTimeout)
+	defer cancel()
+	if err := w.api.MarkRead(ctx, []types.MessageID{evt.Info.ID}, time.Now(), evt.Info.Chat, evt.Info.Sender); err != nil {
+		log.Printf("[whatsapp] mark read %s failed: %v", evt.Info.ID, err)
+	}
+}
+
+func (w *WhatsAppChannel) replyText(chat types.JID, text string) {
+	if w.api == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), whatsappSendTimeout)
+	defer cancel()
+	if _, err := w.api.SendMessage(ctx, chat, &waE2E.Message{Conversation: proto.String(text)}); err != nil {
+		log.Printf("[whatsapp] reply to %s failed: %v", chat, err)
+	}
+}
+
+// EndRun clears "typing…" once the agent is done, which it may be without
+// replying.
+func (w *WhatsAppChannel) EndRun(chatID string) {
+	chat, err := parseWhatsAppJID(chatID)
+	if err != nil {
+		return
+	}
+	w.stopTyping(chat)
+}
+
+// startTyping shows "typing…" in chat until the reply is sent, the run
+// ends or whatsappTypingMax passes.
+func (w *WhatsAppChannel) startTyping(chat types.JID) {
+	if w.api == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), whatsappTypingMax)
+
+	w.typingMu.Lock()
+	if w.typing == nil {
+		w.typing = make(map[string]context.CancelFunc)
+	}
+	if prev, ok := w.typing[chat.String()]; ok {
+		prev()
+	}
+	w.typing[chat.String()] = cancel
+	w.typingMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(whatsappTypingRefresh)
+		defer ticker.Stop()
+		for {
+			if err := w.api.SendChatPresence(ctx, chat, types.ChatPresenceComposing, types.ChatPresenceMediaText); err != nil {
+				log.Printf("[whatsapp] send typing to %s failed: %v", chat, err)
+				return
 			}
-			if mediaType == "application/octet-stream" {
-				mediaType = "image/jpeg"
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
 			}
-
-			contentBlocks = append(contentBlocks, model.ContentBlock{
-				Type:      model.ContentBlockImage,
-				MediaType: mediaType,
-				Data:      base64.StdEncoding.EncodeToString(data),
-			})
 		}
+	}()
+}
+
+func (w *WhatsAppChannel) stopTyping(chat types.JID) {
+	w.typingMu.Lock()
+	cancel, ok := w.typing[chat.String()]
+	delete(w.typing, chat.String())
+	w.typingMu.Unlock()
+	if !ok {
+		return
 	}
+	cancel()
 
-	return content, contentBlocks
+	ctx, done := context.WithTimeout(context.Background(), whatsappSendTimeout)
+	defer done()
+	if err := w.api.SendChatPresence(ctx, chat, types.ChatPresencePaused, types.ChatPresenceMediaText); err != nil {
+		log.Printf("[whatsapp] clear typing in %s failed: %v", chat, err)
+	}
 }
 
 func parseWhatsAppJID(raw string) (types.JID, error) {
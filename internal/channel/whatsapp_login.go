@@ -0,0 +1,305 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	qrterminal "github.com/mdp/qrterminal/v3"
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// WhatsApp login states reported in WhatsAppLoginState.Status.
+const (
+	WhatsAppLoginUnpaired    = "unpaired"
+	WhatsAppLoginQR          = "qr"
+	WhatsAppLoginPairingCode = "pairing_code"
+	WhatsAppLoginPaired      = "paired"
+	WhatsAppLoginFailed      = "failed"
+)
+
+const (
+	// whatsappPairClientName must be a "Browser (OS)" pair the server accepts.
+	whatsappPairClientName = "Chrome (Linux)"
+	// whatsappLoginRetryDelay separates gateway login attempts; each attempt
+	// lasts until the server closes the login socket (about 160s).
+	whatsappLoginRetryDelay = 30 * time.Second
+	whatsappLogoutTimeout   = 30 * time.Second
+)
+
+// WhatsAppLoginState is the login progress. The gateway writes it next to the
+// session store so the Web UI, `myclaw status` and `myclaw whatsapp status`
+// can show the current QR or pairing code when no terminal is attached.
+type WhatsAppLoginState struct {
+	Status      string    `json:"status"`
+	JID         string    `json:"jid,omitempty"`
+	QRCode      string    `json:"qrCode,omitempty"`
+	PairingCode string    `json:"pairingCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func whatsappStorePath(cfg config.WhatsAppConfig) string {
+	if p := strings.TrimSpace(cfg.StorePath); p != "" {
+		return p
+	}
+	return filepath.Join(config.ConfigDir(), "whatsapp-store.db")
+}
+
+func whatsappLoginStatePath(cfg config.WhatsAppConfig) string {
+	return filepath.Join(filepath.Dir(whatsappStorePath(cfg)), "whatsapp-login.json")
+}
+
+func openWhatsAppStore(cfg config.WhatsAppConfig) (*sqlstore.Container, *store.Device, error) {
+	storePath := whatsappStorePath(cfg)
+	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return nil, nil, fmt.Errorf("create whatsapp store dir: %w", err)
+	}
+
+	storeDSN := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)", filepath.ToSlash(storePath))
+	container, err := sqlstore.New(context.Background(), "sqlite", storeDSN, waLog.Noop)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init whatsapp session store: %w", err)
+	}
+
+	device, err := container.GetFirstDevice(context.Background())
+	if err != nil {
+		_ = container.Close()
+		return nil, nil, fmt.Errorf("get whatsapp device: %w", err)
+	}
+	return container, device, nil
+}
+
+// ReadWhatsAppLoginState returns the last state written by a login, or an
+// unpaired state when there is none.
+func ReadWhatsAppLoginState(cfg config.WhatsAppConfig) (WhatsAppLoginState, error) {
+	data, err := os.ReadFile(whatsappLoginStatePath(cfg))
+	if errors.Is(err, os.ErrNotExist) {
+		return WhatsAppLoginState{Status: WhatsAppLoginUnpaired}, nil
+	}
+	if err != nil {
+		return WhatsAppLoginState{}, fmt.Errorf("read whatsapp login state: %w", err)
+	}
+	var state WhatsAppLoginState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return WhatsAppLoginState{}, fmt.Errorf("decode whatsapp login state: %w", err)
+	}
+	return state, nil
+}
+
+func writeWhatsAppLoginState(cfg config.WhatsAppConfig, state WhatsAppLoginState) error {
+	path := whatsappLoginStatePath(cfg)
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// WhatsAppStatus reports whether the session store holds a linked device,
+// falling back to the pending QR or pairing code of a running login.
+func WhatsAppStatus(cfg config.WhatsAppConfig) (WhatsAppLoginState, error) {
+	if _, err := os.Stat(whatsappStorePath(cfg)); errors.Is(err, os.ErrNotExist) {
+		return WhatsAppLoginState{Status: WhatsAppLoginUnpaired}, nil
+	}
+	container, device, err := openWhatsAppStore(cfg)
+	if err != nil {
+		return WhatsAppLoginState{}, err
+	}
+	defer container.Close()
+
+	if device.ID != nil {
+		return WhatsAppLoginState{Status: WhatsAppLoginPaired, JID: device.ID.String()}, nil
+	}
+	state, err := ReadWhatsAppLoginState(cfg)
+	if err != nil {
+		return WhatsAppLoginState{}, err
+	}
+	if state.Status == WhatsAppLoginPaired {
+		// The device was removed after the state was written.
+		state = WhatsAppLoginState{Status: WhatsAppLoginUnpaired}
+	}
+	return state, nil
+}
+
+// WhatsAppLogin links a device without starting the gateway. It prints the
+// QR code (or the pairing code when cfg.PairPhone is set) to out and returns
+// once the phone confirms or the login times out.
+func WhatsAppLogin(ctx context.Context, cfg config.WhatsAppConfig, out io.Writer) error {
+	container, device, err := openWhatsAppStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer container.Close()
+
+	if device.ID != nil {
+		fmt.Fprintf(out, "WhatsApp already paired as %s (run 'myclaw whatsapp logout' first to link another account)\n", device.ID)
+		return nil
+	}
+
+	client := whatsmeow.NewClient(device, waLog.Noop)
+	qrChan, err := client.GetQRChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("get whatsapp qr channel: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connect whatsapp: %w", err)
+	}
+	defer client.Disconnect()
+
+	login := newWhatsAppLogin(client, cfg.PairPhone, func(state WhatsAppLoginState) {
+		if err := writeWhatsAppLoginState(cfg, state); err != nil {
+			fmt.Fprintf(out, "warning: save login state: %v\n", err)
+		}
+		printWhatsAppLoginState(out, state)
+	})
+	return login.run(ctx, qrChan)
+}
+
+// WhatsAppLogout unlinks the device on the phone and removes the local session.
+func WhatsAppLogout(ctx context.Context, cfg config.WhatsAppConfig) error {
+	container, device, err := openWhatsAppStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer container.Close()
+
+	if device.ID == nil {
+		return fmt.Errorf("whatsapp is not paired")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, whatsappLogoutTimeout)
+	defer cancel()
+
+	client := whatsmeow.NewClient(device, waLog.Noop)
+	err = client.Connect()
+	if err == nil {
+		err = client.Logout(ctx)
+		client.Disconnect()
+	}
+	if err != nil {
+		// Offline or already unlinked on the phone: drop the local session anyway.
+		if delErr := device.Delete(ctx); delErr != nil {
+			return fmt.Errorf("logout whatsapp: %w (delete local session: %v)", err, delErr)
+		}
+	}
+	return writeWhatsAppLoginState(cfg, WhatsAppLoginState{Status: WhatsAppLoginUnpaired, UpdatedAt: time.Now()})
+}
+
+// whatsappLogin drives one QR channel until pairing succeeds or the server
+// closes the login socket.
+type whatsappLogin struct {
+	// pair requests a phone pairing code; nil logs in with QR codes.
+	pair    func(ctx context.Context) (string, error)
+	self    func() string
+	publish func(WhatsAppLoginState)
+}
+
+func newWhatsAppLogin(client *whatsmeow.Client, pairPhone string, publish func(WhatsAppLoginState)) *whatsappLogin {
+	l := &whatsappLogin{
+		self: func() string {
+			if id := client.Store.ID; id != nil {
+				return id.String()
+			}
+			return ""
+		},
+		publish: publish,
+	}
+	if phone := normalizeWhatsAppPhone(pairPhone); phone != "" {
+		l.pair = func(ctx context.Context) (string, error) {
+			return client.PairPhone(ctx, phone, true, whatsmeow.PairClientChrome, whatsappPairClientName)
+		}
+	}
+	return l
+}
+
+func (l *whatsappLogin) run(ctx context.Context, qrChan <-chan whatsmeow.QRChannelItem) error {
+	pairingCode := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case evt, ok := <-qrChan:
+			if !ok {
+				return fmt.Errorf("whatsapp login channel closed")
+			}
+
+			switch evt.Event {
+			case whatsmeow.QRChannelEventCode:
+				// The pairing code stays valid while QR codes rotate.
+				if pairingCode != "" {
+					continue
+				}
+				if l.pair != nil {
+					code, err := l.pair(ctx)
+					if err == nil {
+						pairingCode = code
+						l.publish(WhatsAppLoginState{Status: WhatsAppLoginPairingCode, PairingCode: code, UpdatedAt: time.Now()})
+						continue
+					}
+					// Fall back to QR codes, reporting why.
+					l.pair = nil
+					l.publish(WhatsAppLoginState{Status: WhatsAppLoginQR, QRCode: evt.Code, Error: "pairing code: " + err.Error(), UpdatedAt: time.Now()})
+					continue
+				}
+				l.publish(WhatsAppLoginState{Status: WhatsAppLoginQR, QRCode: evt.Code, UpdatedAt: time.Now()})
+			case whatsmeow.QRChannelSuccess.Event:
+				l.publish(WhatsAppLoginState{Status: WhatsAppLoginPaired, JID: l.self(), UpdatedAt: time.Now()})
+				return nil
+			default:
+				err := fmt.Errorf("whatsapp login %s", evt.Event)
+				if evt.Error != nil {
+					err = fmt.Errorf("whatsapp login %s: %w", evt.Event, evt.Error)
+				}
+				l.publish(WhatsAppLoginState{Status: WhatsAppLoginFailed, Error: err.Error(), UpdatedAt: time.Now()})
+				return err
+			}
+		}
+	}
+}
+
+func printWhatsAppLoginState(out io.Writer, state WhatsAppLoginState) {
+	switch state.Status {
+	case WhatsAppLoginQR:
+		if state.Error != "" {
+			fmt.Fprintf(out, "%s; falling back to QR code\n", state.Error)
+		}
+		fmt.Fprintln(out, "Scan the QR code below with WhatsApp (Linked devices > Link a device):")
+		qrterminal.GenerateHalfBlock(state.QRCode, qrterminal.L, out)
+	case WhatsAppLoginPairingCode:
+		fmt.Fprintf(out, "Enter this pairing code in WhatsApp (Linked devices > Link a device > Link with phone number): %s\n", state.PairingCode)
+	case WhatsAppLoginPaired:
+		fmt.Fprintf(out, "WhatsApp paired as %s\n", state.JID)
+	case WhatsAppLoginFailed:
+		fmt.Fprintf(out, "WhatsApp login failed: %s\n", state.Error)
+	}
+}
+
+// normalizeWhatsAppPhone keeps the digits of a phone number in
+// international format ("+86 138-0013-8000" -> "8613800138000").
+func normalizeWhatsAppPhone(raw string) string {
+	var b strings.Builder
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
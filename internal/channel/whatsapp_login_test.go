@@ -0,0 +1,112 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"go.mau.fi/whatsmeow"
+)
+
+func runTestWhatsAppLogin(t *testing.T, l *whatsappLogin, items ...whatsmeow.QRChannelItem) ([]WhatsAppLoginState, error) {
+	t.Helper()
+	qrChan := make(chan whatsmeow.QRChannelItem, len(items))
+	for _, item := range items {
+		qrChan <- item
+	}
+	close(qrChan)
+
+	var states []WhatsAppLoginState
+	l.self = func() string { return "8613800138000@s.whatsapp.net" }
+	l.publish = func(s WhatsAppLoginState) { states = append(states, s) }
+	err := l.run(context.Background(), qrChan)
+	return states, err
+}
+
+func qrItem(code string) whatsmeow.QRChannelItem {
+	return whatsmeow.QRChannelItem{Event: whatsmeow.QRChannelEventCode, Code: code}
+}
+
+func TestWhatsAppLogin_QR(t *testing.T) {
+	states, err := runTestWhatsAppLogin(t, &whatsappLogin{}, qrItem("qr-1"), qrItem("qr-2"), whatsmeow.QRChannelSuccess)
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if len(states) != 3 || states[0].QRCode != "qr-1" || states[1].QRCode != "qr-2" {
+		t.Fatalf("states = %+v", states)
+	}
+	if last := states[2]; last.Status != WhatsAppLoginPaired || last.JID != "8613800138000@s.whatsapp.net" {
+		t.Fatalf("final state = %+v", last)
+	}
+}
+
+func TestWhatsAppLogin_PairingCode(t *testing.T) {
+	calls := 0
+	l := &whatsappLogin{pair: func(ctx context.Context) (string, error) {
+		calls++
+		return "ABCD-EFGH", nil
+	}}
+	states, err := runTestWhatsAppLogin(t, l, qrItem("qr-1"), qrItem("qr-2"), whatsmeow.QRChannelSuccess)
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("PairPhone calls = %d, want 1", calls)
+	}
+	// Rotated QR codes do not replace the pairing code.
+	if len(states) != 2 || states[0].Status != WhatsAppLoginPairingCode || states[0].PairingCode != "ABCD-EFGH" || states[0].QRCode != "" {
+		t.Fatalf("states = %+v", states)
+	}
+}
+
+func TestWhatsAppLogin_PairingCodeFallsBackToQR(t *testing.T) {
+	l := &whatsappLogin{pair: func(ctx context.Context) (string, error) {
+		return "", errors.New("rate limited")
+	}}
+	states, err := runTestWhatsAppLogin(t, l, qrItem("qr-1"), qrItem("qr-2"), whatsmeow.QRChannelTimeout)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+	if states[0].Status != WhatsAppLoginQR || states[0].QRCode != "qr-1" || !strings.Contains(states[0].Error, "rate limited") {
+		t.Fatalf("first state = %+v", states[0])
+	}
+	if states[1].Status != WhatsAppLoginQR || states[1].Error != "" {
+		t.Fatalf("second state = %+v", states[1])
+	}
+	if last := states[len(states)-1]; last.Status != WhatsAppLoginFailed {
+		t.Fatalf("final state = %+v", last)
+	}
+}
+
+func TestWhatsAppLoginState_RoundTrip(t *testing.T) {
+	cfg := config.WhatsAppConfig{StorePath: filepath.Join(t.TempDir(), "wa", "store.db")}
+
+	state, err := ReadWhatsAppLoginState(cfg)
+	if err != nil || state.Status != WhatsAppLoginUnpaired {
+		t.Fatalf("missing state = %+v, %v", state, err)
+	}
+	if got, err := WhatsAppStatus(cfg); err != nil || got.Status != WhatsAppLoginUnpaired {
+		t.Fatalf("WhatsAppStatus without store = %+v, %v", got, err)
+	}
+
+	want := WhatsAppLoginState{Status: WhatsAppLoginPairingCode, PairingCode: "ABCD-EFGH"}
+	if err := writeWhatsAppLoginState(cfg, want); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got, err := ReadWhatsAppLoginState(cfg)
+	if err != nil || got.Status != want.Status || got.PairingCode != want.PairingCode {
+		t.Fatalf("read = %+v, %v", got, err)
+	}
+	if filepath.Dir(whatsappLoginStatePath(cfg)) != filepath.Dir(cfg.StorePath) {
+		t.Fatalf("state path %q not next to store", whatsappLoginStatePath(cfg))
+	}
+}
+
+func TestNormalizeWhatsAppPhone(t *testing.T) {
+	if got := normalizeWhatsAppPhone("+86 138-0013-8000"); got != "8613800138000" {
+		t.Fatalf("normalizeWhatsAppPhone = %q", got)
+	}
+}
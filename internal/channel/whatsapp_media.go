@@ -0,0 +1,314 @@
+package channel
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/agentsdk-go/pkg/model"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	whatsappTranscriptionUnavailableReply = "Sorry, voice messages need speech-to-text, which is not configured. Please send text instead."
+	whatsappTranscriptionFailedReply      = "Sorry, I couldn't transcribe that voice message. Please try again or send text."
+	// whatsappQuoteMaxRunes bounds the quoted text added as context.
+	whatsappQuoteMaxRunes = 500
+)
+
+// extractContent turns an inbound message into text and content blocks.
+// A non-empty reply is sent back to the user instead of running the agent.
+func (w *WhatsAppChannel) extractContent(evt *events.Message) (content string, blocks []model.ContentBlock, reply string) {
+	msg := evt.Message
+	content = strings.TrimSpace(msg.GetConversation())
+	if content == "" && msg.GetExtendedTextMessage() != nil {
+		content = strings.TrimSpace(msg.GetExtendedTextMessage().GetText())
+	}
+
+	var notes []string
+	if image := msg.GetImageMessage(); image != nil {
+		if content == "" {
+			content = strings.TrimSpace(image.GetCaption())
+		}
+		if data, err := w.download(image); err != nil {
+			log.Printf("[whatsapp] download image failed: %v", err)
+		} else if len(data) > 0 {
+			mediaType := whatsappMediaType(image.GetMimetype(), "", data)
+			if !strings.HasPrefix(mediaType, "image/") {
+				mediaType = "image/jpeg"
+			}
+			blocks = append(blocks, whatsappBlock(model.ContentBlockImage, mediaType, data))
+		}
+	}
+
+	if doc := msg.GetDocumentMessage(); doc != nil {
+		if content == "" {
+			content = strings.TrimSpace(doc.GetCaption())
+		}
+		name := strings.TrimSpace(doc.GetFileName())
+		if name == "" {
+			name = strings.TrimSpace(doc.GetTitle())
+		}
+		if data, err := w.download(doc); err != nil {
+			log.Printf("[whatsapp] download document %q failed: %v", name, err)
+			notes = append(notes, strings.TrimSpace("[Document] "+name+" (download failed)"))
+		} else {
+			mediaType := whatsappMediaType(doc.GetMimetype(), name, data)
+			// Use image block type for image MIME types sent as documents
+			blockType := model.ContentBlockDocument
+			if strings.HasPrefix(mediaType, "image/") {
+				blockType = model.ContentBlockImage
+			}
+			notes = append(notes, strings.TrimSpace("[Document] "+name))
+			blocks = append(blocks, whatsappBlock(blockType, mediaType, data))
+		}
+	}
+
+	if audio := msg.GetAudioMessage(); audio != nil {
+		transcript, err := w.transcribeAudio(audio)
+		if err != nil {
+			log.Printf("[whatsapp] transcribe audio failed: %v", err)
+			return "", nil, whatsappTranscriptionFailedReply
+		}
+		if transcript == "" {
+			return "", nil, whatsappTranscriptionUnavailableReply
+		}
+		notes = append(notes, "[Voice message transcript]\n"+transcript)
+	}
+
+	if video := msg.GetVideoMessage(); video != nil {
+		if content == "" {
+			content = strings.TrimSpace(video.GetCaption())
+		}
+		notes = append(notes, fmt.Sprintf("[Video] duration=%ds %dx%d (thumbnail frame attached when available)",
+			video.GetSeconds(), video.GetWidth(), video.GetHeight()))
+		if thumb := video.GetJPEGThumbnail(); len(thumb) > 0 {
+			blocks = append(blocks, whatsappBlock(model.ContentBlockImage, "image/jpeg", thumb))
+		}
+	}
+
+	if quoted := whatsappQuotedText(msg); quoted != "" {
+		notes = append([]string{"[Replying to]\n> " + strings.ReplaceAll(quoted, "\n", "\n> ")}, notes...)
+	}
+
+	if len(notes) > 0 {
+		if content != "" {
+			notes = append(notes, content)
+		}
+		content = strings.Join(notes, "\n\n")
+	}
+	return content, blocks, ""
+}
+
+func (w *WhatsAppChannel) download(msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	if w.api == nil {
+		return nil, fmt.Errorf("whatsapp client not initialized")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), whatsappInboundImageTimeout)
+	defer cancel()
+	return w.api.Download(ctx, msg)
+}
+
+// transcribeAudio returns "" without error when no transcriber is configured.
+func (w *WhatsAppChannel) transcribeAudio(audio *waE2E.AudioMessage) (string, error) {
+	if w.transcriber == nil {
+		return "", nil
+	}
+	data, err := w.download(audio)
+	if err != nil {
+		return "", err
+	}
+
+	filename := "audio.ogg"
+	if exts, _ := mime.ExtensionsByType(whatsappMediaType(audio.GetMimetype(), "", nil)); len(exts) > 0 && !audio.GetPTT() {
+		filename = "audio" + exts[0]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), whatsappTranscribeTimeout)
+	defer cancel()
+	text, err := w.transcriber.Transcribe(ctx, filename, data)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "", fmt.Errorf("empty transcript")
+	}
+	return text, nil
+}
+
+// whatsappContextInfo returns the reply/mention context of the message kinds
+// that carry one.
+func whatsappContextInfo(msg *waE2E.Message) *waE2E.ContextInfo {
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// whatsappQuotedText returns the text of the message being replied to.
+func whatsappQuotedText(msg *waE2E.Message) string {
+	quoted := whatsappContextInfo(msg).GetQuotedMessage()
+	if quoted == nil {
+		return ""
+	}
+	var text string
+	switch {
+	case quoted.GetConversation() != "":
+		text = quoted.GetConversation()
+	case quoted.GetExtendedTextMessage() != nil:
+		text = quoted.GetExtendedTextMessage().GetText()
+	case quoted.GetImageMessage() != nil:
+		text = strings.TrimSpace("[Image] " + quoted.GetImageMessage().GetCaption())
+	case quoted.GetDocumentMessage() != nil:
+		text = strings.TrimSpace("[Document] " + quoted.GetDocumentMessage().GetFileName())
+	case quoted.GetVideoMessage() != nil:
+		text = strings.TrimSpace("[Video] " + quoted.GetVideoMessage().GetCaption())
+	case quoted.GetAudioMessage() != nil:
+		text = "[Voice message]"
+	}
+	text = strings.TrimSpace(text)
+	if r := []rune(text); len(r) > whatsappQuoteMaxRunes {
+		text = string(r[:whatsappQuoteMaxRunes]) + "…"
+	}
+	return text
+}
+
+// whatsappMediaType prefers the reported MIME type (without parameters),
+// then the file extension, then content sniffing.
+func whatsappMediaType(reported, fileName string, data []byte) string {
+	if mt, _, err := mime.ParseMediaType(reported); err == nil && mt != "" && mt != "application/octet-stream" {
+		return mt
+	}
+	if fileName != "" {
+		if mt, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName)))); err == nil && mt != "" {
+			return mt
+		}
+	}
+	if len(data) > 0 {
+		if mt, _, err := mime.ParseMediaType(http.DetectContentType(data)); err == nil {
+			return mt
+		}
+	}
+	return "application/octet-stream"
+}
+
+func whatsappBlock(blockType model.ContentBlockType, mediaType string, data []byte) model.ContentBlock {
+	return model.ContentBlock{
+		Type:      blockType,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+// whatsappAttachment is an outbound image or document.
+type whatsappAttachment struct {
+	name      string
+	mediaType string
+	data      []byte
+}
+
+// sendAttachments uploads and sends the image and document content blocks
+// and the local files in msg.Media.
+func (w *WhatsAppChannel) sendAttachments(ctx context.Context, chat types.JID, msg bus.OutboundMessage) error {
+	var attachments []whatsappAttachment
+	for i, block := range msg.ContentBlocks {
+		if block.Type != model.ContentBlockImage && block.Type != model.ContentBlockDocument {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(block.Data)
+		if err != nil || len(data) == 0 {
+			log.Printf("[whatsapp] skip attachment %d: no inline data", i)
+			continue
+		}
+		attachments = append(attachments, whatsappAttachment{
+			name:      fmt.Sprintf("attachment-%d", i+1),
+			mediaType: whatsappMediaType(block.MediaType, "", data),
+			data:      data,
+		})
+	}
+	for _, path := range msg.Media {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read whatsapp attachment %q: %w", path, err)
+		}
+		attachments = append(attachments, whatsappAttachment{
+			name:      filepath.Base(path),
+			mediaType: whatsappMediaType("", path, data),
+			data:      data,
+		})
+	}
+
+	for _, a := range attachments {
+		if err := w.sendAttachment(ctx, chat, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WhatsAppChannel) sendAttachment(ctx context.Context, chat types.JID, a whatsappAttachment) error {
+	mediaType := whatsmeow.MediaDocument
+	if strings.HasPrefix(a.mediaType, "image/") {
+		mediaType = whatsmeow.MediaImage
+	}
+	up, err := w.api.Upload(ctx, a.data, mediaType)
+	if err != nil {
+		return fmt.Errorf("upload whatsapp %s: %w", a.name, err)
+	}
+
+	var out *waE2E.Message
+	if mediaType == whatsmeow.MediaImage {
+		out = &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			Mimetype:      proto.String(a.mediaType),
+		}}
+	} else {
+		name := a.name
+		if filepath.Ext(name) == "" {
+			if exts, _ := mime.ExtensionsByType(a.mediaType); len(exts) > 0 {
+				name += exts[0]
+			}
+		}
+		out = &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+			URL:           proto.String(up.URL),
+			DirectPath:    proto.String(up.DirectPath),
+			MediaKey:      up.MediaKey,
+			FileEncSHA256: up.FileEncSHA256,
+			FileSHA256:    up.FileSHA256,
+			FileLength:    proto.Uint64(up.FileLength),
+			Mimetype:      proto.String(a.mediaType),
+			FileName:      proto.String(name),
+			Title:         proto.String(name),
+		}}
+	}
+
+	if _, err := w.api.SendMessage(ctx, chat, out); err != nil {
+		return fmt.Errorf("send whatsapp %s: %w", a.name, err)
+	}
+	return nil
+}
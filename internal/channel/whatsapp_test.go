@@ -1,13 +1,18 @@
 package channel
 
 import (
+	"context"
+	"encoding/base64"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/cexll/agentsdk-go/pkg/model"
 	"github.com/stellarlinkco/myclaw/internal/bus"
 	"github.com/stellarlinkco/myclaw/internal/config"
+	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
@@ -226,3 +231,322 @@ func TestWhatsAppChannel_Stop_NotStarted(t *testing.T) {
 		t.Fatalf("Stop error: %v", err)
 	}
 }
+
+type mockWhatsAppClient struct {
+	mu        sync.Mutex
+	data      []byte
+	sent      []*waE2E.Message
+	uploads   []whatsmeow.MediaType
+	read      []types.MessageID
+	presences []types.ChatPresence
+}
+
+func (m *mockWhatsAppClient) Download(ctx context.Context, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	return m.data, nil
+}
+
+func (m *mockWhatsAppClient) Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads = append(m.uploads, appInfo)
+	return whatsmeow.UploadResponse{URL: "https://mmg.whatsapp.net/x", DirectPath: "/x", FileLength: uint64(len(plaintext))}, nil
+}
+
+func (m *mockWhatsAppClient) SendMessage(ctx context.Context, to types.JID, message *waE2E.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, message)
+	return whatsmeow.SendResponse{}, nil
+}
+
+func (m *mockWhatsAppClient) MarkRead(ctx context.Context, ids []types.MessageID, timestamp time.Time, chat, sender types.JID, receiptTypeExtra ...types.ReceiptType) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.read = append(m.read, ids...)
+	return nil
+}
+
+func (m *mockWhatsAppClient) SendChatPresence(ctx context.Context, jid types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.presences = append(m.presences, state)
+	return nil
+}
+
+var whatsappTestBotJID = types.NewJID("8613900000001", types.DefaultUserServer)
+
+func newWhatsAppTestChannel(cfg config.WhatsAppConfig, api *mockWhatsAppClient) (*WhatsAppChannel, *bus.MessageBus) {
+	b := bus.NewMessageBus(10)
+	ch := &WhatsAppChannel{
+		BaseChannel: NewBaseChannel(whatsappChannelName, b, cfg.AllowFrom),
+		cfg:         cfg,
+		api:         api,
+		ownJIDs:     func() []types.JID { return []types.JID{whatsappTestBotJID} },
+	}
+	return ch, b
+}
+
+func whatsappTestEvent(chat types.JID, msg *waE2E.Message) *events.Message {
+	return &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{
+				Sender:  types.NewJID("8613800138000", types.DefaultUserServer),
+				Chat:    chat,
+				IsGroup: chat.Server == types.GroupServer,
+			},
+			ID:        types.MessageID("msg-1"),
+			Timestamp: time.Now(),
+		},
+		Message: msg,
+	}
+}
+
+func nextWhatsAppInbound(t *testing.T, b *bus.MessageBus) (bus.InboundMessage, bool) {
+	t.Helper()
+	select {
+	case msg := <-b.Inbound:
+		return msg, true
+	default:
+		return bus.InboundMessage{}, false
+	}
+}
+
+func TestWhatsAppChannel_Document(t *testing.T) {
+	api := &mockWhatsAppClient{data: []byte("%PDF-1.4 test")}
+	ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{}, api)
+	chat := types.NewJID("8613800138000", types.DefaultUserServer)
+
+	ch.handleMessage(whatsappTestEvent(chat, &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+		FileName: proto.String("report.pdf"),
+		Mimetype: proto.String("application/pdf"),
+		Caption:  proto.String("summarize this"),
+	}}))
+	defer ch.Stop()
+
+	msg, ok := nextWhatsAppInbound(t, b)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	if msg.Content != "[Document] report.pdf\n\nsummarize this" {
+		t.Fatalf("content = %q", msg.Content)
+	}
+	if len(msg.ContentBlocks) != 1 || msg.ContentBlocks[0].Type != model.ContentBlockDocument || msg.ContentBlocks[0].MediaType != "application/pdf" {
+		t.Fatalf("blocks = %+v", msg.ContentBlocks)
+	}
+	if msg.MessageID != "msg-1" {
+		t.Fatalf("MessageID = %q", msg.MessageID)
+	}
+	if len(api.read) != 1 || api.read[0] != "msg-1" {
+		t.Fatalf("read receipts = %v", api.read)
+	}
+}
+
+func TestWhatsAppChannel_VoiceNote(t *testing.T) {
+	voice := &waE2E.Message{AudioMessage: &waE2E.AudioMessage{PTT: proto.Bool(true), Mimetype: proto.String("audio/ogg; codecs=opus")}}
+	chat := types.NewJID("8613800138000", types.DefaultUserServer)
+
+	t.Run("transcribed", func(t *testing.T) {
+		api := &mockWhatsAppClient{data: []byte("OggS")}
+		ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{}, api)
+		tr := &stubTranscriber{text: "turn on the lights"}
+		ch.SetTranscriber(tr)
+		ch.handleMessage(whatsappTestEvent(chat, voice))
+		defer ch.Stop()
+
+		msg, ok := nextWhatsAppInbound(t, b)
+		if !ok {
+			t.Fatal("expected inbound message")
+		}
+		if msg.Content != "[Voice message transcript]\nturn on the lights" {
+			t.Fatalf("content = %q", msg.Content)
+		}
+		if tr.filename != "audio.ogg" || string(tr.audio) != "OggS" {
+			t.Fatalf("transcriber got %q %q", tr.filename, tr.audio)
+		}
+	})
+
+	t.Run("no transcriber", func(t *testing.T) {
+		api := &mockWhatsAppClient{data: []byte("OggS")}
+		ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{}, api)
+		ch.handleMessage(whatsappTestEvent(chat, voice))
+
+		if _, ok := nextWhatsAppInbound(t, b); ok {
+			t.Fatal("voice note without transcriber should not reach the agent")
+		}
+		if len(api.sent) != 1 || api.sent[0].GetConversation() != whatsappTranscriptionUnavailableReply {
+			t.Fatalf("sent = %v", api.sent)
+		}
+	})
+}
+
+func TestWhatsAppChannel_QuotedAndVideo(t *testing.T) {
+	api := &mockWhatsAppClient{}
+	ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{}, api)
+	chat := types.NewJID("8613800138000", types.DefaultUserServer)
+
+	ch.handleMessage(whatsappTestEvent(chat, &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+		Caption:       proto.String("what is this?"),
+		Seconds:       proto.Uint32(12),
+		Width:         proto.Uint32(640),
+		Height:        proto.Uint32(360),
+		JPEGThumbnail: []byte{0xff, 0xd8, 0xff},
+		ContextInfo: &waE2E.ContextInfo{
+			QuotedMessage: &waE2E.Message{Conversation: proto.String("earlier\nmessage")},
+		},
+	}}))
+	defer ch.Stop()
+
+	msg, ok := nextWhatsAppInbound(t, b)
+	if !ok {
+		t.Fatal("expected inbound message")
+	}
+	want := "[Replying to]\n> earlier\n> message\n\n[Video] duration=12s 640x360 (thumbnail frame attached when available)\n\nwhat is this?"
+	if msg.Content != want {
+		t.Fatalf("content = %q, want %q", msg.Content, want)
+	}
+	if len(msg.ContentBlocks) != 1 || msg.ContentBlocks[0].MediaType != "image/jpeg" {
+		t.Fatalf("blocks = %+v", msg.ContentBlocks)
+	}
+}
+
+func TestWhatsAppChannel_GroupMentionGating(t *testing.T) {
+	group := types.NewJID("120363000000000000", types.GroupServer)
+	plain := &waE2E.Message{Conversation: proto.String("hello everyone")}
+	mentioned := &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+		Text:        proto.String("@bot hello"),
+		ContextInfo: &waE2E.ContextInfo{MentionedJID: []string{whatsappTestBotJID.String()}},
+	}}
+	replyToBot := &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+		Text: proto.String("and then?"),
+		ContextInfo: &waE2E.ContextInfo{
+			Participant:   proto.String(whatsappTestBotJID.String()),
+			QuotedMessage: &waE2E.Message{Conversation: proto.String("part one")},
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		allGroup bool
+		msg      *waE2E.Message
+		want     bool
+	}{
+		{"plain group message ignored", false, plain, false},
+		{"mention handled", false, mentioned, true},
+		{"reply to bot handled", false, replyToBot, true},
+		{"groupAllMessages handles everything", true, plain, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &mockWhatsAppClient{}
+			ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{GroupAllMessages: tt.allGroup}, api)
+			ch.handleMessage(whatsappTestEvent(group, tt.msg))
+			defer ch.Stop()
+
+			_, got := nextWhatsAppInbound(t, b)
+			if got != tt.want {
+				t.Fatalf("dispatched = %v, want %v", got, tt.want)
+			}
+			if !got && len(api.read) != 0 {
+				t.Fatalf("ignored group message was marked read: %v", api.read)
+			}
+		})
+	}
+}
+
+func TestWhatsAppChannel_TypingAndAttachments(t *testing.T) {
+	api := &mockWhatsAppClient{}
+	ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{}, api)
+	chat := types.NewJID("8613800138000", types.DefaultUserServer)
+
+	ch.handleMessage(whatsappTestEvent(chat, &waE2E.Message{Conversation: proto.String("draw")}))
+	if _, ok := nextWhatsAppInbound(t, b); !ok {
+		t.Fatal("expected inbound message")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		api.mu.Lock()
+		n := len(api.presences)
+		api.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected composing presence")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	pdf := base64.StdEncoding.EncodeToString([]byte("%PDF-1.4"))
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n0000"))
+	err := ch.Send(bus.OutboundMessage{
+		ChatID:  chat.String(),
+		Content: "here you go",
+		ContentBlocks: []model.ContentBlock{
+			{Type: model.ContentBlockImage, MediaType: "image/png", Data: png},
+			{Type: model.ContentBlockDocument, MediaType: "application/pdf", Data: pdf},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if last := api.presences[len(api.presences)-1]; last != types.ChatPresencePaused {
+		t.Fatalf("last presence = %q, want paused", last)
+	}
+	if len(api.sent) != 3 || api.sent[0].GetConversation() != "here you go" {
+		t.Fatalf("sent = %v", api.sent)
+	}
+	if api.sent[1].GetImageMessage().GetMimetype() != "image/png" {
+		t.Fatalf("image = %v", api.sent[1])
+	}
+	if doc := api.sent[2].GetDocumentMessage(); doc.GetFileName() != "attachment-2.pdf" || doc.GetMimetype() != "application/pdf" {
+		t.Fatalf("document = %v", api.sent[2])
+	}
+	if len(api.uploads) != 2 || api.uploads[0] != whatsmeow.MediaImage || api.uploads[1] != whatsmeow.MediaDocument {
+		t.Fatalf("uploads = %v", api.uploads)
+	}
+}
+
+func TestWhatsAppChannel_TypingStopsWhenRunEndsWithoutReply(t *testing.T) {
+	api := &mockWhatsAppClient{}
+	ch, b := newWhatsAppTestChannel(config.WhatsAppConfig{}, api)
+	chat := types.NewJID("8613800138000", types.DefaultUserServer)
+
+	ch.handleMessage(whatsappTestEvent(chat, &waE2E.Message{Conversation: proto.String("thanks")}))
+	if _, ok := nextWhatsAppInbound(t, b); !ok {
+		t.Fatal("expected inbound message")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		api.mu.Lock()
+		n := len(api.presences)
+		api.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected composing presence")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ch.EndRun(chat.String())
+
+	ch.typingMu.Lock()
+	_, typing := ch.typing[chat.String()]
+	ch.typingMu.Unlock()
+	if typing {
+		t.Fatal("typing still refreshed after the run ended")
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if last := api.presences[len(api.presences)-1]; last != types.ChatPresencePaused {
+		t.Fatalf("last presence = %q, want paused", last)
+	}
+	if len(api.sent) != 0 {
+		t.Fatalf("sent = %v, want nothing", api.sent)
+	}
+}
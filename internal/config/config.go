@@ -24,20 +24,33 @@ const (
 
 	MemoryRetrievalModeClassic  = "classic"
 	MemoryRetrievalModeEnhanced = "enhanced"
+	MemoryScopeUser             = "user"
+	MemoryScopeChat             = "chat"
+	MemoryScopeShared           = "shared"
+	MemoryTriggerKeywords       = "keywords"
+	MemoryTriggerAlways         = "always"
+	MemoryTriggerClassifier     = "classifier"
+	MemoryClassifierEmbedding   = "embedding"
+	MemoryClassifierLLM         = "llm"
 	ModelReasoningEffortLow     = "low"
 	ModelReasoningEffortMedium  = "medium"
 	ModelReasoningEffortHigh    = "high"
 	ModelReasoningEffortXHigh   = "xhigh"
+	FeishuModeWebhook           = "webhook"
+	FeishuModeWebSocket         = "websocket"
 
 	DefaultMemoryRetrievalMode           = MemoryRetrievalModeClassic
+	DefaultMemoryScope                   = MemoryScopeUser
 	DefaultMemoryStrongSignalThreshold   = 0.85
 	DefaultMemoryStrongSignalGap         = 0.15
 	DefaultMemoryRetrievalCandidateLimit = 40
 	DefaultMemoryRetrievalRerankLimit    = 20
+	DefaultMemoryTriggerThreshold        = 0.3
 	DefaultMemoryEmbeddingTimeoutMs      = 30000
 	DefaultMemoryEmbeddingBatchSize      = 16
 	DefaultMemoryRerankTimeoutMs         = 30000
 	DefaultMemoryRerankTopN              = 8
+	DefaultTranscriptionTimeoutMs        = 60000
 )
 
 type Config struct {
@@ -51,6 +64,7 @@ type Config struct {
 	AutoCompact   AutoCompactConfig   `json:"autoCompact"`
 	TokenTracking TokenTrackingConfig `json:"tokenTracking"`
 	Gateway       GatewayConfig       `json:"gateway"`
+	Webhooks      WebhooksConfig      `json:"webhooks"`
 	Memory        MemoryConfig        `json:"memory"`
 }
 
@@ -60,6 +74,7 @@ type MemoryConfig struct {
 	ModelReasoningEffort string           `json:"modelReasoningEffort,omitempty"`
 	MaxTokens            int              `json:"maxTokens,omitempty"`
 	DBPath               string           `json:"dbPath,omitempty"`
+	Scope                string           `json:"scope,omitempty"` // user (default), chat or shared
 	Provider             *ProviderConfig  `json:"provider,omitempty"`
 	Extraction           ExtractionConfig `json:"extraction"`
 	Retrieval            RetrievalConfig  `json:"retrieval"`
@@ -79,6 +94,22 @@ type RetrievalConfig struct {
 	StrongSignalGap       float64 `json:"strongSignalGap,omitempty"`
 	CandidateLimit        int     `json:"candidateLimit,omitempty"`
 	RerankLimit           int     `json:"rerankLimit,omitempty"`
+	// Trigger decides which messages recall memories.
+	Trigger RetrievalTriggerConfig `json:"trigger"`
+}
+
+type RetrievalTriggerConfig struct {
+	Mode string `json:"mode,omitempty"` // keywords (default), always or classifier
+	// Keywords are trigger phrases per language ("zh", "en", ...); a
+	// language listed here replaces its built-in phrases.
+	Keywords map[string][]string `json:"keywords,omitempty"`
+	// MinRelevance drops recalled memories less relevant to the message
+	// (0-1) in always mode.
+	MinRelevance float64 `json:"minRelevance,omitempty"`
+	Classifier   string  `json:"classifier,omitempty"` // embedding (default) or llm
+	// Threshold is the similarity to the core profile at which the
+	// embedding classifier triggers.
+	Threshold float64 `json:"threshold,omitempty"`
 }
 
 type EmbeddingConfig struct {
@@ -119,24 +150,39 @@ type ProviderConfig struct {
 }
 
 type ChannelsConfig struct {
-	Telegram TelegramConfig `json:"telegram"`
-	Feishu   FeishuConfig   `json:"feishu"`
-	WeCom    WeComConfig    `json:"wecom"`
-	WhatsApp WhatsAppConfig `json:"whatsapp"`
-	WebUI    WebUIConfig    `json:"webui"`
+	Telegram      TelegramConfig      `json:"telegram"`
+	Feishu        FeishuConfig        `json:"feishu"`
+	WeCom         WeComConfig         `json:"wecom"`
+	WhatsApp      WhatsAppConfig      `json:"whatsapp"`
+	WebUI         WebUIConfig         `json:"webui"`
+	Transcription TranscriptionConfig `json:"transcription"`
+}
+
+// TranscriptionConfig configures speech-to-text for inbound voice/audio messages
+// via an OpenAI-compatible /v1/audio/transcriptions endpoint.
+type TranscriptionConfig struct {
+	Enabled   bool   `json:"enabled"`
+	BaseURL   string `json:"baseUrl,omitempty"` // default https://api.openai.com
+	APIKey    string `json:"apiKey,omitempty"`
+	Model     string `json:"model,omitempty"`    // default whisper-1
+	Language  string `json:"language,omitempty"` // ISO-639-1 hint, optional
+	TimeoutMs int    `json:"timeoutMs,omitempty"`
 }
 
 type TelegramConfig struct {
-	Enabled   bool     `json:"enabled"`
-	Token     string   `json:"token"`
-	AllowFrom []string `json:"allowFrom"`
-	Proxy     string   `json:"proxy,omitempty"`
+	Enabled       bool     `json:"enabled"`
+	Token         string   `json:"token"`
+	AllowFrom     []string `json:"allowFrom"`
+	Proxy         string   `json:"proxy,omitempty"`
+	WebhookURL    string   `json:"webhookUrl,omitempty"`    // public base URL; empty = long polling
+	WebhookSecret string   `json:"webhookSecret,omitempty"` // generated per start if empty
 }
 
 type FeishuConfig struct {
 	Enabled           bool     `json:"enabled"`
 	AppID             string   `json:"appId"`
 	AppSecret         string   `json:"appSecret"`
+	Mode              string   `json:"mode,omitempty"` // "webhook" (default) or "websocket"
 	VerificationToken string   `json:"verificationToken"`
 	EncryptKey        string   `json:"encryptKey,omitempty"`
 	Port              int      `json:"port,omitempty"`
@@ -150,6 +196,16 @@ type WeComConfig struct {
 	ReceiveID      string   `json:"receiveId,omitempty"`
 	Port           int      `json:"port,omitempty"`
 	AllowFrom      []string `json:"allowFrom"`
+	Stream         bool     `json:"stream,omitempty"` // reply with stream messages refreshed while generating
+	// Proactive delivery when no fresh response_url is cached: the app
+	// message API (corpId + corpSecret + agentId) for users, and a group
+	// robot webhook for the group chats listed in webhookChatIds, which is
+	// the group the robot was added to.
+	CorpID         string   `json:"corpId,omitempty"`
+	CorpSecret     string   `json:"corpSecret,omitempty"`
+	AgentID        int64    `json:"agentId,omitempty"`
+	WebhookURL     string   `json:"webhookUrl,omitempty"`
+	WebhookChatIDs []string `json:"webhookChatIds,omitempty"`
 }
 
 type ToolsConfig struct {
@@ -161,6 +217,24 @@ type ToolsConfig struct {
 type GatewayConfig struct {
 	Host string `json:"host"`
 	Port int    `json:"port"`
+	// Approvers ("channel:senderID", e.g. "telegram:12345") may answer any
+	// tool approval in their chats; by default only the requester can.
+	Approvers []string `json:"approvers,omitempty"`
+}
+
+type WebhooksConfig struct {
+	Enabled   bool              `json:"enabled"`
+	Endpoints []WebhookEndpoint `json:"endpoints,omitempty"`
+}
+
+type WebhookEndpoint struct {
+	Name            string `json:"name"`                      // served at /hooks/<name>
+	Secret          string `json:"secret"`                    // shared secret for verification
+	Verify          string `json:"verify,omitempty"`          // "hmac-sha256" (default) or "token"
+	SignatureHeader string `json:"signatureHeader,omitempty"` // defaults per verify mode
+	Prompt          string `json:"prompt"`                    // Go template rendered with the payload
+	Channel         string `json:"channel,omitempty"`         // delivery channel, empty = no delivery
+	To              string `json:"to,omitempty"`              // delivery chat ID
 }
 
 type SkillsConfig struct {
@@ -189,11 +263,66 @@ type WhatsAppConfig struct {
 	JID       string   `json:"jid,omitempty"`
 	StorePath string   `json:"storePath,omitempty"`
 	AllowFrom []string `json:"allowFrom,omitempty"`
+	// PairPhone logs in with a pairing code for this phone number (country
+	// code first) instead of a QR code.
+	PairPhone string `json:"pairPhone,omitempty"`
+	// Group messages are handled only when the bot is mentioned or replied
+	// to, unless GroupAllMessages is set.
+	GroupAllMessages bool `json:"groupAllMessages,omitempty"`
 }
 
 type WebUIConfig struct {
-	Enabled   bool     `json:"enabled"`
-	AllowFrom []string `json:"allowFrom,omitempty"`
+	Enabled     bool            `json:"enabled"`
+	AllowFrom   []string        `json:"allowFrom,omitempty"` // user IDs (or OIDC emails) allowed to chat
+	Auth        WebUIAuthConfig `json:"auth,omitempty"`
+	MaxUploadMB int             `json:"maxUploadMB,omitempty"` // per file; default 10
+	Admins      []string        `json:"admins,omitempty"`      // user IDs or OIDC emails allowed on the admin dashboard; default every allowed user
+}
+
+// WebUIAuthConfig enables Web UI login. Without users or an OIDC issuer the
+// Web UI stays open and every connection gets a throwaway "webui-N" identity.
+type WebUIAuthConfig struct {
+	Users           []WebUIUser     `json:"users,omitempty"`
+	OIDC            WebUIOIDCConfig `json:"oidc,omitempty"`
+	SessionSecret   string          `json:"sessionSecret,omitempty"`   // cookie signing key; generated into ~/.myclaw/webui-session.key when empty
+	SessionTTLHours int             `json:"sessionTtlHours,omitempty"` // default 168
+}
+
+// WebUIUser is a local account. ID is the stable SenderID; the user logs in
+// with Token, or with ID and Password (plain text or a bcrypt hash).
+type WebUIUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// webUIEnvUserID is the local user MYCLAW_WEBUI_TOKEN logs in as.
+const webUIEnvUserID = "admin"
+
+// Enabled reports whether the Web UI requires a login.
+func (a WebUIAuthConfig) Enabled() bool {
+	return len(a.Users) > 0 || strings.TrimSpace(a.OIDC.Issuer) != ""
+}
+
+func (a *WebUIAuthConfig) setUserToken(id, token string) {
+	for i := range a.Users {
+		if a.Users[i].ID == id {
+			a.Users[i].Token = token
+			return
+		}
+	}
+	a.Users = append(a.Users, WebUIUser{ID: id, Token: token})
+}
+
+// WebUIOIDCConfig logs users in through an OpenID Connect provider
+// (Keycloak, Authelia, Dex, ...). Users get the ID "oidc:<sub>".
+type WebUIOIDCConfig struct {
+	Issuer       string   `json:"issuer,omitempty"`
+	ClientID     string   `json:"clientId,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	RedirectURL  string   `json:"redirectUrl,omitempty"` // default <origin>/api/auth/oidc/callback
+	Scopes       []string `json:"scopes,omitempty"`      // default openid profile email
 }
 
 type AutoCompactConfig struct {
@@ -236,6 +365,7 @@ func DefaultConfig() *Config {
 		},
 		Memory: MemoryConfig{
 			Enabled: true,
+			Scope:   DefaultMemoryScope,
 			Extraction: ExtractionConfig{
 				QuietGap:    DefaultMemoryQuietGap,
 				TokenBudget: DefaultMemoryTokenBudget,
@@ -247,6 +377,11 @@ func DefaultConfig() *Config {
 				StrongSignalGap:       DefaultMemoryStrongSignalGap,
 				CandidateLimit:        DefaultMemoryRetrievalCandidateLimit,
 				RerankLimit:           DefaultMemoryRetrievalRerankLimit,
+				Trigger: RetrievalTriggerConfig{
+					Mode:       MemoryTriggerKeywords,
+					Classifier: MemoryClassifierEmbedding,
+					Threshold:  DefaultMemoryTriggerThreshold,
+				},
 			},
 			Embedding: EmbeddingConfig{
 				Enabled:   false,
@@ -310,12 +445,35 @@ func LoadConfig() (*Config, error) {
 	if token := os.Getenv("MYCLAW_TELEGRAM_TOKEN"); token != "" {
 		cfg.Channels.Telegram.Token = token
 	}
+	if webhookURL := os.Getenv("MYCLAW_TELEGRAM_WEBHOOK_URL"); webhookURL != "" {
+		cfg.Channels.Telegram.WebhookURL = webhookURL
+	}
+	if secret := os.Getenv("MYCLAW_TELEGRAM_WEBHOOK_SECRET"); secret != "" {
+		cfg.Channels.Telegram.WebhookSecret = secret
+	}
+	if enabled := os.Getenv("MYCLAW_TRANSCRIPTION_ENABLED"); enabled != "" {
+		if parsed, err := strconv.ParseBool(enabled); err == nil {
+			cfg.Channels.Transcription.Enabled = parsed
+		}
+	}
+	if url := os.Getenv("MYCLAW_TRANSCRIPTION_BASE_URL"); url != "" {
+		cfg.Channels.Transcription.BaseURL = url
+	}
+	if key := os.Getenv("MYCLAW_TRANSCRIPTION_API_KEY"); key != "" {
+		cfg.Channels.Transcription.APIKey = key
+	}
+	if model := os.Getenv("MYCLAW_TRANSCRIPTION_MODEL"); model != "" {
+		cfg.Channels.Transcription.Model = model
+	}
 	if appID := os.Getenv("MYCLAW_FEISHU_APP_ID"); appID != "" {
 		cfg.Channels.Feishu.AppID = appID
 	}
 	if appSecret := os.Getenv("MYCLAW_FEISHU_APP_SECRET"); appSecret != "" {
 		cfg.Channels.Feishu.AppSecret = appSecret
 	}
+	if mode := os.Getenv("MYCLAW_FEISHU_MODE"); mode != "" {
+		cfg.Channels.Feishu.Mode = mode
+	}
 	if token := os.Getenv("MYCLAW_WECOM_TOKEN"); token != "" {
 		cfg.Channels.WeCom.Token = token
 	}
@@ -325,6 +483,38 @@ func LoadConfig() (*Config, error) {
 	if receiveID := os.Getenv("MYCLAW_WECOM_RECEIVE_ID"); receiveID != "" {
 		cfg.Channels.WeCom.ReceiveID = receiveID
 	}
+	if corpID := os.Getenv("MYCLAW_WECOM_CORP_ID"); corpID != "" {
+		cfg.Channels.WeCom.CorpID = corpID
+	}
+	if corpSecret := os.Getenv("MYCLAW_WECOM_CORP_SECRET"); corpSecret != "" {
+		cfg.Channels.WeCom.CorpSecret = corpSecret
+	}
+	if agentID := os.Getenv("MYCLAW_WECOM_AGENT_ID"); agentID != "" {
+		if parsed, err := strconv.ParseInt(agentID, 10, 64); err == nil {
+			cfg.Channels.WeCom.AgentID = parsed
+		}
+	}
+	if webhookURL := os.Getenv("MYCLAW_WECOM_WEBHOOK_URL"); webhookURL != "" {
+		cfg.Channels.WeCom.WebhookURL = webhookURL
+	}
+	if chatIDs := os.Getenv("MYCLAW_WECOM_WEBHOOK_CHAT_IDS"); chatIDs != "" {
+		cfg.Channels.WeCom.WebhookChatIDs = strings.Split(chatIDs, ",")
+	}
+	if approvers := os.Getenv("MYCLAW_GATEWAY_APPROVERS"); approvers != "" {
+		cfg.Gateway.Approvers = strings.Split(approvers, ",")
+	}
+	if phone := os.Getenv("MYCLAW_WHATSAPP_PAIR_PHONE"); phone != "" {
+		cfg.Channels.WhatsApp.PairPhone = phone
+	}
+	if token := os.Getenv("MYCLAW_WEBUI_TOKEN"); token != "" {
+		cfg.Channels.WebUI.Auth.setUserToken(webUIEnvUserID, token)
+	}
+	if secret := os.Getenv("MYCLAW_WEBUI_SESSION_SECRET"); secret != "" {
+		cfg.Channels.WebUI.Auth.SessionSecret = secret
+	}
+	if secret := os.Getenv("MYCLAW_WEBUI_OIDC_CLIENT_SECRET"); secret != "" {
+		cfg.Channels.WebUI.Auth.OIDC.ClientSecret = secret
+	}
 	if enabled := os.Getenv("MYCLAW_MEMORY_ENABLED"); enabled != "" {
 		if parsed, err := strconv.ParseBool(enabled); err == nil {
 			cfg.Memory.Enabled = parsed
@@ -364,6 +554,9 @@ func LoadConfig() (*Config, error) {
 	if dailyFlush := os.Getenv("MYCLAW_MEMORY_DAILY_FLUSH"); dailyFlush != "" {
 		cfg.Memory.Extraction.DailyFlush = dailyFlush
 	}
+	if scope := os.Getenv("MYCLAW_MEMORY_SCOPE"); scope != "" {
+		cfg.Memory.Scope = scope
+	}
 	if mode := os.Getenv("MYCLAW_MEMORY_RETRIEVAL_MODE"); mode != "" {
 		cfg.Memory.Retrieval.Mode = mode
 	}
@@ -387,6 +580,22 @@ func LoadConfig() (*Config, error) {
 			cfg.Memory.Retrieval.RerankLimit = parsed
 		}
 	}
+	if mode := os.Getenv("MYCLAW_MEMORY_RETRIEVAL_TRIGGER"); mode != "" {
+		cfg.Memory.Retrieval.Trigger.Mode = mode
+	}
+	if relevance := os.Getenv("MYCLAW_MEMORY_RETRIEVAL_MIN_RELEVANCE"); relevance != "" {
+		if parsed, err := strconv.ParseFloat(relevance, 64); err == nil {
+			cfg.Memory.Retrieval.Trigger.MinRelevance = parsed
+		}
+	}
+	if classifier := os.Getenv("MYCLAW_MEMORY_RETRIEVAL_CLASSIFIER"); classifier != "" {
+		cfg.Memory.Retrieval.Trigger.Classifier = classifier
+	}
+	if threshold := os.Getenv("MYCLAW_MEMORY_RETRIEVAL_CLASSIFIER_THRESHOLD"); threshold != "" {
+		if parsed, err := strconv.ParseFloat(threshold, 64); err == nil {
+			cfg.Memory.Retrieval.Trigger.Threshold = parsed
+		}
+	}
 	if enabled := os.Getenv("MYCLAW_MEMORY_EMBEDDING_ENABLED"); enabled != "" {
 		if parsed, err := strconv.ParseBool(enabled); err == nil {
 			cfg.Memory.Embedding.Enabled = parsed
@@ -465,6 +674,7 @@ func LoadConfig() (*Config, error) {
 	cfg.Agent.ModelReasoningEffort = normalizeModelReasoningEffort(cfg.Agent.ModelReasoningEffort)
 	cfg.Memory.ModelReasoningEffort = normalizeModelReasoningEffort(cfg.Memory.ModelReasoningEffort)
 	cfg.Memory.Retrieval.Mode = normalizeRetrievalMode(cfg.Memory.Retrieval.Mode)
+	cfg.Memory.Scope = normalizeMemoryScope(cfg.Memory.Scope)
 	if cfg.Memory.Retrieval.StrongSignalThreshold < 0 {
 		cfg.Memory.Retrieval.StrongSignalThreshold = DefaultMemoryStrongSignalThreshold
 	}
@@ -477,6 +687,14 @@ func LoadConfig() (*Config, error) {
 	if cfg.Memory.Retrieval.RerankLimit <= 0 {
 		cfg.Memory.Retrieval.RerankLimit = DefaultMemoryRetrievalRerankLimit
 	}
+	cfg.Memory.Retrieval.Trigger.Mode = normalizeRetrievalTrigger(cfg.Memory.Retrieval.Trigger.Mode)
+	cfg.Memory.Retrieval.Trigger.Classifier = normalizeRetrievalClassifier(cfg.Memory.Retrieval.Trigger.Classifier)
+	if cfg.Memory.Retrieval.Trigger.MinRelevance < 0 {
+		cfg.Memory.Retrieval.Trigger.MinRelevance = 0
+	}
+	if cfg.Memory.Retrieval.Trigger.Threshold <= 0 {
+		cfg.Memory.Retrieval.Trigger.Threshold = DefaultMemoryTriggerThreshold
+	}
 	if cfg.Memory.Embedding.Dimension < 0 {
 		cfg.Memory.Embedding.Dimension = 0
 	}
@@ -492,6 +710,9 @@ func LoadConfig() (*Config, error) {
 	if cfg.Memory.Rerank.TopN <= 0 {
 		cfg.Memory.Rerank.TopN = DefaultMemoryRerankTopN
 	}
+	if cfg.Channels.Transcription.TimeoutMs <= 0 {
+		cfg.Channels.Transcription.TimeoutMs = DefaultTranscriptionTimeoutMs
+	}
 
 	return cfg, nil
 }
@@ -526,6 +747,35 @@ func normalizeRetrievalMode(mode string) string {
 	}
 }
 
+func normalizeRetrievalTrigger(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case MemoryTriggerAlways:
+		return MemoryTriggerAlways
+	case MemoryTriggerClassifier:
+		return MemoryTriggerClassifier
+	default:
+		return MemoryTriggerKeywords
+	}
+}
+
+func normalizeRetrievalClassifier(classifier string) string {
+	if strings.EqualFold(strings.TrimSpace(classifier), MemoryClassifierLLM) {
+		return MemoryClassifierLLM
+	}
+	return MemoryClassifierEmbedding
+}
+
+func normalizeMemoryScope(scope string) string {
+	switch strings.ToLower(strings.TrimSpace(scope)) {
+	case MemoryScopeChat:
+		return MemoryScopeChat
+	case MemoryScopeShared:
+		return MemoryScopeShared
+	default:
+		return MemoryScopeUser
+	}
+}
+
 func normalizeModelReasoningEffort(value string) string {
 	switch strings.ToLower(strings.TrimSpace(value)) {
 	case ModelReasoningEffortLow:
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -277,6 +278,8 @@ func TestLoadConfig_TelegramToken(t *testing.T) {
 	setTestHome(t, tmpDir)
 
 	t.Setenv("MYCLAW_TELEGRAM_TOKEN", "test-telegram-token")
+	t.Setenv("MYCLAW_TELEGRAM_WEBHOOK_URL", "https://bot.example.com")
+	t.Setenv("MYCLAW_TELEGRAM_WEBHOOK_SECRET", "hook-secret")
 
 	cfg, err := LoadConfig()
 	if err != nil {
@@ -285,6 +288,87 @@ func TestLoadConfig_TelegramToken(t *testing.T) {
 	if cfg.Channels.Telegram.Token != "test-telegram-token" {
 		t.Errorf("telegram token = %q, want test-telegram-token", cfg.Channels.Telegram.Token)
 	}
+	if cfg.Channels.Telegram.WebhookURL != "https://bot.example.com" {
+		t.Errorf("telegram webhookUrl = %q, want https://bot.example.com", cfg.Channels.Telegram.WebhookURL)
+	}
+	if cfg.Channels.Telegram.WebhookSecret != "hook-secret" {
+		t.Errorf("telegram webhookSecret = %q, want hook-secret", cfg.Channels.Telegram.WebhookSecret)
+	}
+}
+
+func TestLoadConfig_FeishuMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	setTestHome(t, tmpDir)
+
+	t.Setenv("MYCLAW_FEISHU_MODE", FeishuModeWebSocket)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.Channels.Feishu.Mode != FeishuModeWebSocket {
+		t.Errorf("feishu mode = %q, want %q", cfg.Channels.Feishu.Mode, FeishuModeWebSocket)
+	}
+}
+
+func TestLoadConfig_GatewayApproversEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	setTestHome(t, tmpDir)
+
+	t.Setenv("MYCLAW_GATEWAY_APPROVERS", "telegram:1,feishu:ou_2")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if got := strings.Join(cfg.Gateway.Approvers, ","); got != "telegram:1,feishu:ou_2" {
+		t.Errorf("gateway approvers = %q", got)
+	}
+}
+
+func TestLoadConfig_WhatsAppPairPhone(t *testing.T) {
+	tmpDir := t.TempDir()
+	setTestHome(t, tmpDir)
+
+	t.Setenv("MYCLAW_WHATSAPP_PAIR_PHONE", "+8613800138000")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.Channels.WhatsApp.PairPhone != "+8613800138000" {
+		t.Errorf("pairPhone = %q, want +8613800138000", cfg.Channels.WhatsApp.PairPhone)
+	}
+}
+
+func TestLoadConfig_WebUIToken(t *testing.T) {
+	tmpDir := t.TempDir()
+	setTestHome(t, tmpDir)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.Channels.WebUI.Auth.Enabled() {
+		t.Fatal("webui auth should be disabled by default")
+	}
+
+	t.Setenv("MYCLAW_WEBUI_TOKEN", "secret-token")
+	t.Setenv("MYCLAW_WEBUI_SESSION_SECRET", "signing-key")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	auth := cfg.Channels.WebUI.Auth
+	if !auth.Enabled() {
+		t.Fatal("webui auth should be enabled by MYCLAW_WEBUI_TOKEN")
+	}
+	if len(auth.Users) != 1 || auth.Users[0].ID != "admin" || auth.Users[0].Token != "secret-token" {
+		t.Errorf("users = %+v, want admin with env token", auth.Users)
+	}
+	if auth.SessionSecret != "signing-key" {
+		t.Errorf("sessionSecret = %q, want signing-key", auth.SessionSecret)
+	}
 }
 
 func TestLoadConfig_MYCLAWBaseURL(t *testing.T) {
@@ -311,6 +395,11 @@ func TestLoadConfig_WeComEnvOverrides(t *testing.T) {
 	t.Setenv("MYCLAW_WECOM_TOKEN", "wecom-token")
 	t.Setenv("MYCLAW_WECOM_ENCODING_AES_KEY", "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG")
 	t.Setenv("MYCLAW_WECOM_RECEIVE_ID", "wecom-receive-id")
+	t.Setenv("MYCLAW_WECOM_CORP_ID", "ww-corp")
+	t.Setenv("MYCLAW_WECOM_CORP_SECRET", "corp-secret")
+	t.Setenv("MYCLAW_WECOM_AGENT_ID", "1000002")
+	t.Setenv("MYCLAW_WECOM_WEBHOOK_URL", "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=k")
+	t.Setenv("MYCLAW_WECOM_WEBHOOK_CHAT_IDS", "wrGroupA,wrGroupB")
 
 	cfg, err := LoadConfig()
 	if err != nil {
@@ -326,6 +415,15 @@ func TestLoadConfig_WeComEnvOverrides(t *testing.T) {
 	if cfg.Channels.WeCom.ReceiveID != "wecom-receive-id" {
 		t.Errorf("wecom receiveId = %q, want wecom-receive-id", cfg.Channels.WeCom.ReceiveID)
 	}
+	if cfg.Channels.WeCom.CorpID != "ww-corp" || cfg.Channels.WeCom.CorpSecret != "corp-secret" || cfg.Channels.WeCom.AgentID != 1000002 {
+		t.Errorf("wecom app = %q/%q/%d, want ww-corp/corp-secret/1000002", cfg.Channels.WeCom.CorpID, cfg.Channels.WeCom.CorpSecret, cfg.Channels.WeCom.AgentID)
+	}
+	if cfg.Channels.WeCom.WebhookURL != "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=k" {
+		t.Errorf("wecom webhookUrl = %q", cfg.Channels.WeCom.WebhookURL)
+	}
+	if !reflect.DeepEqual(cfg.Channels.WeCom.WebhookChatIDs, []string{"wrGroupA", "wrGroupB"}) {
+		t.Errorf("wecom webhookChatIds = %v", cfg.Channels.WeCom.WebhookChatIDs)
+	}
 }
 
 func TestDefaultConfigMemoryRetrievalClassic(t *testing.T) {
@@ -346,6 +444,9 @@ func TestDefaultConfigMemoryRetrievalClassic(t *testing.T) {
 	if cfg.Memory.Retrieval.RerankLimit != DefaultMemoryRetrievalRerankLimit {
 		t.Errorf("memory.retrieval.rerankLimit = %d, want %d", cfg.Memory.Retrieval.RerankLimit, DefaultMemoryRetrievalRerankLimit)
 	}
+	if cfg.Memory.Retrieval.Trigger.Mode != MemoryTriggerKeywords {
+		t.Errorf("memory.retrieval.trigger.mode = %q, want %q", cfg.Memory.Retrieval.Trigger.Mode, MemoryTriggerKeywords)
+	}
 }
 
 func TestLoadConfigBackwardCompatibleMemoryDefaults(t *testing.T) {
@@ -358,6 +459,7 @@ func TestLoadConfigBackwardCompatibleMemoryDefaults(t *testing.T) {
 	t.Setenv("MYCLAW_MEMORY_RERANK_ENABLED", "")
 	t.Setenv("MYCLAW_MEMORY_EMBEDDING_TIMEOUT_MS", "")
 	t.Setenv("MYCLAW_MEMORY_RERANK_TIMEOUT_MS", "")
+	t.Setenv("MYCLAW_MEMORY_SCOPE", "")
 
 	cfgDir := filepath.Join(tmpDir, ".myclaw")
 	if err := os.MkdirAll(cfgDir, 0755); err != nil {
@@ -407,6 +509,31 @@ func TestLoadConfigBackwardCompatibleMemoryDefaults(t *testing.T) {
 	if cfg.Memory.Rerank.TimeoutMs != DefaultMemoryRerankTimeoutMs {
 		t.Errorf("memory.rerank.timeoutMs = %d, want %d", cfg.Memory.Rerank.TimeoutMs, DefaultMemoryRerankTimeoutMs)
 	}
+	if cfg.Memory.Scope != DefaultMemoryScope {
+		t.Errorf("memory.scope = %q, want %q", cfg.Memory.Scope, DefaultMemoryScope)
+	}
+}
+
+func TestLoadConfigMemoryScope(t *testing.T) {
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"chat", MemoryScopeChat},
+		{" Shared ", MemoryScopeShared},
+		{"bogus", MemoryScopeUser},
+	}
+	for _, tt := range tests {
+		setTestHome(t, t.TempDir())
+		t.Setenv("MYCLAW_MEMORY_SCOPE", tt.env)
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig error: %v", err)
+		}
+		if cfg.Memory.Scope != tt.want {
+			t.Errorf("MYCLAW_MEMORY_SCOPE=%q: memory.scope = %q, want %q", tt.env, cfg.Memory.Scope, tt.want)
+		}
+	}
 }
 
 func TestLoadConfigMemoryRetrievalEnvOverrides(t *testing.T) {
@@ -418,6 +545,10 @@ func TestLoadConfigMemoryRetrievalEnvOverrides(t *testing.T) {
 	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_STRONG_SIGNAL_GAP", "0.25")
 	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_CANDIDATE_LIMIT", "64")
 	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_RERANK_LIMIT", "24")
+	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_TRIGGER", "Classifier")
+	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_MIN_RELEVANCE", "0.4")
+	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_CLASSIFIER", "llm")
+	t.Setenv("MYCLAW_MEMORY_RETRIEVAL_CLASSIFIER_THRESHOLD", "0.5")
 	t.Setenv("MYCLAW_MEMORY_EMBEDDING_ENABLED", "true")
 	t.Setenv("MYCLAW_MEMORY_EMBEDDING_PROVIDER", "ollama")
 	t.Setenv("MYCLAW_MEMORY_EMBEDDING_BASE_URL", "http://localhost:11434/v1")
@@ -455,6 +586,9 @@ func TestLoadConfigMemoryRetrievalEnvOverrides(t *testing.T) {
 	if cfg.Memory.Retrieval.RerankLimit != 24 {
 		t.Errorf("memory.retrieval.rerankLimit = %d, want 24", cfg.Memory.Retrieval.RerankLimit)
 	}
+	if want := (RetrievalTriggerConfig{Mode: MemoryTriggerClassifier, MinRelevance: 0.4, Classifier: MemoryClassifierLLM, Threshold: 0.5}); !reflect.DeepEqual(cfg.Memory.Retrieval.Trigger, want) {
+		t.Errorf("memory.retrieval.trigger = %+v, want %+v", cfg.Memory.Retrieval.Trigger, want)
+	}
 	if !cfg.Memory.Embedding.Enabled {
 		t.Error("memory.embedding.enabled = false, want true")
 	}
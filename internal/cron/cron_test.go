@@ -575,3 +575,102 @@ func TestTruncate(t *testing.T) {
 		}
 	}
 }
+
+func TestService_RunHistory(t *testing.T) {
+	s := NewService(filepath.Join(t.TempDir(), "jobs.json"))
+	fail := false
+	s.OnJob = func(job CronJob) (string, error) {
+		if fail {
+			return "", fmt.Errorf("boom")
+		}
+		return "done", nil
+	}
+
+	job, _ := s.AddJob("history", Schedule{Kind: "every", EveryMs: 60000}, Payload{Message: "x"})
+	for i := 0; i < maxRunHistory+3; i++ {
+		s.executeJob(*job)
+	}
+	fail = true
+	s.executeJob(*job)
+
+	history := s.ListJobs()[0].State.History
+	if len(history) != maxRunHistory {
+		t.Fatalf("history length = %d, want %d", len(history), maxRunHistory)
+	}
+	last := history[len(history)-1]
+	if last.Status != "error" || last.Error != "boom" || last.Manual {
+		t.Errorf("last run = %+v", last)
+	}
+	if history[0].Status != "ok" || history[0].Output != "done" {
+		t.Errorf("first run = %+v", history[0])
+	}
+
+	// History survives a reload.
+	s2 := NewService(s.storePath)
+	if err := s2.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s2.ListJobs()[0].State.History); got != maxRunHistory {
+		t.Errorf("reloaded history length = %d", got)
+	}
+}
+
+func TestService_RunJob(t *testing.T) {
+	s := NewService(filepath.Join(t.TempDir(), "jobs.json"))
+	ran := make(chan string, 1)
+	s.OnJob = func(job CronJob) (string, error) {
+		ran <- job.ID
+		return "ok", nil
+	}
+
+	job, _ := s.AddJob("manual", Schedule{Kind: "every", EveryMs: 60000}, Payload{Message: "x"})
+	if _, err := s.EnableJob(job.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunJob(job.ID); err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	select {
+	case id := <-ran:
+		if id != job.ID {
+			t.Errorf("ran %q, want %q", id, job.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+	if err := s.RunJob("missing"); err == nil {
+		t.Error("RunJob of an unknown job should fail")
+	}
+
+	// Wait for the run to be recorded.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if h := s.ListJobs()[0].State.History; len(h) == 1 {
+			if !h[0].Manual {
+				t.Errorf("run = %+v, want manual", h[0])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("manual run not recorded")
+}
+
+func TestService_ListJobs_NextRun(t *testing.T) {
+	s := NewService(filepath.Join(t.TempDir(), "jobs.json"))
+	every, _ := s.AddJob("every", Schedule{Kind: "every", EveryMs: 60000}, Payload{Message: "x"})
+	at, _ := s.AddJob("at", Schedule{Kind: "at", AtMs: 1_900_000_000_000}, Payload{Message: "x"})
+	off, _ := s.AddJob("off", Schedule{Kind: "at", AtMs: 1_900_000_000_000}, Payload{Message: "x"})
+	_, _ = s.EnableJob(off.ID, false)
+
+	s.mu.Lock()
+	s.jobs[0].State.LastRunAtMs = 1_000
+	s.mu.Unlock()
+
+	want := map[string]int64{every.ID: 61_000, at.ID: 1_900_000_000_000, off.ID: 0}
+	for _, job := range s.ListJobs() {
+		if job.State.NextRunAtMs != want[job.ID] {
+			t.Errorf("%s next run = %d, want %d", job.Name, job.State.NextRunAtMs, want[job.ID])
+		}
+	}
+}
@@ -13,6 +13,13 @@ import (
 	rcron "github.com/robfig/cron/v3"
 )
 
+const (
+	// maxRunHistory bounds the runs kept in JobState.History.
+	maxRunHistory = 20
+	// runOutputMax bounds the output kept per run.
+	runOutputMax = 500
+)
+
 type Service struct {
 	storePath string
 	mu        sync.Mutex
@@ -84,6 +91,10 @@ func (s *Service) registerJob(job *CronJob) {
 }
 
 func (s *Service) executeJob(job CronJob) {
+	s.runJob(job, false)
+}
+
+func (s *Service) runJob(job CronJob, manual bool) {
 	log.Printf("[cron] executing job %s (%s)", job.Name, job.ID)
 
 	if s.OnJob == nil {
@@ -91,7 +102,19 @@ func (s *Service) executeJob(job CronJob) {
 		return
 	}
 
+	started := time.Now()
 	result, err := s.OnJob(job)
+	run := RunRecord{
+		StartedAtMs: started.UnixMilli(),
+		DurationMs:  time.Since(started).Milliseconds(),
+		Status:      "ok",
+		Output:      truncate(result, runOutputMax),
+		Manual:      manual,
+	}
+	if err != nil {
+		run.Status = "error"
+		run.Error = err.Error()
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -109,6 +132,10 @@ func (s *Service) executeJob(job CronJob) {
 				s.jobs[i].State.LastError = ""
 				log.Printf("[cron] job %s result: %s", job.Name, truncate(result, 100))
 			}
+			s.jobs[i].State.History = append(s.jobs[i].State.History, run)
+			if extra := len(s.jobs[i].State.History) - maxRunHistory; extra > 0 {
+				s.jobs[i].State.History = s.jobs[i].State.History[extra:]
+			}
 
 			if s.jobs[i].DeleteAfterRun {
 				if entryID, ok := s.entryMap[jobID]; ok && s.cron != nil {
@@ -227,14 +254,61 @@ func (s *Service) RemoveJob(id string) bool {
 	return false
 }
 
+// ListJobs returns a copy of the jobs with State.NextRunAtMs filled in for
+// enabled jobs (0 when unknown, e.g. before Start).
 func (s *Service) ListJobs() []CronJob {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	result := make([]CronJob, len(s.jobs))
 	copy(result, s.jobs)
+	for i := range result {
+		result[i].State.NextRunAtMs = s.nextRunLocked(result[i])
+	}
 	return result
 }
 
+func (s *Service) nextRunLocked(job CronJob) int64 {
+	if !job.Enabled {
+		return 0
+	}
+	switch job.Schedule.Kind {
+	case "cron":
+		if entryID, ok := s.entryMap[job.ID]; ok && s.cron != nil {
+			if next := s.cron.Entry(entryID).Next; !next.IsZero() {
+				return next.UnixMilli()
+			}
+		}
+	case "every":
+		if job.Schedule.EveryMs > 0 {
+			return job.State.LastRunAtMs + job.Schedule.EveryMs
+		}
+	case "at":
+		return job.Schedule.AtMs
+	}
+	return 0
+}
+
+// RunJob executes a job now, in the background, whether or not it is enabled.
+func (s *Service) RunJob(id string) error {
+	s.mu.Lock()
+	var (
+		job   CronJob
+		found bool
+	)
+	for _, j := range s.jobs {
+		if j.ID == id {
+			job, found = j, true
+			break
+		}
+	}
+	s.mu.Unlock()
+	if !found {
+		return fmt.Errorf("job %s not found", id)
+	}
+	go s.runJob(job, true)
+	return nil
+}
+
 func (s *Service) EnableJob(id string, enabled bool) (*CronJob, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -20,10 +20,21 @@ type Payload struct {
 }
 
 type JobState struct {
-	NextRunAtMs int64  `json:"nextRunAtMs"`
-	LastRunAtMs int64  `json:"lastRunAtMs"`
-	LastStatus  string `json:"lastStatus"` // "ok" | "error"
-	LastError   string `json:"lastError"`
+	NextRunAtMs int64       `json:"nextRunAtMs"`
+	LastRunAtMs int64       `json:"lastRunAtMs"`
+	LastStatus  string      `json:"lastStatus"` // "ok" | "error"
+	LastError   string      `json:"lastError"`
+	History     []RunRecord `json:"history,omitempty"` // most recent last, at most maxRunHistory
+}
+
+// RunRecord is one execution of a job.
+type RunRecord struct {
+	StartedAtMs int64  `json:"startedAtMs"`
+	DurationMs  int64  `json:"durationMs"`
+	Status      string `json:"status"` // "ok" | "error"
+	Error       string `json:"error,omitempty"`
+	Output      string `json:"output,omitempty"` // truncated
+	Manual      bool   `json:"manual,omitempty"` // started with RunJob
 }
 
 type CronJob struct {
@@ -0,0 +1,333 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/channel"
+	"github.com/stellarlinkco/myclaw/internal/cron"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+const (
+	// adminSessionWindow is how long a session counts as active after its
+	// last message.
+	adminSessionWindow = time.Hour
+	// adminMaxSessions bounds the sessions listed on the dashboard.
+	adminMaxSessions = 100
+	// adminMaxErrors bounds the recent errors kept for the dashboard.
+	adminMaxErrors = 50
+)
+
+// TokenStatsRuntime is implemented by runtimes that track token usage
+// (tokenTracking.enabled).
+type TokenStatsRuntime interface {
+	GetTotalStats() *api.SessionTokenStats
+}
+
+func (r *runtimeAdapter) GetTotalStats() *api.SessionTokenStats {
+	return r.rt.GetTotalStats()
+}
+
+// adminSession is one agent session seen since the gateway started.
+type adminSession struct {
+	Key        string    `json:"key"`
+	Channel    string    `json:"channel"`
+	ChatID     string    `json:"chatId"`
+	SenderID   string    `json:"senderId"`
+	Messages   int       `json:"messages"`
+	LastActive time.Time `json:"lastActive"`
+	Running    bool      `json:"running"` // the agent is working on a message
+}
+
+// sessionTracker records inbound activity per session. A nil tracker
+// ignores everything.
+type sessionTracker struct {
+	mu    sync.Mutex
+	byKey map[string]*adminSession
+	now   func() time.Time
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{byKey: map[string]*adminSession{}, now: time.Now}
+}
+
+func (t *sessionTracker) begin(msg bus.InboundMessage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := msg.SessionKey()
+	s, ok := t.byKey[key]
+	if !ok {
+		s = &adminSession{Key: key, Channel: msg.Channel, ChatID: msg.ChatID}
+		t.byKey[key] = s
+	}
+	s.SenderID = msg.SenderID
+	s.Messages++
+	s.LastActive = t.now()
+	s.Running = true
+}
+
+func (t *sessionTracker) end(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, ok := t.byKey[key]; ok {
+		s.Running = false
+		s.LastActive = t.now()
+	}
+}
+
+// active returns running sessions and those active within
+// adminSessionWindow, most recent first. Older sessions are forgotten.
+func (t *sessionTracker) active() []adminSession {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := t.now().Add(-adminSessionWindow)
+	out := make([]adminSession, 0, len(t.byKey))
+	for key, s := range t.byKey {
+		if !s.Running && s.LastActive.Before(cutoff) {
+			delete(t.byKey, key)
+			continue
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastActive.After(out[j].LastActive) })
+	if len(out) > adminMaxSessions {
+		out = out[:adminMaxSessions]
+	}
+	return out
+}
+
+// adminError is a recent error or warning from the log.
+type adminError struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source,omitempty"` // the [prefix] of the log line
+	Message string    `json:"message"`
+}
+
+// errorLog tees the standard logger and keeps its recent error and warning
+// lines, so the dashboard shows what would otherwise need tailing the log.
+type errorLog struct {
+	mu      sync.Mutex
+	out     io.Writer
+	entries []adminError
+	now     func() time.Time
+}
+
+func newErrorLog() *errorLog {
+	return &errorLog{now: time.Now}
+}
+
+// capture routes the standard logger through l until the returned func is called.
+func (l *errorLog) capture() func() {
+	l.mu.Lock()
+	l.out = log.Writer()
+	l.mu.Unlock()
+	log.SetOutput(l)
+	return func() { log.SetOutput(l.out) }
+}
+
+func (l *errorLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record(string(p))
+	if l.out == nil {
+		return len(p), nil
+	}
+	return l.out.Write(p)
+}
+
+func (l *errorLog) record(line string) {
+	line = strings.TrimSpace(line)
+	// Drop the logger's timestamp: messages start at their "[prefix]".
+	e := adminError{Time: l.now(), Message: line}
+	if i := strings.Index(line, "["); i >= 0 && i <= 30 {
+		e.Message = line[i:]
+		if j := strings.Index(e.Message, "]"); j > 0 {
+			e.Source = e.Message[1:j]
+			e.Message = strings.TrimSpace(e.Message[j+1:])
+		}
+	}
+	if !isErrorMessage(e.Message) {
+		return
+	}
+	l.entries = append(l.entries, e)
+	if extra := len(l.entries) - adminMaxErrors; extra > 0 {
+		l.entries = l.entries[extra:]
+	}
+}
+
+// isErrorMessage looks for error words before the first ": ", so the user
+// text quoted in lines like "inbound from x: ..." does not count.
+func isErrorMessage(msg string) bool {
+	head, _, _ := strings.Cut(strings.ToLower(msg), ": ")
+	for _, word := range []string{"error", "fail", "warning", "panic"} {
+		if strings.Contains(head, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// recent returns the kept errors, newest first.
+func (l *errorLog) recent() []adminError {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]adminError, len(l.entries))
+	for i, e := range l.entries {
+		out[len(out)-1-i] = e
+	}
+	return out
+}
+
+type adminQueue struct {
+	Inbound     int `json:"inbound"`
+	InboundCap  int `json:"inboundCap"`
+	Outbound    int `json:"outbound"`
+	OutboundCap int `json:"outboundCap"`
+	Callbacks   int `json:"callbacks"`
+}
+
+type adminStatus struct {
+	StartedAt     time.Time               `json:"startedAt"`
+	UptimeSeconds int64                   `json:"uptimeSeconds"`
+	Channels      []channel.ChannelStatus `json:"channels"`
+	Sessions      []adminSession          `json:"sessions"`
+	Queue         adminQueue              `json:"queue"`
+	Cron          []cron.CronJob          `json:"cron"`
+	Memory        *memory.MemoryStats     `json:"memory,omitempty"`
+	MemoryError   string                  `json:"memoryError,omitempty"`
+	// MemoryTokens is what the memory model used for extraction, compression
+	// and retrieval.
+	MemoryTokens *memory.ModelUsage `json:"memoryTokens,omitempty"`
+	// MemoryReembed is the progress of the job embedding memories that lack
+	// a current embedding, once one has run.
+	MemoryReembed *memory.ReembedProgress `json:"memoryReembed,omitempty"`
+	// MemoryRetrieval counts which messages recalled memories and how
+	// often they found any.
+	MemoryRetrieval *memory.RetrievalGateStats `json:"memoryRetrieval,omitempty"`
+	// Tokens is nil unless tokenTracking is enabled.
+	Tokens *api.SessionTokenStats `json:"tokens,omitempty"`
+	Errors []adminError           `json:"errors"`
+}
+
+// adminHandler serves the JSON admin API. The Web UI mounts it under
+// /api/admin/ behind its login.
+func (g *Gateway) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/admin/status", g.handleAdminStatus)
+	mux.HandleFunc("POST /api/admin/cron/{id}/run", g.handleAdminCronRun)
+	mux.HandleFunc("POST /api/admin/cron/{id}/enable", g.handleAdminCronEnable(true))
+	mux.HandleFunc("POST /api/admin/cron/{id}/disable", g.handleAdminCronEnable(false))
+	mux.HandleFunc("DELETE /api/admin/cron/{id}", g.handleAdminCronDelete)
+	return mux
+}
+
+func (g *Gateway) adminStatus() adminStatus {
+	st := adminStatus{
+		StartedAt: g.startedAt,
+		Sessions:  g.sessions.active(),
+		Queue: adminQueue{
+			Inbound:     len(g.bus.Inbound),
+			InboundCap:  cap(g.bus.Inbound),
+			Outbound:    len(g.bus.Outbound),
+			OutboundCap: cap(g.bus.Outbound),
+			Callbacks:   len(g.bus.Callbacks),
+		},
+		Errors: g.errors.recent(),
+	}
+	if !g.startedAt.IsZero() {
+		st.UptimeSeconds = int64(time.Since(g.startedAt).Seconds())
+	}
+	if g.channels != nil {
+		st.Channels = g.channels.Status()
+	}
+	if g.cron != nil {
+		st.Cron = g.cron.ListJobs()
+	}
+	if g.memEngine != nil {
+		if stats, err := g.memEngine.Stats(); err != nil {
+			st.MemoryError = err.Error()
+		} else {
+			st.Memory = &stats
+		}
+		usage := g.memEngine.ModelUsage()
+		if r, ok := g.memLLM.(memory.UsageReporter); ok {
+			usage = usage.Add(r.Usage())
+		}
+		usage = usage.Add(g.memGate.Usage())
+		st.MemoryTokens = &usage
+		if reembed := g.memEngine.ReembedStatus(); reembed.Running || reembed.Total > 0 {
+			st.MemoryReembed = &reembed
+		}
+		if g.memGate != nil {
+			retrieval := g.memGate.Stats()
+			st.MemoryRetrieval = &retrieval
+		}
+	}
+	if tr, ok := g.runtime.(TokenStatsRuntime); ok && g.cfg.TokenTracking.Enabled {
+		st.Tokens = tr.GetTotalStats()
+	}
+	return st
+}
+
+func (g *Gateway) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, http.StatusOK, g.adminStatus())
+}
+
+func (g *Gateway) handleAdminCronRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := g.cron.RunJob(id); err != nil {
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("[admin] cron job %s started manually", id)
+	writeAdminJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+}
+
+func (g *Gateway) handleAdminCronEnable(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := g.cron.EnableJob(r.PathValue("id"), enabled)
+		if err != nil {
+			writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("[admin] cron job %s enabled=%v", job.ID, enabled)
+		writeAdminJSON(w, http.StatusOK, job)
+	}
+}
+
+func (g *Gateway) handleAdminCronDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !g.cron.RemoveJob(id) {
+		writeAdminJSON(w, http.StatusNotFound, map[string]string{"error": "job " + id + " not found"})
+		return
+	}
+	log.Printf("[admin] cron job %s removed", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,229 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/channel"
+	"github.com/stellarlinkco/myclaw/internal/config"
+	"github.com/stellarlinkco/myclaw/internal/cron"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+type tokenRuntime struct {
+	mockRuntime
+	stats *api.SessionTokenStats
+}
+
+func (r *tokenRuntime) GetTotalStats() *api.SessionTokenStats { return r.stats }
+
+// usageLLM is a memory model client that has used some tokens.
+type usageLLM struct{ memory.LLMClient }
+
+func (usageLLM) Usage() memory.ModelUsage {
+	return memory.ModelUsage{Requests: 2, InputTokens: 300, OutputTokens: 60, TotalTokens: 360}
+}
+
+func newAdminTestGateway(t *testing.T) *Gateway {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.TokenTracking.Enabled = true
+
+	engine, err := memory.NewEngine(filepath.Join(dir, "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+
+	b := bus.NewMessageBus(10)
+	chMgr, err := channel.NewChannelManager(config.ChannelsConfig{}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Gateway{
+		cfg:       cfg,
+		bus:       b,
+		runtime:   &tokenRuntime{stats: &api.SessionTokenStats{TotalTokens: 42, RequestCount: 3}},
+		channels:  chMgr,
+		cron:      cron.NewService(filepath.Join(dir, "jobs.json")),
+		memEngine: engine,
+		memLLM:    usageLLM{},
+		startedAt: time.Now().Add(-time.Minute),
+		sessions:  newSessionTracker(),
+		errors:    newErrorLog(),
+	}
+}
+
+func adminRequest(t *testing.T, h http.Handler, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestAdminStatus(t *testing.T) {
+	g := newAdminTestGateway(t)
+	h := g.adminHandler()
+
+	g.sessions.begin(bus.InboundMessage{Channel: "telegram", ChatID: "42", SenderID: "7", Content: "hi"})
+	g.sessions.begin(bus.InboundMessage{Channel: "webui", ChatID: "alice/default", SenderID: "alice"})
+	g.sessions.end("webui:alice/default")
+	g.bus.Inbound <- bus.InboundMessage{Channel: "webui"}
+	g.errors.record("2026/01/02 15:04:05 [gateway] agent error: rate limited")
+	if _, err := g.cron.AddJob("digest", cron.Schedule{Kind: "every", EveryMs: 60000}, cron.Payload{Message: "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	g.memGate = memory.NewRetrievalGate(g.cfg, g.memEngine)
+	for _, content := range []string{"what did I tell you yesterday?", "translate this paragraph"} {
+		msg := bus.InboundMessage{Channel: "telegram", ChatID: "42", SenderID: "7", Content: content}
+		g.memoryContext(msg, g.memoryScopes(msg.Channel, msg.ChatID, msg.SenderID))
+	}
+
+	rec := adminRequest(t, h, http.MethodGet, "/api/admin/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var st adminStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.UptimeSeconds < 60 {
+		t.Errorf("uptime = %d", st.UptimeSeconds)
+	}
+	if len(st.Sessions) != 2 || st.Sessions[0].Key != "webui:alice/default" || st.Sessions[0].Running || !st.Sessions[1].Running {
+		t.Errorf("sessions = %+v", st.Sessions)
+	}
+	if st.Queue.Inbound != 1 || st.Queue.InboundCap != 10 {
+		t.Errorf("queue = %+v", st.Queue)
+	}
+	if len(st.Cron) != 1 || st.Cron[0].Name != "digest" {
+		t.Errorf("cron = %+v", st.Cron)
+	}
+	if st.Memory == nil || st.MemoryError != "" {
+		t.Errorf("memory = %+v %q", st.Memory, st.MemoryError)
+	}
+	if st.MemoryTokens == nil || st.MemoryTokens.Requests != 2 || st.MemoryTokens.TotalTokens != 360 {
+		t.Errorf("memory tokens = %+v", st.MemoryTokens)
+	}
+	if r := st.MemoryRetrieval; r == nil || r.Mode != config.MemoryTriggerKeywords || r.Messages != 2 || r.Triggered != 1 || r.ByKeyword != 1 || r.Hits != 0 {
+		t.Errorf("memory retrieval = %+v", st.MemoryRetrieval)
+	}
+	if st.Tokens == nil || st.Tokens.TotalTokens != 42 {
+		t.Errorf("tokens = %+v", st.Tokens)
+	}
+	if len(st.Errors) != 1 || st.Errors[0].Source != "gateway" || st.Errors[0].Message != "agent error: rate limited" {
+		t.Errorf("errors = %+v", st.Errors)
+	}
+
+	// Token usage is hidden while tracking is off.
+	g.cfg.TokenTracking.Enabled = false
+	if st := g.adminStatus(); st.Tokens != nil {
+		t.Errorf("tokens with tracking off = %+v", st.Tokens)
+	}
+
+	if rec := adminRequest(t, h, http.MethodPost, "/api/admin/status"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestAdminCronControls(t *testing.T) {
+	g := newAdminTestGateway(t)
+	h := g.adminHandler()
+	ran := make(chan string, 1)
+	g.cron.OnJob = func(job cron.CronJob) (string, error) {
+		ran <- job.ID
+		return "ok", nil
+	}
+	job, err := g.cron.AddJob("digest", cron.Schedule{Kind: "every", EveryMs: 60000}, cron.Payload{Message: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "/api/admin/cron/" + job.ID
+
+	if rec := adminRequest(t, h, http.MethodPost, base+"/disable"); rec.Code != http.StatusOK || g.cron.ListJobs()[0].Enabled {
+		t.Fatalf("disable = %d, enabled = %v", rec.Code, g.cron.ListJobs()[0].Enabled)
+	}
+	if rec := adminRequest(t, h, http.MethodPost, base+"/enable"); rec.Code != http.StatusOK || !g.cron.ListJobs()[0].Enabled {
+		t.Fatalf("enable = %d", rec.Code)
+	}
+	if rec := adminRequest(t, h, http.MethodPost, base+"/run"); rec.Code != http.StatusAccepted {
+		t.Fatalf("run = %d", rec.Code)
+	}
+	select {
+	case id := <-ran:
+		if id != job.ID {
+			t.Errorf("ran %q", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+
+	for _, path := range []string{"/api/admin/cron/nope/run", "/api/admin/cron/nope/enable"} {
+		if rec := adminRequest(t, h, http.MethodPost, path); rec.Code != http.StatusNotFound {
+			t.Errorf("%s = %d, want 404", path, rec.Code)
+		}
+	}
+	if rec := adminRequest(t, h, http.MethodDelete, base); rec.Code != http.StatusNoContent || len(g.cron.ListJobs()) != 0 {
+		t.Errorf("delete = %d, jobs = %d", rec.Code, len(g.cron.ListJobs()))
+	}
+	if rec := adminRequest(t, h, http.MethodDelete, base); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", rec.Code)
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	l := newErrorLog()
+	l.record("2026/01/02 15:04:05 [gateway] inbound from telegram/1: why does this error?")
+	l.record("2026/01/02 15:04:05 [cron] job digest error: timeout")
+	l.record("2026/01/02 15:04:05 [channel-mgr] send to telegram failed: 403")
+	l.record("2026/01/02 15:04:05 [memory] retrieve warning: locked")
+	l.record("2026/01/02 15:04:05 [gateway] running on 0.0.0.0:18790")
+
+	got := l.recent()
+	want := []string{"memory", "channel-mgr", "cron"}
+	if len(got) != len(want) {
+		t.Fatalf("recent = %+v", got)
+	}
+	for i, src := range want {
+		if got[i].Source != src {
+			t.Errorf("recent[%d] = %+v, want source %s", i, got[i], src)
+		}
+	}
+
+	for i := 0; i < adminMaxErrors+5; i++ {
+		l.record("[x] failed")
+	}
+	if n := len(l.recent()); n != adminMaxErrors {
+		t.Errorf("kept %d errors, want %d", n, adminMaxErrors)
+	}
+}
+
+func TestSessionTracker_ForgetsIdleSessions(t *testing.T) {
+	tr := newSessionTracker()
+	now := time.Unix(1_700_000_000, 0)
+	tr.now = func() time.Time { return now }
+
+	tr.begin(bus.InboundMessage{Channel: "telegram", ChatID: "1"})
+	tr.end("telegram:1")
+	tr.begin(bus.InboundMessage{Channel: "telegram", ChatID: "2"}) // still running
+	tr.begin(bus.InboundMessage{Channel: "telegram", ChatID: "2"})
+	now = now.Add(adminSessionWindow + time.Minute)
+
+	got := tr.active()
+	if len(got) != 1 || got[0].Key != "telegram:2" || got[0].Messages != 2 {
+		t.Fatalf("active = %+v", got)
+	}
+	var nilTracker *sessionTracker
+	nilTracker.begin(bus.InboundMessage{})
+	if nilTracker.active() != nil {
+		t.Error("nil tracker should report nothing")
+	}
+}
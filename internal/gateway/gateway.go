@@ -4,14 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cexll/agentsdk-go/pkg/api"
 	"github.com/cexll/agentsdk-go/pkg/model"
+	"github.com/cexll/agentsdk-go/pkg/security"
+	"github.com/cexll/agentsdk-go/pkg/tool"
 	"github.com/stellarlinkco/myclaw/internal/bus"
 	"github.com/stellarlinkco/myclaw/internal/channel"
 	"github.com/stellarlinkco/myclaw/internal/config"
@@ -19,9 +26,14 @@ import (
 	"github.com/stellarlinkco/myclaw/internal/heartbeat"
 	"github.com/stellarlinkco/myclaw/internal/memory"
 	"github.com/stellarlinkco/myclaw/internal/skills"
+	"github.com/stellarlinkco/myclaw/internal/webhook"
 )
 
 // Runtime interface for agent runtime (allows mocking in tests)
+// webhookShutdownTimeout bounds how long Shutdown waits for running webhook
+// triggers before cancelling them.
+const webhookShutdownTimeout = 30 * time.Second
+
 type Runtime interface {
 	Run(ctx context.Context, req api.Request) (*api.Response, error)
 	Close()
@@ -47,17 +59,22 @@ type RuntimeFactory func(cfg *config.Config, sysPrompt string) (Runtime, error)
 type Options struct {
 	RuntimeFactory RuntimeFactory
 	SignalChan     chan os.Signal // for testing signal handling
+	// ApprovalsPath is where tool approvals are persisted; empty keeps them
+	// in memory. New uses ~/.myclaw/data/approvals.json.
+	ApprovalsPath string
 }
 
 // DefaultRuntimeFactory creates the default agentsdk-go runtime
 func DefaultRuntimeFactory(cfg *config.Config, sysPrompt string) (Runtime, error) {
-	return newRuntime(cfg, sysPrompt, nil)
+	return newRuntime(cfg, sysPrompt, nil, nil, nil)
 }
 
-func newRuntime(cfg *config.Config, sysPrompt string, skillRegs []api.SkillRegistration) (Runtime, error) {
+// newRuntime creates the agentsdk-go runtime with extra custom tools (memory)
+// and, when in is set, the approval and question tools.
+func newRuntime(cfg *config.Config, sysPrompt string, skillRegs []api.SkillRegistration, in *interactor, tools []tool.Tool) (Runtime, error) {
 	provider := runtimeModelFactory(cfg)
 
-	rt, err := api.New(context.Background(), api.Options{
+	opts := api.Options{
 		ProjectRoot:   cfg.Agent.Workspace,
 		ModelFactory:  provider,
 		SystemPrompt:  sysPrompt,
@@ -69,8 +86,18 @@ func newRuntime(cfg *config.Config, sysPrompt string, skillRegs []api.SkillRegis
 			Threshold:     cfg.AutoCompact.Threshold,
 			PreserveCount: cfg.AutoCompact.PreserveCount,
 		},
-		Skills: skillRegs,
-	})
+		Skills:      skillRegs,
+		CustomTools: tools,
+	}
+	if in != nil {
+		opts.ApprovalQueue = in.approvals
+		opts.ApprovalWait = true
+		opts.PermissionRequestHandler = in.requestPermission
+		opts.CustomTools = append(opts.CustomTools, newAskUserTool(in))
+		opts.DisallowedTools = []string{builtinAskToolName}
+	}
+
+	rt, err := api.New(context.Background(), opts)
 	if err != nil {
 		return nil, fmt.Errorf("create runtime: %w", err)
 	}
@@ -104,76 +131,58 @@ type Gateway struct {
 	channels           *channel.ChannelManager
 	cron               *cron.Service
 	hb                 *heartbeat.Service
+	webhooks           *webhook.Service
+	interact           *interactor
+	mux                *http.ServeMux
+	httpServer         *http.Server
 	memEngine          *memory.Engine
+	memGate            *memory.RetrievalGate
 	memLLM             memory.LLMClient
 	extraction         *memory.ExtractionService
-	retrieveClassicFn  func(string) ([]memory.Memory, error)
-	retrieveEnhancedFn func(string) ([]memory.Memory, error)
-	skillRegs          []api.SkillRegistration
-	signalChan         chan os.Signal // for testing
+	retrieveClassicFn  func(msg string, visible []string) ([]memory.Memory, error)
+	retrieveEnhancedFn func(msg string, visible []string) ([]memory.Memory, error)
+	// profiledSessions records, per session and write scope, who has been
+	// shown their scoped core profile since the gateway started.
+	profiledSessions sync.Map
+	// queues holds, per session key, the messages waiting for the
+	// session's worker; a key is present while its worker runs.
+	queueMu sync.Mutex
+	queues  map[string][]bus.InboundMessage
+	// retrieveMu keeps the engine's retrieval config set for the whole
+	// retrieval it is set for.
+	retrieveMu sync.Mutex
+	skillRegs  []api.SkillRegistration
+	signalChan chan os.Signal // for testing
+	// startedAt, sessions and errors feed the admin dashboard.
+	startedAt time.Time
+	sessions  *sessionTracker
+	errors    *errorLog
 }
 
 // New creates a Gateway with default options
 func New(cfg *config.Config) (*Gateway, error) {
-	return NewWithOptions(cfg, Options{})
+	return NewWithOptions(cfg, Options{ApprovalsPath: filepath.Join(config.ConfigDir(), "data", "approvals.json")})
 }
 
 // NewWithOptions creates a Gateway with custom options for testing
 func NewWithOptions(cfg *config.Config, opts Options) (*Gateway, error) {
-	g := &Gateway{cfg: cfg}
+	g := &Gateway{cfg: cfg, sessions: newSessionTracker(), errors: newErrorLog()}
 
 	// Message bus
 	g.bus = bus.NewMessageBus(config.DefaultBufSize)
 
 	// Memory (SQLite layered memory is the primary runtime backend)
-	dbPath := strings.TrimSpace(cfg.Memory.DBPath)
-	if dbPath == "" {
-		dbPath = filepath.Join(config.ConfigDir(), "data", "memory.db")
-	}
-	engine, err := memory.NewEngine(dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("create memory engine: %w", err)
-	}
-	g.memEngine = engine
-
-	empty, err := g.memEngine.IsEmpty()
-	if err != nil {
-		_ = g.memEngine.Close()
-		return nil, fmt.Errorf("inspect memory engine state: %w", err)
-	}
-	if empty {
-		if err := memory.MigrateFromFiles(cfg.Agent.Workspace, g.memEngine); err != nil {
-			_ = g.memEngine.Close()
-			return nil, fmt.Errorf("migrate legacy file memory: %w", err)
-		}
-	}
-	if projects, err := g.memEngine.LoadKnownProjects(); err != nil {
-		log.Printf("[memory] load known projects warning: %v", err)
-	} else {
-		g.memEngine.SetKnownProjects(projects)
-	}
-
-	g.memEngine.SetRetrievalConfig(g.retrievalConfigForMode(config.MemoryRetrievalModeClassic))
-	if strings.EqualFold(strings.TrimSpace(g.cfg.Memory.Retrieval.Mode), config.MemoryRetrievalModeEnhanced) {
-		g.memEngine.SetQueryExpander(memory.NewQueryExpander(cfg))
-		if cfg.Memory.Rerank.Enabled {
-			g.memEngine.SetReranker(memory.NewReranker(cfg))
-		}
-	}
-	if cfg.Memory.Embedding.Enabled {
-		embeddingModel := strings.TrimSpace(cfg.Memory.Embedding.Model)
-		if embeddingModel == "" {
-			embeddingModel = strings.TrimSpace(cfg.Memory.Model)
-		}
-		if embeddingModel == "" {
-			embeddingModel = strings.TrimSpace(cfg.Agent.Model)
+	if cfg.Memory.Enabled {
+		engine, err := memory.Open(cfg)
+		if err != nil {
+			return nil, err
 		}
-		g.memEngine.SetEmbedder(memory.NewEmbedder(cfg), embeddingModel, cfg.Memory.Embedding.TimeoutMs)
+		g.memEngine = engine
+		g.memGate = memory.NewRetrievalGate(cfg, engine)
+		g.ensureRetrievalFns()
+		g.memLLM = memory.NewLLMClient(cfg)
+		g.extraction = memory.NewExtractionService(g.memEngine, g.memLLM, cfg.Memory.Extraction)
 	}
-	g.ensureRetrievalFns()
-
-	g.memLLM = memory.NewLLMClient(cfg)
-	g.extraction = memory.NewExtractionService(g.memEngine, g.memLLM, cfg.Memory.Extraction)
 
 	// Build system prompt
 	sysPrompt := g.buildSystemPrompt()
@@ -190,11 +199,30 @@ func NewWithOptions(cfg *config.Config, opts Options) (*Gateway, error) {
 		g.skillRegs = skillRegs
 	}
 
+	// Approvals and questions are answered with buttons on channels that support them
+	if opts.ApprovalsPath != "" {
+		if err := pruneApprovals(opts.ApprovalsPath, time.Now()); err != nil {
+			log.Printf("[gateway] prune approvals warning: %v", err)
+		}
+	}
+	approvals, err := security.NewApprovalQueue(opts.ApprovalsPath)
+	if err != nil {
+		_ = g.memEngine.Close()
+		return nil, fmt.Errorf("create approval queue: %w", err)
+	}
+	g.interact = newInteractor(g.bus, approvals, cfg.Gateway.Approvers, func(name string) bool {
+		return g.channels != nil && g.channels.SupportsButtons(name)
+	})
+
 	// Create runtime using factory (allows injection for testing)
 	factory := opts.RuntimeFactory
 	var rt Runtime
 	if factory == nil {
-		rt, err = newRuntime(cfg, sysPrompt, g.skillRegs)
+		var tools []tool.Tool
+		if g.memEngine != nil {
+			tools = memory.NewTools(g.memEngine)
+		}
+		rt, err = newRuntime(cfg, sysPrompt, g.skillRegs, g.interact, tools)
 	} else {
 		rt, err = factory(cfg, sysPrompt)
 	}
@@ -209,7 +237,7 @@ func NewWithOptions(cfg *config.Config, opts Options) (*Gateway, error) {
 
 	// runAgent helper for cron/heartbeat
 	runAgent := func(prompt string) (string, error) {
-		return g.runAgent(context.Background(), prompt, "system", nil)
+		return g.runAgent(memory.WithScopes(context.Background(), g.memoryScopes("", "", "")), prompt, "system", nil)
 	}
 
 	// Cron
@@ -218,23 +246,22 @@ func NewWithOptions(cfg *config.Config, opts Options) (*Gateway, error) {
 	g.cron.OnJob = func(job cron.CronJob) (string, error) {
 		switch job.Payload.Message {
 		case "__internal:memory:daily-compress":
+			if g.memEngine == nil {
+				return "memory disabled", nil
+			}
 			return "ok", g.memEngine.DailyCompress(g.memLLM)
 		case "__internal:memory:weekly-compress":
+			if g.memEngine == nil {
+				return "memory disabled", nil
+			}
 			return "ok", g.memEngine.WeeklyDeepCompress(g.memLLM)
 		}
 
-		result, err := runAgent(job.Payload.Message)
-		if err != nil {
-			return "", err
-		}
-		if job.Payload.Deliver && job.Payload.Channel != "" {
-			g.bus.Outbound <- bus.OutboundMessage{
-				Channel: job.Payload.Channel,
-				ChatID:  job.Payload.To,
-				Content: result,
-			}
+		deliverChannel := ""
+		if job.Payload.Deliver {
+			deliverChannel = job.Payload.Channel
 		}
-		return result, nil
+		return g.runAndDeliver(context.Background(), job.Payload.Message, "system", deliverChannel, job.Payload.To)
 	}
 
 	// Heartbeat
@@ -246,10 +273,74 @@ func NewWithOptions(cfg *config.Config, opts Options) (*Gateway, error) {
 		return nil, fmt.Errorf("create channel manager: %w", err)
 	}
 	g.channels = chMgr
+	chMgr.SetHistory(func(sessionKey string) ([]channel.HistoryEntry, error) {
+		return sessionHistory(cfg.Agent.Workspace, sessionKey)
+	})
+	chMgr.SetAdmin(g.adminHandler())
+
+	// Gateway HTTP server routes (WebUI, webhooks)
+	g.mux = http.NewServeMux()
+	httpChannels := g.channels.HTTPChannels()
+	for _, ch := range httpChannels {
+		if err := ch.Attach(g.mux); err != nil {
+			return nil, fmt.Errorf("attach %s to gateway http server: %w", ch.Name(), err)
+		}
+	}
+
+	if cfg.Webhooks.Enabled && len(cfg.Webhooks.Endpoints) > 0 {
+		g.webhooks, err = webhook.NewService(cfg.Webhooks.Endpoints)
+		if err != nil {
+			_ = g.memEngine.Close()
+			return nil, fmt.Errorf("create webhook service: %w", err)
+		}
+		g.webhooks.OnTrigger = func(ctx context.Context, ep config.WebhookEndpoint, prompt string) (string, error) {
+			return g.runAndDeliver(ctx, prompt, "webhook:"+ep.Name, ep.Channel, ep.To)
+		}
+		g.mux.Handle(webhook.PathPrefix, g.webhooks.Handler())
+	}
+
+	if len(httpChannels) > 0 || g.webhooks != nil {
+		g.httpServer = g.newHTTPServer()
+	}
 
 	return g, nil
 }
 
+func (g *Gateway) newHTTPServer() *http.Server {
+	port := g.cfg.Gateway.Port
+	if port == 0 {
+		port = config.DefaultPort
+	}
+	return &http.Server{
+		Addr:              net.JoinHostPort(g.cfg.Gateway.Host, strconv.Itoa(port)),
+		Handler:           g.mux,
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+}
+
+// runAndDeliver runs a prompt outside of a chat turn (cron, webhooks) and
+// optionally pushes the result to a channel.
+func (g *Gateway) runAndDeliver(ctx context.Context, prompt, sessionID, channel, to string) (string, error) {
+	// Without a sender these runs only see shared memory and that of the
+	// chat they report to.
+	ctx = memory.WithScopes(ctx, g.memoryScopes(channel, to, ""))
+	result, err := g.runAgent(ctx, prompt, sessionID, nil)
+	if err != nil {
+		return "", err
+	}
+	if channel != "" {
+		g.bus.Outbound <- bus.OutboundMessage{
+			Channel: channel,
+			ChatID:  to,
+			Content: result,
+		}
+	}
+	return result, nil
+}
+
 func (g *Gateway) buildSystemPrompt() string {
 	var sb strings.Builder
 
@@ -263,7 +354,16 @@ func (g *Gateway) buildSystemPrompt() string {
 		sb.WriteString("\n\n")
 	}
 
-	if profile, err := g.memEngine.LoadTier1(); err != nil {
+	if g.memEngine == nil {
+		return sb.String()
+	}
+	// With scoped memory only the shared profile goes into the prompt every
+	// conversation gets; scoped entries come with the messages.
+	var visible []string
+	if g.cfg.Memory.Scope != config.MemoryScopeShared {
+		visible = []string{memory.SharedScope}
+	}
+	if profile, err := g.memEngine.LoadProfile(visible); err != nil {
 		log.Printf("[memory] load tier1 for system prompt warning: %v", err)
 	} else if strings.TrimSpace(profile) != "" {
 		sb.WriteString("# Core Memory\n")
@@ -312,6 +412,17 @@ func (g *Gateway) ensureInternalMemoryJobs() error {
 }
 
 func (g *Gateway) runAgent(ctx context.Context, prompt, sessionID string, contentBlocks []model.ContentBlock) (string, error) {
+	resp, err := g.runtime.Run(withSessionID(ctx, sessionID), agentRequest(prompt, sessionID, contentBlocks))
+	if err != nil {
+		return "", err
+	}
+	if resp == nil || resp.Result == nil {
+		return "", nil
+	}
+	return resp.Result.Output, nil
+}
+
+func agentRequest(prompt, sessionID string, contentBlocks []model.ContentBlock) api.Request {
 	// Workaround: agentsdk-go drops Prompt when ContentBlocks exist (anthropic.go:420-431).
 	// Merge text prompt into ContentBlocks so both text and media reach the API.
 	blocks := contentBlocks
@@ -321,25 +432,22 @@ func (g *Gateway) runAgent(ctx context.Context, prompt, sessionID string, conten
 		blocks = append(blocks, contentBlocks...)
 		prompt = "" // clear to avoid duplication if SDK is fixed later
 	}
-
-	resp, err := g.runtime.Run(ctx, api.Request{
+	return api.Request{
 		Prompt:        prompt,
 		ContentBlocks: blocks,
 		SessionID:     sessionID,
-	})
-	if err != nil {
-		return "", err
-	}
-	if resp == nil || resp.Result == nil {
-		return "", nil
 	}
-	return resp.Result.Output, nil
 }
 
 func (g *Gateway) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	g.startedAt = time.Now()
+	if g.errors != nil {
+		defer g.errors.capture()()
+	}
+
 	go g.bus.DispatchOutbound(ctx)
 
 	if err := g.channels.StartAll(ctx); err != nil {
@@ -347,16 +455,34 @@ func (g *Gateway) Run(ctx context.Context) error {
 	}
 	log.Printf("[gateway] channels started: %v", g.channels.EnabledChannels())
 
+	if g.httpServer != nil {
+		ln, err := net.Listen("tcp", g.httpServer.Addr)
+		if err != nil {
+			return fmt.Errorf("listen gateway http: %w", err)
+		}
+		go func() {
+			log.Printf("[gateway] http listening on %s", ln.Addr())
+			if err := g.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+				log.Printf("[gateway] http server error: %v", err)
+			}
+		}()
+	}
+
 	if err := g.cron.Start(ctx); err != nil {
 		log.Printf("[gateway] cron start warning: %v", err)
 	}
-	if err := g.ensureInternalMemoryJobs(); err != nil {
+	if g.memEngine == nil {
+		log.Printf("[gateway] memory disabled (memory.enabled=false)")
+	} else if err := g.ensureInternalMemoryJobs(); err != nil {
 		log.Printf("[gateway] ensure internal memory jobs warning: %v", err)
 	}
 
 	if g.extraction != nil {
 		g.extraction.Start(ctx)
 	}
+	if g.memEngine != nil {
+		g.memEngine.StartReembedding(ctx, g.cfg.Memory.Embedding.BatchSize)
+	}
 
 	go func() {
 		if err := g.hb.Start(ctx); err != nil {
@@ -365,6 +491,7 @@ func (g *Gateway) Run(ctx context.Context) error {
 	}()
 
 	go g.processLoop(ctx)
+	go g.callbackLoop(ctx)
 
 	log.Printf("[gateway] running on %s:%d", g.cfg.Gateway.Host, g.cfg.Gateway.Port)
 
@@ -380,43 +507,119 @@ func (g *Gateway) Run(ctx context.Context) error {
 	return g.Shutdown()
 }
 
+// processLoop hands each inbound message to its session's worker. Sessions
+// run concurrently, so a run waiting for an approval or an answer holds up
+// only its own chat; messages within a session are handled in order.
 func (g *Gateway) processLoop(ctx context.Context) {
 	for {
 		select {
 		case msg := <-g.bus.Inbound:
-			log.Printf("[gateway] inbound from %s/%s: %s", msg.Channel, msg.SenderID, truncate(msg.Content, 80))
+			g.dispatch(ctx, msg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-			if g.extraction != nil {
-				go g.extraction.BufferMessage(msg.Channel, msg.SenderID, "user", msg.Content)
+// dispatch queues msg for its session, starting a worker for the session
+// when none is running. The worker exits once the queue is empty.
+func (g *Gateway) dispatch(ctx context.Context, msg bus.InboundMessage) {
+	key := msg.SessionKey()
+	g.queueMu.Lock()
+	defer g.queueMu.Unlock()
+	if g.queues == nil {
+		g.queues = make(map[string][]bus.InboundMessage)
+	}
+	queue, running := g.queues[key]
+	g.queues[key] = append(queue, msg)
+	if running {
+		return
+	}
+	go func() {
+		for {
+			g.queueMu.Lock()
+			queue := g.queues[key]
+			if len(queue) == 0 || ctx.Err() != nil {
+				delete(g.queues, key)
+				g.queueMu.Unlock()
+				return
 			}
+			next := queue[0]
+			g.queues[key] = queue[1:]
+			g.queueMu.Unlock()
+			g.handleInbound(ctx, next)
+		}
+	}()
+}
 
-			prompt := msg.Content
-			if memory.ShouldRetrieve(msg.Content) {
-				memories, err := g.retrieveMemories(msg.Content)
-				if err != nil {
-					log.Printf("[memory] retrieve warning: %v", err)
-				} else if len(memories) > 0 {
-					memoryContext := memory.FormatMemories(memories)
-					prompt = fmt.Sprintf("[Relevant Memory]\n%s\n\n[User Message]\n%s", memoryContext, msg.Content)
-				}
-			}
+// handleInbound runs the agent on one message and sends the reply.
+func (g *Gateway) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	log.Printf("[gateway] inbound from %s/%s: %s", msg.Channel, msg.SenderID, truncate(msg.Content, 80))
+	g.sessions.begin(msg)
+	scopes := g.inboundScopes(msg)
+	runCtx := withSenderID(memory.WithScopes(ctx, scopes), msg.SenderID)
 
-			result, err := g.runAgent(ctx, prompt, msg.SessionKey(), msg.ContentBlocks)
-			if err != nil {
-				log.Printf("[gateway] agent error: %v", err)
-				result = "Sorry, I encountered an error processing your message."
-			}
+	if g.extraction != nil {
+		go g.extraction.BufferMessage(scopes.Write, msg.Channel, msg.SenderID, "user", msg.Content)
+	}
+
+	prompt := msg.Content
+	if memoryContext := g.memoryContext(msg, scopes); memoryContext != "" {
+		prompt = memory.WithMemoryContext(memoryContext, msg.Content)
+	}
 
-			if g.extraction != nil && strings.TrimSpace(result) != "" {
-				go g.extraction.BufferMessage(msg.Channel, msg.SenderID, "assistant", result)
+	streamed := false
+	var result string
+	var err error
+	if sr, ok := g.runtime.(StreamingRuntime); ok && g.channels != nil && g.channels.SupportsStreaming(msg.Channel) {
+		streamed = true
+		result, err = g.runAgentStream(runCtx, sr, prompt, msg.SessionKey(), msg.ContentBlocks, func(partial string) {
+			g.bus.Outbound <- bus.OutboundMessage{
+				Channel: msg.Channel,
+				ChatID:  msg.ChatID,
+				Content: partial,
+				ReplyTo: msg.MessageID,
+				Partial: true,
 			}
+		})
+	} else {
+		result, err = g.runAgent(runCtx, prompt, msg.SessionKey(), msg.ContentBlocks)
+	}
+	if err != nil {
+		log.Printf("[gateway] agent error: %v", err)
+		result = "Sorry, I encountered an error processing your message."
+	}
+	g.sessions.end(msg.SessionKey())
+
+	if g.extraction != nil && strings.TrimSpace(result) != "" {
+		go g.extraction.BufferMessage(scopes.Write, msg.Channel, msg.SenderID, "assistant", result)
+	}
+
+	// A streamed reply always gets a final frame so the channel can
+	// close the stream.
+	if result != "" || streamed {
+		g.bus.Outbound <- bus.OutboundMessage{
+			Channel:   msg.Channel,
+			ChatID:    msg.ChatID,
+			Content:   result,
+			ReplyTo:   msg.MessageID,
+			StreamEnd: streamed,
+		}
+	}
+	if g.channels != nil {
+		g.channels.EndRun(msg.Channel, msg.ChatID)
+	}
+}
 
-			if result != "" {
-				g.bus.Outbound <- bus.OutboundMessage{
-					Channel: msg.Channel,
-					ChatID:  msg.ChatID,
-					Content: result,
-				}
+// callbackLoop runs apart from the session workers, one of which is blocked
+// while its run waits for the button press it is about to deliver.
+func (g *Gateway) callbackLoop(ctx context.Context) {
+	for {
+		select {
+		case msg := <-g.bus.Callbacks:
+			accepted := g.interact.handleCallback(msg)
+			if g.channels != nil {
+				g.channels.AckCallback(msg, accepted)
 			}
 		case <-ctx.Done():
 			return
@@ -424,20 +627,92 @@ func (g *Gateway) processLoop(ctx context.Context) {
 	}
 }
 
+// memoryScopes returns the memory scopes of a conversation under the
+// memory.scope setting.
+func (g *Gateway) memoryScopes(channel, chatID, senderID string) memory.Scopes {
+	mode := config.DefaultMemoryScope
+	if g.cfg != nil {
+		mode = g.cfg.Memory.Scope
+	}
+	return memory.ScopesFor(mode, channel, chatID, senderID)
+}
+
+// inboundScopes is memoryScopes for a message; in a group chat the sender's
+// own memories are not recalled, since the session is shared.
+func (g *Gateway) inboundScopes(msg bus.InboundMessage) memory.Scopes {
+	scopes := g.memoryScopes(msg.Channel, msg.ChatID, msg.SenderID)
+	if msg.IsGroup() && msg.SenderID != "" {
+		scopes = scopes.InGroup(memory.UserScope(msg.Channel, msg.SenderID))
+	}
+	return scopes
+}
+
+// memoryContext is the memory shown with a message: the retrieved memories
+// and, on a session's first message and whenever memories are retrieved,
+// the sender's scoped core profile, which the system prompt leaves out.
+func (g *Gateway) memoryContext(msg bus.InboundMessage, scopes memory.Scopes) string {
+	retrieve := g.memGate.Decide(msg.Content, scopes.Visible) != ""
+	var memories []memory.Memory
+	if retrieve {
+		var err error
+		if memories, err = g.retrieveMemories(msg.Content, scopes.Visible); err != nil {
+			log.Printf("[memory] retrieve warning: %v", err)
+			memories = nil
+		}
+		memories = g.memGate.Filter(msg.Content, memories)
+		g.memGate.Record(len(memories))
+	}
+
+	var parts []string
+	_, profiled := g.profiledSessions.LoadOrStore(msg.SessionKey()+"|"+scopes.Write, true)
+	if retrieve || !profiled {
+		if profile := g.scopedProfile(scopes); profile != "" {
+			parts = append(parts, profile)
+		}
+	}
+	if len(memories) > 0 {
+		parts = append(parts, memory.FormatMemories(memories))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// scopedProfile renders the core-profile entries of the conversation's own
+// (non-shared) scopes.
+func (g *Gateway) scopedProfile(scopes memory.Scopes) string {
+	if g.memEngine == nil || scopes.Visible == nil {
+		return ""
+	}
+	var own []string
+	for _, s := range scopes.Visible {
+		if s != memory.SharedScope {
+			own = append(own, s)
+		}
+	}
+	if len(own) == 0 {
+		return ""
+	}
+	profile, err := g.memEngine.LoadProfile(own)
+	if err != nil {
+		log.Printf("[memory] load scoped profile warning: %v", err)
+		return ""
+	}
+	return profile
+}
+
 func (g *Gateway) ensureRetrievalFns() {
 	if g.memEngine == nil {
 		return
 	}
 	if g.retrieveClassicFn == nil {
-		g.retrieveClassicFn = func(msg string) ([]memory.Memory, error) {
+		g.retrieveClassicFn = func(msg string, visible []string) ([]memory.Memory, error) {
 			g.memEngine.SetRetrievalConfig(g.retrievalConfigForMode(config.MemoryRetrievalModeClassic))
-			return g.memEngine.Retrieve(msg)
+			return g.memEngine.RetrieveFor(msg, visible)
 		}
 	}
 	if g.retrieveEnhancedFn == nil {
-		g.retrieveEnhancedFn = func(msg string) ([]memory.Memory, error) {
+		g.retrieveEnhancedFn = func(msg string, visible []string) ([]memory.Memory, error) {
 			g.memEngine.SetRetrievalConfig(g.retrievalConfigForMode(config.MemoryRetrievalModeEnhanced))
-			return g.memEngine.Retrieve(msg)
+			return g.memEngine.RetrieveFor(msg, visible)
 		}
 	}
 }
@@ -477,7 +752,9 @@ func (g *Gateway) retrievalConfigForMode(mode string) config.RetrievalConfig {
 	return retrievalCfg
 }
 
-func (g *Gateway) retrieveMemories(msg string) ([]memory.Memory, error) {
+func (g *Gateway) retrieveMemories(msg string, visible []string) ([]memory.Memory, error) {
+	g.retrieveMu.Lock()
+	defer g.retrieveMu.Unlock()
 	g.ensureRetrievalFns()
 
 	mode := config.MemoryRetrievalModeClassic
@@ -489,7 +766,7 @@ func (g *Gateway) retrieveMemories(msg string) ([]memory.Memory, error) {
 		if g.retrieveEnhancedFn == nil {
 			return nil, nil
 		}
-		memories, err := g.retrieveEnhancedFn(msg)
+		memories, err := g.retrieveEnhancedFn(msg, visible)
 		if err == nil {
 			return memories, nil
 		}
@@ -497,19 +774,33 @@ func (g *Gateway) retrieveMemories(msg string) ([]memory.Memory, error) {
 		if g.retrieveClassicFn == nil {
 			return nil, nil
 		}
-		return g.retrieveClassicFn(msg)
+		return g.retrieveClassicFn(msg, visible)
 	}
 
 	if g.retrieveClassicFn == nil {
 		return nil, nil
 	}
-	return g.retrieveClassicFn(msg)
+	return g.retrieveClassicFn(msg, visible)
 }
 
 func (g *Gateway) Shutdown() error {
 	if g.extraction != nil {
 		g.extraction.Stop()
 	}
+	if g.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := g.httpServer.Shutdown(ctx); err != nil {
+			log.Printf("[gateway] http shutdown warning: %v", err)
+		}
+		cancel()
+	}
+	if g.webhooks != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		if err := g.webhooks.Shutdown(ctx); err != nil {
+			log.Printf("[gateway] webhook shutdown: cancelled running triggers: %v", err)
+		}
+		cancel()
+	}
 	g.cron.Stop()
 	if g.memEngine != nil {
 		if err := g.memEngine.Close(); err != nil {
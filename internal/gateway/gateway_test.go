@@ -3,6 +3,8 @@ package gateway
 import (
 	"context"
 	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -127,6 +129,61 @@ func TestGateway_BuildSystemPrompt_WithMemory(t *testing.T) {
 	}
 }
 
+func TestGateway_MemoryContextScoped(t *testing.T) {
+	engine, err := memory.NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer engine.Close()
+	alice := memory.UserScope("telegram", "alice")
+	if err := engine.WriteTier1(memory.ProfileEntry{Content: "Alice is a vet.", Category: "identity", Scope: alice}); err != nil {
+		t.Fatalf("WriteTier1 error: %v", err)
+	}
+
+	g := &Gateway{
+		cfg:       &config.Config{Agent: config.AgentConfig{Workspace: t.TempDir()}},
+		memEngine: engine,
+	}
+	if prompt := g.buildSystemPrompt(); contains(prompt, "vet") {
+		t.Fatalf("system prompt leaks a user's profile: %q", prompt)
+	}
+
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "alice", SenderID: "alice", Content: "hello"}
+	scopes := g.inboundScopes(msg)
+	if scopes.Write != alice {
+		t.Fatalf("write scope = %q, want %q", scopes.Write, alice)
+	}
+	if got := g.memoryContext(msg, scopes); !contains(got, "Alice is a vet") {
+		t.Fatalf("first message memory context = %q", got)
+	}
+	if got := g.memoryContext(msg, scopes); got != "" {
+		t.Fatalf("profile repeated on a later message: %q", got)
+	}
+
+	// A group's session is shared by its members, so nobody's own profile
+	// or memories go into it.
+	if err := engine.WriteTier1(memory.ProfileEntry{Content: "Bob is a pilot.", Category: "identity", Scope: memory.UserScope("telegram", "bob")}); err != nil {
+		t.Fatalf("WriteTier1 error: %v", err)
+	}
+	if err := engine.WriteTier1(memory.ProfileEntry{Content: "The group plans a trip.", Category: "context", Scope: memory.ChatScope("telegram", "c1")}); err != nil {
+		t.Fatalf("WriteTier1 error: %v", err)
+	}
+	for _, sender := range []string{"alice", "bob"} {
+		in := bus.InboundMessage{Channel: "telegram", ChatID: "c1", SenderID: sender, Content: "hello", Metadata: map[string]any{"is_group": true}}
+		scopes := g.inboundScopes(in)
+		if scopes.Write != memory.UserScope("telegram", sender) || scopes.Allows(memory.UserScope("telegram", sender)) {
+			t.Fatalf("%s's group scopes = %+v", sender, scopes)
+		}
+		got := g.memoryContext(in, scopes)
+		if contains(got, "vet") || contains(got, "pilot") {
+			t.Fatalf("%s's message in the group shows a member's profile: %q", sender, got)
+		}
+		if sender == "alice" && !contains(got, "The group plans a trip") {
+			t.Fatalf("group profile missing: %q", got)
+		}
+	}
+}
+
 func TestGateway_BuildSystemPrompt_NoFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -326,7 +383,7 @@ func TestGatewayClassicModeUsesRetrieve(t *testing.T) {
 		cfg:     cfg,
 		bus:     msgBus,
 		runtime: mockRt,
-		retrieveClassicFn: func(msg string) ([]memory.Memory, error) {
+		retrieveClassicFn: func(msg string, visible []string) ([]memory.Memory, error) {
 			classicCalls++
 			return []memory.Memory{{
 				ID:      1,
@@ -335,7 +392,7 @@ func TestGatewayClassicModeUsesRetrieve(t *testing.T) {
 				Content: "classic memory",
 			}}, nil
 		},
-		retrieveEnhancedFn: func(msg string) ([]memory.Memory, error) {
+		retrieveEnhancedFn: func(msg string, visible []string) ([]memory.Memory, error) {
 			enhancedCalls++
 			return nil, nil
 		},
@@ -404,11 +461,11 @@ func TestGatewayEnhancedModeUsesHybridRetrieve(t *testing.T) {
 		cfg:     cfg,
 		bus:     msgBus,
 		runtime: mockRt,
-		retrieveClassicFn: func(msg string) ([]memory.Memory, error) {
+		retrieveClassicFn: func(msg string, visible []string) ([]memory.Memory, error) {
 			classicCalls++
 			return []memory.Memory{{Content: "classic memory"}}, nil
 		},
-		retrieveEnhancedFn: func(msg string) ([]memory.Memory, error) {
+		retrieveEnhancedFn: func(msg string, visible []string) ([]memory.Memory, error) {
 			enhancedCalls++
 			return []memory.Memory{{
 				ID:      2,
@@ -482,7 +539,7 @@ func TestGatewayEnhancedFailureFallsBackClassic(t *testing.T) {
 		cfg:     cfg,
 		bus:     msgBus,
 		runtime: mockRt,
-		retrieveClassicFn: func(msg string) ([]memory.Memory, error) {
+		retrieveClassicFn: func(msg string, visible []string) ([]memory.Memory, error) {
 			classicCalls++
 			return []memory.Memory{{
 				ID:      3,
@@ -491,7 +548,7 @@ func TestGatewayEnhancedFailureFallsBackClassic(t *testing.T) {
 				Content: "classic fallback memory",
 			}}, nil
 		},
-		retrieveEnhancedFn: func(msg string) ([]memory.Memory, error) {
+		retrieveEnhancedFn: func(msg string, visible []string) ([]memory.Memory, error) {
 			enhancedCalls++
 			return nil, context.DeadlineExceeded
 		},
@@ -820,6 +877,7 @@ func TestNewWithOptions_MockRuntime(t *testing.T) {
 			Workspace: tmpDir,
 		},
 		Channels: config.ChannelsConfig{},
+		Memory:   config.MemoryConfig{Enabled: true, DBPath: filepath.Join(tmpDir, "memory.db")},
 	}
 
 	mockRt := &mockRuntime{
@@ -861,6 +919,39 @@ func TestNewWithOptions_MockRuntime(t *testing.T) {
 	g.Shutdown()
 }
 
+func TestNewWithOptions_MemoryDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	os.WriteFile(filepath.Join(tmpDir, "AGENTS.md"), []byte("# Agent"), 0644)
+	cfg := &config.Config{
+		Agent:  config.AgentConfig{Workspace: tmpDir},
+		Memory: config.MemoryConfig{Enabled: false, DBPath: filepath.Join(tmpDir, "memory.db")},
+	}
+
+	g, err := NewWithOptions(cfg, Options{RuntimeFactory: mockRuntimeFactory(&mockRuntime{})})
+	if err != nil {
+		t.Fatalf("NewWithOptions error: %v", err)
+	}
+	defer g.Shutdown()
+
+	if g.memEngine != nil || g.extraction != nil {
+		t.Fatal("memory should not be opened when memory.enabled is false")
+	}
+	if _, err := os.Stat(cfg.Memory.DBPath); !os.IsNotExist(err) {
+		t.Fatalf("memory database created while disabled: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.ConfigDir(), "data", "approvals.json")); !os.IsNotExist(err) {
+		t.Fatalf("approval store created without ApprovalsPath: %v", err)
+	}
+	if prompt := g.buildSystemPrompt(); !contains(prompt, "# Agent") || contains(prompt, "Core Memory") {
+		t.Fatalf("system prompt = %q", prompt)
+	}
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "c1", SenderID: "u1", Content: "what did I tell you yesterday?"}
+	if got := g.memoryContext(msg, g.memoryScopes(msg.Channel, msg.ChatID, msg.SenderID)); got != "" {
+		t.Fatalf("memory context with memory disabled = %q", got)
+	}
+}
+
 func TestNewWithOptions_RuntimeFactoryError(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -1189,3 +1280,144 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestGateway_WebhookTriggerDelivers(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		Agent: config.AgentConfig{
+			Workspace: tmpDir,
+		},
+		Webhooks: config.WebhooksConfig{
+			Enabled: true,
+			Endpoints: []config.WebhookEndpoint{{
+				Name:    "ha",
+				Secret:  "s3cret",
+				Verify:  "token",
+				Prompt:  "door {{.Payload.state}}",
+				Channel: "telegram",
+				To:      "99",
+			}},
+		},
+	}
+
+	mockRt := &mockRuntime{
+		response: &api.Response{
+			Result: &api.Result{Output: "door report"},
+		},
+		reqCh: make(chan api.Request, 1),
+	}
+
+	g, err := NewWithOptions(cfg, Options{
+		RuntimeFactory: mockRuntimeFactory(mockRt),
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions error: %v", err)
+	}
+	defer g.Shutdown()
+
+	if g.httpServer == nil {
+		t.Fatal("http server should be configured when webhooks are enabled")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/hooks/ha", strings.NewReader(`{"state":"open"}`))
+	req.Header.Set("X-Webhook-Token", "s3cret")
+	rec := httptest.NewRecorder()
+	g.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+
+	select {
+	case runReq := <-mockRt.reqCh:
+		if runReq.Prompt != "door open" {
+			t.Errorf("prompt = %q, want 'door open'", runReq.Prompt)
+		}
+		if runReq.SessionID != "webhook:ha" {
+			t.Errorf("sessionID = %q, want 'webhook:ha'", runReq.SessionID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for agent run")
+	}
+
+	select {
+	case msg := <-g.bus.Outbound:
+		if msg.Channel != "telegram" || msg.ChatID != "99" || msg.Content != "door report" {
+			t.Errorf("outbound = %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for outbound message")
+	}
+}
+
+func TestNewWithOptions_InvalidWebhookConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		Agent: config.AgentConfig{
+			Workspace: tmpDir,
+		},
+		Webhooks: config.WebhooksConfig{
+			Enabled:   true,
+			Endpoints: []config.WebhookEndpoint{{Name: "missing-secret", Prompt: "x"}},
+		},
+	}
+
+	_, err := NewWithOptions(cfg, Options{
+		RuntimeFactory: mockRuntimeFactory(&mockRuntime{}),
+	})
+	if err == nil || !strings.Contains(err.Error(), "secret is required") {
+		t.Fatalf("err = %v, want secret is required", err)
+	}
+}
+
+// blockingRuntime holds runs for chat "busy" until release is closed.
+type blockingRuntime struct {
+	release chan struct{}
+}
+
+func (r *blockingRuntime) Run(ctx context.Context, req api.Request) (*api.Response, error) {
+	if strings.HasPrefix(req.SessionID, "test:busy") {
+		<-r.release
+	}
+	return &api.Response{Result: &api.Result{Output: "re: " + req.Prompt}}, nil
+}
+
+func (r *blockingRuntime) Close() {}
+
+func TestGateway_ProcessLoop_SessionsRunConcurrently(t *testing.T) {
+	msgBus := bus.NewMessageBus(10)
+	rt := &blockingRuntime{release: make(chan struct{})}
+	g := &Gateway{cfg: &config.Config{Agent: config.AgentConfig{Workspace: t.TempDir()}}, bus: msgBus, runtime: rt}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.processLoop(ctx)
+
+	// The busy chat's first run waits, as for an approval; its second
+	// message queues behind it while another chat is answered.
+	msgBus.Inbound <- bus.InboundMessage{Channel: "test", ChatID: "busy", SenderID: "u1", Content: "first"}
+	msgBus.Inbound <- bus.InboundMessage{Channel: "test", ChatID: "busy", SenderID: "u1", Content: "second"}
+	msgBus.Inbound <- bus.InboundMessage{Channel: "test", ChatID: "free", SenderID: "u2", Content: "other"}
+
+	select {
+	case out := <-msgBus.Outbound:
+		if out.ChatID != "free" || out.Content != "re: other" {
+			t.Fatalf("first reply = %+v, want the free chat's", out)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a waiting run blocked another chat")
+	}
+
+	close(rt.release)
+	for _, want := range []string{"re: first", "re: second"} {
+		select {
+		case out := <-msgBus.Outbound:
+			if out.ChatID != "busy" || out.Content != want {
+				t.Fatalf("reply = %+v, want %q", out, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
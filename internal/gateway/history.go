@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cexll/agentsdk-go/pkg/message"
+	"github.com/stellarlinkco/myclaw/internal/channel"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+// historyReplayLimit bounds the turns replayed to a reconnecting client.
+const historyReplayLimit = 200
+
+// sessionHistory reads the conversation agentsdk-go persists under
+// <workspace>/.claude/history (kept for cleanupPeriodDays, 30 by default)
+// and returns its user and final assistant turns.
+func sessionHistory(workspace, sessionKey string) ([]channel.HistoryEntry, error) {
+	path := filepath.Join(workspace, ".claude", "history", historyFileName(sessionKey)+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+
+	var (
+		msgs    []message.Message
+		wrapper struct {
+			Messages []message.Message `json:"messages"`
+		}
+	)
+	// Older files are a bare message array.
+	if err := json.Unmarshal(data, &wrapper); err == nil {
+		msgs = wrapper.Messages
+	} else if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, fmt.Errorf("decode history: %w", err)
+	}
+
+	var entries []channel.HistoryEntry
+	for _, m := range msgs {
+		content := historyText(m)
+		switch {
+		case content == "":
+			continue
+		case m.Role == "user":
+			content = memory.StripMemoryContext(content)
+		case m.Role == "assistant" && len(m.ToolCalls) == 0:
+		default:
+			// Tool calls, tool results and system turns are not shown.
+			continue
+		}
+		entries = append(entries, channel.HistoryEntry{Role: m.Role, Content: content})
+	}
+	if len(entries) > historyReplayLimit {
+		entries = entries[len(entries)-historyReplayLimit:]
+	}
+	return entries, nil
+}
+
+func historyText(m message.Message) string {
+	if len(m.ContentBlocks) == 0 {
+		return strings.TrimSpace(m.Content)
+	}
+	var parts []string
+	for _, b := range m.ContentBlocks {
+		switch b.Type {
+		case message.ContentBlockText:
+			if t := strings.TrimSpace(b.Text); t != "" {
+				parts = append(parts, t)
+			}
+		case message.ContentBlockImage:
+			parts = append(parts, "[Image]")
+		case message.ContentBlockDocument:
+			parts = append(parts, "[Document]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// historyFileName mirrors agentsdk-go's file naming for a session ID.
+func historyFileName(sessionID string) string {
+	trimmed := strings.TrimSpace(sessionID)
+	var b strings.Builder
+	for _, r := range trimmed {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	if name := strings.Trim(b.String(), "-"); name != "" {
+		return name
+	}
+	return "default"
+}
@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cexll/agentsdk-go/pkg/message"
+	"github.com/stellarlinkco/myclaw/internal/memory"
+)
+
+func writeHistoryFile(t *testing.T, workspace, sessionKey string, payload any) {
+	t.Helper()
+	dir := filepath.Join(workspace, ".claude", "history")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, historyFileName(sessionKey)+".json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionHistory(t *testing.T) {
+	workspace := t.TempDir()
+	key := "webui:alice/trip"
+	writeHistoryFile(t, workspace, key, map[string]any{
+		"version":    1,
+		"session_id": key,
+		"messages": []message.Message{
+			{Role: "user", Content: memory.WithMemoryContext("- likes temples", "Plan a trip to Kyoto")},
+			{Role: "assistant", Content: "Checking the weather", ToolCalls: []message.ToolCall{{ID: "1", Name: "web_search"}}},
+			{Role: "tool", Content: "sunny"},
+			{Role: "assistant", Content: "Day 1: Kiyomizu-dera"},
+			{Role: "user", ContentBlocks: []message.ContentBlock{{Type: message.ContentBlockImage, Data: "xx"}, {Type: message.ContentBlockText, Text: "this one?"}}},
+		},
+	})
+
+	got, err := sessionHistory(workspace, key)
+	if err != nil {
+		t.Fatalf("sessionHistory: %v", err)
+	}
+	want := []struct{ role, content string }{
+		{"user", "Plan a trip to Kyoto"},
+		{"assistant", "Day 1: Kiyomizu-dera"},
+		{"user", "[Image]\nthis one?"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("entries = %+v", got)
+	}
+	for i, w := range want {
+		if got[i].Role != w.role || got[i].Content != w.content {
+			t.Errorf("entry %d = %+v, want %s %q", i, got[i], w.role, w.content)
+		}
+	}
+
+	// Legacy bare arrays and missing files.
+	writeHistoryFile(t, workspace, "webui:bob/default", []message.Message{{Role: "user", Content: "hi"}})
+	if got, err := sessionHistory(workspace, "webui:bob/default"); err != nil || len(got) != 1 {
+		t.Fatalf("legacy history = %+v, %v", got, err)
+	}
+	if got, err := sessionHistory(workspace, "webui:nobody/default"); err != nil || got != nil {
+		t.Fatalf("missing history = %+v, %v", got, err)
+	}
+}
+
+func TestHistoryFileName(t *testing.T) {
+	tests := map[string]string{
+		"webui:alice/default": "webui-alice-default",
+		"telegram:123":        "telegram-123",
+		"webui:oidc:abc/c_1":  "webui-oidc-abc-c_1",
+		"  ":                  "default",
+		"::":                  "default",
+	}
+	for in, want := range tests {
+		if got := historyFileName(in); got != want {
+			t.Errorf("historyFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	coreevents "github.com/cexll/agentsdk-go/pkg/core/events"
+	"github.com/cexll/agentsdk-go/pkg/security"
+	"github.com/cexll/agentsdk-go/pkg/tool"
+	toolbuiltin "github.com/cexll/agentsdk-go/pkg/tool/builtin"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+)
+
+const (
+	callbackApprove = "approve"
+	callbackDeny    = "deny"
+	callbackAnswer  = "answer"
+
+	// builtinAskToolName is disabled in favour of askUserToolName: the builtin
+	// only echoes its questions back to the model.
+	builtinAskToolName = "AskUserQuestion"
+	askUserToolName    = "AskUser"
+
+	interactionTimeout = 10 * time.Minute
+)
+
+type sessionIDKey struct{}
+
+func withSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, sessionID)
+}
+
+func sessionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey{}).(string)
+	return id
+}
+
+type senderIDKey struct{}
+
+// withSenderID records who sent the message a run answers, so approvals it
+// asks for can only be resolved by that person.
+func withSenderID(ctx context.Context, senderID string) context.Context {
+	return context.WithValue(ctx, senderIDKey{}, senderID)
+}
+
+func senderIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(senderIDKey{}).(string)
+	return id
+}
+
+// interactor surfaces tool approvals and agent questions as buttons on
+// channels that support them, and routes the presses back to the waiting run.
+type interactor struct {
+	bus       *bus.MessageBus
+	approvals *security.ApprovalQueue
+	supports  func(channel string) bool
+	timeout   time.Duration
+	// approvers ("channel:senderID") may resolve any approval; everyone else
+	// only the ones their own messages led to.
+	approvers map[string]bool
+
+	mu         sync.Mutex
+	pending    map[string]*pendingQuestion
+	requesters map[string]string // approval ID -> sender of the triggering message
+}
+
+type pendingQuestion struct {
+	sessionID string
+	options   []string
+	answer    chan string
+}
+
+func newInteractor(b *bus.MessageBus, approvals *security.ApprovalQueue, approvers []string, supports func(channel string) bool) *interactor {
+	in := &interactor{
+		bus:        b,
+		approvals:  approvals,
+		supports:   supports,
+		timeout:    interactionTimeout,
+		approvers:  make(map[string]bool, len(approvers)),
+		pending:    make(map[string]*pendingQuestion),
+		requesters: make(map[string]string),
+	}
+	for _, a := range approvers {
+		if a = strings.TrimSpace(a); a != "" {
+			in.approvers[a] = true
+		}
+	}
+	return in
+}
+
+// route maps a session key ("channel:chatID") to a channel that can show buttons.
+func (in *interactor) route(sessionID string) (channel, chatID string, ok bool) {
+	channel, chatID, found := strings.Cut(sessionID, ":")
+	if !found || chatID == "" || in.supports == nil || !in.supports(channel) {
+		return "", "", false
+	}
+	return channel, chatID, true
+}
+
+// requestPermission is the runtime PermissionRequestHandler. It shows
+// Approve/Deny buttons for the pending ApprovalRecord and waits for a press;
+// the resolution is read back from the approval queue by the runtime.
+func (in *interactor) requestPermission(ctx context.Context, req api.PermissionRequest) (coreevents.PermissionDecisionType, error) {
+	channel, chatID, ok := in.route(req.SessionID)
+	if !ok || req.Approval == nil {
+		return coreevents.PermissionDeny, nil
+	}
+	id := req.Approval.ID
+	in.mu.Lock()
+	in.requesters[id] = senderIDFromContext(ctx)
+	in.mu.Unlock()
+	defer func() {
+		in.mu.Lock()
+		delete(in.requesters, id)
+		in.mu.Unlock()
+	}()
+
+	in.bus.Outbound <- bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: formatApprovalPrompt(req),
+		Buttons: [][]bus.Button{{
+			{Text: "✅ Approve", Data: callbackApprove + ":" + id},
+			{Text: "❌ Deny", Data: callbackDeny + ":" + id},
+		}},
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, in.timeout)
+	defer cancel()
+	if _, err := in.approvals.Wait(waitCtx, id); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if _, err := in.approvals.Deny(id, "myclaw", "approval timed out"); err != nil {
+			log.Printf("[gateway] deny expired approval %s: %v", id, err)
+		}
+		in.bus.Outbound <- bus.OutboundMessage{
+			Channel: channel,
+			ChatID:  chatID,
+			Content: fmt.Sprintf("No answer within %s, %s was denied.", in.timeout, req.ToolName),
+		}
+	}
+	return coreevents.PermissionAsk, nil
+}
+
+// approvalStore mirrors the file layout of security.ApprovalQueue.
+type approvalStore struct {
+	Records   []*security.ApprovalRecord `json:"records"`
+	Whitelist map[string]time.Time       `json:"whitelist"`
+}
+
+// pruneApprovals rewrites the approval store at path before the queue loads
+// it. No run outlives the process, so every record left is either resolved
+// or can no longer be answered; only unexpired session whitelists are kept.
+func pruneApprovals(path string, now time.Time) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read approvals: %w", err)
+	}
+	var store approvalStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return fmt.Errorf("parse approvals: %w", err)
+	}
+
+	pruned := approvalStore{Records: []*security.ApprovalRecord{}, Whitelist: make(map[string]time.Time)}
+	for session, expiry := range store.Whitelist {
+		if expiry.After(now) {
+			pruned.Whitelist[session] = expiry
+		}
+	}
+	if len(store.Records) == 0 && len(pruned.Whitelist) == len(store.Whitelist) {
+		return nil
+	}
+	data, err = json.MarshalIndent(pruned, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode approvals: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write approvals: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func formatApprovalPrompt(req api.PermissionRequest) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔐 Approval required: %s", req.ToolName)
+	if target := strings.TrimSpace(req.Target); target != "" {
+		fmt.Fprintf(&sb, "\n```\n%s\n```", target)
+	}
+	if reason := strings.TrimSpace(req.Reason); reason != "" {
+		fmt.Fprintf(&sb, "\n%s", reason)
+	}
+	return sb.String()
+}
+
+// ask posts a question with one button per option and blocks until one is
+// pressed, the timeout passes or ctx is done.
+func (in *interactor) ask(ctx context.Context, sessionID, text string, options []string) (string, error) {
+	channel, chatID, ok := in.route(sessionID)
+	if !ok {
+		return "", fmt.Errorf("session %q has no chat that can show choices", sessionID)
+	}
+
+	token, err := newQuestionToken()
+	if err != nil {
+		return "", err
+	}
+	q := &pendingQuestion{sessionID: sessionID, options: options, answer: make(chan string, 1)}
+	in.mu.Lock()
+	in.pending[token] = q
+	in.mu.Unlock()
+	defer func() {
+		in.mu.Lock()
+		delete(in.pending, token)
+		in.mu.Unlock()
+	}()
+
+	buttons := make([][]bus.Button, 0, len(options))
+	for i, opt := range options {
+		buttons = append(buttons, []bus.Button{{Text: opt, Data: fmt.Sprintf("%s:%s:%d", callbackAnswer, token, i)}})
+	}
+	in.bus.Outbound <- bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: text, Buttons: buttons}
+
+	timer := time.NewTimer(in.timeout)
+	defer timer.Stop()
+	select {
+	case answer := <-q.answer:
+		return answer, nil
+	case <-timer.C:
+		return "", fmt.Errorf("no answer within %s", in.timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func newQuestionToken() (string, error) {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate question token: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// handleCallback resolves the approval or question a button press refers to
+// and reports whether it did. Presses from a chat other than the one the
+// prompt was sent to are ignored, as are approval presses by anyone but the
+// requester or a configured approver.
+func (in *interactor) handleCallback(msg bus.CallbackMessage) bool {
+	action, arg, _ := strings.Cut(msg.Data, ":")
+	switch action {
+	case callbackApprove, callbackDeny:
+		return in.resolveApproval(msg, action == callbackApprove, arg)
+	case callbackAnswer:
+		return in.answer(msg, arg)
+	default:
+		log.Printf("[gateway] unknown callback %q from %s", msg.Data, msg.SessionKey())
+		return false
+	}
+}
+
+func (in *interactor) resolveApproval(msg bus.CallbackMessage, approve bool, id string) bool {
+	var record *security.ApprovalRecord
+	for _, rec := range in.approvals.ListPending() {
+		if rec.ID == id {
+			record = rec
+			break
+		}
+	}
+	if record == nil {
+		log.Printf("[gateway] approval %s is no longer pending", id)
+		return false
+	}
+	if record.SessionID != msg.SessionKey() {
+		log.Printf("[gateway] approval %s pressed from %s, belongs to %s", id, msg.SessionKey(), record.SessionID)
+		return false
+	}
+	approver := msg.Channel + ":" + msg.SenderID
+	in.mu.Lock()
+	requester := in.requesters[id]
+	in.mu.Unlock()
+	if !in.approvers[approver] && (requester == "" || msg.SenderID != requester) {
+		log.Printf("[gateway] approval %s pressed by %s, who neither requested it nor is an approver", id, approver)
+		return false
+	}
+
+	var err error
+	if approve {
+		_, err = in.approvals.Approve(id, approver, 0)
+	} else {
+		_, err = in.approvals.Deny(id, approver, "denied in chat")
+	}
+	if err != nil {
+		log.Printf("[gateway] resolve approval %s: %v", id, err)
+		return false
+	}
+	return true
+}
+
+func (in *interactor) answer(msg bus.CallbackMessage, arg string) bool {
+	token, idxStr, _ := strings.Cut(arg, ":")
+	idx, err := strconv.Atoi(idxStr)
+	if err != nil {
+		log.Printf("[gateway] malformed answer callback %q", msg.Data)
+		return false
+	}
+
+	in.mu.Lock()
+	q, ok := in.pending[token]
+	in.mu.Unlock()
+	if !ok {
+		log.Printf("[gateway] question %s is no longer pending", token)
+		return false
+	}
+	if q.sessionID != msg.SessionKey() || idx < 0 || idx >= len(q.options) {
+		log.Printf("[gateway] ignored answer %q from %s", msg.Data, msg.SessionKey())
+		return false
+	}
+	select {
+	case q.answer <- q.options[idx]:
+		return true
+	default: // already answered
+		return false
+	}
+}
+
+// askUserTool lets the agent put multiple-choice questions to the chat user.
+// It accepts the AskUserQuestion builtin's parameters.
+type askUserTool struct {
+	in      *interactor
+	builtin *toolbuiltin.AskUserQuestionTool
+}
+
+func newAskUserTool(in *interactor) *askUserTool {
+	return &askUserTool{in: in, builtin: toolbuiltin.NewAskUserQuestionTool()}
+}
+
+func (t *askUserTool) Name() string { return askUserToolName }
+
+func (t *askUserTool) Description() string {
+	return `Ask the user multiple-choice questions in the chat and wait for the answers.
+Use it when you need a decision or preference before you can continue.
+Each question is shown with one button per option and the user taps exactly one,
+so multiSelect questions also receive a single answer. If the chat cannot show
+buttons the tool fails; ask in your reply instead.`
+}
+
+func (t *askUserTool) Schema() *tool.JSONSchema { return t.builtin.Schema() }
+
+func (t *askUserTool) Execute(ctx context.Context, params map[string]interface{}) (*tool.ToolResult, error) {
+	// The builtin validates the parameters and normalises the questions.
+	parsed, err := t.builtin.Execute(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := parsed.Data.(map[string]interface{})
+	questions, _ := data["questions"].([]toolbuiltin.Question)
+
+	sessionID := sessionIDFromContext(ctx)
+	answers := make(map[string]string, len(questions))
+	var out strings.Builder
+	for i, q := range questions {
+		text := q.Question
+		if q.Header != "" {
+			text = q.Header + "\n" + q.Question
+		}
+		options := make([]string, len(q.Options))
+		for j, opt := range q.Options {
+			options[j] = opt.Label
+			if opt.Description != "" {
+				text += fmt.Sprintf("\n• %s: %s", opt.Label, opt.Description)
+			}
+		}
+
+		answer, err := t.in.ask(ctx, sessionID, text, options)
+		if err != nil {
+			return nil, err
+		}
+		answers[q.Question] = answer
+		fmt.Fprintf(&out, "%d. %s\n   → %s\n", i+1, q.Question, answer)
+	}
+
+	return &tool.ToolResult{
+		Success: true,
+		Output:  strings.TrimRight(out.String(), "\n"),
+		Data:    map[string]interface{}{"questions": questions, "answers": answers},
+	}, nil
+}
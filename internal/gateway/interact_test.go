@@ -0,0 +1,266 @@
+package gateway
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	coreevents "github.com/cexll/agentsdk-go/pkg/core/events"
+	"github.com/cexll/agentsdk-go/pkg/security"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+)
+
+func newTestInteractor(t *testing.T) (*interactor, *bus.MessageBus) {
+	t.Helper()
+	q, err := security.NewApprovalQueue(filepath.Join(t.TempDir(), "approvals.json"))
+	if err != nil {
+		t.Fatalf("NewApprovalQueue: %v", err)
+	}
+	b := bus.NewMessageBus(10)
+	in := newInteractor(b, q, nil, func(channel string) bool { return channel == "telegram" })
+	return in, b
+}
+
+func nextOutbound(t *testing.T, b *bus.MessageBus) bus.OutboundMessage {
+	t.Helper()
+	select {
+	case msg := <-b.Outbound:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for outbound message")
+		return bus.OutboundMessage{}
+	}
+}
+
+type permissionResult struct {
+	decision coreevents.PermissionDecisionType
+	err      error
+}
+
+func requestPermissionAsync(in *interactor, req api.PermissionRequest) chan permissionResult {
+	done := make(chan permissionResult, 1)
+	go func() {
+		// The run answers a message from sender 7.
+		d, err := in.requestPermission(withSenderID(context.Background(), "7"), req)
+		done <- permissionResult{d, err}
+	}()
+	return done
+}
+
+func TestInteractor_ApprovalButtons(t *testing.T) {
+	in, b := newTestInteractor(t)
+	rec, err := in.approvals.Request("telegram:42", "Bash(rm -rf build)", nil)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+
+	done := requestPermissionAsync(in, api.PermissionRequest{
+		ToolName:  "Bash",
+		SessionID: "telegram:42",
+		Target:    "rm -rf build",
+		Approval:  rec,
+	})
+
+	msg := nextOutbound(t, b)
+	if msg.Channel != "telegram" || msg.ChatID != "42" || !strings.Contains(msg.Content, "rm -rf build") {
+		t.Fatalf("outbound = %+v", msg)
+	}
+	if len(msg.Buttons) != 1 || len(msg.Buttons[0]) != 2 || msg.Buttons[0][0].Data != "approve:"+rec.ID {
+		t.Fatalf("buttons = %+v", msg.Buttons)
+	}
+
+	// A press from another chat must not resolve the approval.
+	if in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "99", SenderID: "7", Data: msg.Buttons[0][0].Data}) || len(in.approvals.ListPending()) != 1 {
+		t.Fatal("approval resolved from the wrong chat")
+	}
+	// Nor may another member of the same chat answer for the requester.
+	if in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "42", SenderID: "8", Data: msg.Buttons[0][0].Data}) || len(in.approvals.ListPending()) != 1 {
+		t.Fatal("approval resolved by someone other than the requester")
+	}
+
+	if !in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "42", SenderID: "7", Data: msg.Buttons[0][0].Data}) {
+		t.Fatal("requester's press was not accepted")
+	}
+	select {
+	case res := <-done:
+		if res.err != nil || res.decision != coreevents.PermissionAsk {
+			t.Fatalf("requestPermission = %v, %v", res.decision, res.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("requestPermission did not return after approval")
+	}
+
+	resolved, err := in.approvals.Wait(context.Background(), rec.ID)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if resolved.State != security.ApprovalApproved || resolved.Approver != "telegram:7" {
+		t.Errorf("record = %+v", resolved)
+	}
+}
+
+func TestInteractor_ApprovalDenyAndTimeout(t *testing.T) {
+	in, b := newTestInteractor(t)
+
+	rec, _ := in.approvals.Request("telegram:42", "Bash", nil)
+	done := requestPermissionAsync(in, api.PermissionRequest{ToolName: "Bash", SessionID: "telegram:42", Approval: rec})
+	msg := nextOutbound(t, b)
+	in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "42", SenderID: "7", Data: msg.Buttons[0][1].Data})
+	<-done
+	if got, _ := in.approvals.Wait(context.Background(), rec.ID); got.State != security.ApprovalDenied {
+		t.Errorf("state after deny = %s", got.State)
+	}
+
+	in.timeout = 50 * time.Millisecond
+	rec, _ = in.approvals.Request("telegram:42", "Bash", nil)
+	done = requestPermissionAsync(in, api.PermissionRequest{ToolName: "Bash", SessionID: "telegram:42", Approval: rec})
+	nextOutbound(t, b)
+	if notice := nextOutbound(t, b); !strings.Contains(notice.Content, "denied") {
+		t.Errorf("timeout notice = %q", notice.Content)
+	}
+	<-done
+	if got, _ := in.approvals.Wait(context.Background(), rec.ID); got.State != security.ApprovalDenied {
+		t.Errorf("state after timeout = %s", got.State)
+	}
+}
+
+func TestInteractor_ApprovalByConfiguredApprover(t *testing.T) {
+	in, b := newTestInteractor(t)
+	in.approvers = map[string]bool{"telegram:8": true}
+
+	rec, _ := in.approvals.Request("telegram:42", "Bash", nil)
+	done := requestPermissionAsync(in, api.PermissionRequest{ToolName: "Bash", SessionID: "telegram:42", Approval: rec})
+	msg := nextOutbound(t, b)
+	in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "42", SenderID: "8", Data: msg.Buttons[0][0].Data})
+	<-done
+	if got, _ := in.approvals.Wait(context.Background(), rec.ID); got.State != security.ApprovalApproved || got.Approver != "telegram:8" {
+		t.Errorf("record = %+v", got)
+	}
+}
+
+func TestInteractor_ApprovalWithoutButtonsDenies(t *testing.T) {
+	in, b := newTestInteractor(t)
+	for _, session := range []string{"system", "webui:abc", "webhook:github"} {
+		rec, _ := in.approvals.Request(session, "Bash", nil)
+		d, err := in.requestPermission(context.Background(), api.PermissionRequest{ToolName: "Bash", SessionID: session, Approval: rec})
+		if err != nil || d != coreevents.PermissionDeny {
+			t.Errorf("%s: decision = %v, %v", session, d, err)
+		}
+	}
+	select {
+	case msg := <-b.Outbound:
+		t.Fatalf("unexpected outbound: %+v", msg)
+	default:
+	}
+}
+
+func TestAskUserTool(t *testing.T) {
+	in, b := newTestInteractor(t)
+	askTool := newAskUserTool(in)
+
+	params := map[string]interface{}{
+		"questions": []interface{}{map[string]interface{}{
+			"question":    "Which database?",
+			"header":      "DB",
+			"multiSelect": false,
+			"options": []interface{}{
+				map[string]interface{}{"label": "Postgres", "description": "relational"},
+				map[string]interface{}{"label": "SQLite", "description": "embedded"},
+			},
+		}},
+	}
+
+	type toolResult struct {
+		output string
+		err    error
+	}
+	done := make(chan toolResult, 1)
+	go func() {
+		res, err := askTool.Execute(withSessionID(context.Background(), "telegram:42"), params)
+		if err != nil {
+			done <- toolResult{err: err}
+			return
+		}
+		done <- toolResult{output: res.Output}
+	}()
+
+	msg := nextOutbound(t, b)
+	if !strings.Contains(msg.Content, "Which database?") || len(msg.Buttons) != 2 || msg.Buttons[1][0].Text != "SQLite" {
+		t.Fatalf("question message = %+v", msg)
+	}
+
+	if in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "7", SenderID: "7", Data: msg.Buttons[1][0].Data}) {
+		t.Error("answer from another chat was accepted")
+	}
+	if !in.handleCallback(bus.CallbackMessage{Channel: "telegram", ChatID: "42", SenderID: "7", Data: msg.Buttons[1][0].Data}) {
+		t.Error("answer was not accepted")
+	}
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("Execute error: %v", res.err)
+		}
+		if !strings.Contains(res.output, "→ SQLite") {
+			t.Errorf("output = %q", res.output)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("tool did not return after answer")
+	}
+}
+
+func TestAskUserTool_NoButtons(t *testing.T) {
+	in, _ := newTestInteractor(t)
+	params := map[string]interface{}{
+		"questions": []interface{}{map[string]interface{}{
+			"question": "Proceed?", "header": "Go", "multiSelect": false,
+			"options": []interface{}{
+				map[string]interface{}{"label": "Yes", "description": ""},
+				map[string]interface{}{"label": "No", "description": ""},
+			},
+		}},
+	}
+	if _, err := newAskUserTool(in).Execute(withSessionID(context.Background(), "system"), params); err == nil {
+		t.Fatal("expected error for session without buttons")
+	}
+}
+
+func TestPruneApprovals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.json")
+	q, err := security.NewApprovalQueue(path)
+	if err != nil {
+		t.Fatalf("NewApprovalQueue: %v", err)
+	}
+	done, _ := q.Request("telegram:42", "Bash", nil)
+	if _, err := q.Approve(done.ID, "telegram:7", time.Hour); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	q.Request("telegram:43", "Bash", nil) // orphaned by the restart
+
+	if err := pruneApprovals(path, time.Now()); err != nil {
+		t.Fatalf("pruneApprovals: %v", err)
+	}
+	q, err = security.NewApprovalQueue(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if pending := q.ListPending(); len(pending) != 0 {
+		t.Errorf("pending after prune = %+v", pending)
+	}
+	if !q.IsWhitelisted("telegram:42") {
+		t.Error("unexpired session whitelist was dropped")
+	}
+
+	if err := pruneApprovals(path, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("pruneApprovals: %v", err)
+	}
+	if q, _ = security.NewApprovalQueue(path); q.IsWhitelisted("telegram:42") {
+		t.Error("expired session whitelist was kept")
+	}
+	if err := pruneApprovals(filepath.Join(t.TempDir(), "missing.json"), time.Now()); err != nil {
+		t.Errorf("missing store: %v", err)
+	}
+}
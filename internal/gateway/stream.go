@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	"github.com/cexll/agentsdk-go/pkg/model"
+)
+
+// streamPublishInterval throttles partial replies sent to streaming channels.
+const streamPublishInterval = 500 * time.Millisecond
+
+// StreamingRuntime is implemented by runtimes that can stream model output.
+type StreamingRuntime interface {
+	RunStream(ctx context.Context, req api.Request) (<-chan api.StreamEvent, error)
+}
+
+func (r *runtimeAdapter) RunStream(ctx context.Context, req api.Request) (<-chan api.StreamEvent, error) {
+	return r.rt.RunStream(ctx, req)
+}
+
+// runAgentStream runs the agent through RunStream and calls publish with the
+// reply text so far, at most once per streamPublishInterval. Text of
+// successive model messages (around tool calls) is joined by a blank line.
+func (g *Gateway) runAgentStream(ctx context.Context, sr StreamingRuntime, prompt, sessionID string, contentBlocks []model.ContentBlock, publish func(string)) (string, error) {
+	events, err := sr.RunStream(withSessionID(ctx, sessionID), agentRequest(prompt, sessionID, contentBlocks))
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		done      strings.Builder
+		current   strings.Builder
+		published string
+		lastPub   time.Time
+		runErr    error
+	)
+	text := func() string {
+		if current.Len() == 0 {
+			return done.String()
+		}
+		if done.Len() == 0 {
+			return current.String()
+		}
+		return done.String() + "\n\n" + current.String()
+	}
+
+	for evt := range events {
+		switch evt.Type {
+		case api.EventMessageStart:
+			if strings.TrimSpace(current.String()) != "" {
+				done.Reset()
+				done.WriteString(text())
+			}
+			current.Reset()
+		case api.EventContentBlockDelta:
+			if evt.Delta == nil || evt.Delta.Type != "text_delta" || evt.Delta.Text == "" {
+				continue
+			}
+			current.WriteString(evt.Delta.Text)
+			if out := text(); out != published && time.Since(lastPub) >= streamPublishInterval {
+				publish(out)
+				published = out
+				lastPub = time.Now()
+			}
+		case api.EventError:
+			if runErr == nil {
+				runErr = fmt.Errorf("agent stream: %v", evt.Output)
+			}
+		}
+	}
+	if runErr != nil {
+		return "", runErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text()), nil
+}
@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/api"
+	"github.com/stellarlinkco/myclaw/internal/bus"
+	"github.com/stellarlinkco/myclaw/internal/channel"
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+// mockStreamRuntime replays events from RunStream.
+type mockStreamRuntime struct {
+	mockRuntime
+	events []api.StreamEvent
+}
+
+func (m *mockStreamRuntime) RunStream(ctx context.Context, req api.Request) (<-chan api.StreamEvent, error) {
+	ch := make(chan api.StreamEvent, len(m.events))
+	for _, evt := range m.events {
+		ch <- evt
+	}
+	close(ch)
+	return ch, nil
+}
+
+func textDelta(s string) api.StreamEvent {
+	return api.StreamEvent{Type: api.EventContentBlockDelta, Delta: &api.Delta{Type: "text_delta", Text: s}}
+}
+
+func TestRunAgentStream_AccumulatesMessages(t *testing.T) {
+	rt := &mockStreamRuntime{events: []api.StreamEvent{
+		{Type: api.EventMessageStart},
+		textDelta("Hel"),
+		textDelta("lo"),
+		{Type: api.EventMessageStop},
+		{Type: api.EventToolExecutionStart, Name: "bash"},
+		{Type: api.EventMessageStart},
+		textDelta("world"),
+		{Type: api.EventMessageStop},
+		{Type: api.EventAgentStop},
+	}}
+	g := &Gateway{runtime: rt}
+
+	var partials []string
+	out, err := g.runAgentStream(context.Background(), rt, "hi", "s1", nil, func(s string) {
+		partials = append(partials, s)
+	})
+	if err != nil {
+		t.Fatalf("runAgentStream error: %v", err)
+	}
+	if out != "Hello\n\nworld" {
+		t.Fatalf("result = %q", out)
+	}
+	// Later deltas arrive within the throttle interval.
+	if len(partials) != 1 || partials[0] != "Hel" {
+		t.Fatalf("partials = %q", partials)
+	}
+}
+
+func TestRunAgentStream_Error(t *testing.T) {
+	isErr := true
+	rt := &mockStreamRuntime{events: []api.StreamEvent{
+		textDelta("partial"),
+		{Type: api.EventError, Output: "model overloaded", IsError: &isErr},
+	}}
+	g := &Gateway{runtime: rt}
+
+	_, err := g.runAgentStream(context.Background(), rt, "hi", "s1", nil, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "model overloaded") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestGateway_ProcessLoopStreamsToStreamingChannel(t *testing.T) {
+	msgBus := bus.NewMessageBus(10)
+	chMgr, err := channel.NewChannelManager(config.ChannelsConfig{
+		WeCom: config.WeComConfig{
+			Enabled:        true,
+			Token:          "token",
+			EncodingAESKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG",
+			Stream:         true,
+		},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewChannelManager error: %v", err)
+	}
+
+	rt := &mockStreamRuntime{events: []api.StreamEvent{
+		{Type: api.EventMessageStart},
+		textDelta("streamed"),
+		{Type: api.EventMessageStop},
+	}}
+	g := &Gateway{
+		cfg:      &config.Config{Agent: config.AgentConfig{Workspace: t.TempDir()}},
+		bus:      msgBus,
+		channels: chMgr,
+		runtime:  rt,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.processLoop(ctx)
+
+	msgBus.Inbound <- bus.InboundMessage{
+		Channel:   "wecom",
+		SenderID:  "user1",
+		ChatID:    "user1",
+		Content:   "hello",
+		MessageID: "msg-1",
+	}
+
+	want := []bus.OutboundMessage{
+		{Channel: "wecom", ChatID: "user1", Content: "streamed", ReplyTo: "msg-1", Partial: true},
+		{Channel: "wecom", ChatID: "user1", Content: "streamed", ReplyTo: "msg-1", StreamEnd: true},
+	}
+	for i, w := range want {
+		select {
+		case got := <-msgBus.Outbound:
+			if got.Channel != w.Channel || got.ChatID != w.ChatID || got.Content != w.Content ||
+				got.ReplyTo != w.ReplyTo || got.Partial != w.Partial || got.StreamEnd != w.StreamEnd {
+				t.Fatalf("outbound[%d] = %+v, want %+v", i, got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for outbound[%d]", i)
+		}
+	}
+}
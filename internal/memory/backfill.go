@@ -35,14 +35,14 @@ func (e *Engine) embeddingSnapshot() (Embedder, string, int) {
 	return e.embedder, e.embeddingModel, e.embeddingTimeoutMs
 }
 
-func (e *Engine) insertTier2Row(project, topic, category, content string, importance float64) (int64, error) {
+func (e *Engine) insertTier2Row(project, topic, category, content string, importance float64, scope string) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	result, err := e.db.Exec(`
-		INSERT INTO memories (tier, project, topic, category, content, importance, source)
-		VALUES (2, ?, ?, ?, ?, ?, 'extraction')
-	`, project, topic, category, content, importance)
+		INSERT INTO memories (tier, project, topic, category, content, fts_content, importance, source, scope)
+		VALUES (2, ?, ?, ?, ?, ?, ?, 'extraction', ?)
+	`, project, topic, category, content, ftsText(content), importance, scope)
 	if err != nil {
 		return 0, fmt.Errorf("write tier2: %w", err)
 	}
@@ -61,8 +61,8 @@ func (e *Engine) scheduleTier2Embedding(memoryID int64, content string) {
 	if embedder == nil || memoryID <= 0 {
 		return
 	}
-	content = strings.TrimSpace(content)
-	if content == "" {
+	text := strings.TrimSpace(content)
+	if text == "" {
 		return
 	}
 
@@ -70,12 +70,12 @@ func (e *Engine) scheduleTier2Embedding(memoryID int64, content string) {
 		ctx, cancel := withEmbeddingTimeout(context.Background(), timeoutMs)
 		defer cancel()
 
-		vector, err := embedder.Embed(ctx, content)
+		vector, err := embedder.Embed(ctx, text)
 		if err != nil {
 			log.Printf("[memory] async tier2 embedding failed id=%d: %v", memoryID, err)
 			return
 		}
-		if err := e.UpdateMemoryEmbedding(memoryID, model, vector); err != nil {
+		if _, err := e.storeEmbedding(memoryID, model, vector, &content); err != nil {
 			log.Printf("[memory] async tier2 embedding persist failed id=%d: %v", memoryID, err)
 		}
 	}()
@@ -83,60 +83,96 @@ func (e *Engine) scheduleTier2Embedding(memoryID int64, content string) {
 
 // UpdateMemoryEmbedding idempotently upserts embedding fields for a memory row.
 func (e *Engine) UpdateMemoryEmbedding(memoryID int64, model string, vector []float32) error {
+	_, err := e.storeEmbedding(memoryID, model, vector, nil)
+	return err
+}
+
+// storeEmbedding writes the vector and reports whether the row took it. With
+// a non-nil content the vector is only stored while the row still holds that
+// text: an UpdateMemory that ran during the embedding call has cleared the
+// vector and queued its own, which this older one must not overwrite.
+func (e *Engine) storeEmbedding(memoryID int64, model string, vector []float32, content *string) (bool, error) {
 	if memoryID <= 0 {
-		return fmt.Errorf("update memory embedding: invalid id %d", memoryID)
+		return false, fmt.Errorf("update memory embedding: invalid id %d", memoryID)
 	}
 
 	blob, err := EncodeVector(vector)
 	if err != nil {
-		return fmt.Errorf("update memory embedding: %w", err)
+		return false, fmt.Errorf("update memory embedding: %w", err)
 	}
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if _, err := e.db.Exec(`
+	q := `
 		UPDATE memories
 		SET embedding = ?,
 		    embedding_model = ?,
 		    embedding_dim = ?,
 		    embedding_updated_at = datetime('now'),
+		    embedding_stale = 0,
 		    updated_at = datetime('now')
-		WHERE id = ? AND tier = 2
-	`, blob, strings.TrimSpace(model), len(vector), memoryID); err != nil {
-		return fmt.Errorf("update memory embedding: %w", err)
+		WHERE id = ? AND tier = 2`
+	args := []any{blob, strings.TrimSpace(model), len(vector), memoryID}
+	if content != nil {
+		q += ` AND content = ?`
+		args = append(args, *content)
+	}
+	res, err := e.db.Exec(q, args...)
+	if err != nil {
+		return false, fmt.Errorf("update memory embedding: %w", err)
 	}
-	return nil
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return false, nil
+	}
+	e.indexVector(memoryID, vector)
+	return true, nil
 }
 
-// BackfillEmbeddings fills missing tier-2 embeddings in deterministic id order.
-// It is idempotent: rows with existing embeddings are skipped.
+// BackfillEmbeddings fills missing tier-2 embeddings and replaces stale ones
+// in deterministic id order. It is idempotent: rows with current embeddings
+// are skipped. Rows the embedder rejects are skipped and reported in the
+// error; the next run tries them again.
 func (e *Engine) BackfillEmbeddings(ctx context.Context, batchSize int) (int, error) {
+	done, failed, err := e.embedPending(ctx, batchSize, nil)
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("backfill embeddings: %d memories could not be embedded", failed)
+	}
+	return done, err
+}
+
+// embedPending is BackfillEmbeddings, calling progress with the running
+// totals after every batch. A batch the embedder rejects is retried row by
+// row, and rows that still fail are counted and skipped, so one bad row
+// cannot hold back the rest. It returns an error only when it stops early.
+func (e *Engine) embedPending(ctx context.Context, batchSize int, progress func(done, failed int)) (int, int, error) {
 	embedder, model, timeoutMs := e.embeddingSnapshot()
 	if embedder == nil {
-		return 0, nil
+		return 0, 0, nil
 	}
 	if batchSize <= 0 {
 		batchSize = config.DefaultMemoryEmbeddingBatchSize
 	}
 
-	totalUpdated := 0
+	totalUpdated, totalFailed := 0, 0
+	var afterID int64
 	for {
 		if ctx != nil {
 			select {
 			case <-ctx.Done():
-				return totalUpdated, ctx.Err()
+				return totalUpdated, totalFailed, ctx.Err()
 			default:
 			}
 		}
 
-		rows, err := e.queryTier2MissingEmbeddings(batchSize)
+		rows, err := e.queryTier2MissingEmbeddings(afterID, batchSize)
 		if err != nil {
-			return totalUpdated, err
+			return totalUpdated, totalFailed, err
 		}
 		if len(rows) == 0 {
-			return totalUpdated, nil
+			return totalUpdated, totalFailed, nil
 		}
+		afterID = rows[len(rows)-1].ID
 
 		texts := make([]string, 0, len(rows))
 		ids := make([]int64, 0, len(rows))
@@ -148,27 +184,55 @@ func (e *Engine) BackfillEmbeddings(ctx context.Context, batchSize int) (int, er
 		embedCtx, cancel := withEmbeddingTimeout(ctx, timeoutMs)
 		vectors, err := embedder.EmbedBatch(embedCtx, texts)
 		cancel()
-		if err != nil {
-			return totalUpdated, fmt.Errorf("backfill embeddings: embed batch: %w", err)
+		if err == nil && len(vectors) != len(ids) {
+			err = fmt.Errorf("embed batch count mismatch: got %d want %d", len(vectors), len(ids))
 		}
-		if len(vectors) != len(ids) {
-			return totalUpdated, fmt.Errorf("backfill embeddings: embed batch count mismatch: got %d want %d", len(vectors), len(ids))
+		if err != nil {
+			if ctx != nil && ctx.Err() != nil {
+				return totalUpdated, totalFailed, ctx.Err()
+			}
+			log.Printf("[memory] backfill embeddings: batch failed, retrying one by one: %v", err)
+			vectors = make([][]float32, len(ids))
+			for i, text := range texts {
+				embedCtx, cancel := withEmbeddingTimeout(ctx, timeoutMs)
+				vectors[i], err = embedder.Embed(embedCtx, text)
+				cancel()
+				if err != nil {
+					if ctx != nil && ctx.Err() != nil {
+						return totalUpdated, totalFailed, ctx.Err()
+					}
+					log.Printf("[memory] backfill embeddings: skipping id=%d: %v", ids[i], err)
+					vectors[i] = nil
+					totalFailed++
+				}
+			}
 		}
 
 		for i, id := range ids {
-			if err := e.UpdateMemoryEmbedding(id, model, vectors[i]); err != nil {
-				return totalUpdated, fmt.Errorf("backfill embeddings: update id=%d: %w", id, err)
+			if vectors[i] == nil {
+				continue
+			}
+			stored, err := e.storeEmbedding(id, model, vectors[i], &texts[i])
+			if err != nil {
+				return totalUpdated, totalFailed, fmt.Errorf("backfill embeddings: update id=%d: %w", id, err)
 			}
-			totalUpdated++
+			if stored {
+				totalUpdated++
+			}
+		}
+		if progress != nil {
+			progress(totalUpdated, totalFailed)
 		}
 
 		if len(rows) < batchSize {
-			return totalUpdated, nil
+			return totalUpdated, totalFailed, nil
 		}
 	}
 }
 
-func (e *Engine) queryTier2MissingEmbeddings(limit int) ([]missingEmbeddingRow, error) {
+// queryTier2MissingEmbeddings returns up to limit rows needing an embedding
+// with an id above afterID.
+func (e *Engine) queryTier2MissingEmbeddings(afterID int64, limit int) ([]missingEmbeddingRow, error) {
 	if limit <= 0 {
 		limit = config.DefaultMemoryEmbeddingBatchSize
 	}
@@ -177,12 +241,13 @@ func (e *Engine) queryTier2MissingEmbeddings(limit int) ([]missingEmbeddingRow,
 		SELECT id, content
 		FROM memories
 		WHERE tier = 2
+		  AND id > ?
 		  AND is_archived = 0
 		  AND TRIM(content) != ''
-		  AND (embedding IS NULL OR embedding_dim = 0)
+		  AND (embedding IS NULL OR embedding_dim = 0 OR embedding_stale = 1)
 		ORDER BY id ASC
 		LIMIT ?
-	`, limit)
+	`, afterID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("query missing embeddings: %w", err)
 	}
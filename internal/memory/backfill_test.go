@@ -305,3 +305,174 @@ func memoryIDByContent(t *testing.T, e *Engine, content string) int64 {
 	}
 	return id
 }
+
+// flakyBatchEmbedder goes down after the first failAfter batches: every
+// later call fails.
+type flakyBatchEmbedder struct {
+	deterministicBackfillEmbedder
+	failAfter int
+}
+
+func (m *flakyBatchEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if len(m.snapshotCalls()) >= m.failAfter {
+		return nil, fmt.Errorf("forced failure")
+	}
+	return m.deterministicBackfillEmbedder.Embed(ctx, text)
+}
+
+func (m *flakyBatchEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(m.snapshotCalls()) >= m.failAfter {
+		return nil, fmt.Errorf("forced batch failure")
+	}
+	return m.deterministicBackfillEmbedder.EmbedBatch(ctx, texts)
+}
+
+// rejectingEmbedder rejects one text, alone or in a batch.
+type rejectingEmbedder struct {
+	deterministicBackfillEmbedder
+	reject string
+}
+
+func (m *rejectingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if text == m.reject {
+		return nil, fmt.Errorf("rejected %q", text)
+	}
+	vecs, err := m.deterministicBackfillEmbedder.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (m *rejectingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	for _, text := range texts {
+		if text == m.reject {
+			return nil, fmt.Errorf("batch contains rejected %q", text)
+		}
+	}
+	return m.deterministicBackfillEmbedder.EmbedBatch(ctx, texts)
+}
+
+func TestReembedAfterModelChange(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	for i := 0; i < 5; i++ {
+		id, err := e.AddTier2(FactEntry{Content: fmt.Sprintf("fact %d", i), Project: "myclaw", Topic: "reembed", Category: "event", Importance: 0.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.UpdateMemoryEmbedding(id, "old-model", []float32{1, 0, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flaky := &flakyBatchEmbedder{failAfter: 1}
+	e.SetEmbedder(flaky, "new-model", 1000)
+	stale, err := e.MarkStaleEmbeddings(0)
+	if err != nil || stale != 5 {
+		t.Fatalf("MarkStaleEmbeddings = %d, %v; want 5", stale, err)
+	}
+	if rows, err := e.queryVectorRowsByID(nil, "", nil); err != nil || len(rows) != 0 {
+		t.Fatalf("stale embeddings searchable: %d rows, err %v", len(rows), err)
+	}
+
+	// The embedder goes down after one batch: the rest are skipped and the
+	// next run picks them up.
+	var seen []ReembedProgress
+	done, err := e.Reembed(context.Background(), 2, func(p ReembedProgress) { seen = append(seen, p) })
+	if err != nil || done != 2 {
+		t.Fatalf("first Reembed = %d, %v; want 2", done, err)
+	}
+	if got := e.ReembedStatus(); got.Running || got.Done != 2 || got.Failed != 3 || got.Total != 5 || got.Error != "" {
+		t.Fatalf("status after failure = %+v", got)
+	}
+	if len(seen) != 4 || seen[0] != (ReembedProgress{Running: true, Done: 2, Total: 5}) {
+		t.Fatalf("progress = %+v", seen)
+	}
+	if pending, _ := e.PendingEmbeddings(); pending != 3 {
+		t.Fatalf("pending after first run = %d, want 3", pending)
+	}
+
+	flaky.failAfter = 10
+	if done, err := e.Reembed(context.Background(), 2, nil); err != nil || done != 3 {
+		t.Fatalf("second Reembed = %d, %v; want 3", done, err)
+	}
+	if rows, err := e.queryVectorRowsByID(nil, "", nil); err != nil || len(rows) != 5 {
+		t.Fatalf("re-embedded rows searchable: %d, err %v", len(rows), err)
+	}
+
+	// A dimension change marks them again; matching embeddings are unmarked.
+	if stale, _ := e.MarkStaleEmbeddings(3); stale != 5 {
+		t.Fatalf("stale after dimension change = %d, want 5", stale)
+	}
+	if stale, _ := e.MarkStaleEmbeddings(2); stale != 0 {
+		t.Fatalf("stale after switching back = %d, want 0", stale)
+	}
+}
+
+func TestEmbeddingOfReplacedContentIsDropped(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	id, err := e.AddTier2(FactEntry{Content: "user lives in Berlin", Category: "fact"})
+	if err != nil {
+		t.Fatalf("AddTier2 error: %v", err)
+	}
+	oldContent := "user lives in Berlin"
+	if _, err := e.UpdateMemory(id, FactEntry{Content: "user lives in Munich"}); err != nil {
+		t.Fatalf("UpdateMemory error: %v", err)
+	}
+
+	// The Berlin vector finishes after the update and must not be stored.
+	stored, err := e.storeEmbedding(id, "m", []float32{1, 0}, &oldContent)
+	if err != nil || stored {
+		t.Fatalf("storeEmbedding(old content) = %v, %v; want dropped", stored, err)
+	}
+	var dim int
+	if err := e.db.QueryRow(`SELECT embedding_dim FROM memories WHERE id = ?`, id).Scan(&dim); err != nil || dim != 0 {
+		t.Fatalf("embedding_dim = %d, %v; want 0", dim, err)
+	}
+
+	newContent := "user lives in Munich"
+	if stored, err := e.storeEmbedding(id, "m", []float32{0, 1}, &newContent); err != nil || !stored {
+		t.Fatalf("storeEmbedding(current content) = %v, %v", stored, err)
+	}
+}
+
+func TestReembedSkipsRejectedRows(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	for _, content := range []string{"first fact", "👍", "second fact", "third fact"} {
+		if _, err := e.AddTier2(FactEntry{Content: content, Category: "event"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.SetEmbedder(&rejectingEmbedder{reject: "👍"}, "m", 1000)
+
+	done, err := e.Reembed(context.Background(), 2, nil)
+	if err != nil || done != 3 {
+		t.Fatalf("Reembed = %d, %v; want 3", done, err)
+	}
+	if got := e.ReembedStatus(); got.Done != 3 || got.Failed != 1 || got.Total != 4 || got.Error != "" {
+		t.Fatalf("status = %+v", got)
+	}
+	if pending, _ := e.PendingEmbeddings(); pending != 1 {
+		t.Fatalf("pending = %d, want only the rejected row", pending)
+	}
+
+	// Later runs get past the row instead of failing on it forever.
+	if done, err := e.BackfillEmbeddings(context.Background(), 2); done != 0 || err == nil {
+		t.Fatalf("BackfillEmbeddings = %d, %v; want 0 and the skipped row reported", done, err)
+	}
+}
@@ -17,29 +17,45 @@ func (e *Engine) DailyCompress(llm LLMClient) error {
 		return nil
 	}
 
-	content := joinEventSummaries(events)
-	if strings.TrimSpace(content) == "" {
-		return e.MarkEventsCompressed(yesterday)
-	}
-
-	result, err := llm.Compress(dailyCompressPrompt, content)
-	if err != nil {
-		log.Printf("[memory] daily compress llm error: %v", err)
-		return nil
+	// Each scope is compressed on its own so facts stay with their owner.
+	var scopes []string
+	byScope := map[string][]EventEntry{}
+	for _, ev := range events {
+		if _, ok := byScope[ev.Scope]; !ok {
+			scopes = append(scopes, ev.Scope)
+		}
+		byScope[ev.Scope] = append(byScope[ev.Scope], ev)
 	}
 
-	for _, fact := range result.Facts {
-		if err := e.WriteTier2(fact); err != nil {
-			log.Printf("[memory] daily compress write tier2 error: %v", err)
+	var done []int64
+	for _, scope := range scopes {
+		scoped := byScope[scope]
+		if content := joinEventSummaries(scoped); strings.TrimSpace(content) != "" {
+			result, err := llm.Compress(dailyCompressPrompt, content)
+			if err != nil {
+				log.Printf("[memory] daily compress llm error for %s: %v", scope, err)
+				continue
+			}
+			for _, fact := range result.Facts {
+				fact.Scope = scope
+				if err := e.WriteTier2(fact); err != nil {
+					log.Printf("[memory] daily compress write tier2 error: %v", err)
+				}
+			}
+		}
+		for _, ev := range scoped {
+			done = append(done, ev.ID)
 		}
 	}
-
-	return e.MarkEventsCompressed(yesterday)
+	if len(done) == len(events) {
+		return e.MarkEventsCompressed(yesterday)
+	}
+	return e.markEventIDsCompressed(done)
 }
 
 func (e *Engine) WeeklyDeepCompress(llm LLMClient) error {
 	rows, err := e.db.Query(`
-		SELECT DISTINCT project, topic FROM memories
+		SELECT DISTINCT scope, project, topic FROM memories
 		WHERE tier = 2 AND is_archived = 0
 	`)
 	if err != nil {
@@ -47,18 +63,18 @@ func (e *Engine) WeeklyDeepCompress(llm LLMClient) error {
 	}
 	defer rows.Close()
 
-	type partition struct{ project, topic string }
+	type partition struct{ scope, project, topic string }
 	parts := make([]partition, 0)
 	for rows.Next() {
 		var p partition
-		if err := rows.Scan(&p.project, &p.topic); err != nil {
+		if err := rows.Scan(&p.scope, &p.project, &p.topic); err != nil {
 			return fmt.Errorf("scan partition: %w", err)
 		}
 		parts = append(parts, p)
 	}
 
 	for _, p := range parts {
-		entries, err := e.QueryTier2(p.project, p.topic, 500)
+		entries, err := e.ListMemories(MemoryFilter{Tier: 2, Project: p.project, Topic: p.topic, Scopes: []string{p.scope}, Limit: 500})
 		if err != nil {
 			log.Printf("[memory] weekly compress query partition error: %v", err)
 			continue
@@ -69,7 +85,7 @@ func (e *Engine) WeeklyDeepCompress(llm LLMClient) error {
 
 		merged, err := llm.Compress(weeklyCompressPrompt, formatEntries(entries))
 		if err != nil {
-			log.Printf("[memory] weekly compress llm error for %s/%s: %v", p.project, p.topic, err)
+			log.Printf("[memory] weekly compress llm error for %s %s/%s: %v", p.scope, p.project, p.topic, err)
 			continue
 		}
 
@@ -79,6 +95,7 @@ func (e *Engine) WeeklyDeepCompress(llm LLMClient) error {
 			}
 		}
 		for _, fact := range merged.Facts {
+			fact.Scope = p.scope
 			if err := e.WriteTier2(fact); err != nil {
 				log.Printf("[memory] weekly compress write merged fact error: %v", err)
 			}
@@ -94,20 +111,52 @@ func (e *Engine) WeeklyDeepCompress(llm LLMClient) error {
 	return nil
 }
 
+// refreshTier1 rewrites each scope's core profile from that scope's
+// high-importance facts, so one owner's facts never reach another's profile.
 func (e *Engine) refreshTier1(llm LLMClient) error {
-	current, err := e.LoadTier1()
+	rows, err := e.db.Query(`
+		SELECT DISTINCT scope FROM memories
+		WHERE tier = 2 AND importance >= 0.7 AND is_archived = 0
+	`)
+	if err != nil {
+		return fmt.Errorf("query profile scopes: %w", err)
+	}
+	var scopes []string
+	for rows.Next() {
+		var scope string
+		if err := rows.Scan(&scope); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan profile scope: %w", err)
+		}
+		scopes = append(scopes, scope)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate profile scopes: %w", err)
+	}
+
+	for _, scope := range scopes {
+		if err := e.refreshProfile(llm, scope); err != nil {
+			return fmt.Errorf("%s: %w", scope, err)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) refreshProfile(llm LLMClient, scope string) error {
+	current, err := e.LoadProfile([]string{scope})
 	if err != nil {
 		return fmt.Errorf("load current tier1: %w", err)
 	}
 
 	rows, err := e.db.Query(`
 		SELECT id, tier, project, topic, category, content, importance, source,
-		       created_at, updated_at, last_accessed, access_count, is_archived
+		       created_at, updated_at, last_accessed, access_count, is_archived, scope
 		FROM memories
-		WHERE tier = 2 AND importance >= 0.7 AND is_archived = 0
+		WHERE tier = 2 AND importance >= 0.7 AND is_archived = 0 AND scope = ?
 		ORDER BY importance DESC
 		LIMIT 200
-	`)
+	`, scope)
 	if err != nil {
 		return fmt.Errorf("query high-importance facts: %w", err)
 	}
@@ -129,7 +178,7 @@ func (e *Engine) refreshTier1(llm LLMClient) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if _, err := e.db.Exec(`UPDATE memories SET is_archived = 1, updated_at = datetime('now') WHERE tier = 1 AND is_archived = 0`); err != nil {
+	if _, err := e.db.Exec(`UPDATE memories SET is_archived = 1, updated_at = datetime('now') WHERE tier = 1 AND is_archived = 0 AND scope = ?`, scope); err != nil {
 		return fmt.Errorf("archive old tier1: %w", err)
 	}
 	for _, p := range result.Entries {
@@ -138,9 +187,9 @@ func (e *Engine) refreshTier1(llm LLMClient) error {
 			category = "identity"
 		}
 		if _, err := e.db.Exec(`
-			INSERT INTO memories (tier, project, topic, category, content, importance, source)
-			VALUES (1, '_global', '_profile', ?, ?, 1.0, 'compression')
-		`, category, strings.TrimSpace(p.Content)); err != nil {
+			INSERT INTO memories (tier, project, topic, category, content, fts_content, importance, source, scope)
+			VALUES (1, '_global', '_profile', ?, ?, ?, 1.0, 'compression', ?)
+		`, category, strings.TrimSpace(p.Content), ftsText(strings.TrimSpace(p.Content)), scope); err != nil {
 			return fmt.Errorf("insert new tier1: %w", err)
 		}
 	}
@@ -150,7 +199,7 @@ func (e *Engine) refreshTier1(llm LLMClient) error {
 func (e *Engine) cleanupDecayed() error {
 	rows, err := e.db.Query(`
 		SELECT id, tier, project, topic, category, content, importance, source,
-		       created_at, updated_at, last_accessed, access_count, is_archived
+		       created_at, updated_at, last_accessed, access_count, is_archived, scope
 		FROM memories
 		WHERE tier = 2 AND is_archived = 0 AND category IN ('temp', 'debug')
 	`)
@@ -49,7 +49,13 @@ type embeddingData struct {
 	Embedding []float32 `json:"embedding"`
 }
 
+// NewEmbedder returns the embedder memory.embedding.provider selects:
+// "api" (OpenAI-compatible, the default), "ollama", or "local", the
+// built-in offline embedder.
 func NewEmbedder(cfg *config.Config) Embedder {
+	if cfg != nil && strings.EqualFold(strings.TrimSpace(cfg.Memory.Embedding.Provider), embeddingProviderLocal) {
+		return newLocalEmbedder(cfg.Memory.Embedding.Dimension)
+	}
 	client := &embedderClient{
 		provider:   embeddingProviderAPI,
 		batchSize:  config.DefaultMemoryEmbeddingBatchSize,
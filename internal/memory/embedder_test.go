@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -247,3 +248,103 @@ func assertFloat32Slice(t *testing.T, got, want []float32) {
 		}
 	}
 }
+
+func TestLocalEmbedder(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Memory.Embedding.Provider = "Local"
+	cfg.Memory.Embedding.Dimension = 256
+	embedder := NewEmbedder(cfg)
+	if _, ok := embedder.(*localEmbedder); !ok {
+		t.Fatalf("NewEmbedder returned %T, want *localEmbedder", embedder)
+	}
+	if got := EmbeddingModel(cfg); got != localEmbeddingModel {
+		t.Fatalf("EmbeddingModel = %q, want %q", got, localEmbeddingModel)
+	}
+
+	texts := []string{
+		"deploy the gateway with docker compose",
+		"deployment of the gateway uses docker",
+		"favourite food is spicy noodles",
+		"生产环境的部署流程需要审批",
+		"部署流程",
+		"喜欢吃辣的面条",
+	}
+	vecs, err := embedder.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("EmbedBatch error: %v", err)
+	}
+	for i, vec := range vecs {
+		if len(vec) != 256 {
+			t.Fatalf("len(vecs[%d]) = %d, want 256", i, len(vec))
+		}
+		var norm float64
+		for _, v := range vec {
+			norm += float64(v) * float64(v)
+		}
+		if math.Abs(norm-1) > 1e-5 {
+			t.Fatalf("vecs[%d] norm = %f, want 1", i, norm)
+		}
+	}
+	again, err := embedder.Embed(context.Background(), texts[0])
+	if err != nil {
+		t.Fatalf("Embed error: %v", err)
+	}
+	assertFloat32Slice(t, again, vecs[0])
+
+	similarity := func(a, b int) float64 {
+		s, err := CosineSimilarity(vecs[a], vecs[b])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if similarity(0, 1) <= similarity(0, 2) {
+		t.Fatalf("deploy/deployment %.3f <= deploy/food %.3f", similarity(0, 1), similarity(0, 2))
+	}
+	if similarity(4, 3) <= similarity(4, 5) {
+		t.Fatalf("部署流程 matches deployment %.3f <= food %.3f", similarity(4, 3), similarity(4, 5))
+	}
+
+	// Text without words still gets an embedding; only blank text fails.
+	for _, text := range []string{"👍", "+1", "---", " ... "} {
+		if vec, err := embedder.Embed(context.Background(), text); err != nil || len(vec) != 256 {
+			t.Fatalf("Embed(%q) = %d dims, %v", text, len(vec), err)
+		}
+	}
+	if _, err := embedder.Embed(context.Background(), " \t\n"); err == nil {
+		t.Fatal("expected error for blank text")
+	}
+}
+
+func TestEnhancedRetrieveWithLocalEmbedder(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	e.SetRetrievalConfig(config.RetrievalConfig{Mode: config.MemoryRetrievalModeEnhanced})
+	e.SetEmbedder(newLocalEmbedder(0), localEmbeddingModel, 5000)
+
+	facts := []string{
+		"生产环境的部署流程需要两人审批",
+		"用户喜欢吃辣的面条",
+		"gateway deployment runs through docker compose",
+	}
+	for _, content := range facts {
+		if err := e.WriteTier2(FactEntry{Content: content, Project: "myclaw", Topic: "ops", Category: "decision", Importance: 0.5}); err != nil {
+			t.Fatalf("WriteTier2 error: %v", err)
+		}
+	}
+	if _, err := e.BackfillEmbeddings(context.Background(), 10); err != nil {
+		t.Fatalf("BackfillEmbeddings error: %v", err)
+	}
+
+	results, err := e.Retrieve("部署流程是什么")
+	if err != nil {
+		t.Fatalf("Retrieve error: %v", err)
+	}
+	if len(results) == 0 || results[0].Content != facts[0] {
+		t.Fatalf("expected deployment fact first, got %+v", results)
+	}
+}
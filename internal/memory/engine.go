@@ -2,6 +2,7 @@ package memory
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,9 +27,21 @@ type Engine struct {
 	embedder           Embedder
 	embeddingModel     string
 	embeddingTimeoutMs int
+	vectors            vectorIndexState
+	reembed            reembedState
 }
 
-const latestSchemaVersion = 1
+const latestSchemaVersion = 4
+
+// ErrMemoryNotFound is returned for IDs that do not exist or are archived.
+var ErrMemoryNotFound = errors.New("memory not found")
+
+const memoryColumns = `id, tier, project, topic, category, content, importance, source,
+		       created_at, updated_at, last_accessed, access_count, is_archived, scope`
+
+// memoryColumnsQualified is memoryColumns for queries that alias memories as m.
+const memoryColumnsQualified = `m.id, m.tier, m.project, m.topic, m.category, m.content, m.importance, m.source,
+		       m.created_at, m.updated_at, m.last_accessed, m.access_count, m.is_archived, m.scope`
 
 func NewEngine(dbPath string) (*Engine, error) {
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
@@ -75,7 +88,7 @@ func (e *Engine) configure() error {
 }
 
 func (e *Engine) Close() error {
-	if e.db == nil {
+	if e == nil || e.db == nil {
 		return nil
 	}
 	return e.db.Close()
@@ -175,6 +188,18 @@ func (e *Engine) migrateSchema() error {
 			if err := migrateSchemaV1(tx); err != nil {
 				return fmt.Errorf("migrate schema v1: %w", err)
 			}
+		case 2:
+			if err := migrateSchemaV2(tx); err != nil {
+				return fmt.Errorf("migrate schema v2: %w", err)
+			}
+		case 3:
+			if err := migrateSchemaV3(tx); err != nil {
+				return fmt.Errorf("migrate schema v3: %w", err)
+			}
+		case 4:
+			if err := migrateSchemaV4(tx); err != nil {
+				return fmt.Errorf("migrate schema v4: %w", err)
+			}
 		default:
 			return fmt.Errorf("migrate schema: no migration registered for version %d", nextVersion)
 		}
@@ -228,6 +253,77 @@ func migrateSchemaV1(tx *sql.Tx) error {
 	return nil
 }
 
+// scopedTables record whose memory a row is; rows from before scoping are
+// shared.
+var scopedTables = []string{"memories", "daily_events", "extraction_buffer"}
+
+func migrateSchemaV2(tx *sql.Tx) error {
+	for _, table := range scopedTables {
+		if err := addColumnIfMissing(tx, table, "scope", "TEXT NOT NULL DEFAULT '"+SharedScope+"'"); err != nil {
+			return fmt.Errorf("add column %s.scope: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_memories_scope ON memories(scope, tier, is_archived)`); err != nil {
+		return fmt.Errorf("create scope index: %w", err)
+	}
+	return nil
+}
+
+// migrateSchemaV3 adds embedding_stale, set on embeddings made by another
+// embedding model or dimension than the configured one until they are
+// re-embedded.
+func migrateSchemaV3(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "memories", "embedding_stale", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add column embedding_stale: %w", err)
+	}
+	return nil
+}
+
+// migrateSchemaV4 moves memories_fts from content to fts_content, the
+// content with CJK text split into bigrams (see ftsText), and rebuilds it.
+func migrateSchemaV4(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "memories", "fts_content", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return fmt.Errorf("add column fts_content: %w", err)
+	}
+	for _, stmt := range []string{
+		`DROP TRIGGER IF EXISTS memories_ai`,
+		`DROP TRIGGER IF EXISTS memories_ad`,
+		`DROP TRIGGER IF EXISTS memories_au`,
+		`DROP TABLE IF EXISTS memories_fts`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("drop unicode61 fts: %w", err)
+		}
+	}
+	if err := segmentFTSContent(tx); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE VIRTUAL TABLE memories_fts USING fts5(
+			fts_content,
+			content='memories',
+			content_rowid='id',
+			tokenize='unicode61'
+		)`,
+		`INSERT INTO memories_fts(memories_fts) VALUES('rebuild')`,
+		`CREATE TRIGGER memories_ai AFTER INSERT ON memories BEGIN
+			INSERT INTO memories_fts(rowid, fts_content) VALUES (new.id, new.fts_content);
+		END`,
+		`CREATE TRIGGER memories_ad AFTER DELETE ON memories BEGIN
+			INSERT INTO memories_fts(memories_fts, rowid, fts_content) VALUES('delete', old.id, old.fts_content);
+		END`,
+		`CREATE TRIGGER memories_au AFTER UPDATE OF fts_content ON memories BEGIN
+			INSERT INTO memories_fts(memories_fts, rowid, fts_content) VALUES('delete', old.id, old.fts_content);
+			INSERT INTO memories_fts(rowid, fts_content) VALUES (new.id, new.fts_content);
+		END`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("create segmented fts: %w", err)
+		}
+	}
+	return nil
+}
+
 func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
 	exists, err := hasColumn(tx, table, column)
 	if err != nil {
@@ -287,19 +383,56 @@ func validateSchemaState(tx *sql.Tx, version int) error {
 			missing = append(missing, column)
 		}
 	}
+	if version >= 2 {
+		for _, table := range scopedTables {
+			exists, err := hasColumn(tx, table, "scope")
+			if err != nil {
+				return fmt.Errorf("validate column %s.scope: %w", table, err)
+			}
+			if !exists {
+				missing = append(missing, table+".scope")
+			}
+		}
+	}
+	if version >= 3 {
+		exists, err := hasColumn(tx, "memories", "embedding_stale")
+		if err != nil {
+			return fmt.Errorf("validate column embedding_stale: %w", err)
+		}
+		if !exists {
+			missing = append(missing, "embedding_stale")
+		}
+	}
+	if version >= 4 {
+		exists, err := hasColumn(tx, "memories", "fts_content")
+		if err != nil {
+			return fmt.Errorf("validate column fts_content: %w", err)
+		}
+		if !exists {
+			missing = append(missing, "fts_content")
+		}
+	}
 	if len(missing) > 0 {
 		return fmt.Errorf("schema version %d missing required columns: %s", version, strings.Join(missing, ", "))
 	}
 	return nil
 }
 
+// LoadTier1 renders the whole core profile, whatever its scope.
 func (e *Engine) LoadTier1() (string, error) {
+	return e.LoadProfile(nil)
+}
+
+// LoadProfile renders the core-profile entries in the visible scopes (all
+// of them when visible is nil) as a bullet list.
+func (e *Engine) LoadProfile(visible []string) (string, error) {
+	cond, args := scopeCondition("scope", visible)
 	rows, err := e.db.Query(`
 		SELECT content FROM memories
-		WHERE tier = 1 AND is_archived = 0
+		WHERE tier = 1 AND is_archived = 0`+cond+`
 		ORDER BY importance DESC, created_at ASC
 		LIMIT 100
-	`)
+	`, args...)
 	if err != nil {
 		return "", fmt.Errorf("load tier1: %w", err)
 	}
@@ -322,6 +455,12 @@ func (e *Engine) LoadTier1() (string, error) {
 }
 
 func (e *Engine) WriteTier1(entry ProfileEntry) error {
+	_, err := e.AddTier1(entry)
+	return err
+}
+
+// AddTier1 writes a core-profile entry and returns its ID.
+func (e *Engine) AddTier1(entry ProfileEntry) (int64, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -329,17 +468,41 @@ func (e *Engine) WriteTier1(entry ProfileEntry) error {
 	if category == "" {
 		category = "identity"
 	}
-	_, err := e.db.Exec(`
-		INSERT INTO memories (tier, project, topic, category, content, importance, source)
-		VALUES (1, '_global', '_profile', ?, ?, 1.0, 'manual')
-	`, category, strings.TrimSpace(entry.Content))
+	result, err := e.db.Exec(`
+		INSERT INTO memories (tier, project, topic, category, content, fts_content, importance, source, scope)
+		VALUES (1, '_global', '_profile', ?, ?, ?, 1.0, 'manual', ?)
+	`, category, strings.TrimSpace(entry.Content), ftsText(strings.TrimSpace(entry.Content)), normalizeScope(entry.Scope))
 	if err != nil {
-		return fmt.Errorf("write tier1: %w", err)
+		return 0, fmt.Errorf("write tier1: %w", err)
 	}
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("write tier1 id: %w", err)
+	}
+	return id, nil
+}
+
+// ListTier1 returns the active core-profile entries.
+func (e *Engine) ListTier1() ([]Memory, error) {
+	rows, err := e.db.Query(`SELECT ` + memoryColumns + ` FROM memories
+		WHERE tier = 1 AND is_archived = 0
+		ORDER BY importance DESC, created_at ASC
+		LIMIT 100`)
+	if err != nil {
+		return nil, fmt.Errorf("list tier1: %w", err)
+	}
+	defer rows.Close()
+	return scanMemories(rows)
 }
 
 func (e *Engine) WriteTier2(fact FactEntry) error {
+	_, err := e.AddTier2(fact)
+	return err
+}
+
+// AddTier2 writes a fact and returns its ID (0 if the driver cannot report
+// it). The embedding, if configured, is computed in the background.
+func (e *Engine) AddTier2(fact FactEntry) (int64, error) {
 	project := strings.TrimSpace(fact.Project)
 	if project == "" {
 		project = "_global"
@@ -361,12 +524,155 @@ func (e *Engine) WriteTier2(fact FactEntry) error {
 	}
 	content := strings.TrimSpace(fact.Content)
 
-	memoryID, err := e.insertTier2Row(project, topic, category, content, importance)
+	memoryID, err := e.insertTier2Row(project, topic, category, content, importance, normalizeScope(fact.Scope))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	e.scheduleTier2Embedding(memoryID, content)
-	return nil
+	return memoryID, nil
+}
+
+// GetMemory returns an active memory by ID, or ErrMemoryNotFound.
+func (e *Engine) GetMemory(id int64) (Memory, error) {
+	return e.getMemory(id, false)
+}
+
+// LookupMemory returns a memory by ID whether or not it is archived, or
+// ErrMemoryNotFound.
+func (e *Engine) LookupMemory(id int64) (Memory, error) {
+	return e.getMemory(id, true)
+}
+
+func (e *Engine) getMemory(id int64, includeArchived bool) (Memory, error) {
+	q := `SELECT ` + memoryColumns + ` FROM memories WHERE id = ?`
+	if !includeArchived {
+		q += ` AND is_archived = 0`
+	}
+	rows, err := e.db.Query(q, id)
+	if err != nil {
+		return Memory{}, fmt.Errorf("get memory: %w", err)
+	}
+	defer rows.Close()
+	memories, err := scanMemories(rows)
+	if err != nil {
+		return Memory{}, err
+	}
+	if len(memories) == 0 {
+		return Memory{}, fmt.Errorf("memory %d: %w", id, ErrMemoryNotFound)
+	}
+	return memories[0], nil
+}
+
+// ListMemories returns the memories matching f. With a query they are
+// ordered by keyword relevance, otherwise by tier, importance and age.
+func (e *Engine) ListMemories(f MemoryFilter) ([]Memory, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	q := `SELECT ` + memoryColumnsQualified + ` FROM memories m`
+	var where []string
+	var args []any
+	if query := strings.TrimSpace(f.Query); query != "" {
+		match := buildFTSMatchQuery(extractKeywords(query))
+		if match == "" {
+			return []Memory{}, nil
+		}
+		q += ` JOIN memories_fts f ON m.id = f.rowid`
+		where = append(where, `memories_fts MATCH ?`)
+		args = append(args, match)
+	}
+	if f.Tier != 0 {
+		where = append(where, `m.tier = ?`)
+		args = append(args, f.Tier)
+	}
+	for _, c := range []struct{ column, value string }{
+		{"m.project", f.Project}, {"m.topic", f.Topic}, {"m.category", f.Category},
+	} {
+		if v := strings.TrimSpace(c.value); v != "" {
+			where = append(where, c.column+` = ?`)
+			args = append(args, v)
+		}
+	}
+	if f.Scopes != nil {
+		cond, scopeArgs := scopeCondition("m.scope", f.Scopes)
+		where = append(where, strings.TrimPrefix(cond, " AND "))
+		args = append(args, scopeArgs...)
+	}
+	switch f.Archived {
+	case ActiveOnly:
+		where = append(where, `m.is_archived = 0`)
+	case ArchivedOnly:
+		where = append(where, `m.is_archived = 1`)
+	}
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	if strings.TrimSpace(f.Query) != "" {
+		q += ` ORDER BY bm25(memories_fts), m.importance DESC`
+	} else {
+		q += ` ORDER BY m.tier ASC, m.importance DESC, m.created_at DESC, m.id DESC`
+	}
+	q += ` LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := e.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list memories: %w", err)
+	}
+	defer rows.Close()
+	return scanMemories(rows)
+}
+
+// UpdateMemory changes the non-empty fields of an active memory and returns
+// the result. Core-profile entries keep their fixed project, topic and
+// importance. A tier-2 content change drops the stale embedding and
+// computes a new one in the background.
+func (e *Engine) UpdateMemory(id int64, fact FactEntry) (Memory, error) {
+	current, err := e.GetMemory(id)
+	if err != nil {
+		return Memory{}, err
+	}
+	updated := current
+	if c := strings.TrimSpace(fact.Content); c != "" {
+		updated.Content = c
+	}
+	if c := strings.TrimSpace(fact.Category); c != "" {
+		updated.Category = c
+	}
+	if sc := strings.TrimSpace(fact.Scope); sc != "" {
+		updated.Scope = sc
+	}
+	if current.Tier != 1 {
+		if p := strings.TrimSpace(fact.Project); p != "" {
+			updated.Project = p
+		}
+		if t := strings.TrimSpace(fact.Topic); t != "" {
+			updated.Topic = t
+		}
+		if fact.Importance > 0 {
+			updated.Importance = min(fact.Importance, 1)
+		}
+	}
+	contentChanged := updated.Content != current.Content
+
+	e.mu.Lock()
+	q := `UPDATE memories SET content = ?, category = ?, project = ?, topic = ?, importance = ?, scope = ?, updated_at = datetime('now')`
+	args := []any{updated.Content, updated.Category, updated.Project, updated.Topic, updated.Importance, updated.Scope}
+	if contentChanged {
+		q += `, fts_content = ?, embedding = NULL, embedding_model = '', embedding_dim = 0, embedding_updated_at = '', embedding_stale = 0`
+		args = append(args, ftsText(updated.Content))
+	}
+	_, err = e.db.Exec(q+` WHERE id = ?`, append(args, id)...)
+	e.mu.Unlock()
+	if err != nil {
+		return Memory{}, fmt.Errorf("update memory: %w", err)
+	}
+	if contentChanged && updated.Tier == 2 {
+		e.unindexVector(id)
+		e.scheduleTier2Embedding(id, updated.Content)
+	}
+	return e.GetMemory(id)
 }
 
 func (e *Engine) QueryTier2(project, topic string, limit int) ([]Memory, error) {
@@ -375,7 +681,7 @@ func (e *Engine) QueryTier2(project, topic string, limit int) ([]Memory, error)
 	}
 	q := `
 		SELECT id, tier, project, topic, category, content, importance, source,
-		       created_at, updated_at, last_accessed, access_count, is_archived
+		       created_at, updated_at, last_accessed, access_count, is_archived, scope
 		FROM memories
 		WHERE tier = 2 AND is_archived = 0
 	`
@@ -399,6 +705,8 @@ func (e *Engine) QueryTier2(project, topic string, limit int) ([]Memory, error)
 	return scanMemories(rows)
 }
 
+// SearchFTS runs an FTS5 MATCH query over active facts. CJK text in it is
+// split into bigrams, as memory content is for the index.
 func (e *Engine) SearchFTS(keywords string, limit int) ([]Memory, error) {
 	if limit <= 0 {
 		limit = 10
@@ -410,7 +718,7 @@ func (e *Engine) SearchFTS(keywords string, limit int) ([]Memory, error) {
 
 	rows, err := e.db.Query(`
 		SELECT m.id, m.tier, m.project, m.topic, m.category, m.content, m.importance, m.source,
-		       m.created_at, m.updated_at, m.last_accessed, m.access_count, m.is_archived
+		       m.created_at, m.updated_at, m.last_accessed, m.access_count, m.is_archived, m.scope
 		FROM memories m
 		JOIN memories_fts f ON m.id = f.rowid
 		WHERE memories_fts MATCH ?
@@ -418,7 +726,7 @@ func (e *Engine) SearchFTS(keywords string, limit int) ([]Memory, error) {
 		  AND m.is_archived = 0
 		ORDER BY bm25(memories_fts), m.importance DESC
 		LIMIT ?
-	`, query, limit)
+	`, ftsText(query), limit)
 	if err != nil {
 		return nil, fmt.Errorf("search fts: %w", err)
 	}
@@ -433,6 +741,23 @@ func (e *Engine) ArchiveMemory(id int64) error {
 	if err != nil {
 		return fmt.Errorf("archive memory: %w", err)
 	}
+	e.unindexVector(id)
+	return nil
+}
+
+// RestoreMemory brings an archived memory back, or returns
+// ErrMemoryNotFound.
+func (e *Engine) RestoreMemory(id int64) error {
+	e.mu.Lock()
+	result, err := e.db.Exec(`UPDATE memories SET is_archived = 0, updated_at = datetime('now') WHERE id = ?`, id)
+	e.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("restore memory: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("memory %d: %w", id, ErrMemoryNotFound)
+	}
+	e.reindexMemory(id)
 	return nil
 }
 
@@ -459,9 +784,9 @@ func (e *Engine) WriteTier3(event EventEntry) error {
 		channel = "unknown"
 	}
 	_, err := e.db.Exec(`
-		INSERT INTO daily_events (event_date, channel, sender_id, summary, raw_tokens, is_compressed)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, strings.TrimSpace(event.Date), channel, strings.TrimSpace(event.SenderID), strings.TrimSpace(event.Summary), event.Tokens, boolToInt(event.IsCompressed))
+		INSERT INTO daily_events (event_date, channel, sender_id, summary, raw_tokens, is_compressed, scope)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, strings.TrimSpace(event.Date), channel, strings.TrimSpace(event.SenderID), strings.TrimSpace(event.Summary), event.Tokens, boolToInt(event.IsCompressed), normalizeScope(event.Scope))
 	if err != nil {
 		return fmt.Errorf("write tier3: %w", err)
 	}
@@ -470,7 +795,7 @@ func (e *Engine) WriteTier3(event EventEntry) error {
 
 func (e *Engine) QueryEvents(date string, compressed bool) ([]EventEntry, error) {
 	rows, err := e.db.Query(`
-		SELECT id, event_date, channel, sender_id, summary, raw_tokens, is_compressed, created_at
+		SELECT id, event_date, channel, sender_id, summary, raw_tokens, is_compressed, created_at, scope
 		FROM daily_events
 		WHERE event_date = ? AND is_compressed = ?
 		ORDER BY id ASC
@@ -484,7 +809,7 @@ func (e *Engine) QueryEvents(date string, compressed bool) ([]EventEntry, error)
 	for rows.Next() {
 		var e2 EventEntry
 		var compressedInt int
-		if err := rows.Scan(&e2.ID, &e2.Date, &e2.Channel, &e2.SenderID, &e2.Summary, &e2.Tokens, &compressedInt, &e2.CreatedAt); err != nil {
+		if err := rows.Scan(&e2.ID, &e2.Date, &e2.Channel, &e2.SenderID, &e2.Summary, &e2.Tokens, &compressedInt, &e2.CreatedAt, &e2.Scope); err != nil {
 			return nil, fmt.Errorf("scan event: %w", err)
 		}
 		e2.IsCompressed = compressedInt == 1
@@ -506,13 +831,29 @@ func (e *Engine) MarkEventsCompressed(date string) error {
 	return nil
 }
 
+func (e *Engine) markEventIDsCompressed(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	if _, err := e.db.Exec(`UPDATE daily_events SET is_compressed = 1 WHERE id IN (`+placeholders(len(ids))+`)`, args...); err != nil {
+		return fmt.Errorf("mark compressed: %w", err)
+	}
+	return nil
+}
+
 func (e *Engine) WriteBuffer(msg BufferMessage) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	_, err := e.db.Exec(`
-		INSERT INTO extraction_buffer (channel, sender_id, role, content, token_count)
-		VALUES (?, ?, ?, ?, ?)
-	`, strings.TrimSpace(msg.Channel), strings.TrimSpace(msg.SenderID), strings.TrimSpace(msg.Role), strings.TrimSpace(msg.Content), msg.TokenCount)
+		INSERT INTO extraction_buffer (channel, sender_id, role, content, token_count, scope)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, strings.TrimSpace(msg.Channel), strings.TrimSpace(msg.SenderID), strings.TrimSpace(msg.Role), strings.TrimSpace(msg.Content), msg.TokenCount, normalizeScope(msg.Scope))
 	if err != nil {
 		return fmt.Errorf("write buffer: %w", err)
 	}
@@ -533,7 +874,7 @@ func (e *Engine) DrainBuffer(limit int) ([]BufferMessage, error) {
 	defer tx.Rollback()
 
 	rows, err := tx.Query(`
-		SELECT id, channel, sender_id, role, content, token_count, created_at
+		SELECT id, channel, sender_id, role, content, token_count, created_at, scope
 		FROM extraction_buffer
 		ORDER BY id ASC
 		LIMIT ?
@@ -547,7 +888,7 @@ func (e *Engine) DrainBuffer(limit int) ([]BufferMessage, error) {
 	ids := make([]int64, 0)
 	for rows.Next() {
 		var msg BufferMessage
-		if err := rows.Scan(&msg.ID, &msg.Channel, &msg.SenderID, &msg.Role, &msg.Content, &msg.TokenCount, &msg.CreatedAt); err != nil {
+		if err := rows.Scan(&msg.ID, &msg.Channel, &msg.SenderID, &msg.Role, &msg.Content, &msg.TokenCount, &msg.CreatedAt, &msg.Scope); err != nil {
 			return nil, fmt.Errorf("scan drain buffer: %w", err)
 		}
 		msgs = append(msgs, msg)
@@ -631,6 +972,19 @@ func (e *Engine) rerankerSnapshot() Reranker {
 	return e.reranker
 }
 
+// ModelUsage reports the tokens retrieval has spent on the memory model for
+// query expansion and the LLM rerank fallback.
+func (e *Engine) ModelUsage() ModelUsage {
+	var usage ModelUsage
+	if r, ok := e.queryExpanderSnapshot().(UsageReporter); ok {
+		usage = usage.Add(r.Usage())
+	}
+	if r, ok := e.rerankerSnapshot().(UsageReporter); ok {
+		usage = usage.Add(r.Usage())
+	}
+	return usage
+}
+
 func (e *Engine) knownProjectsSnapshot() []string {
 	e.knownMu.RLock()
 	defer e.knownMu.RUnlock()
@@ -724,6 +1078,7 @@ func scanMemories(rows *sql.Rows) ([]Memory, error) {
 			&m.LastAccessed,
 			&m.AccessCount,
 			&archived,
+			&m.Scope,
 		); err != nil {
 			return nil, fmt.Errorf("scan memory: %w", err)
 		}
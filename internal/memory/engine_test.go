@@ -2,6 +2,7 @@ package memory
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -9,7 +10,7 @@ import (
 	"testing"
 )
 
-const testLatestSchemaVersion = 1
+const testLatestSchemaVersion = 4
 
 func TestNewEngine(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "memory.db")
@@ -133,6 +134,8 @@ func TestMigrateSchemaFromLegacyDB(t *testing.T) {
 			t.Fatalf("expected migrated column %q to exist once, got %d", name, count)
 		}
 	}
+	sharedDefault := "'shared'"
+	assertMemoriesColumn(t, columns, "scope", "TEXT", true, &sharedDefault)
 }
 
 func TestMigrateSchemaRejectsInvalidState(t *testing.T) {
@@ -427,3 +430,156 @@ func TestEngineStateHelpers(t *testing.T) {
 		t.Fatalf("expected tier2 active count=1, got %d", stats.Tier2ActiveCount)
 	}
 }
+
+func TestEngineUpdateMemory(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	id, err := e.AddTier2(FactEntry{Content: "user lives in Berlin", Project: "home", Category: "identity", Importance: 0.6})
+	if err != nil || id == 0 {
+		t.Fatalf("AddTier2 id=%d err=%v", id, err)
+	}
+	if _, err := e.db.Exec(`UPDATE memories SET embedding = x'00', embedding_model = 'm', embedding_dim = 1 WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := e.UpdateMemory(id, FactEntry{Content: "user lives in Munich", Importance: 2})
+	if err != nil {
+		t.Fatalf("UpdateMemory error: %v", err)
+	}
+	if m.Content != "user lives in Munich" || m.Project != "home" || m.Category != "identity" || m.Importance != 1 {
+		t.Fatalf("updated = %+v", m)
+	}
+	var model string
+	if err := e.db.QueryRow(`SELECT embedding_model FROM memories WHERE id = ?`, id).Scan(&model); err != nil || model != "" {
+		t.Fatalf("stale embedding kept: model=%q err=%v", model, err)
+	}
+	if got, _ := e.SearchFTS("munich", 10); len(got) != 1 {
+		t.Fatalf("FTS after update = %+v", got)
+	}
+	if got, _ := e.SearchFTS("berlin", 10); len(got) != 0 {
+		t.Fatalf("FTS still finds old content: %+v", got)
+	}
+
+	// Profile entries keep their fixed placement.
+	pid, err := e.AddTier1(ProfileEntry{Content: "user is called Sam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := e.UpdateMemory(pid, FactEntry{Content: "user is called Samantha", Project: "x", Importance: 0.1})
+	if err != nil || p.Content != "user is called Samantha" || p.Project != "_global" || p.Importance != 1 {
+		t.Fatalf("profile update = %+v, %v", p, err)
+	}
+
+	if err := e.ArchiveMemory(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.GetMemory(id); !errors.Is(err, ErrMemoryNotFound) {
+		t.Fatalf("GetMemory(archived) err = %v", err)
+	}
+	if _, err := e.UpdateMemory(999, FactEntry{Content: "x"}); !errors.Is(err, ErrMemoryNotFound) {
+		t.Fatalf("UpdateMemory(missing) err = %v", err)
+	}
+}
+
+func TestEngineListMemoriesAndRestore(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	profileID, _ := e.AddTier1(ProfileEntry{Content: "user prefers short answers", Category: "config"})
+	goID, _ := e.AddTier2(FactEntry{Content: "myclaw is written in Go", Project: "myclaw", Topic: "stack", Category: "decision", Importance: 0.9})
+	dbID, _ := e.AddTier2(FactEntry{Content: "myclaw stores memory in SQLite", Project: "myclaw", Topic: "storage", Category: "decision", Importance: 0.6})
+	debugID, _ := e.AddTier2(FactEntry{Content: "port 8080 was busy", Project: "home", Category: "debug", Importance: 0.2})
+	if err := e.ArchiveMemory(debugID); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(ms []Memory) []int64 {
+		out := make([]int64, len(ms))
+		for i, m := range ms {
+			out[i] = m.ID
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name   string
+		filter MemoryFilter
+		want   []int64
+	}{
+		{"active", MemoryFilter{}, []int64{profileID, goID, dbID}},
+		{"tier", MemoryFilter{Tier: 2}, []int64{goID, dbID}},
+		{"project and topic", MemoryFilter{Project: "myclaw", Topic: "storage"}, []int64{dbID}},
+		{"category", MemoryFilter{Category: "config"}, []int64{profileID}},
+		{"archived", MemoryFilter{Archived: ArchivedOnly}, []int64{debugID}},
+		{"all", MemoryFilter{Archived: ActiveAndArchived, Project: "home"}, []int64{debugID}},
+		{"limit", MemoryFilter{Limit: 1}, []int64{profileID}},
+		{"query", MemoryFilter{Query: "where is memory stored? sqlite"}, []int64{dbID}},
+		{"query archived", MemoryFilter{Query: "port", Archived: ActiveAndArchived}, []int64{debugID}},
+		{"query no keywords", MemoryFilter{Query: "?"}, []int64{}},
+	} {
+		got, err := e.ListMemories(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if g := ids(got); fmt.Sprint(g) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, g, tc.want)
+		}
+	}
+
+	if m, err := e.LookupMemory(debugID); err != nil || !m.IsArchived {
+		t.Fatalf("LookupMemory(archived) = %+v, %v", m, err)
+	}
+	if err := e.RestoreMemory(debugID); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := e.GetMemory(debugID); err != nil || m.IsArchived {
+		t.Fatalf("GetMemory(restored) = %+v, %v", m, err)
+	}
+	if err := e.RestoreMemory(999); !errors.Is(err, ErrMemoryNotFound) {
+		t.Fatalf("RestoreMemory(missing) err = %v", err)
+	}
+}
+
+func TestMigrateSchemaSegmentsFTS(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+	seedLegacyMemoriesSchema(t, dbPath, 0)
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO memories (tier, content) VALUES (2, '服务器部署在 Hetzner 上')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	e, err := NewEngine(dbPath)
+	if err != nil {
+		t.Fatalf("NewEngine upgrade error: %v", err)
+	}
+	defer e.Close()
+
+	for _, query := range []string{"部署", "hetzner"} {
+		if got, err := e.SearchFTS(query, 10); err != nil || len(got) != 1 {
+			t.Fatalf("SearchFTS(%q) = %d results, err %v", query, len(got), err)
+		}
+	}
+	// Only content changes re-index a memory; the index stays consistent.
+	if err := e.TouchMemory(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.UpdateMemory(1, FactEntry{Content: "服务器部署在 Hetzner 的德国机房"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.db.Exec(`INSERT INTO memories_fts(memories_fts) VALUES('integrity-check')`); err != nil {
+		t.Fatalf("fts integrity-check: %v", err)
+	}
+	if got, _ := e.ListMemories(MemoryFilter{Query: "德国机房"}); len(got) != 1 {
+		t.Fatalf("ListMemories after edit = %d results", len(got))
+	}
+}
@@ -54,8 +54,11 @@ func NewExtractionService(engine *Engine, llm LLMClient, cfg config.ExtractionCo
 	}
 }
 
-func (s *ExtractionService) BufferMessage(channel, senderID, role, content string) {
+// BufferMessage queues a conversation message for extraction. Facts drawn
+// from it are saved in scope (see ScopesFor).
+func (s *ExtractionService) BufferMessage(scope, channel, senderID, role, content string) {
 	msg := BufferMessage{
+		Scope:      scope,
 		Channel:    channel,
 		SenderID:   senderID,
 		Role:       role,
@@ -144,10 +147,15 @@ func (s *ExtractionService) flush() {
 		log.Printf("[memory] drain buffer error: %v", err)
 		return
 	}
-	if len(msgs) == 0 {
-		return
+	for _, group := range groupByScope(msgs) {
+		s.extract(group)
 	}
+}
 
+// extract turns one scope's buffered messages into facts and a daily event
+// in that scope, so one person's conversation never feeds another's memory.
+func (s *ExtractionService) extract(msgs []BufferMessage) {
+	scope := msgs[0].Scope
 	conversation := formatConversation(msgs)
 	extracted, err := s.llm.Extract(conversation)
 	if err != nil {
@@ -160,6 +168,7 @@ func (s *ExtractionService) flush() {
 	}
 
 	for _, fact := range extracted.Facts {
+		fact.Scope = scope
 		if err := s.engine.WriteTier2(fact); err != nil {
 			log.Printf("[memory] write tier2 from extraction error: %v", err)
 		}
@@ -171,12 +180,30 @@ func (s *ExtractionService) flush() {
 		SenderID: msgs[0].SenderID,
 		Summary:  extracted.Summary,
 		Tokens:   totalTokens(msgs),
+		Scope:    scope,
 	}
 	if err := s.engine.WriteTier3(event); err != nil {
 		log.Printf("[memory] write tier3 from extraction error: %v", err)
 	}
 }
 
+// groupByScope splits msgs by scope, keeping the order of first appearance
+// and of the messages within each scope.
+func groupByScope(msgs []BufferMessage) [][]BufferMessage {
+	index := map[string]int{}
+	var groups [][]BufferMessage
+	for _, m := range msgs {
+		i, ok := index[m.Scope]
+		if !ok {
+			i = len(groups)
+			index[m.Scope] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], m)
+	}
+	return groups
+}
+
 func formatConversation(msgs []BufferMessage) string {
 	var sb strings.Builder
 	for _, m := range msgs {
@@ -33,7 +33,7 @@ func TestBufferMessage(t *testing.T) {
 	defer e.Close()
 
 	svc := NewExtractionService(e, &mockLLM{}, config.ExtractionConfig{QuietGap: "1h", TokenBudget: 0.9, DailyFlush: "03:00"})
-	svc.BufferMessage("telegram", "u1", "user", "hello world")
+	svc.BufferMessage("", "telegram", "u1", "user", "hello world")
 
 	count, err := e.BufferTokenCount()
 	if err != nil {
@@ -56,7 +56,7 @@ func TestFlushOnTokenBudget(t *testing.T) {
 	}}, config.ExtractionConfig{QuietGap: "1h", TokenBudget: 0.1, DailyFlush: "03:00"})
 	svc.tokenCap = 1
 
-	svc.BufferMessage("telegram", "u1", "user", "这是一条比较长的消息用于触发token预算")
+	svc.BufferMessage("", "telegram", "u1", "user", "这是一条比较长的消息用于触发token预算")
 	time.Sleep(100 * time.Millisecond)
 
 	mems, err := e.QueryTier2("myclaw", "test", 10)
@@ -79,7 +79,7 @@ func TestFlushOnQuietGap(t *testing.T) {
 		return &ExtractionResult{Facts: []FactEntry{{Content: "qfact", Project: "myclaw", Topic: "quiet", Category: "event", Importance: 0.5}}, Summary: "qsummary"}, nil
 	}}, config.ExtractionConfig{QuietGap: "50ms", TokenBudget: 0.9, DailyFlush: "03:00"})
 
-	svc.BufferMessage("telegram", "u1", "user", "quiet message")
+	svc.BufferMessage("", "telegram", "u1", "user", "quiet message")
 	time.Sleep(200 * time.Millisecond)
 
 	mems, err := e.QueryTier2("myclaw", "quiet", 10)
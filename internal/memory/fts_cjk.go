@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ftsText is what memories_fts indexes for a memory's content. The unicode61
+// tokenizer takes a run of Chinese or Japanese characters, which has no
+// spaces, for one word, so a query word only matches a run it equals. Every
+// run of two or more CJK characters is therefore replaced by its overlapping
+// character bigrams ("部署流程" -> "部署 署流 流程"), which a query split the
+// same way matches wherever the words occur. Other text is left to the
+// tokenizer.
+func ftsText(content string) string {
+	var b strings.Builder
+	b.Grow(len(content) * 2)
+	var run []rune
+	flush := func() {
+		switch len(run) {
+		case 0:
+			return
+		case 1:
+			b.WriteString(" " + string(run) + " ")
+		default:
+			for i := 0; i+1 < len(run); i++ {
+				b.WriteString(" " + string(run[i:i+2]))
+			}
+			b.WriteByte(' ')
+		}
+		run = run[:0]
+	}
+	for _, r := range content {
+		if isCJK(r) {
+			run = append(run, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// ftsQueryTerms splits a sanitized query token into the terms ftsText
+// indexes for it.
+func ftsQueryTerms(token string) []string {
+	return strings.Fields(ftsText(token))
+}
+
+// segmentFTSContent fills fts_content for memories written before it
+// existed, or by other tools.
+func segmentFTSContent(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, content FROM memories WHERE fts_content = '' AND content != ''`)
+	if err != nil {
+		return fmt.Errorf("query unsegmented memories: %w", err)
+	}
+	type pending struct {
+		id      int64
+		content string
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.content); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan unsegmented memory: %w", err)
+		}
+		todo = append(todo, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate unsegmented memories: %w", err)
+	}
+	rows.Close()
+
+	for _, p := range todo {
+		if _, err := tx.Exec(`UPDATE memories SET fts_content = ? WHERE id = ?`, ftsText(p.content), p.id); err != nil {
+			return fmt.Errorf("segment memory %d: %w", p.id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package memory
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+const (
+	hnswM              = 16 // links per node above layer 0
+	hnswEfConstruction = 64 // candidate list size while inserting
+	hnswEfSearch       = 64 // minimum candidate list size while searching
+)
+
+// hnswIndex is an in-memory Hierarchical Navigable Small World graph
+// (Malkov & Yashunin) over unit vectors, keyed by memory ID. Similarity is
+// the dot product, i.e. cosine similarity of the original vectors.
+// Removed vectors stay in the graph as tombstones, which are still walked
+// but never returned, until they make up half the graph and it is rebuilt.
+type hnswIndex struct {
+	mu        sync.RWMutex
+	dim       int
+	nodes     []hnswNode
+	ids       map[int64]int32 // memory ID -> live node
+	entry     int32
+	maxLevel  int
+	deleted   int
+	levelMult float64
+	rng       *rand.Rand
+}
+
+type hnswNode struct {
+	id      int64
+	vec     []float32
+	links   [][]int32 // per layer
+	deleted bool
+}
+
+// hnswHit is a search result.
+type hnswHit struct {
+	ID    int64
+	Score float64
+}
+
+func newHNSWIndex(dim int) *hnswIndex {
+	return &hnswIndex{
+		dim:       dim,
+		ids:       make(map[int64]int32),
+		entry:     -1,
+		levelMult: 1 / math.Log(hnswM),
+		rng:       rand.New(rand.NewSource(1)),
+	}
+}
+
+// Dim is the vector dimension the index accepts; an empty index created
+// with dimension 0 takes that of its first vector.
+func (h *hnswIndex) Dim() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.dim
+}
+
+// Len is the number of live vectors.
+func (h *hnswIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.ids)
+}
+
+// Add inserts or replaces the vector of id.
+func (h *hnswIndex) Add(id int64, vector []float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.dim == 0 && len(h.nodes) == 0 {
+		h.dim = len(vector)
+	}
+	vec, err := unitVector(vector, h.dim)
+	if err != nil {
+		return err
+	}
+	h.removeLocked(id)
+	h.insertLocked(id, vec)
+	return nil
+}
+
+// Remove drops id from the results; unknown IDs are ignored.
+func (h *hnswIndex) Remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(id)
+}
+
+// Search returns up to k live vectors most similar to query, best first,
+// exploring at least ef candidates.
+func (h *hnswIndex) Search(query []float32, k, ef int) ([]hnswHit, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	q, err := unitVector(query, h.dim)
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+	ef = max(ef, k, hnswEfSearch)
+	if h.entry < 0 {
+		return nil, nil
+	}
+	ep := h.entry
+	for level := h.maxLevel; level > 0; level-- {
+		ep = h.greedyClosest(q, ep, level)
+	}
+	found := h.searchLayer(q, []int32{ep}, ef, 0)
+
+	hits := make([]hnswHit, 0, min(k, len(found)))
+	for _, c := range found {
+		if h.nodes[c.node].deleted {
+			continue
+		}
+		hits = append(hits, hnswHit{ID: h.nodes[c.node].id, Score: c.score})
+		if len(hits) == k {
+			break
+		}
+	}
+	return hits, nil
+}
+
+func (h *hnswIndex) removeLocked(id int64) {
+	n, ok := h.ids[id]
+	if !ok {
+		return
+	}
+	delete(h.ids, id)
+	h.nodes[n].deleted = true
+	h.deleted++
+	if h.deleted > 64 && h.deleted > len(h.nodes)/2 {
+		h.compactLocked()
+	}
+}
+
+// compactLocked rebuilds the graph from the live vectors.
+func (h *hnswIndex) compactLocked() {
+	old := h.nodes
+	h.nodes = make([]hnswNode, 0, len(h.ids))
+	h.ids = make(map[int64]int32, len(h.ids))
+	h.entry, h.maxLevel, h.deleted = -1, 0, 0
+	for _, n := range old {
+		if !n.deleted {
+			h.insertLocked(n.id, n.vec)
+		}
+	}
+}
+
+func (h *hnswIndex) insertLocked(id int64, vec []float32) {
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	n := int32(len(h.nodes))
+	h.nodes = append(h.nodes, hnswNode{id: id, vec: vec, links: make([][]int32, level+1)})
+	h.ids[id] = n
+	if h.entry < 0 {
+		h.entry, h.maxLevel = n, level
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedyClosest(vec, ep, l)
+	}
+	eps := []int32{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(vec, eps, hnswEfConstruction, l)
+		neighbours := h.selectNeighbours(found, hnswM)
+		h.nodes[n].links[l] = neighbours
+		for _, nb := range neighbours {
+			h.link(nb, n, l)
+		}
+		eps = eps[:0]
+		for _, c := range found {
+			eps = append(eps, c.node)
+		}
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = n, level
+	}
+}
+
+// link adds a link from a to b on level, pruning a's links when it has too
+// many.
+func (h *hnswIndex) link(a, b int32, level int) {
+	links := append(h.nodes[a].links[level], b)
+	limit := hnswM
+	if level == 0 {
+		limit = 2 * hnswM
+	}
+	// Pruning is the costly part of an insert, so links may overshoot by
+	// half before they are cut back to the limit.
+	if len(links) > limit+limit/2 {
+		vec := h.nodes[a].vec
+		candidates := make([]hnswCandidate, len(links))
+		for i, nb := range links {
+			candidates[i] = hnswCandidate{node: nb, score: dot(vec, h.nodes[nb].vec)}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+		links = h.selectNeighbours(candidates, limit)
+	}
+	h.nodes[a].links[level] = links
+}
+
+// selectNeighbours picks up to m of candidates (best first) with the
+// paper's diversity heuristic: a candidate closer to an already selected
+// neighbour than to the base is skipped, and skipped candidates fill any
+// remaining slots.
+func (h *hnswIndex) selectNeighbours(candidates []hnswCandidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if dot(h.nodes[c.node].vec, h.nodes[s].vec) > c.score {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, s := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+func (h *hnswIndex) greedyClosest(q []float32, ep int32, level int) int32 {
+	best := dot(q, h.nodes[ep].vec)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.nodes[ep].links[level] {
+			if s := dot(q, h.nodes[nb].vec); s > best {
+				best, ep, changed = s, nb, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns the ef nodes most similar to q on level, best first.
+func (h *hnswIndex) searchLayer(q []float32, eps []int32, ef, level int) []hnswCandidate {
+	visited := visitedPool.Get().(*visitedSet)
+	defer visitedPool.Put(visited)
+	visited.reset(len(h.nodes))
+	candidates := &hnswMaxHeap{}
+	results := &hnswMinHeap{}
+	for _, ep := range eps {
+		if visited.visit(ep) {
+			continue
+		}
+		c := hnswCandidate{node: ep, score: dot(q, h.nodes[ep].vec)}
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.score < (*results)[0].score {
+			break
+		}
+		node := &h.nodes[c.node]
+		if level >= len(node.links) {
+			continue
+		}
+		for _, nb := range node.links[level] {
+			if visited.visit(nb) {
+				continue
+			}
+			s := dot(q, h.nodes[nb].vec)
+			if results.Len() < ef || s > (*results)[0].score {
+				heap.Push(candidates, hnswCandidate{node: nb, score: s})
+				heap.Push(results, hnswCandidate{node: nb, score: s})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	out := make([]hnswCandidate, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return out
+}
+
+func unitVector(vector []float32, dim int) ([]float32, error) {
+	if len(vector) != dim {
+		return nil, fmt.Errorf("vector index: dimension mismatch: %d vs %d", len(vector), dim)
+	}
+	var norm float64
+	for i, v := range vector {
+		if !isFiniteFloat64(float64(v)) {
+			return nil, fmt.Errorf("vector index: invalid value at index %d", i)
+		}
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("vector index: zero vector norm")
+	}
+	scale := 1 / math.Sqrt(norm)
+	out := make([]float32, len(vector))
+	for i, v := range vector {
+		out[i] = float32(float64(v) * scale)
+	}
+	return out, nil
+}
+
+func dot(a, b []float32) float64 {
+	var s0, s1, s2, s3 float32
+	b = b[:len(a)]
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return float64(s0 + s1 + s2 + s3)
+}
+
+// visitedSet marks the nodes a search has seen; an epoch per search saves
+// clearing it.
+type visitedSet struct {
+	epoch uint32
+	marks []uint32
+}
+
+var visitedPool = sync.Pool{New: func() any { return &visitedSet{} }}
+
+func (v *visitedSet) reset(n int) {
+	if len(v.marks) < n {
+		v.marks = make([]uint32, n+n/4)
+		v.epoch = 0
+	}
+	v.epoch++
+	if v.epoch == 0 {
+		clear(v.marks)
+		v.epoch = 1
+	}
+}
+
+// visit marks n and reports whether it was already marked.
+func (v *visitedSet) visit(n int32) bool {
+	if v.marks[n] == v.epoch {
+		return true
+	}
+	v.marks[n] = v.epoch
+	return false
+}
+
+type hnswCandidate struct {
+	node  int32
+	score float64
+}
+
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int           { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool { return h[i].score > h[j].score }
+func (h hnswMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int           { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h hnswMinHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
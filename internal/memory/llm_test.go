@@ -6,81 +6,268 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stellarlinkco/myclaw/internal/config"
 )
 
-func TestLLMClient_RequestAndResponseParsing(t *testing.T) {
+const extractionJSON = `{"facts":[{"content":"x","project":"myclaw","topic":"arch","category":"decision","importance":0.8}],"summary":"s"}`
+
+// openAIToolCallResponse answers a chat completion with a call to tool.
+func openAIToolCallResponse(tool, arguments string) map[string]any {
+	return map[string]any{
+		"id":     "chatcmpl-test",
+		"object": "chat.completion",
+		"model":  "gpt-test",
+		"choices": []map[string]any{{
+			"index":         0,
+			"finish_reason": "tool_calls",
+			"message": map[string]any{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]any{{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]any{"name": tool, "arguments": arguments},
+				}},
+			},
+		}},
+		"usage": map[string]any{"prompt_tokens": 100, "completion_tokens": 20, "total_tokens": 120},
+	}
+}
+
+// openAITextResponse answers a chat completion with plain text.
+func openAITextResponse(content string) map[string]any {
+	return map[string]any{
+		"id":     "chatcmpl-test",
+		"object": "chat.completion",
+		"model":  "gpt-test",
+		"choices": []map[string]any{{
+			"index":         0,
+			"finish_reason": "stop",
+			"message":       map[string]any{"role": "assistant", "content": content},
+		}},
+		"usage": map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	}
+}
+
+func newOpenAIMemoryConfig(baseURL string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Provider.Type = "openai"
+	cfg.Provider.APIKey = "test-key"
+	cfg.Provider.BaseURL = baseURL
+	cfg.Agent.Model = "gpt-test"
+	return cfg
+}
+
+func TestLLMClient_OpenAIToolCall(t *testing.T) {
+	var body map[string]any
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			t.Fatalf("method = %s", r.Method)
-		}
-		if r.URL.Path != "/chat/completions" {
-			t.Fatalf("path = %s", r.URL.Path)
+		if r.Method != http.MethodPost || r.URL.Path != "/chat/completions" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
 		}
 		if r.Header.Get("Authorization") != "Bearer test-key" {
-			t.Fatalf("auth header mismatch")
+			t.Errorf("auth header mismatch")
 		}
-
-		var body map[string]any
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			t.Fatalf("decode request: %v", err)
-		}
-		if body["temperature"].(float64) != 0.3 {
-			t.Fatalf("expected temperature 0.3")
+			t.Errorf("decode request: %v", err)
 		}
+		writeJSON(w, openAIToolCallResponse(extractionTool.Name, extractionJSON))
+	}))
+	defer srv.Close()
 
-		resp := map[string]any{
-			"choices": []map[string]any{{
-				"message": map[string]any{
-					"content": `{"facts":[{"content":"x","project":"myclaw","topic":"arch","category":"decision","importance":0.8}],"summary":"s"}`,
-				},
-			}},
+	client := NewLLMClient(newOpenAIMemoryConfig(srv.URL))
+	out, err := client.Extract("conversation")
+	if err != nil {
+		t.Fatalf("Extract error: %v", err)
+	}
+	if len(out.Facts) != 1 || out.Facts[0].Project != "myclaw" || out.Summary != "s" {
+		t.Fatalf("unexpected extract output: %+v", out)
+	}
+
+	if body["temperature"] != 0.3 || body["model"] != "gpt-test" {
+		t.Fatalf("temperature/model = %v/%v", body["temperature"], body["model"])
+	}
+	tools, _ := body["tools"].([]any)
+	if len(tools) != 1 || !strings.Contains(mustJSON(t, tools[0]), `"name":"save_extraction"`) {
+		t.Fatalf("tools = %v", body["tools"])
+	}
+
+	usage := client.(UsageReporter).Usage()
+	if usage != (ModelUsage{Requests: 1, InputTokens: 100, OutputTokens: 20, TotalTokens: 120}) {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestLLMClient_AnthropicMessages(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("X-Api-Key") != "test-key" {
+			t.Errorf("x-api-key = %q", r.Header.Get("X-Api-Key"))
 		}
-		_ = json.NewEncoder(w).Encode(resp)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		writeJSON(w, map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "claude-test",
+			"stop_reason": "tool_use",
+			"content": []map[string]any{{
+				"type":  "tool_use",
+				"id":    "toolu_1",
+				"name":  profileTool.Name,
+				"input": map[string]any{"entries": []map[string]any{{"content": "Works on myclaw", "category": "identity"}}},
+			}},
+			"usage": map[string]any{"input_tokens": 50, "output_tokens": 7},
+		})
 	}))
 	defer srv.Close()
 
 	cfg := config.DefaultConfig()
+	cfg.Provider.Type = "anthropic"
 	cfg.Provider.APIKey = "test-key"
 	cfg.Provider.BaseURL = srv.URL
-	cfg.Agent.Model = "gpt-test"
+	cfg.Agent.Model = "claude-test"
 
 	client := NewLLMClient(cfg)
+	out, err := client.UpdateProfile("", "- works on myclaw")
+	if err != nil {
+		t.Fatalf("UpdateProfile error: %v", err)
+	}
+	if len(out.Entries) != 1 || out.Entries[0].Content != "Works on myclaw" {
+		t.Fatalf("unexpected profile output: %+v", out)
+	}
+	if body["model"] != "claude-test" || body["temperature"] != 0.3 {
+		t.Fatalf("model/temperature = %v/%v", body["model"], body["temperature"])
+	}
+	if !strings.Contains(mustJSON(t, body["tools"]), `"name":"save_profile"`) {
+		t.Fatalf("tools = %v", body["tools"])
+	}
+
+	usage := client.(UsageReporter).Usage()
+	if usage != (ModelUsage{Requests: 1, InputTokens: 50, OutputTokens: 7, TotalTokens: 57}) {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestLLMClient_TextAnswerAndParseRetry(t *testing.T) {
+	var mu sync.Mutex
+	requests, broken := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		answerText := requests == 1 || broken
+		mu.Unlock()
+		if answerText {
+			writeJSON(w, openAITextResponse("Sorry, here are the facts: x"))
+			return
+		}
+		writeJSON(w, openAITextResponse("```json\n"+extractionJSON+"\n```"))
+	}))
+	defer srv.Close()
+
+	client := NewLLMClient(newOpenAIMemoryConfig(srv.URL))
 	out, err := client.Extract("conversation")
 	if err != nil {
 		t.Fatalf("Extract error: %v", err)
 	}
-	if len(out.Facts) != 1 || out.Summary != "s" {
-		t.Fatalf("unexpected extract output: %+v", out)
+	if len(out.Facts) != 1 || requests != 2 {
+		t.Fatalf("facts = %+v after %d requests", out.Facts, requests)
+	}
+	if usage := client.(UsageReporter).Usage(); usage.Requests != 2 || usage.TotalTokens != 30 {
+		t.Fatalf("usage = %+v", usage)
+	}
+
+	mu.Lock()
+	broken = true // every answer from now on is unusable
+	mu.Unlock()
+	if _, err := client.Extract("conversation"); err == nil || !strings.Contains(err.Error(), "parse save_extraction result") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestQueryExpander_ToolCallAndEngineUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, openAIToolCallResponse(queryExpansionTool.Name, `{"lexical":["deploy"],"semantic":["release"],"hyde":["docker compose up"]}`))
+	}))
+	defer srv.Close()
+
+	cfg := newOpenAIMemoryConfig(srv.URL)
+	expander := NewQueryExpander(cfg)
+	out, err := expander.Expand("how do we ship?")
+	if err != nil {
+		t.Fatalf("Expand error: %v", err)
+	}
+	if !reflect.DeepEqual(out.Lexical, []string{"deploy"}) || len(out.HyDE) != 3 {
+		t.Fatalf("expansion = %+v", out)
+	}
+
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.SetQueryExpander(expander)
+	if usage := e.ModelUsage(); usage.Requests != 1 || usage.TotalTokens != 120 {
+		t.Fatalf("engine usage = %+v", usage)
+	}
+
+	cfg.Provider.APIKey = ""
+	if NewQueryExpander(cfg) != nil {
+		t.Fatal("expected no expander without a memory api key")
 	}
 }
 
 func TestLLMClient_ProviderSelection(t *testing.T) {
-	cfg := config.DefaultConfig()
-	cfg.Provider.APIKey = "main-key"
-	cfg.Provider.BaseURL = "https://main.example.com"
-	cfg.Agent.Model = "main-model"
+	var mainHits, memHits int
+	var memBody map[string]any
+	mainSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mainHits++
+		writeJSON(w, openAIToolCallResponse(extractionTool.Name, extractionJSON))
+	}))
+	defer mainSrv.Close()
+	memSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		memHits++
+		if got := r.Header.Get("Authorization"); got != "Bearer mem-key" {
+			t.Errorf("auth = %q", got)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&memBody)
+		writeJSON(w, openAIToolCallResponse(extractionTool.Name, extractionJSON))
+	}))
+	defer memSrv.Close()
 
-	c := NewLLMClient(cfg).(*llmClient)
-	if c.apiKey != "main-key" || c.baseURL != "https://main.example.com" || c.model != "main-model" {
-		t.Fatal("expected fallback to main provider/model")
+	cfg := newOpenAIMemoryConfig(mainSrv.URL)
+	if _, err := NewLLMClient(cfg).Extract("conversation"); err != nil || mainHits != 1 {
+		t.Fatalf("expected fallback to main provider: err=%v hits=%d", err, mainHits)
 	}
 
-	cfg.Memory.Provider = &config.ProviderConfig{APIKey: "mem-key", BaseURL: "https://mem.example.com"}
+	cfg.Memory.Provider = &config.ProviderConfig{APIKey: "mem-key", BaseURL: memSrv.URL}
 	cfg.Memory.Model = "mem-model"
 	cfg.Memory.MaxTokens = 1234
+	if _, err := NewLLMClient(cfg).Extract("conversation"); err != nil || memHits != 1 || mainHits != 1 {
+		t.Fatalf("expected memory provider: err=%v main=%d mem=%d", err, mainHits, memHits)
+	}
+	if memBody["model"] != "mem-model" || memBody["max_completion_tokens"] != float64(1234) {
+		t.Fatalf("model/max tokens = %v/%v", memBody["model"], memBody["max_completion_tokens"])
+	}
 
-	c2 := NewLLMClient(cfg).(*llmClient)
-	if c2.apiKey != "mem-key" || c2.baseURL != "https://mem.example.com" || c2.model != "mem-model" || c2.maxTokens != 1234 {
-		t.Fatal("expected memory-specific provider/model/maxTokens")
+	cfg.Memory.Provider = nil
+	cfg.Provider.APIKey = ""
+	if _, err := NewLLMClient(cfg).Extract("conversation"); err == nil || !strings.Contains(err.Error(), "missing memory api key") {
+		t.Fatalf("expected missing key error, got %v", err)
 	}
 }
 
-func TestLLMClient_ReasoningEffortPrecedence(t *testing.T) {
+func TestLLMClient_ReasoningEffort_PayloadInjection(t *testing.T) {
 	tests := []struct {
 		name         string
 		memoryEffort string
@@ -99,41 +286,6 @@ func TestLLMClient_ReasoningEffortPrecedence(t *testing.T) {
 			agentEffort:  "medium",
 			want:         "medium",
 		},
-		{
-			name:         "empty when both empty",
-			memoryEffort: "",
-			agentEffort:  "",
-			want:         "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			cfg := config.DefaultConfig()
-			cfg.Memory.ModelReasoningEffort = tt.memoryEffort
-			cfg.Agent.ModelReasoningEffort = tt.agentEffort
-
-			c := NewLLMClient(cfg).(*llmClient)
-			if c.reasoningEffort != tt.want {
-				t.Fatalf("reasoningEffort = %q, want %q", c.reasoningEffort, tt.want)
-			}
-		})
-	}
-}
-
-func TestLLMClient_ReasoningEffort_PayloadInjection(t *testing.T) {
-	tests := []struct {
-		name         string
-		memoryEffort string
-		agentEffort  string
-		want         string
-	}{
-		{
-			name:         "injects when resolved non-empty",
-			memoryEffort: "high",
-			agentEffort:  "low",
-			want:         "high",
-		},
 		{
 			name:         "omits when resolved empty",
 			memoryEffort: "",
@@ -147,24 +299,13 @@ func TestLLMClient_ReasoningEffort_PayloadInjection(t *testing.T) {
 			var captured map[string]any
 			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				if err := json.NewDecoder(r.Body).Decode(&captured); err != nil {
-					t.Fatalf("decode request: %v", err)
-				}
-
-				resp := map[string]any{
-					"choices": []map[string]any{{
-						"message": map[string]any{
-							"content": `{"facts":[{"content":"x","project":"myclaw","topic":"arch","category":"decision","importance":0.8}],"summary":"s"}`,
-						},
-					}},
+					t.Errorf("decode request: %v", err)
 				}
-				_ = json.NewEncoder(w).Encode(resp)
+				writeJSON(w, openAIToolCallResponse(extractionTool.Name, extractionJSON))
 			}))
 			defer srv.Close()
 
-			cfg := config.DefaultConfig()
-			cfg.Provider.APIKey = "test-key"
-			cfg.Provider.BaseURL = srv.URL
-			cfg.Agent.Model = "gpt-test"
+			cfg := newOpenAIMemoryConfig(srv.URL)
 			cfg.Memory.ModelReasoningEffort = tt.memoryEffort
 			cfg.Agent.ModelReasoningEffort = tt.agentEffort
 
@@ -180,10 +321,6 @@ func TestLLMClient_ReasoningEffort_PayloadInjection(t *testing.T) {
 				}
 				return
 			}
-
-			if !exists {
-				t.Fatalf("expected reasoning_effort=%q in payload", tt.want)
-			}
 			if got != tt.want {
 				t.Fatalf("reasoning_effort = %v, want %q", got, tt.want)
 			}
@@ -196,12 +333,13 @@ func TestLLMClient_ReasoningEffort_UnsupportedFallback(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var body map[string]any
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			t.Fatalf("decode request: %v", err)
+			t.Errorf("decode request: %v", err)
 		}
 		requests = append(requests, body)
 
 		switch len(requests) {
 		case 1:
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
 			_ = json.NewEncoder(w).Encode(map[string]any{
 				"error": map[string]any{
@@ -210,23 +348,14 @@ func TestLLMClient_ReasoningEffort_UnsupportedFallback(t *testing.T) {
 				},
 			})
 		case 2:
-			_ = json.NewEncoder(w).Encode(map[string]any{
-				"choices": []map[string]any{{
-					"message": map[string]any{
-						"content": `{"facts":[{"content":"x","project":"myclaw","topic":"arch","category":"decision","importance":0.8}],"summary":"s"}`,
-					},
-				}},
-			})
+			writeJSON(w, openAIToolCallResponse(extractionTool.Name, extractionJSON))
 		default:
-			t.Fatalf("unexpected extra retry: %d", len(requests))
+			t.Errorf("unexpected extra retry: %d", len(requests))
 		}
 	}))
 	defer srv.Close()
 
-	cfg := config.DefaultConfig()
-	cfg.Provider.APIKey = "test-key"
-	cfg.Provider.BaseURL = srv.URL
-	cfg.Agent.Model = "gpt-test"
+	cfg := newOpenAIMemoryConfig(srv.URL)
 	cfg.Agent.ModelReasoningEffort = "medium"
 
 	logBuf := bytes.NewBuffer(nil)
@@ -263,7 +392,22 @@ func TestLLMClient_ReasoningEffort_UnsupportedFallback(t *testing.T) {
 		t.Fatalf("fallback payload mismatch: first(without reasoning_effort)=%v second=%v", first, second)
 	}
 
-	if !strings.Contains(logBuf.String(), "retrying without reasoning_effort") {
+	if !strings.Contains(logBuf.String(), "retrying without reasoning effort") {
 		t.Fatalf("expected warning log about unsupported reasoning_effort, got: %q", logBuf.String())
 	}
 }
+
+// writeJSON answers like the provider APIs, which the SDK clients check.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	embeddingProviderLocal = "local"
+
+	// localEmbeddingModel is recorded as the model of local embeddings.
+	localEmbeddingModel = "local-hash-v1"
+	// defaultLocalEmbeddingDim is used when embedding.dimension is unset.
+	defaultLocalEmbeddingDim = 512
+)
+
+// localEmbedder embeds text offline with feature hashing: words, character
+// trigrams of words, and CJK characters and character bigrams (or, for text
+// without any, its symbols) are hashed into a fixed number of signed buckets
+// with sublinear term frequency, and the vector is L2-normalised. Trigrams
+// match inflections ("deploy", "deployment") and bigrams match Chinese and
+// Japanese text, which has no spaces. There is no IDF: vectors must not
+// change as the store grows, so that stored embeddings stay comparable with
+// new queries.
+type localEmbedder struct {
+	dim int
+}
+
+func newLocalEmbedder(dim int) *localEmbedder {
+	if dim <= 0 {
+		dim = defaultLocalEmbeddingDim
+	}
+	return &localEmbedder{dim: dim}
+}
+
+func (l *localEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	vec, err := l.embed(text)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	return vec, nil
+}
+
+func (l *localEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("embed batch: empty texts")
+	}
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("embed batch: %w", err)
+		}
+		vec, err := l.embed(text)
+		if err != nil {
+			return nil, fmt.Errorf("embed batch: text at index %d: %w", i, err)
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+func (l *localEmbedder) embed(text string) ([]float32, error) {
+	features := localEmbeddingFeatures(text)
+	if len(features) == 0 {
+		return nil, fmt.Errorf("empty text")
+	}
+
+	acc := make([]float64, l.dim)
+	for feature, f := range features {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		weight := f.weight * (1 + math.Log(float64(f.count)))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		acc[sum%uint64(l.dim)] += weight
+	}
+
+	var norm float64
+	for _, v := range acc {
+		norm += v * v
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("features cancel out")
+	}
+	norm = math.Sqrt(norm)
+	vec := make([]float32, l.dim)
+	for i, v := range acc {
+		vec[i] = float32(v / norm)
+	}
+	return vec, nil
+}
+
+type localFeature struct {
+	weight float64
+	count  int
+}
+
+// localEmbeddingFeatures splits text into weighted, counted features.
+func localEmbeddingFeatures(text string) map[string]localFeature {
+	features := make(map[string]localFeature)
+	add := func(feature string, weight float64) {
+		f := features[feature]
+		f.weight = weight
+		f.count++
+		features[feature] = f
+	}
+
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		add("w:"+string(word), 1)
+		padded := append(append([]rune{'^'}, word...), '$')
+		for i := 0; i+3 <= len(padded); i++ {
+			add("g:"+string(padded[i:i+3]), 0.5)
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		for i, r := range cjk {
+			add("c:"+string(r), 0.5)
+			if i+1 < len(cjk) {
+				add("b:"+string(cjk[i:i+2]), 1)
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	// Text without words ("👍", "---") falls back to its symbols, so every
+	// non-blank memory gets an embedding.
+	if len(features) == 0 {
+		for _, r := range text {
+			if !unicode.IsSpace(r) {
+				add("s:"+string(r), 1)
+			}
+		}
+	}
+	return features
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cexll/agentsdk-go/pkg/model"
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+const (
+	// memoryModelMaxRetries bounds the provider-level retries of transient
+	// API errors; memory work runs in the background and can wait for the
+	// next flush.
+	memoryModelMaxRetries = 3
+	// memoryJSONAttempts is how often a request is sent when the answer
+	// does not parse.
+	memoryJSONAttempts = 2
+)
+
+// ModelUsage is the token usage of the memory model.
+type ModelUsage struct {
+	Requests     int64 `json:"requests"`
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+	TotalTokens  int64 `json:"totalTokens"`
+}
+
+// Add returns the sum of u and o.
+func (u ModelUsage) Add(o ModelUsage) ModelUsage {
+	return ModelUsage{
+		Requests:     u.Requests + o.Requests,
+		InputTokens:  u.InputTokens + o.InputTokens,
+		OutputTokens: u.OutputTokens + o.OutputTokens,
+		TotalTokens:  u.TotalTokens + o.TotalTokens,
+	}
+}
+
+// UsageReporter is implemented by memory clients that call the memory model.
+type UsageReporter interface {
+	Usage() ModelUsage
+}
+
+// memoryModel is the chat model behind extraction, compression, query
+// expansion and the LLM rerank fallback: Anthropic Messages or OpenAI chat
+// completions through agentsdk-go, following the memory provider (or the
+// main one).
+type memoryModel struct {
+	model       model.Model
+	err         error // why model is nil
+	maxTokens   int
+	temperature float64
+
+	mu    sync.Mutex
+	usage ModelUsage
+}
+
+func newMemoryModel(cfg *config.Config, timeout time.Duration, temperature float64) *memoryModel {
+	m := &memoryModel{temperature: temperature}
+
+	providerType, apiKey, baseURL := cfg.Provider.Type, cfg.Provider.APIKey, cfg.Provider.BaseURL
+	if p := cfg.Memory.Provider; p != nil {
+		if strings.TrimSpace(p.Type) != "" {
+			providerType = p.Type
+		}
+		if strings.TrimSpace(p.APIKey) != "" {
+			apiKey = p.APIKey
+		}
+		if strings.TrimSpace(p.BaseURL) != "" {
+			baseURL = p.BaseURL
+		}
+	}
+	modelName := strings.TrimSpace(cfg.Memory.Model)
+	if modelName == "" {
+		modelName = strings.TrimSpace(cfg.Agent.Model)
+	}
+	m.maxTokens = cfg.Agent.MaxTokens
+	if cfg.Memory.MaxTokens > 0 {
+		m.maxTokens = cfg.Memory.MaxTokens
+	}
+
+	switch {
+	case strings.TrimSpace(apiKey) == "":
+		m.err = errors.New("missing memory api key")
+		return m
+	case modelName == "":
+		m.err = errors.New("missing memory model")
+		return m
+	}
+
+	httpClient := &http.Client{Timeout: timeout}
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if strings.EqualFold(strings.TrimSpace(providerType), "openai") {
+		m.model, m.err = model.NewOpenAI(model.OpenAIConfig{
+			APIKey:          apiKey,
+			BaseURL:         baseURL,
+			Model:           modelName,
+			MaxTokens:       m.maxTokens,
+			MaxRetries:      memoryModelMaxRetries,
+			ReasoningEffort: cfg.ModelReasoningEffort(),
+			HTTPClient:      httpClient,
+		})
+	} else {
+		m.model, m.err = model.NewAnthropic(model.AnthropicConfig{
+			APIKey:     apiKey,
+			BaseURL:    baseURL,
+			Model:      modelName,
+			MaxTokens:  m.maxTokens,
+			MaxRetries: memoryModelMaxRetries,
+			HTTPClient: httpClient,
+		})
+	}
+	return m
+}
+
+// Usage reports the tokens the model has used since it was created.
+func (m *memoryModel) Usage() ModelUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+func (m *memoryModel) record(u model.Usage) {
+	total := u.TotalTokens
+	if total == 0 {
+		total = u.InputTokens + u.OutputTokens
+	}
+	m.mu.Lock()
+	m.usage = m.usage.Add(ModelUsage{Requests: 1, InputTokens: int64(u.InputTokens), OutputTokens: int64(u.OutputTokens), TotalTokens: int64(total)})
+	m.mu.Unlock()
+}
+
+// completeJSON sends prompt with schema as the only tool, so the answer
+// comes back as structured tool input (or, from models that answer in
+// text, as a JSON object), and hands the JSON to parse. An answer parse
+// rejects is asked for once more.
+func (m *memoryModel) completeJSON(ctx context.Context, prompt string, schema model.ToolDefinition, parse func(payload string) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	temperature := m.temperature
+	req := model.Request{
+		System:      fmt.Sprintf("Answer only by calling the %s tool.", schema.Name),
+		Messages:    []model.Message{{Role: "user", Content: prompt}},
+		Tools:       []model.ToolDefinition{schema},
+		MaxTokens:   m.maxTokens,
+		Temperature: &temperature,
+	}
+
+	var err error
+	for attempt := 1; attempt <= memoryJSONAttempts; attempt++ {
+		resp, callErr := m.model.Complete(ctx, req)
+		if callErr != nil {
+			return callErr
+		}
+		m.record(resp.Usage)
+		payload := jsonPayload(resp.Message, schema.Name)
+		if payload == "" {
+			err = errors.New("empty content in response")
+		} else if err = parse(payload); err == nil {
+			return nil
+		}
+		if attempt < memoryJSONAttempts {
+			log.Printf("[memory] %s answer unusable, asking again: %v", schema.Name, err)
+		}
+	}
+	return err
+}
+
+// jsonPayload is the input of the call to tool, or else the message text
+// without a Markdown code fence.
+func jsonPayload(msg model.Message, tool string) string {
+	for _, call := range msg.ToolCalls {
+		if call.Name != tool {
+			continue
+		}
+		data, err := json.Marshal(call.Arguments)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+	text := strings.TrimSpace(msg.TextContent())
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	return text
+}
+
+// Tool schemas for the memory model's answers.
+
+func memoryFactSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content":    map[string]any{"type": "string"},
+			"project":    map[string]any{"type": "string"},
+			"topic":      map[string]any{"type": "string"},
+			"category":   map[string]any{"type": "string", "enum": []string{"identity", "config", "credential", "decision", "solution", "event", "conversation", "temp", "debug"}},
+			"importance": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+		},
+		"required": []string{"content", "project", "topic", "category", "importance"},
+	}
+}
+
+func stringList() map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string"}}
+}
+
+var (
+	extractionTool = model.ToolDefinition{
+		Name:        "save_extraction",
+		Description: "Save the facts extracted from the conversation and its summary.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"facts":   map[string]any{"type": "array", "items": memoryFactSchema()},
+				"summary": map[string]any{"type": "string"},
+			},
+			"required": []string{"facts", "summary"},
+		},
+	}
+	compressionTool = model.ToolDefinition{
+		Name:        "save_facts",
+		Description: "Save the resulting memory facts.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"facts": map[string]any{"type": "array", "items": memoryFactSchema()},
+			},
+			"required": []string{"facts"},
+		},
+	}
+	profileTool = model.ToolDefinition{
+		Name:        "save_profile",
+		Description: "Save the updated core profile entries.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"entries": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"content":  map[string]any{"type": "string"},
+							"category": map[string]any{"type": "string"},
+						},
+						"required": []string{"content", "category"},
+					},
+				},
+			},
+			"required": []string{"entries"},
+		},
+	}
+	queryExpansionTool = model.ToolDefinition{
+		Name:        "save_query_expansion",
+		Description: "Save the alternative search tokens for the query.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"lexical":  stringList(),
+				"semantic": stringList(),
+				"hyde":     stringList(),
+			},
+			"required": []string{"lexical", "semantic", "hyde"},
+		},
+	}
+	rerankTool = model.ToolDefinition{
+		Name:        "save_rerank_scores",
+		Description: "Save the relevance score of every document.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"scores": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"index": map[string]any{"type": "integer"},
+							"score": map[string]any{"type": "number"},
+						},
+						"required": []string{"index", "score"},
+					},
+				},
+			},
+			"required": []string{"scores"},
+		},
+	}
+	retrievalDecisionTool = model.ToolDefinition{
+		Name:        "save_retrieval_decision",
+		Description: "Save whether answering the message needs the user's stored memories.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"retrieve": map[string]any{"type": "boolean"},
+			},
+			"required": []string{"retrieve"},
+		},
+	}
+)
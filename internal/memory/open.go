@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+// DBPath is the configured memory database, or memory.db in the config data
+// directory.
+func DBPath(cfg *config.Config) string {
+	if dbPath := strings.TrimSpace(cfg.Memory.DBPath); dbPath != "" {
+		return dbPath
+	}
+	return filepath.Join(config.ConfigDir(), "data", "memory.db")
+}
+
+// Open opens the memory store the agent works with, in the gateway and in
+// the CLI alike: legacy file memory is migrated into an empty store, and
+// project matching, retrieval mode, query expansion, rerank and embeddings
+// follow cfg.Memory.
+func Open(cfg *config.Config) (*Engine, error) {
+	engine, err := NewEngine(DBPath(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("create memory engine: %w", err)
+	}
+
+	empty, err := engine.IsEmpty()
+	if err != nil {
+		_ = engine.Close()
+		return nil, fmt.Errorf("inspect memory engine state: %w", err)
+	}
+	if empty {
+		if err := MigrateFromFiles(cfg.Agent.Workspace, engine); err != nil {
+			_ = engine.Close()
+			return nil, fmt.Errorf("migrate legacy file memory: %w", err)
+		}
+	}
+	if projects, err := engine.LoadKnownProjects(); err != nil {
+		log.Printf("[memory] load known projects warning: %v", err)
+	} else {
+		engine.SetKnownProjects(projects)
+	}
+
+	engine.SetRetrievalConfig(cfg.Memory.Retrieval)
+	if strings.EqualFold(strings.TrimSpace(cfg.Memory.Retrieval.Mode), config.MemoryRetrievalModeEnhanced) {
+		engine.SetQueryExpander(NewQueryExpander(cfg))
+		if cfg.Memory.Rerank.Enabled {
+			engine.SetReranker(NewReranker(cfg))
+		}
+	}
+	if cfg.Memory.Embedding.Enabled {
+		engine.SetEmbedder(NewEmbedder(cfg), EmbeddingModel(cfg), cfg.Memory.Embedding.TimeoutMs)
+		if stale, err := engine.MarkStaleEmbeddings(EmbeddingDimension(cfg)); err != nil {
+			log.Printf("[memory] stale embedding check warning: %v", err)
+		} else if stale > 0 {
+			log.Printf("[memory] %d embeddings are from another embedding model or dimension and wait for re-embedding", stale)
+		}
+		engine.StartVectorIndex()
+	}
+	return engine, nil
+}
+
+// EmbeddingModel is the model name recorded with new embeddings.
+func EmbeddingModel(cfg *config.Config) string {
+	if strings.EqualFold(strings.TrimSpace(cfg.Memory.Embedding.Provider), embeddingProviderLocal) {
+		return localEmbeddingModel
+	}
+	embeddingModel := strings.TrimSpace(cfg.Memory.Embedding.Model)
+	if embeddingModel == "" {
+		embeddingModel = strings.TrimSpace(cfg.Memory.Model)
+	}
+	if embeddingModel == "" {
+		embeddingModel = strings.TrimSpace(cfg.Agent.Model)
+	}
+	return embeddingModel
+}
+
+// EmbeddingDimension is the dimension new embeddings have, or 0 when only
+// the model knows it.
+func EmbeddingDimension(cfg *config.Config) int {
+	if strings.EqualFold(strings.TrimSpace(cfg.Memory.Embedding.Provider), embeddingProviderLocal) {
+		return newLocalEmbedder(cfg.Memory.Embedding.Dimension).dim
+	}
+	return cfg.Memory.Embedding.Dimension
+}
@@ -23,12 +23,12 @@ type enhancedCandidate struct {
 	finalScore  float64
 }
 
-func (e *Engine) retrieveEnhanced(msg string) ([]Memory, error) {
+func (e *Engine) retrieveEnhanced(msg string, visible []string) ([]Memory, error) {
 	keywords := sanitizeFTSTokens(extractKeywords(msg))
 	project := matchProject(msg, e.knownProjectsSnapshot())
 	retrievalCfg := e.retrievalConfigSnapshot()
 
-	base, err := e.queryRetrieveBase(project)
+	base, err := e.queryRetrieveBase(project, visible)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +64,7 @@ func (e *Engine) retrieveEnhanced(msg string) ([]Memory, error) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		matches, err := e.searchFTSScored(keywords, candidateLimit)
+		matches, err := e.searchFTSScored(keywords, candidateLimit, visible)
 		if err != nil {
 			return
 		}
@@ -75,7 +75,7 @@ func (e *Engine) retrieveEnhanced(msg string) ([]Memory, error) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			matches, err := e.searchFTSScored(expandedTokens, candidateLimit)
+			matches, err := e.searchFTSScored(expandedTokens, candidateLimit, visible)
 			if err != nil {
 				return
 			}
@@ -90,7 +90,7 @@ func (e *Engine) retrieveEnhanced(msg string) ([]Memory, error) {
 			defer wg.Done()
 			ctx, cancel := withEmbeddingTimeout(context.Background(), embeddingTimeoutMs)
 			defer cancel()
-			memories, err := e.searchVectorCandidates(ctx, embedder, msg, project, candidateLimit)
+			memories, err := e.searchVectorCandidates(ctx, embedder, msg, project, candidateLimit, visible)
 			if err != nil {
 				return
 			}
@@ -104,7 +104,7 @@ func (e *Engine) retrieveEnhanced(msg string) ([]Memory, error) {
 				defer wg.Done()
 				ctx, cancel := withEmbeddingTimeout(context.Background(), embeddingTimeoutMs)
 				defer cancel()
-				memories, err := e.searchVectorCandidates(ctx, embedder, expandedQuery, project, candidateLimit)
+				memories, err := e.searchVectorCandidates(ctx, embedder, expandedQuery, project, candidateLimit, visible)
 				if err != nil {
 					return
 				}
@@ -200,7 +200,7 @@ func (e *Engine) finalizeClassicResults(base []Memory) []Memory {
 	return results
 }
 
-func (e *Engine) searchVectorCandidates(ctx context.Context, embedder Embedder, query, project string, limit int) ([]Memory, error) {
+func (e *Engine) searchVectorCandidates(ctx context.Context, embedder Embedder, query, project string, limit int, visible []string) ([]Memory, error) {
 	if embedder == nil {
 		return nil, nil
 	}
@@ -213,10 +213,19 @@ func (e *Engine) searchVectorCandidates(ctx context.Context, embedder Embedder,
 		return nil, fmt.Errorf("search vector embed query: %w", err)
 	}
 
-	rows, err := e.queryVectorRows(project)
+	if limit <= 0 {
+		limit = 40
+	}
+	rows, indexed, err := e.indexedVectorRows(queryVector, project, visible, limit)
 	if err != nil {
 		return nil, err
 	}
+	if !indexed {
+		rows, err = e.queryVectorRows(project, visible)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	type vectorCandidate struct {
 		Memory
@@ -245,9 +254,6 @@ func (e *Engine) searchVectorCandidates(ctx context.Context, embedder Embedder,
 		return candidates[i].score > candidates[j].score
 	})
 
-	if limit <= 0 {
-		limit = 40
-	}
 	if len(candidates) > limit {
 		candidates = candidates[:limit]
 	}
@@ -264,21 +270,40 @@ type memoryWithEmbedding struct {
 	embedding []byte
 }
 
-func (e *Engine) queryVectorRows(project string) ([]memoryWithEmbedding, error) {
+func (e *Engine) queryVectorRows(project string, visible []string) ([]memoryWithEmbedding, error) {
+	return e.queryVectorRowsByID(nil, project, visible)
+}
+
+// queryVectorRowsByID is queryVectorRows limited to ids, or unlimited when
+// ids is nil.
+func (e *Engine) queryVectorRowsByID(ids []int64, project string, visible []string) ([]memoryWithEmbedding, error) {
+	if ids != nil && len(ids) == 0 {
+		return nil, nil
+	}
 	query := `
 		SELECT id, tier, project, topic, category, content, importance, source,
-		       created_at, updated_at, last_accessed, access_count, is_archived, embedding
+		       created_at, updated_at, last_accessed, access_count, is_archived, scope, embedding
 		FROM memories
 		WHERE tier = 2
 		  AND is_archived = 0
 		  AND embedding IS NOT NULL
 		  AND embedding_dim > 0
+		  AND embedding_stale = 0
 	`
-	args := make([]any, 0, 1)
+	args := make([]any, 0, 1+len(ids))
+	if ids != nil {
+		query += ` AND id IN (` + placeholders(len(ids)) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
 	if project != "" {
 		query += ` AND (project = ? OR project = '_global')`
 		args = append(args, project)
 	}
+	cond, scopeArgs := scopeCondition("scope", visible)
+	query += cond
+	args = append(args, scopeArgs...)
 
 	rows, err := e.db.Query(query, args...)
 	if err != nil {
@@ -305,6 +330,7 @@ func (e *Engine) queryVectorRows(project string) ([]memoryWithEmbedding, error)
 			&m.LastAccessed,
 			&m.AccessCount,
 			&archived,
+			&m.Scope,
 			&embedding,
 		); err != nil {
 			return nil, fmt.Errorf("scan vector row: %w", err)
@@ -1,11 +1,10 @@
 package memory
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"strings"
 	"time"
 
@@ -44,49 +43,25 @@ type QueryExpander interface {
 }
 
 type queryExpanderClient struct {
-	apiKey     string
-	baseURL    string
-	model      string
-	maxTokens  int
-	httpClient *http.Client
+	model *memoryModel
 }
 
+// NewQueryExpander returns an expander backed by the memory model, or nil
+// when no memory model is configured.
 func NewQueryExpander(cfg *config.Config) QueryExpander {
 	if cfg == nil {
 		return nil
 	}
-
-	apiKey := cfg.Provider.APIKey
-	baseURL := cfg.Provider.BaseURL
-	if cfg.Memory.Provider != nil {
-		if strings.TrimSpace(cfg.Memory.Provider.APIKey) != "" {
-			apiKey = cfg.Memory.Provider.APIKey
-		}
-		if strings.TrimSpace(cfg.Memory.Provider.BaseURL) != "" {
-			baseURL = cfg.Memory.Provider.BaseURL
-		}
-	}
-
-	model := cfg.Agent.Model
-	if strings.TrimSpace(cfg.Memory.Model) != "" {
-		model = cfg.Memory.Model
-	}
-	maxTokens := cfg.Agent.MaxTokens
-	if cfg.Memory.MaxTokens > 0 {
-		maxTokens = cfg.Memory.MaxTokens
-	}
-
-	if strings.TrimSpace(apiKey) == "" || strings.TrimSpace(baseURL) == "" || strings.TrimSpace(model) == "" {
+	m := newMemoryModel(cfg, 20*time.Second, 0.2)
+	if m.err != nil {
 		return nil
 	}
+	return &queryExpanderClient{model: m}
+}
 
-	return &queryExpanderClient{
-		apiKey:     apiKey,
-		baseURL:    strings.TrimRight(strings.TrimSpace(baseURL), "/"),
-		model:      model,
-		maxTokens:  maxTokens,
-		httpClient: &http.Client{Timeout: 20 * time.Second},
-	}
+// Usage reports the tokens query expansion used.
+func (c *queryExpanderClient) Usage() ModelUsage {
+	return c.model.Usage()
 }
 
 func (c *queryExpanderClient) Expand(query string) (*QueryExpansion, error) {
@@ -95,61 +70,17 @@ func (c *queryExpanderClient) Expand(query string) (*QueryExpansion, error) {
 		return &QueryExpansion{}, nil
 	}
 
-	body := map[string]any{
-		"model": c.model,
-		"messages": []map[string]string{{
-			"role":    "user",
-			"content": fmt.Sprintf(queryExpansionPrompt, q),
-		}},
-		"max_tokens":  c.maxTokens,
-		"temperature": 0.2,
-		"response_format": map[string]string{
-			"type": "json_object",
-		},
-	}
-	payload, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshal expansion request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(payload))
-	if err != nil {
-		return nil, fmt.Errorf("create expansion request: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("send expansion request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read expansion response: %w", err)
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("query expander http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
-	}
-
-	var decoded struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(respBody, &decoded); err != nil {
-		return nil, fmt.Errorf("decode expansion response: %w", err)
-	}
-	if len(decoded.Choices) == 0 {
-		return nil, fmt.Errorf("decode expansion response: empty choices")
-	}
-
-	result, err := parseQueryExpansionPayload(decoded.Choices[0].Message.Content)
+	var result *QueryExpansion
+	err := c.model.completeJSON(context.Background(), fmt.Sprintf(queryExpansionPrompt, q), queryExpansionTool, func(payload string) error {
+		parsed, err := parseQueryExpansionPayload(payload)
+		if err != nil {
+			return fmt.Errorf("parse expansion payload: %w", err)
+		}
+		result = parsed
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parse expansion payload: %w", err)
+		return nil, err
 	}
 	return result, nil
 }
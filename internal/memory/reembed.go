@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// ReembedProgress reports the embedding job: how many of the memories that
+// lacked a current embedding when it started have been embedded, and how
+// many the embedder rejected and were skipped.
+type ReembedProgress struct {
+	Running bool   `json:"running"`
+	Done    int    `json:"done"`
+	Failed  int    `json:"failed,omitempty"`
+	Total   int    `json:"total"`
+	Error   string `json:"error,omitempty"`
+}
+
+type reembedState struct {
+	mu       sync.Mutex
+	progress ReembedProgress
+}
+
+// MarkStaleEmbeddings compares stored tier-2 embeddings with the configured
+// embedding model and, when dimension > 0, dimension. Embeddings that differ
+// are marked stale: vector search leaves them out, since they are not
+// comparable with new query vectors, and BackfillEmbeddings replaces them.
+// Embeddings that match again, e.g. after switching the model back, are
+// unmarked. It returns the number of active stale embeddings.
+func (e *Engine) MarkStaleEmbeddings(dimension int) (int, error) {
+	_, model, _ := e.embeddingSnapshot()
+	if model == "" {
+		return 0, nil
+	}
+
+	e.mu.Lock()
+	_, err := e.db.Exec(`
+		UPDATE memories
+		SET embedding_stale = CASE WHEN embedding_model != ? OR (? > 0 AND embedding_dim != ?) THEN 1 ELSE 0 END
+		WHERE tier = 2
+		  AND embedding IS NOT NULL
+		  AND embedding_dim > 0
+	`, model, dimension, dimension)
+	e.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("mark stale embeddings: %w", err)
+	}
+
+	rows, err := e.db.Query(`SELECT id FROM memories WHERE tier = 2 AND is_archived = 0 AND embedding_stale = 1`)
+	if err != nil {
+		return 0, fmt.Errorf("query stale embeddings: %w", err)
+	}
+	defer rows.Close()
+	stale := 0
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return stale, fmt.Errorf("scan stale embedding: %w", err)
+		}
+		e.unindexVector(id)
+		stale++
+	}
+	if err := rows.Err(); err != nil {
+		return stale, fmt.Errorf("iterate stale embeddings: %w", err)
+	}
+	return stale, nil
+}
+
+// PendingEmbeddings counts the active tier-2 memories whose embedding is
+// missing or stale.
+func (e *Engine) PendingEmbeddings() (int, error) {
+	var n int
+	if err := e.db.QueryRow(`
+		SELECT COUNT(1)
+		FROM memories
+		WHERE tier = 2
+		  AND is_archived = 0
+		  AND TRIM(content) != ''
+		  AND (embedding IS NULL OR embedding_dim = 0 OR embedding_stale = 1)
+	`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count pending embeddings: %w", err)
+	}
+	return n, nil
+}
+
+// Reembed embeds every memory whose embedding is missing or stale, in
+// batches, calling progress after each batch when it is not nil. Embeddings
+// are saved batch by batch, so an interrupted run resumes where it stopped.
+// Memories the embedder rejects are skipped and counted in Failed; the
+// returned error is set only when the run stops early.
+func (e *Engine) Reembed(ctx context.Context, batchSize int, progress func(ReembedProgress)) (int, error) {
+	if embedder, _, _ := e.embeddingSnapshot(); embedder == nil {
+		return 0, fmt.Errorf("reembed: embeddings are not enabled")
+	}
+	total, err := e.PendingEmbeddings()
+	if err != nil {
+		return 0, fmt.Errorf("reembed: %w", err)
+	}
+
+	s := &e.reembed
+	s.mu.Lock()
+	if s.progress.Running {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("reembed: already running")
+	}
+	s.progress = ReembedProgress{Running: true, Total: total}
+	s.mu.Unlock()
+
+	report := func(p ReembedProgress) {
+		s.mu.Lock()
+		s.progress = p
+		s.mu.Unlock()
+		if progress != nil {
+			progress(p)
+		}
+	}
+	done, failed, err := e.embedPending(ctx, batchSize, func(done, failed int) {
+		report(ReembedProgress{Running: true, Done: done, Failed: failed, Total: max(total, done+failed)})
+	})
+	final := ReembedProgress{Done: done, Failed: failed, Total: max(total, done+failed)}
+	if err != nil {
+		final.Error = err.Error()
+	}
+	report(final)
+	return done, err
+}
+
+// ReembedStatus is the progress of the running or last embedding job.
+func (e *Engine) ReembedStatus() ReembedProgress {
+	e.reembed.mu.Lock()
+	defer e.reembed.mu.Unlock()
+	return e.reembed.progress
+}
+
+// StartReembedding runs Reembed in the background when memories are waiting
+// for an embedding, logging progress every tenth of the way. It stops with
+// ctx; the next start carries on.
+func (e *Engine) StartReembedding(ctx context.Context, batchSize int) {
+	pending, err := e.PendingEmbeddings()
+	if err != nil {
+		log.Printf("[memory] re-embedding skipped: %v", err)
+		return
+	}
+	if embedder, _, _ := e.embeddingSnapshot(); embedder == nil || pending == 0 {
+		return
+	}
+	_, model, _ := e.embeddingSnapshot()
+	go func() {
+		log.Printf("[memory] embedding %d memories with %s", pending, strings.TrimSpace(model))
+		tenth := 0
+		done, err := e.Reembed(ctx, batchSize, func(p ReembedProgress) {
+			if p.Running && p.Total > 0 && (p.Done+p.Failed)*10/p.Total > tenth {
+				tenth = (p.Done + p.Failed) * 10 / p.Total
+				log.Printf("[memory] re-embedding: %d/%d", p.Done, p.Total)
+			}
+		})
+		if err != nil {
+			log.Printf("[memory] re-embedding stopped after %d memories: %v", done, err)
+			return
+		}
+		if failed := e.ReembedStatus().Failed; failed > 0 {
+			log.Printf("[memory] re-embedding done: %d memories, %d skipped (rejected by the embedder)", done, failed)
+			return
+		}
+		log.Printf("[memory] re-embedding done: %d memories", done)
+	}()
+}
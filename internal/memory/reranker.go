@@ -50,6 +50,7 @@ type rerankerClient struct {
 	model      string
 	topN       int
 	httpClient *http.Client
+	llm        *memoryModel // LLM fallback
 }
 
 type rerankRequest struct {
@@ -108,6 +109,7 @@ func NewReranker(cfg *config.Config) Reranker {
 	if client.provider == rerankProviderOllama && client.baseURL == "" {
 		client.baseURL = defaultOllamaRerankBaseURL
 	}
+	client.llm = newMemoryModel(cfg, client.httpClient.Timeout, 0)
 
 	return client
 }
@@ -233,14 +235,11 @@ func (c *rerankerClient) rerankWithAPI(ctx context.Context, query string, docs [
 	return scores, nil
 }
 
+// rerankWithLLM scores docs with the memory chat model when the rerank
+// endpoint is missing or fails.
 func (c *rerankerClient) rerankWithLLM(ctx context.Context, query string, docs []string) ([]RerankScore, error) {
-	if strings.TrimSpace(c.model) == "" {
-		return nil, fmt.Errorf("missing rerank model")
-	}
-
-	baseURL, err := c.resolveBaseURL()
-	if err != nil {
-		return nil, fmt.Errorf("resolve base url: %w", err)
+	if c.llm == nil {
+		return nil, fmt.Errorf("missing memory model")
 	}
 
 	prompt, err := buildRerankFallbackPrompt(query, docs)
@@ -248,70 +247,27 @@ func (c *rerankerClient) rerankWithLLM(ctx context.Context, query string, docs [
 		return nil, fmt.Errorf("build fallback prompt: %w", err)
 	}
 
-	body := map[string]any{
-		"model": c.model,
-		"messages": []map[string]string{{
-			"role":    "user",
-			"content": prompt,
-		}},
-		"temperature": 0,
-		"max_tokens":  1024,
-		"response_format": map[string]string{
-			"type": "json_object",
-		},
-	}
-	payload, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/chat/completions", bytes.NewReader(payload))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if strings.TrimSpace(c.apiKey) != "" {
-		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(c.apiKey))
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
+	var scores []RerankScore
+	err = c.llm.completeJSON(ctx, prompt, rerankTool, func(payload string) error {
+		parsed, err := parseLLMRerankContent(payload)
+		if err != nil {
+			return fmt.Errorf("parse llm rerank content: %w", err)
+		}
+		scores = parsed
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("llm rerank http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
-	}
-
-	var decoded struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(respBody, &decoded); err != nil {
-		return nil, fmt.Errorf("decode llm response: %w", err)
-	}
-	if len(decoded.Choices) == 0 {
-		return nil, fmt.Errorf("empty choices in llm rerank response")
-	}
-
-	content := strings.TrimSpace(decoded.Choices[0].Message.Content)
-	if content == "" {
-		return nil, fmt.Errorf("empty content in llm rerank response")
+		return nil, err
 	}
+	return scores, nil
+}
 
-	scores, err := parseLLMRerankContent(content)
-	if err != nil {
-		return nil, fmt.Errorf("parse llm rerank content: %w", err)
+// Usage reports the tokens the LLM rerank fallback used.
+func (c *rerankerClient) Usage() ModelUsage {
+	if c.llm == nil {
+		return ModelUsage{}
 	}
-	return scores, nil
+	return c.llm.Usage()
 }
 
 func (c *rerankerClient) resolveBaseURL() (string, error) {
@@ -102,21 +102,15 @@ func TestRerankerLLMFallback(t *testing.T) {
 			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 				t.Fatalf("decode llm request: %v", err)
 			}
-			if body["model"] != "rerank-test-model" {
-				t.Fatalf("llm model = %v", body["model"])
+			if body["model"] != "memory-chat-model" {
+				t.Errorf("llm model = %v", body["model"])
 			}
-			responseFormat, ok := body["response_format"].(map[string]any)
-			if !ok || responseFormat["type"] != "json_object" {
-				t.Fatalf("unexpected response_format: %#v", body["response_format"])
+			if !strings.Contains(mustJSON(t, body["tools"]), `"name":"save_rerank_scores"`) {
+				t.Errorf("unexpected tools: %#v", body["tools"])
 			}
 
-			_ = json.NewEncoder(w).Encode(map[string]any{
-				"choices": []map[string]any{{
-					"message": map[string]any{
-						"content": `{"scores":[{"index":1,"score":7.0},{"index":0,"score":3.0},{"index":2,"score":5.0}]}`,
-					},
-				}},
-			})
+			writeJSON(w, openAIToolCallResponse(rerankTool.Name,
+				`{"scores":[{"index":1,"score":7.0},{"index":0,"score":3.0},{"index":2,"score":5.0}]}`))
 		default:
 			t.Fatalf("unexpected path = %s", r.URL.Path)
 		}
@@ -134,6 +128,9 @@ func TestRerankerLLMFallback(t *testing.T) {
 	if !llmCalled {
 		t.Fatal("expected LLM fallback call")
 	}
+	if usage := r.(UsageReporter).Usage(); usage.Requests != 1 || usage.TotalTokens != 120 {
+		t.Fatalf("usage = %+v", usage)
+	}
 
 	assertRerankScores(t, scores, []RerankScore{
 		{Index: 0, Score: 0},
@@ -148,13 +145,7 @@ func TestRerankerMalformedResponse(t *testing.T) {
 		case "/v1/rerank":
 			_, _ = fmt.Fprint(w, `{"results":`)
 		case "/chat/completions":
-			_ = json.NewEncoder(w).Encode(map[string]any{
-				"choices": []map[string]any{{
-					"message": map[string]any{
-						"content": `{"scores":[{"index":0,"score":1.0,"extra":true}]}`,
-					},
-				}},
-			})
+			writeJSON(w, openAIToolCallResponse(rerankTool.Name, `{"scores":[{"index":0,"score":1.0,"extra":true}]}`))
 		default:
 			t.Fatalf("unexpected path = %s", r.URL.Path)
 		}
@@ -183,6 +174,11 @@ func newRerankerTestConfig(baseURL string) *config.Config {
 	cfg.Memory.Rerank.Model = "rerank-test-model"
 	cfg.Memory.Rerank.TopN = 2
 	cfg.Memory.Rerank.TimeoutMs = 1000
+	// The LLM fallback uses the memory chat model.
+	cfg.Provider.Type = "openai"
+	cfg.Provider.APIKey = "test-chat-key"
+	cfg.Provider.BaseURL = baseURL
+	cfg.Agent.Model = "memory-chat-model"
 	return cfg
 }
 
@@ -18,12 +18,37 @@ var (
 	enWordRegex    = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9_\-]{2,}`)
 )
 
+// cnStopWords are question words, pronouns and particles cut out of Chinese
+// queries before keyword extraction, so "我之前的部署流程是什么" looks for
+// "部署流程" rather than the whole sentence. Longer words come first.
+var cnStopWords = strings.NewReplacer(
+	"为什么", " ", "是不是", " ", "有没有", " ", "你记得", " ", "怎么样", " ",
+	"什么", " ", "怎么", " ", "哪里", " ", "哪个", " ", "如何", " ",
+	"之前", " ", "以前", " ", "上次", " ", "记得", " ", "我们", " ", "你们", " ", "他们", " ",
+	"这个", " ", "那个", " ", "一下", " ", "的话", " ", "还是", " ", "就是", " ", "可以", " ",
+	"的", " ", "了", " ", "吗", " ", "呢", " ", "吧", " ", "啊", " ", "呀", " ",
+	"我", " ", "你", " ", "他", " ", "她", " ", "它", " ", "是", " ",
+	"和", " ", "与", " ", "及", " ", "或", " ", "请", " ", "帮", " ",
+)
+
 type scoredFTSMatch struct {
 	Memory Memory
 	Score  float64
 }
 
 func shouldRetrieve(msg string) bool {
+	return retrievable(msg) && matchesTrigger(msg, builtinRetrievalTriggers)
+}
+
+// ShouldRetrieve reports whether msg contains one of the built-in trigger
+// phrases; RetrievalGate applies the configured trigger instead.
+func ShouldRetrieve(msg string) bool {
+	return shouldRetrieve(msg)
+}
+
+// retrievable rules out messages that never recall memories: very short
+// ones, code and bare acknowledgements.
+func retrievable(msg string) bool {
 	trimmed := strings.TrimSpace(msg)
 	if len(trimmed) < 5 {
 		return false
@@ -39,22 +64,7 @@ func shouldRetrieve(msg string) bool {
 			return false
 		}
 	}
-
-	triggers := []string{
-		"我的", "我之前", "你记得", "上次", "之前", "昨天",
-		"什么", "怎么", "为什么", "?", "？", "喜欢", "设置", "配置", "密码",
-	}
-	for _, t := range triggers {
-		if strings.Contains(trimmed, t) {
-			return true
-		}
-	}
-
-	return false
-}
-
-func ShouldRetrieve(msg string) bool {
-	return shouldRetrieve(msg)
+	return true
 }
 
 func isMainlyCode(msg string) bool {
@@ -81,7 +91,7 @@ func extractKeywords(msg string) []string {
 	keywords := make([]string, 0)
 	seen := map[string]struct{}{}
 
-	for _, w := range cnWordRegex.FindAllString(msg, -1) {
+	for _, w := range cnWordRegex.FindAllString(cnStopWords.Replace(msg), -1) {
 		if _, ok := seen[w]; ok {
 			continue
 		}
@@ -133,22 +143,29 @@ func relevanceScore(mem Memory, daysSinceAccess float64) float64 {
 	}
 }
 
+// Retrieve recalls the memories relevant to msg from every scope.
 func (e *Engine) Retrieve(msg string) ([]Memory, error) {
+	return e.RetrieveFor(msg, nil)
+}
+
+// RetrieveFor recalls the memories relevant to msg from the visible scopes;
+// nil visible means every scope.
+func (e *Engine) RetrieveFor(msg string, visible []string) ([]Memory, error) {
 	retrievalCfg := e.retrievalConfigSnapshot()
 	if retrievalCfg.Mode == config.MemoryRetrievalModeEnhanced {
-		results, err := e.retrieveEnhanced(msg)
+		results, err := e.retrieveEnhanced(msg, visible)
 		if err == nil {
 			return results, nil
 		}
 	}
-	return e.retrieveClassic(msg)
+	return e.retrieveClassic(msg, visible)
 }
 
-func (e *Engine) retrieveClassic(msg string) ([]Memory, error) {
+func (e *Engine) retrieveClassic(msg string, visible []string) ([]Memory, error) {
 	keywords := sanitizeFTSTokens(extractKeywords(msg))
 	project := matchProject(msg, e.knownProjectsSnapshot())
 
-	base, err := e.queryRetrieveBase(project)
+	base, err := e.queryRetrieveBase(project, visible)
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +179,7 @@ func (e *Engine) retrieveClassic(msg string) ([]Memory, error) {
 
 	if len(results) < 5 && len(keywords) > 0 {
 		retrievalCfg := e.retrievalConfigSnapshot()
-		stage1Matches, stage1Err := e.searchFTSScored(keywords, 10)
+		stage1Matches, stage1Err := e.searchFTSScored(keywords, 10, visible)
 		if stage1Err == nil && isStrongSignalMatch(stage1Matches, retrievalCfg) {
 			results = appendUniqueScoredMatches(results, seen, stage1Matches)
 		} else {
@@ -173,7 +190,7 @@ func (e *Engine) retrieveClassic(msg string) ([]Memory, error) {
 				}
 			}
 
-			extraMatches, err := e.searchFTSScored(expandedTokens, 10)
+			extraMatches, err := e.searchFTSScored(expandedTokens, 10, visible)
 			if err == nil {
 				results = appendUniqueScoredMatches(results, seen, extraMatches)
 			}
@@ -240,7 +257,7 @@ func mergeUniqueTokens(base, extra []string) []string {
 	return merged
 }
 
-func (e *Engine) searchFTSScored(tokens []string, limit int) ([]scoredFTSMatch, error) {
+func (e *Engine) searchFTSScored(tokens []string, limit int, visible []string) ([]scoredFTSMatch, error) {
 	if limit <= 0 {
 		limit = 10
 	}
@@ -248,19 +265,20 @@ func (e *Engine) searchFTSScored(tokens []string, limit int) ([]scoredFTSMatch,
 	if matchQuery == "" {
 		return nil, nil
 	}
+	cond, scopeArgs := scopeCondition("m.scope", visible)
 
 	rows, err := e.db.Query(`
 		SELECT m.id, m.tier, m.project, m.topic, m.category, m.content, m.importance, m.source,
-		       m.created_at, m.updated_at, m.last_accessed, m.access_count, m.is_archived,
+		       m.created_at, m.updated_at, m.last_accessed, m.access_count, m.is_archived, m.scope,
 		       bm25(memories_fts) AS bm25_score
 		FROM memories m
 		JOIN memories_fts f ON m.id = f.rowid
 		WHERE memories_fts MATCH ?
 		  AND m.tier = 2
-		  AND m.is_archived = 0
+		  AND m.is_archived = 0`+cond+`
 		ORDER BY bm25(memories_fts), m.importance DESC
 		LIMIT ?
-	`, matchQuery, limit)
+	`, append(append([]any{matchQuery}, scopeArgs...), limit)...)
 	if err != nil {
 		return nil, fmt.Errorf("search fts scored: %w", err)
 	}
@@ -299,6 +317,7 @@ func scanScoredFTSMatch(rows *sql.Rows) (scoredFTSMatch, error) {
 		&m.LastAccessed,
 		&m.AccessCount,
 		&archived,
+		&m.Scope,
 		&score,
 	); err != nil {
 		return scoredFTSMatch{}, fmt.Errorf("scan scored fts match: %w", err)
@@ -377,10 +396,10 @@ func appendUniqueScoredMatches(results []Memory, seen map[int64]struct{}, matche
 	return results
 }
 
-func (e *Engine) queryRetrieveBase(project string) ([]Memory, error) {
+func (e *Engine) queryRetrieveBase(project string, visible []string) ([]Memory, error) {
 	query := `
 		SELECT id, tier, project, topic, category, content, importance, source,
-		       created_at, updated_at, last_accessed, access_count, is_archived
+		       created_at, updated_at, last_accessed, access_count, is_archived, scope
 		FROM memories
 		WHERE tier = 2 AND is_archived = 0
 	`
@@ -389,7 +408,9 @@ func (e *Engine) queryRetrieveBase(project string) ([]Memory, error) {
 		query += ` AND (project = ? OR project = '_global')`
 		args = append(args, project)
 	}
-	query += ` ORDER BY importance DESC LIMIT 20`
+	cond, scopeArgs := scopeCondition("scope", visible)
+	query += cond + ` ORDER BY importance DESC LIMIT 20`
+	args = append(args, scopeArgs...)
 
 	rows, err := e.db.Query(query, args...)
 	if err != nil {
@@ -420,6 +441,27 @@ func FormatMemories(memories []Memory) string {
 	return formatMemories(memories)
 }
 
+const (
+	memoryPromptHeader = "[Relevant Memory]\n"
+	// memoryPromptMarker separates recalled memories from the user's text in
+	// the prompt the agent sees (and persists).
+	memoryPromptMarker = "\n\n[User Message]\n"
+)
+
+// WithMemoryContext prefixes a user message with recalled memories.
+func WithMemoryContext(memoryContext, content string) string {
+	return memoryPromptHeader + memoryContext + memoryPromptMarker + content
+}
+
+// StripMemoryContext returns the user's text of a prompt built by
+// WithMemoryContext, or prompt unchanged.
+func StripMemoryContext(prompt string) string {
+	if _, text, ok := strings.Cut(prompt, memoryPromptMarker); ok && strings.HasPrefix(prompt, memoryPromptHeader) {
+		return text
+	}
+	return prompt
+}
+
 func daysSince(lastAccessed string, now time.Time) float64 {
 	if strings.TrimSpace(lastAccessed) == "" {
 		return 365
@@ -56,8 +56,15 @@ func buildFTSMatchQuery(tokens []string) string {
 		return ""
 	}
 	quoted := make([]string, 0, len(safe))
+	seen := make(map[string]struct{}, len(safe))
 	for _, token := range safe {
-		quoted = append(quoted, `"`+token+`"`)
+		for _, term := range ftsQueryTerms(token) {
+			if _, ok := seen[term]; ok {
+				continue
+			}
+			seen[term] = struct{}{}
+			quoted = append(quoted, `"`+term+`"`)
+		}
 	}
 	return strings.Join(quoted, " OR ")
 }
@@ -3,6 +3,7 @@ package memory
 import (
 	"math"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -152,3 +153,82 @@ func BenchmarkRetrieve(b *testing.B) {
 		}
 	}
 }
+
+func TestFTSTextSplitsCJK(t *testing.T) {
+	got := strings.Fields(ftsText("生产环境部署用Docker compose，猫"))
+	want := []string{"生产", "产环", "环境", "境部", "部署", "署用", "Docker", "compose，", "猫"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("ftsText fields = %q, want %q", got, want)
+	}
+	if got := buildFTSMatchQuery([]string{"部署流程", "docker部署"}); got != `"部署" OR "署流" OR "流程" OR "docker"` {
+		t.Fatalf("buildFTSMatchQuery = %s", got)
+	}
+	if got := extractKeywords("我之前的部署流程是什么？"); strings.Join(got, "|") != "部署流程" {
+		t.Fatalf("extractKeywords = %q, want [部署流程]", got)
+	}
+}
+
+func TestRetrieveMixedChineseEnglish(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	facts := []string{
+		"生产环境的部署流程需要两人审批",
+		"myclaw 的数据库从 MySQL 迁移到了 SQLite",
+		"用户喜欢吃辣的牛肉面",
+		"the gateway runs behind nginx on port 8443",
+		"周报每周五下午提交给 Alice",
+		"Redis 缓存过期时间设置为 10 分钟",
+	}
+	ids := make(map[string]int64, len(facts))
+	for _, content := range facts {
+		id, err := e.AddTier2(FactEntry{Content: content, Project: "ops", Topic: "misc", Category: "decision", Importance: 0.5})
+		if err != nil {
+			t.Fatalf("AddTier2 error: %v", err)
+		}
+		ids[content] = id
+	}
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"我之前说的部署流程是什么？", facts[0]},
+		{"数据库迁移到哪里了", facts[1]},
+		{"我喜欢吃什么面", facts[2]},
+		{"which port does nginx use", facts[3]},
+		{"周报什么时候交", facts[4]},
+		{"redis 缓存多久过期", facts[5]},
+	}
+	for _, tc := range cases {
+		matches, err := e.searchFTSScored(sanitizeFTSTokens(extractKeywords(tc.query)), 3, nil)
+		if err != nil {
+			t.Fatalf("searchFTSScored(%q) error: %v", tc.query, err)
+		}
+		if len(matches) == 0 || matches[0].Memory.ID != ids[tc.want] {
+			got := make([]string, 0, len(matches))
+			for _, m := range matches {
+				got = append(got, m.Memory.Content)
+			}
+			t.Errorf("query %q ranked %q first, want %q", tc.query, got, tc.want)
+		}
+	}
+
+	// Keyword search in the CLI and edits go through the same index.
+	listed, err := e.ListMemories(MemoryFilter{Query: "审批"})
+	if err != nil || len(listed) != 1 || listed[0].ID != ids[facts[0]] {
+		t.Fatalf("ListMemories(审批) = %+v, %v", listed, err)
+	}
+	if _, err := e.UpdateMemory(ids[facts[0]], FactEntry{Content: "测试环境的发布不需要审批"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := e.SearchFTS("部署流程", 10); len(got) != 0 {
+		t.Fatalf("old content still indexed: %+v", got)
+	}
+	if got, _ := e.SearchFTS("发布", 10); len(got) != 1 {
+		t.Fatalf("edited content not indexed: %+v", got)
+	}
+}
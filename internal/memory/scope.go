@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"strings"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+// SharedScope is the scope of memories every conversation may see. Rows
+// written before scoping existed belong to it.
+const SharedScope = "shared"
+
+// UserScope is the scope of one person's memories on a channel.
+func UserScope(channel, senderID string) string {
+	return "user:" + channel + ":" + senderID
+}
+
+// ChatScope is the scope of memories belonging to one chat, shared by
+// everyone in it.
+func ChatScope(channel, chatID string) string {
+	return "chat:" + channel + ":" + chatID
+}
+
+// Scopes says where a conversation's new memories go and which memories it
+// may recall.
+type Scopes struct {
+	Write   string
+	Visible []string // nil means every scope
+}
+
+// ScopesFor returns the scopes of a message from senderID in chatID on
+// channel under the memory.scope mode: "user" keeps memories per person,
+// "chat" per chat, and "shared" puts everything in one pool as before
+// scoping. Messages without a sender or chat fall back to the shared pool.
+func ScopesFor(mode, channel, chatID, senderID string) Scopes {
+	user, chat := SharedScope, SharedScope
+	if senderID != "" {
+		user = UserScope(channel, senderID)
+	}
+	if chatID != "" {
+		chat = ChatScope(channel, chatID)
+	}
+	switch mode {
+	case config.MemoryScopeShared:
+		return Scopes{Write: SharedScope}
+	case config.MemoryScopeChat:
+		return Scopes{Write: chat, Visible: uniqueScopes(SharedScope, chat, user)}
+	default:
+		return Scopes{Write: user, Visible: uniqueScopes(SharedScope, user, chat)}
+	}
+}
+
+// InGroup leaves the sender's own scope out of what a group chat recalls.
+// Every member shares the group's session, so the sender's profile and
+// memories (credentials included) would otherwise stay in front of the next
+// member. New memories still go to Write.
+func (s Scopes) InGroup(userScope string) Scopes {
+	if s.Visible == nil {
+		return s
+	}
+	visible := make([]string, 0, len(s.Visible))
+	for _, v := range s.Visible {
+		if v != userScope {
+			visible = append(visible, v)
+		}
+	}
+	return Scopes{Write: s.Write, Visible: visible}
+}
+
+// Allows reports whether a memory in scope may be recalled.
+func (s Scopes) Allows(scope string) bool {
+	if s.Visible == nil {
+		return true
+	}
+	for _, v := range s.Visible {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type scopesKey struct{}
+
+// WithScopes attaches the conversation's scopes to ctx for the memory tools.
+func WithScopes(ctx context.Context, s Scopes) context.Context {
+	return context.WithValue(ctx, scopesKey{}, s)
+}
+
+// ScopesFromContext returns the scopes set by WithScopes, or unrestricted
+// shared scopes when there are none.
+func ScopesFromContext(ctx context.Context) Scopes {
+	if s, ok := ctx.Value(scopesKey{}).(Scopes); ok {
+		return s
+	}
+	return Scopes{Write: SharedScope}
+}
+
+func normalizeScope(scope string) string {
+	if scope = strings.TrimSpace(scope); scope == "" {
+		return SharedScope
+	}
+	return scope
+}
+
+func uniqueScopes(scopes ...string) []string {
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		dup := false
+		for _, o := range out {
+			dup = dup || o == s
+		}
+		if !dup {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// scopeCondition restricts column to visible; nil visible adds nothing.
+func scopeCondition(column string, visible []string) (string, []any) {
+	if visible == nil {
+		return "", nil
+	}
+	if len(visible) == 0 {
+		return " AND 0", nil
+	}
+	args := make([]any, len(visible))
+	for i, s := range visible {
+		args[i] = s
+	}
+	return " AND " + column + " IN (" + placeholders(len(visible)) + ")", args
+}
@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+func TestScopesFor(t *testing.T) {
+	tests := []struct {
+		mode, chatID, senderID string
+		want                   Scopes
+	}{
+		{config.MemoryScopeUser, "c1", "u1", Scopes{Write: "user:telegram:u1", Visible: []string{SharedScope, "user:telegram:u1", "chat:telegram:c1"}}},
+		{"", "c1", "u1", Scopes{Write: "user:telegram:u1", Visible: []string{SharedScope, "user:telegram:u1", "chat:telegram:c1"}}},
+		{config.MemoryScopeChat, "c1", "u1", Scopes{Write: "chat:telegram:c1", Visible: []string{SharedScope, "chat:telegram:c1", "user:telegram:u1"}}},
+		{config.MemoryScopeUser, "", "", Scopes{Write: SharedScope, Visible: []string{SharedScope}}},
+		{config.MemoryScopeChat, "c1", "", Scopes{Write: "chat:telegram:c1", Visible: []string{SharedScope, "chat:telegram:c1"}}},
+		{config.MemoryScopeShared, "c1", "u1", Scopes{Write: SharedScope}},
+	}
+	for _, tt := range tests {
+		if got := ScopesFor(tt.mode, "telegram", tt.chatID, tt.senderID); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ScopesFor(%q, %q, %q) = %+v, want %+v", tt.mode, tt.chatID, tt.senderID, got, tt.want)
+		}
+	}
+
+	if s := ScopesFromContext(context.Background()); s.Write != SharedScope || s.Visible != nil {
+		t.Fatalf("default scopes = %+v", s)
+	}
+	alice := ScopesFor(config.MemoryScopeUser, "telegram", "c1", "alice")
+	if got := ScopesFromContext(WithScopes(context.Background(), alice)); !reflect.DeepEqual(got, alice) {
+		t.Fatalf("ScopesFromContext = %+v", got)
+	}
+	if !alice.Allows(SharedScope) || alice.Allows(UserScope("telegram", "bob")) {
+		t.Fatalf("alice scopes allow the wrong memories: %+v", alice)
+	}
+}
+
+func TestScopedRetrievalAndProfile(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	alice := ScopesFor(config.MemoryScopeUser, "telegram", "c1", "alice")
+	bob := ScopesFor(config.MemoryScopeUser, "telegram", "c1", "bob")
+	for _, f := range []FactEntry{
+		{Content: "Alice's favourite colour is green", Topic: "colour", Category: "preference", Importance: 0.8, Scope: alice.Write},
+		{Content: "Bob's favourite colour is red", Topic: "colour", Category: "preference", Importance: 0.8, Scope: bob.Write},
+		{Content: "The office colour scheme is blue", Topic: "colour", Category: "fact", Importance: 0.8},
+	} {
+		if err := e.WriteTier2(f); err != nil {
+			t.Fatalf("WriteTier2 error: %v", err)
+		}
+	}
+	if err := e.WriteTier1(ProfileEntry{Content: "Alice is a vet", Category: "identity", Scope: alice.Write}); err != nil {
+		t.Fatalf("WriteTier1 error: %v", err)
+	}
+
+	got, err := e.RetrieveFor("favourite colour", alice.Visible)
+	if err != nil {
+		t.Fatalf("RetrieveFor error: %v", err)
+	}
+	var contents []string
+	for _, m := range got {
+		contents = append(contents, m.Content)
+		if !alice.Allows(m.Scope) {
+			t.Fatalf("retrieved memory from scope %q for alice", m.Scope)
+		}
+	}
+	if joined := strings.Join(contents, "|"); !strings.Contains(joined, "green") || strings.Contains(joined, "red") {
+		t.Fatalf("alice retrieved %q", joined)
+	}
+	if all, _ := e.Retrieve("favourite colour"); len(all) != 3 {
+		t.Fatalf("unscoped Retrieve returned %d memories, want 3", len(all))
+	}
+
+	if profile, _ := e.LoadProfile(bob.Visible); strings.Contains(profile, "vet") {
+		t.Fatalf("bob sees alice's profile: %q", profile)
+	}
+	if profile, _ := e.LoadProfile(alice.Visible); !strings.Contains(profile, "vet") {
+		t.Fatalf("alice profile = %q", profile)
+	}
+
+	listed, err := e.ListMemories(MemoryFilter{Scopes: []string{SharedScope}})
+	if err != nil || len(listed) != 1 || listed[0].Scope != SharedScope {
+		t.Fatalf("ListMemories shared = %+v, %v", listed, err)
+	}
+}
+
+func TestExtractionKeepsScopesApart(t *testing.T) {
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	defer e.Close()
+
+	var mu sync.Mutex
+	var conversations []string
+	svc := NewExtractionService(e, &mockLLM{extractFn: func(conversation string) (*ExtractionResult, error) {
+		mu.Lock()
+		conversations = append(conversations, conversation)
+		mu.Unlock()
+		return &ExtractionResult{Facts: []FactEntry{{Content: "from " + conversation, Topic: "chat", Category: "event", Importance: 0.5}}, Summary: "summary"}, nil
+	}}, config.ExtractionConfig{QuietGap: "1h", TokenBudget: 0.9, DailyFlush: "03:00"})
+
+	svc.BufferMessage("user:telegram:alice", "telegram", "alice", "user", "alice says hi")
+	svc.BufferMessage("user:telegram:bob", "telegram", "bob", "user", "bob says hi")
+	svc.BufferMessage("user:telegram:alice", "telegram", "alice", "user", "alice again")
+	svc.flush()
+
+	if len(conversations) != 2 {
+		t.Fatalf("expected one extraction per scope, got %d: %q", len(conversations), conversations)
+	}
+	mems, err := e.ListMemories(MemoryFilter{Scopes: []string{"user:telegram:alice"}})
+	if err != nil || len(mems) != 1 {
+		t.Fatalf("alice memories = %+v, %v", mems, err)
+	}
+	if c := mems[0].Content; !strings.Contains(c, "alice again") || strings.Contains(c, "bob") {
+		t.Fatalf("alice fact built from %q", c)
+	}
+}
+
+func TestMemoryTools_RespectContextScopes(t *testing.T) {
+	e, tools := memoryTools(t)
+	alice := WithScopes(context.Background(), ScopesFor(config.MemoryScopeUser, "telegram", "c1", "alice"))
+	bob := WithScopes(context.Background(), ScopesFor(config.MemoryScopeUser, "telegram", "c1", "bob"))
+
+	res, err := tools[SaveToolName].Execute(alice, map[string]interface{}{"content": "Alice's locker code is 4321", "topic": "locker"})
+	if err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	id := res.Data.(map[string]interface{})["id"].(int64)
+	if m, _ := e.GetMemory(id); m.Scope != "user:telegram:alice" {
+		t.Fatalf("saved scope = %q", m.Scope)
+	}
+
+	if res, _ := tools[SearchToolName].Execute(bob, map[string]interface{}{"query": "locker code"}); strings.Contains(res.Output, "4321") {
+		t.Fatalf("bob found alice's memory: %q", res.Output)
+	}
+	if res, _ := tools[SearchToolName].Execute(alice, map[string]interface{}{"query": "locker code"}); !strings.Contains(res.Output, "4321") {
+		t.Fatalf("alice search = %q", res.Output)
+	}
+	if _, err := tools[UpdateToolName].Execute(bob, map[string]interface{}{"id": float64(id), "content": "hijacked"}); err == nil {
+		t.Fatal("bob should not update alice's memory")
+	}
+	if res, _ := tools[ForgetToolName].Execute(bob, map[string]interface{}{"ids": []interface{}{float64(id)}}); !strings.Contains(res.Output, "Not found") {
+		t.Fatalf("bob forget = %q", res.Output)
+	}
+	if _, err := e.GetMemory(id); err != nil {
+		t.Fatalf("alice's memory was archived by bob: %v", err)
+	}
+}
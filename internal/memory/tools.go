@@ -0,0 +1,377 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cexll/agentsdk-go/pkg/tool"
+)
+
+// Names of the memory tools registered on the agent runtime.
+const (
+	SaveToolName   = "memory_save"
+	SearchToolName = "memory_search"
+	ForgetToolName = "memory_forget"
+	UpdateToolName = "memory_update"
+)
+
+const (
+	searchToolDefaultLimit = 10
+	searchToolMaxLimit     = 50
+)
+
+var memoryCategories = []interface{}{"identity", "config", "credential", "decision", "solution", "event", "conversation", "temp", "debug"}
+
+// NewTools returns the tools that let the agent save, search, forget and
+// update memories directly, instead of waiting for background extraction.
+func NewTools(e *Engine) []tool.Tool {
+	return []tool.Tool{
+		&saveTool{engine: e},
+		&searchTool{engine: e},
+		&forgetTool{engine: e},
+		&updateTool{engine: e},
+	}
+}
+
+type saveTool struct{ engine *Engine }
+
+func (t *saveTool) Name() string { return SaveToolName }
+
+func (t *saveTool) Description() string {
+	return `Save something to long-term memory right away, e.g. when the user says "remember that...".
+Write one self-contained fact per call, in the third person ("The user's dog is called Rex").
+Use tier 1 only for lasting facts about who the user is and how they want to be helped
+(name, language, strong preferences); they are shown in every conversation. Everything else
+is tier 2 and is recalled when relevant. Search first to avoid saving duplicates; use
+memory_update to correct an existing memory.`
+}
+
+func (t *saveTool) Schema() *tool.JSONSchema {
+	return &tool.JSONSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"content":    map[string]interface{}{"type": "string", "description": "The fact to remember."},
+			"tier":       map[string]interface{}{"type": "integer", "enum": []interface{}{1, 2}, "description": "1 = core profile, 2 = fact (default)."},
+			"project":    map[string]interface{}{"type": "string", "description": "Project the fact belongs to (tier 2). Default _global."},
+			"topic":      map[string]interface{}{"type": "string", "description": "Topic within the project (tier 2). Default _general."},
+			"category":   map[string]interface{}{"type": "string", "enum": memoryCategories},
+			"importance": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1, "description": "0-1, default 0.5 (tier 2)."},
+		},
+		Required: []string{"content"},
+	}
+}
+
+func (t *saveTool) Execute(ctx context.Context, params map[string]interface{}) (*tool.ToolResult, error) {
+	content := stringParam(params, "content")
+	if content == "" {
+		return nil, errors.New("content is required")
+	}
+	tier, _, err := intParam(params, "tier")
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := ScopesFromContext(ctx)
+	var id int64
+	switch tier {
+	case 1:
+		id, err = t.engine.AddTier1(ProfileEntry{Content: content, Category: stringParam(params, "category"), Scope: scopes.Write})
+	case 0, 2:
+		tier = 2
+		id, err = t.engine.AddTier2(FactEntry{
+			Scope:      scopes.Write,
+			Content:    content,
+			Project:    stringParam(params, "project"),
+			Topic:      stringParam(params, "topic"),
+			Category:   stringParam(params, "category"),
+			Importance: floatParam(params, "importance"),
+		})
+	default:
+		return nil, fmt.Errorf("tier must be 1 or 2, got %d", tier)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tool.ToolResult{
+		Success: true,
+		Output:  fmt.Sprintf("Saved memory #%d (tier %d).", id, tier),
+		Data:    map[string]interface{}{"id": id, "tier": tier},
+	}, nil
+}
+
+type searchTool struct{ engine *Engine }
+
+func (t *searchTool) Name() string { return SearchToolName }
+
+func (t *searchTool) Description() string {
+	return `Search long-term memory. Returns matching memories with their IDs, which
+memory_forget and memory_update take. Give a query to search by meaning and keywords,
+or a project/topic to list what is stored there. Set profile to list the core profile.`
+}
+
+func (t *searchTool) Schema() *tool.JSONSchema {
+	return &tool.JSONSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"query":   map[string]interface{}{"type": "string", "description": "What to look for."},
+			"project": map[string]interface{}{"type": "string", "description": "List tier-2 memories of this project."},
+			"topic":   map[string]interface{}{"type": "string", "description": "List tier-2 memories of this topic."},
+			"profile": map[string]interface{}{"type": "boolean", "description": "List the tier-1 core profile."},
+			"limit":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": searchToolMaxLimit},
+		},
+	}
+}
+
+func (t *searchTool) Execute(ctx context.Context, params map[string]interface{}) (*tool.ToolResult, error) {
+	limit, ok, err := intParam(params, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if !ok || limit <= 0 {
+		limit = searchToolDefaultLimit
+	}
+	limit = min(limit, searchToolMaxLimit)
+
+	query := stringParam(params, "query")
+	project, topic := stringParam(params, "project"), stringParam(params, "topic")
+	profile, _ := params["profile"].(bool)
+
+	visible := ScopesFromContext(ctx).Visible
+	var memories []Memory
+	switch {
+	case profile:
+		memories, err = t.engine.ListMemories(MemoryFilter{Tier: 1, Scopes: visible, Limit: 100})
+	case query != "":
+		memories, err = t.engine.searchForTool(query, project, topic, limit, visible)
+	case project != "" || topic != "":
+		memories, err = t.engine.ListMemories(MemoryFilter{Tier: 2, Project: project, Topic: topic, Scopes: visible, Limit: limit})
+	default:
+		return nil, errors.New("give a query, a project or topic, or set profile")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+
+	if len(memories) == 0 {
+		return &tool.ToolResult{Success: true, Output: "No matching memories.", Data: map[string]interface{}{"memories": memories}}, nil
+	}
+	var out strings.Builder
+	for _, m := range memories {
+		out.WriteString(formatToolMemory(m))
+		out.WriteByte('\n')
+	}
+	return &tool.ToolResult{
+		Success: true,
+		Output:  strings.TrimRight(out.String(), "\n"),
+		Data:    map[string]interface{}{"memories": memories},
+	}, nil
+}
+
+// searchForTool puts keyword matches first, then what Retrieve adds (semantic
+// matches with embeddings, otherwise the most important memories),
+// optionally filtered by project and topic. Only the visible scopes are
+// searched.
+func (e *Engine) searchForTool(query, project, topic string, limit int, visible []string) ([]Memory, error) {
+	keyword, err := e.ListMemories(MemoryFilter{Query: query, Tier: 2, Scopes: visible, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	retrieved, err := e.RetrieveFor(query, visible)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[int64]bool{}
+	var out []Memory
+	for _, m := range append(keyword, retrieved...) {
+		if seen[m.ID] || (project != "" && m.Project != project) || (topic != "" && m.Topic != topic) {
+			continue
+		}
+		seen[m.ID] = true
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+type forgetTool struct{ engine *Engine }
+
+func (t *forgetTool) Name() string { return ForgetToolName }
+
+func (t *forgetTool) Description() string {
+	return `Forget memories by ID, e.g. when the user says "forget my old address" or a fact
+is no longer true. Find the IDs with memory_search first. Forgotten memories are archived
+and no longer recalled.`
+}
+
+func (t *forgetTool) Schema() *tool.JSONSchema {
+	return &tool.JSONSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"ids": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}, "description": "IDs from memory_search."},
+		},
+		Required: []string{"ids"},
+	}
+}
+
+func (t *forgetTool) Execute(ctx context.Context, params map[string]interface{}) (*tool.ToolResult, error) {
+	raw, _ := params["ids"].([]interface{})
+	if len(raw) == 0 {
+		if _, ok := params["id"]; ok { // tolerate a single id
+			raw = []interface{}{params["id"]}
+		}
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("ids is required")
+	}
+
+	scopes := ScopesFromContext(ctx)
+	var forgotten, missing []string
+	for _, v := range raw {
+		id, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("ids: %w", err)
+		}
+		m, err := t.engine.GetMemory(id)
+		if errors.Is(err, ErrMemoryNotFound) || (err == nil && !scopes.Allows(m.Scope)) {
+			missing = append(missing, fmt.Sprintf("#%d", id))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := t.engine.ArchiveMemory(id); err != nil {
+			return nil, err
+		}
+		forgotten = append(forgotten, fmt.Sprintf("#%d %s", id, m.Content))
+	}
+
+	var out strings.Builder
+	if len(forgotten) > 0 {
+		out.WriteString("Forgot:\n- " + strings.Join(forgotten, "\n- "))
+	}
+	if len(missing) > 0 {
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString("Not found: " + strings.Join(missing, ", "))
+	}
+	return &tool.ToolResult{Success: len(forgotten) > 0, Output: out.String()}, nil
+}
+
+type updateTool struct{ engine *Engine }
+
+func (t *updateTool) Name() string { return UpdateToolName }
+
+func (t *updateTool) Description() string {
+	return `Correct or refine an existing memory by ID (from memory_search), e.g. when the
+user's address or a decision changed. Only the given fields change.`
+}
+
+func (t *updateTool) Schema() *tool.JSONSchema {
+	return &tool.JSONSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"id":         map[string]interface{}{"type": "integer"},
+			"content":    map[string]interface{}{"type": "string", "description": "The corrected fact."},
+			"project":    map[string]interface{}{"type": "string"},
+			"topic":      map[string]interface{}{"type": "string"},
+			"category":   map[string]interface{}{"type": "string", "enum": memoryCategories},
+			"importance": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+		},
+		Required: []string{"id"},
+	}
+}
+
+func (t *updateTool) Execute(ctx context.Context, params map[string]interface{}) (*tool.ToolResult, error) {
+	id, ok, err := intParam(params, "id")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("id is required")
+	}
+	// Memories outside the conversation's scopes do not exist for it.
+	if current, err := t.engine.GetMemory(int64(id)); err != nil {
+		return nil, err
+	} else if !ScopesFromContext(ctx).Allows(current.Scope) {
+		return nil, fmt.Errorf("memory %d: %w", id, ErrMemoryNotFound)
+	}
+	m, err := t.engine.UpdateMemory(int64(id), FactEntry{
+		Content:    stringParam(params, "content"),
+		Project:    stringParam(params, "project"),
+		Topic:      stringParam(params, "topic"),
+		Category:   stringParam(params, "category"),
+		Importance: floatParam(params, "importance"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tool.ToolResult{
+		Success: true,
+		Output:  "Updated " + formatToolMemory(m),
+		Data:    map[string]interface{}{"memory": m},
+	}, nil
+}
+
+func formatToolMemory(m Memory) string {
+	if m.Tier == 1 {
+		return fmt.Sprintf("#%d [profile, %s] %s", m.ID, m.Category, m.Content)
+	}
+	return fmt.Sprintf("#%d [%s/%s, %s, importance %.2g] %s", m.ID, m.Project, m.Topic, m.Category, m.Importance, m.Content)
+}
+
+func stringParam(params map[string]interface{}, key string) string {
+	s, _ := params[key].(string)
+	return strings.TrimSpace(s)
+}
+
+func floatParam(params map[string]interface{}, key string) float64 {
+	switch v := params[key].(type) {
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f
+	}
+	return 0
+}
+
+// intParam reads an integer that models send as a JSON number or a string.
+func intParam(params map[string]interface{}, key string) (int, bool, error) {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", key, err)
+	}
+	return int(n), true, nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case float64:
+		if n != float64(int64(n)) {
+			return 0, fmt.Errorf("%v is not an integer", n)
+		}
+		return int64(n), nil
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(n), "#"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", n)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("unexpected %T", v)
+}
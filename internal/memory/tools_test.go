@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cexll/agentsdk-go/pkg/tool"
+)
+
+func memoryTools(t *testing.T) (*Engine, map[string]tool.Tool) {
+	t.Helper()
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	tools := map[string]tool.Tool{}
+	for _, tl := range NewTools(e) {
+		if tl.Schema() == nil || tl.Description() == "" {
+			t.Fatalf("%s has no schema or description", tl.Name())
+		}
+		tools[tl.Name()] = tl
+	}
+	return e, tools
+}
+
+func runTool(t *testing.T, tl tool.Tool, params map[string]interface{}) *tool.ToolResult {
+	t.Helper()
+	res, err := tl.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("%s(%v) error: %v", tl.Name(), params, err)
+	}
+	return res
+}
+
+func TestMemoryTools_SaveSearchUpdateForget(t *testing.T) {
+	e, tools := memoryTools(t)
+
+	saved := runTool(t, tools[SaveToolName], map[string]interface{}{
+		"content": "The user's address is 1 Old Street", "topic": "address", "category": "identity", "importance": 0.9,
+	})
+	id := saved.Data.(map[string]interface{})["id"].(int64)
+	runTool(t, tools[SaveToolName], map[string]interface{}{"content": "The user is called Sam", "tier": float64(1)})
+	if profile, _ := e.LoadTier1(); !strings.Contains(profile, "Sam") {
+		t.Fatalf("tier 1 save not in profile: %q", profile)
+	}
+
+	found := runTool(t, tools[SearchToolName], map[string]interface{}{"query": "what is my address?"})
+	if !strings.HasPrefix(found.Output, "#"+strconv.FormatInt(id, 10)+" [_global/address, identity") {
+		t.Fatalf("search output = %q", found.Output)
+	}
+	if out := runTool(t, tools[SearchToolName], map[string]interface{}{"profile": true}).Output; !strings.Contains(out, "[profile, identity] The user is called Sam") {
+		t.Fatalf("profile listing = %q", out)
+	}
+	if out := runTool(t, tools[SearchToolName], map[string]interface{}{"topic": "address"}).Output; !strings.Contains(out, "Old Street") {
+		t.Fatalf("topic listing = %q", out)
+	}
+
+	updated := runTool(t, tools[UpdateToolName], map[string]interface{}{"id": "#" + strconv.FormatInt(id, 10), "content": "The user's address is 2 New Road"})
+	if !strings.Contains(updated.Output, "2 New Road") {
+		t.Fatalf("update output = %q", updated.Output)
+	}
+
+	forgot := runTool(t, tools[ForgetToolName], map[string]interface{}{"ids": []interface{}{float64(id), float64(9999)}})
+	if !forgot.Success || !strings.Contains(forgot.Output, "New Road") || !strings.Contains(forgot.Output, "Not found: #9999") {
+		t.Fatalf("forget = %+v", forgot)
+	}
+	if out := runTool(t, tools[SearchToolName], map[string]interface{}{"query": "address"}).Output; strings.Contains(out, "Road") {
+		t.Fatalf("forgotten memory still found: %q", out)
+	}
+}
+
+func TestMemoryTools_InvalidParams(t *testing.T) {
+	_, tools := memoryTools(t)
+	tests := []struct {
+		tool   string
+		params map[string]interface{}
+	}{
+		{SaveToolName, map[string]interface{}{"content": "  "}},
+		{SaveToolName, map[string]interface{}{"content": "x", "tier": float64(3)}},
+		{SearchToolName, map[string]interface{}{}},
+		{SearchToolName, map[string]interface{}{"query": "x", "limit": 1.5}},
+		{ForgetToolName, map[string]interface{}{}},
+		{ForgetToolName, map[string]interface{}{"ids": []interface{}{"abc"}}},
+		{UpdateToolName, map[string]interface{}{"content": "x"}},
+		{UpdateToolName, map[string]interface{}{"id": float64(42), "content": "x"}},
+	}
+	for _, tt := range tests {
+		if _, err := tools[tt.tool].Execute(context.Background(), tt.params); err == nil {
+			t.Errorf("%s(%v) should fail", tt.tool, tt.params)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+// defaultRetrievalTriggers are the phrases, per language, that make a
+// message recall memories. memory.retrieval.trigger.keywords replaces the
+// list of any language it names.
+var defaultRetrievalTriggers = map[string][]string{
+	"zh": {
+		"我的", "我之前", "你记得", "上次", "之前", "昨天",
+		"什么", "怎么", "为什么", "？", "喜欢", "设置", "配置", "密码",
+	},
+	"en": {
+		"?", "my", "remember", "last time", "before", "yesterday", "usual", "again",
+		"favorite", "favourite", "prefer", "what", "how", "why", "when", "where",
+		"which", "who", "setting", "config", "password",
+	},
+}
+
+var builtinRetrievalTriggers = retrievalTriggers(nil)
+
+// Reasons a message recalls memories.
+const (
+	TriggerKeyword    = "keyword"
+	TriggerAlways     = "always"
+	TriggerClassifier = "classifier"
+)
+
+// retrievalClassifierTimeout bounds a classifier call, which runs before
+// every message that matches no trigger phrase.
+const retrievalClassifierTimeout = 10 * time.Second
+
+// retrievalTriggers merges the configured phrases into the defaults and
+// lowercases them.
+func retrievalTriggers(configured map[string][]string) []string {
+	languages := make(map[string][]string, len(defaultRetrievalTriggers)+len(configured))
+	for lang, phrases := range defaultRetrievalTriggers {
+		languages[lang] = phrases
+	}
+	for lang, phrases := range configured {
+		languages[strings.ToLower(strings.TrimSpace(lang))] = phrases
+	}
+	var out []string
+	for _, phrases := range languages {
+		for _, p := range phrases {
+			if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+// matchesTrigger reports whether msg contains one of the phrases. Phrases
+// that start or end with a Latin letter or digit match whole words only,
+// so "my" does not match "myclaw".
+func matchesTrigger(msg string, phrases []string) bool {
+	lower := strings.ToLower(msg)
+	for _, p := range phrases {
+		for from := 0; from < len(lower); {
+			i := strings.Index(lower[from:], p)
+			if i < 0 {
+				break
+			}
+			start, end := from+i, from+i+len(p)
+			if wordEdge(lower, p, start, end) {
+				return true
+			}
+			from = start + 1
+		}
+	}
+	return false
+}
+
+func wordEdge(s, phrase string, start, end int) bool {
+	isWord := func(b byte) bool { return b < 0x80 && (unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))) }
+	if isWord(phrase[0]) && start > 0 && isWord(s[start-1]) {
+		return false
+	}
+	if isWord(phrase[len(phrase)-1]) && end < len(s) && isWord(s[end]) {
+		return false
+	}
+	return true
+}
+
+// retrievalClassifier decides for messages without a trigger phrase.
+type retrievalClassifier interface {
+	Classify(ctx context.Context, msg string, visible []string) (bool, error)
+}
+
+// RetrievalGate decides which messages recall memories under
+// memory.retrieval.trigger, and counts how often recalling finds something.
+// A nil gate uses the built-in trigger phrases.
+type RetrievalGate struct {
+	mode         string
+	triggers     []string
+	minRelevance float64
+	classifier   retrievalClassifier
+	engine       *Engine
+
+	mu    sync.Mutex
+	stats RetrievalGateStats
+}
+
+// RetrievalGateStats counts the gate's decisions since it was created.
+type RetrievalGateStats struct {
+	Mode      string `json:"mode"`
+	Messages  int    `json:"messages"`
+	Triggered int    `json:"triggered"`
+	// ByKeyword, ByClassifier and Always split Triggered by reason.
+	ByKeyword        int `json:"byKeyword"`
+	ByClassifier     int `json:"byClassifier"`
+	Always           int `json:"always"`
+	ClassifierErrors int `json:"classifierErrors,omitempty"`
+	// Hits are triggered messages that were sent with at least one memory,
+	// Memories how many memories they got in all, and Filtered the
+	// memories dropped for being below minRelevance.
+	Hits     int     `json:"hits"`
+	Memories int     `json:"memories"`
+	Filtered int     `json:"filtered"`
+	HitRate  float64 `json:"hitRate"`
+}
+
+// NewRetrievalGate returns the gate cfg.Memory.Retrieval.Trigger describes.
+// The embedding classifier needs the engine's embedder; without one,
+// classifier mode only uses the trigger phrases.
+func NewRetrievalGate(cfg *config.Config, engine *Engine) *RetrievalGate {
+	t := cfg.Memory.Retrieval.Trigger
+	g := &RetrievalGate{
+		mode:         strings.ToLower(strings.TrimSpace(t.Mode)),
+		triggers:     retrievalTriggers(t.Keywords),
+		minRelevance: t.MinRelevance,
+		engine:       engine,
+	}
+	switch g.mode {
+	case config.MemoryTriggerAlways, config.MemoryTriggerClassifier:
+	default:
+		g.mode = config.MemoryTriggerKeywords
+	}
+	g.stats.Mode = g.mode
+	if g.mode != config.MemoryTriggerClassifier {
+		return g
+	}
+
+	if strings.EqualFold(strings.TrimSpace(t.Classifier), config.MemoryClassifierLLM) {
+		m := newMemoryModel(cfg, retrievalClassifierTimeout, 0)
+		if m.err != nil {
+			log.Printf("[memory] llm retrieval classifier unavailable, using trigger keywords only: %v", m.err)
+			return g
+		}
+		g.classifier = &llmRetrievalClassifier{model: m}
+		return g
+	}
+	if embedder, _, _ := engine.embeddingSnapshot(); embedder == nil {
+		log.Printf("[memory] embedding retrieval classifier needs memory.embedding, using trigger keywords only")
+		return g
+	}
+	threshold := t.Threshold
+	if threshold <= 0 {
+		threshold = config.DefaultMemoryTriggerThreshold
+	}
+	g.classifier = &embeddingRetrievalClassifier{engine: engine, threshold: threshold, profile: make(map[string]map[string][]float32)}
+	return g
+}
+
+// Decide returns why msg should recall memories, or "" when it should not.
+func (g *RetrievalGate) Decide(msg string, visible []string) string {
+	if g == nil {
+		if shouldRetrieve(msg) {
+			return TriggerKeyword
+		}
+		return ""
+	}
+
+	reason := g.decide(msg, visible)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats.Messages++
+	switch reason {
+	case TriggerKeyword:
+		g.stats.ByKeyword++
+	case TriggerClassifier:
+		g.stats.ByClassifier++
+	case TriggerAlways:
+		g.stats.Always++
+	default:
+		return ""
+	}
+	g.stats.Triggered++
+	return reason
+}
+
+func (g *RetrievalGate) decide(msg string, visible []string) string {
+	if !retrievable(msg) {
+		return ""
+	}
+	if g.mode == config.MemoryTriggerAlways {
+		return TriggerAlways
+	}
+	if matchesTrigger(msg, g.triggers) {
+		return TriggerKeyword
+	}
+	if g.classifier == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), retrievalClassifierTimeout)
+	defer cancel()
+	ok, err := g.classifier.Classify(ctx, msg, visible)
+	if err != nil {
+		log.Printf("[memory] retrieval classifier warning: %v", err)
+		g.mu.Lock()
+		g.stats.ClassifierErrors++
+		g.mu.Unlock()
+		return ""
+	}
+	if ok {
+		return TriggerClassifier
+	}
+	return ""
+}
+
+// Filter drops, in always mode, the memories whose relevance to msg is
+// below minRelevance.
+func (g *RetrievalGate) Filter(msg string, memories []Memory) []Memory {
+	if g == nil || g.mode != config.MemoryTriggerAlways || g.minRelevance <= 0 || len(memories) == 0 || g.engine == nil {
+		return memories
+	}
+	scores := g.engine.Relevance(msg, memories)
+	kept := make([]Memory, 0, len(memories))
+	for i, m := range memories {
+		if scores[i] >= g.minRelevance {
+			kept = append(kept, m)
+		}
+	}
+	g.mu.Lock()
+	g.stats.Filtered += len(memories) - len(kept)
+	g.mu.Unlock()
+	return kept
+}
+
+// Relevance scores how related each memory is to msg, from 0 to 1: the
+// larger of the share of msg's keywords found in the memory and, when
+// embeddings are enabled, the cosine similarity between msg and the
+// memory's stored embedding.
+func (e *Engine) Relevance(msg string, memories []Memory) []float64 {
+	scores := make([]float64, len(memories))
+	var terms []string
+	seen := map[string]struct{}{}
+	for _, token := range sanitizeFTSTokens(extractKeywords(msg)) {
+		for _, term := range ftsQueryTerms(token) {
+			if _, ok := seen[term]; !ok {
+				seen[term] = struct{}{}
+				terms = append(terms, term)
+			}
+		}
+	}
+	if len(terms) > 0 {
+		for i, m := range memories {
+			words := map[string]struct{}{}
+			for _, w := range strings.Fields(ftsText(normalizeFTSToken(m.Content))) {
+				words[w] = struct{}{}
+			}
+			found := 0
+			for _, term := range terms {
+				if _, ok := words[term]; ok {
+					found++
+				}
+			}
+			scores[i] = float64(found) / float64(len(terms))
+		}
+	}
+
+	embedder, _, timeoutMs := e.embeddingSnapshot()
+	if embedder == nil {
+		return scores
+	}
+	stored, err := e.storedEmbeddings(memories)
+	if err != nil {
+		log.Printf("[memory] relevance warning: %v", err)
+		return scores
+	}
+	if len(stored) == 0 {
+		return scores
+	}
+	ctx, cancel := withEmbeddingTimeout(context.Background(), timeoutMs)
+	defer cancel()
+	query, err := embedder.Embed(ctx, msg)
+	if err != nil {
+		log.Printf("[memory] relevance warning: embed message: %v", err)
+		return scores
+	}
+	for i, m := range memories {
+		vec, ok := stored[m.ID]
+		if !ok {
+			continue
+		}
+		if s, err := CosineSimilarity(query, vec); err == nil && s > scores[i] {
+			scores[i] = s
+		}
+	}
+	return scores
+}
+
+// storedEmbeddings loads the current embeddings of memories, by ID.
+func (e *Engine) storedEmbeddings(memories []Memory) (map[int64][]float32, error) {
+	if len(memories) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(memories))
+	for i, m := range memories {
+		args[i] = m.ID
+	}
+	rows, err := e.db.Query(`
+		SELECT id, embedding
+		FROM memories
+		WHERE id IN (`+placeholders(len(args))+`)
+		  AND embedding IS NOT NULL
+		  AND embedding_dim > 0
+		  AND embedding_stale = 0
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stored embeddings: %w", err)
+	}
+	defer rows.Close()
+	out := make(map[int64][]float32, len(memories))
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, fmt.Errorf("scan stored embedding: %w", err)
+		}
+		if vec, err := DecodeVector(blob); err == nil {
+			out[id] = vec
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stored embeddings: %w", err)
+	}
+	return out, nil
+}
+
+// Record counts the memories a triggered message was sent with.
+func (g *RetrievalGate) Record(memories int) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if memories > 0 {
+		g.stats.Hits++
+	}
+	g.stats.Memories += memories
+}
+
+// Stats returns the counts so far.
+func (g *RetrievalGate) Stats() RetrievalGateStats {
+	if g == nil {
+		return RetrievalGateStats{}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := g.stats
+	if stats.Triggered > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Triggered)
+	}
+	return stats
+}
+
+// Usage reports the tokens the LLM classifier used.
+func (g *RetrievalGate) Usage() ModelUsage {
+	if g == nil {
+		return ModelUsage{}
+	}
+	if r, ok := g.classifier.(UsageReporter); ok {
+		return r.Usage()
+	}
+	return ModelUsage{}
+}
+
+// embeddingRetrievalClassifier triggers when the message is similar to an
+// entry of the visible core profile. Profile embeddings are cached by
+// content, per set of visible scopes; each call keeps only the entries that
+// are still in the profile, so edited and archived ones do not linger.
+type embeddingRetrievalClassifier struct {
+	engine    *Engine
+	threshold float64
+
+	mu      sync.Mutex
+	profile map[string]map[string][]float32
+}
+
+func (c *embeddingRetrievalClassifier) Classify(ctx context.Context, msg string, visible []string) (bool, error) {
+	embedder, _, _ := c.engine.embeddingSnapshot()
+	if embedder == nil {
+		return false, nil
+	}
+	entries, err := c.engine.ListMemories(MemoryFilter{Tier: 1, Scopes: visible, Limit: 100})
+	if err != nil {
+		return false, err
+	}
+	if len(entries) == 0 {
+		return false, nil
+	}
+
+	key := strings.Join(visible, "\x00")
+	if visible == nil {
+		key = "*"
+	}
+	c.mu.Lock()
+	cached := c.profile[key]
+	var missing []string
+	for _, m := range entries {
+		if _, ok := cached[m.Content]; !ok {
+			missing = append(missing, m.Content)
+		}
+	}
+	c.mu.Unlock()
+	texts := append([]string{msg}, missing...)
+	vectors, err := embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return false, fmt.Errorf("embed message and profile: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return false, fmt.Errorf("embed message and profile: got %d vectors for %d texts", len(vectors), len(texts))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := make(map[string][]float32, len(entries))
+	for i, content := range missing {
+		current[content] = vectors[i+1]
+	}
+	for _, m := range entries {
+		if _, ok := current[m.Content]; !ok {
+			current[m.Content] = c.profile[key][m.Content]
+		}
+	}
+	c.profile[key] = current
+	for _, m := range entries {
+		if s, err := CosineSimilarity(vectors[0], current[m.Content]); err == nil && s >= c.threshold {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const retrievalDecisionPrompt = `Decide whether answering this message needs what the assistant remembers about the user: their preferences, habits, people, projects, settings or earlier conversations. Small talk, general knowledge and self-contained tasks do not.
+
+Message:
+%s`
+
+// llmRetrievalClassifier asks the memory model.
+type llmRetrievalClassifier struct {
+	model *memoryModel
+}
+
+func (c *llmRetrievalClassifier) Classify(ctx context.Context, msg string, _ []string) (bool, error) {
+	var retrieve bool
+	err := c.model.completeJSON(ctx, fmt.Sprintf(retrievalDecisionPrompt, strings.TrimSpace(msg)), retrievalDecisionTool, func(payload string) error {
+		var decision struct {
+			Retrieve *bool `json:"retrieve"`
+		}
+		if err := json.Unmarshal([]byte(payload), &decision); err != nil {
+			return err
+		}
+		if decision.Retrieve == nil {
+			return fmt.Errorf("missing retrieve")
+		}
+		retrieve = *decision.Retrieve
+		return nil
+	})
+	return retrieve, err
+}
+
+func (c *llmRetrievalClassifier) Usage() ModelUsage {
+	return c.model.Usage()
+}
@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+func TestMatchesTrigger(t *testing.T) {
+	cases := []struct {
+		msg  string
+		want bool
+	}{
+		{"Book the usual restaurant for Friday", true},
+		{"What did I say about the deploy", true},
+		{"Remind me of my dentist appointment", true},
+		{"show the myclaw logs", false},
+		{"translate this paragraph into French", false},
+		{"上次部署遇到的问题", true},
+		{"帮我写个排序函数", false},
+	}
+	for _, tc := range cases {
+		if got := matchesTrigger(tc.msg, builtinRetrievalTriggers); got != tc.want {
+			t.Fatalf("matchesTrigger(%q) = %v, want %v", tc.msg, got, tc.want)
+		}
+	}
+
+	triggers := retrievalTriggers(map[string][]string{"zh": {"记得"}, "de": {"Letztes Mal"}})
+	if matchesTrigger("上次部署遇到的问题", triggers) {
+		t.Fatal("configured zh list should replace the built-in one")
+	}
+	for _, msg := range []string{"还记得那家店吗", "wie letztes mal bitte", "book the usual restaurant"} {
+		if !matchesTrigger(msg, triggers) {
+			t.Fatalf("matchesTrigger(%q) = false with configured triggers", msg)
+		}
+	}
+}
+
+func newGateTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	e, err := NewEngine(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	e.SetEmbedder(newLocalEmbedder(0), localEmbeddingModel, 5000)
+	return e
+}
+
+func TestRetrievalGateAlwaysFiltersByRelevance(t *testing.T) {
+	e := newGateTestEngine(t)
+	facts := []string{"生产环境的部署流程需要两人审批", "用户喜欢吃辣的面条"}
+	for _, content := range facts {
+		if err := e.WriteTier2(FactEntry{Content: content, Project: "myclaw", Topic: "ops", Category: "decision", Importance: 0.5}); err != nil {
+			t.Fatalf("WriteTier2 error: %v", err)
+		}
+	}
+	if _, err := e.BackfillEmbeddings(context.Background(), 10); err != nil {
+		t.Fatalf("BackfillEmbeddings error: %v", err)
+	}
+	memories, err := e.ListMemories(MemoryFilter{Tier: 2})
+	if err != nil || len(memories) != 2 {
+		t.Fatalf("ListMemories = %v, %v", memories, err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Memory.Retrieval.Trigger.Mode = config.MemoryTriggerAlways
+	cfg.Memory.Retrieval.Trigger.MinRelevance = 0.3
+	gate := NewRetrievalGate(cfg, e)
+
+	if got := gate.Decide("帮我写个排序函数", nil); got != TriggerAlways {
+		t.Fatalf("Decide = %q, want %q", got, TriggerAlways)
+	}
+	if got := gate.Decide("好的", nil); got != "" {
+		t.Fatalf("Decide(acknowledgement) = %q, want none", got)
+	}
+	kept := gate.Filter("部署流程要谁审批", memories)
+	if len(kept) != 1 || kept[0].Content != facts[0] {
+		t.Fatalf("Filter kept %+v, want only the deployment fact", kept)
+	}
+	gate.Record(len(kept))
+
+	stats := gate.Stats()
+	want := RetrievalGateStats{Mode: config.MemoryTriggerAlways, Messages: 2, Triggered: 1, Always: 1, Hits: 1, Memories: 1, Filtered: 1, HitRate: 1}
+	if stats != want {
+		t.Fatalf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestRetrievalGateEmbeddingClassifier(t *testing.T) {
+	e := newGateTestEngine(t)
+	id, err := e.AddTier1(ProfileEntry{Content: "用户喜欢吃辣的面条", Category: "preference"})
+	if err != nil {
+		t.Fatalf("AddTier1 error: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Memory.Retrieval.Trigger.Mode = config.MemoryTriggerClassifier
+	gate := NewRetrievalGate(cfg, e)
+	visible := []string{SharedScope}
+
+	cases := []struct {
+		msg  string
+		want string
+	}{
+		{"上次那家店叫什么", TriggerKeyword},
+		{"今晚吃辣的面条吧", TriggerClassifier},
+		{"帮我写个排序函数", ""},
+	}
+	for _, tc := range cases {
+		if got := gate.Decide(tc.msg, visible); got != tc.want {
+			t.Fatalf("Decide(%q) = %q, want %q", tc.msg, got, tc.want)
+		}
+	}
+	if stats := gate.Stats(); stats.ByKeyword != 1 || stats.ByClassifier != 1 || stats.Triggered != 2 || stats.Messages != 3 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+
+	// An edited entry replaces its cached vector instead of piling up.
+	if _, err := e.UpdateMemory(id, FactEntry{Content: "用户喜欢喝绿茶"}); err != nil {
+		t.Fatalf("UpdateMemory error: %v", err)
+	}
+	gate.Decide("帮我写个排序函数", visible)
+	cache := gate.classifier.(*embeddingRetrievalClassifier).profile
+	if len(cache) != 1 || len(cache[SharedScope]) != 1 || cache[SharedScope]["用户喜欢喝绿茶"] == nil {
+		t.Fatalf("profile cache = %v", cache)
+	}
+}
+
+func TestRetrievalGateLLMClassifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, openAIToolCallResponse(retrievalDecisionTool.Name, `{"retrieve":true}`))
+	}))
+	defer srv.Close()
+
+	cfg := newOpenAIMemoryConfig(srv.URL)
+	cfg.Memory.Retrieval.Trigger.Mode = config.MemoryTriggerClassifier
+	cfg.Memory.Retrieval.Trigger.Classifier = config.MemoryClassifierLLM
+	gate := NewRetrievalGate(cfg, nil)
+
+	if got := gate.Decide("book a table for tonight", nil); got != TriggerClassifier {
+		t.Fatalf("Decide = %q, want %q", got, TriggerClassifier)
+	}
+	gate.Record(0)
+	if stats := gate.Stats(); stats.Triggered != 1 || stats.Hits != 0 || stats.HitRate != 0 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	if usage := gate.Usage(); usage.Requests != 1 || usage.TotalTokens != 120 {
+		t.Fatalf("Usage = %+v", usage)
+	}
+}
+
+func TestNilRetrievalGateUsesBuiltinTriggers(t *testing.T) {
+	var gate *RetrievalGate
+	if got := gate.Decide("我之前的 myclaw 配置是什么？", nil); got != TriggerKeyword {
+		t.Fatalf("Decide = %q, want %q", got, TriggerKeyword)
+	}
+	if got := gate.Decide("帮我写个排序函数", nil); got != "" {
+		t.Fatalf("Decide = %q, want none", got)
+	}
+	memories := []Memory{{ID: 1}}
+	if got := gate.Filter("anything", memories); len(got) != 1 {
+		t.Fatalf("Filter = %+v", got)
+	}
+	gate.Record(1)
+	if stats := gate.Stats(); stats != (RetrievalGateStats{}) {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
@@ -2,21 +2,44 @@ package memory
 
 // Memory is a tier-1/tier-2 memory record.
 type Memory struct {
-	ID           int64
-	Tier         int
-	Project      string
-	Topic        string
-	Category     string
-	Content      string
-	Importance   float64
-	Source       string
-	CreatedAt    string
-	UpdatedAt    string
-	LastAccessed string
-	AccessCount  int
-	IsArchived   bool
+	ID           int64   `json:"id"`
+	Tier         int     `json:"tier"`
+	Project      string  `json:"project"`
+	Topic        string  `json:"topic"`
+	Category     string  `json:"category"`
+	Content      string  `json:"content"`
+	Importance   float64 `json:"importance"`
+	Source       string  `json:"source"`
+	CreatedAt    string  `json:"createdAt"`
+	UpdatedAt    string  `json:"updatedAt"`
+	LastAccessed string  `json:"lastAccessed"`
+	AccessCount  int     `json:"accessCount"`
+	IsArchived   bool    `json:"archived"`
+	Scope        string  `json:"scope"`
+}
+
+// MemoryFilter selects memories for ListMemories. Zero fields match
+// everything; by default only active memories are listed.
+type MemoryFilter struct {
+	Query    string // keywords matched against the full-text index
+	Tier     int
+	Project  string
+	Topic    string
+	Category string
+	Archived ArchivedFilter
+	Scopes   []string // nil matches every scope
+	Limit    int
 }
 
+// ArchivedFilter chooses between active and archived memories.
+type ArchivedFilter int
+
+const (
+	ActiveOnly ArchivedFilter = iota
+	ArchivedOnly
+	ActiveAndArchived
+)
+
 // EventEntry is a tier-3 daily event row.
 type EventEntry struct {
 	ID           int64
@@ -27,6 +50,7 @@ type EventEntry struct {
 	Tokens       int
 	IsCompressed bool
 	CreatedAt    string
+	Scope        string
 }
 
 // BufferMessage is a persisted extraction buffer message.
@@ -38,6 +62,7 @@ type BufferMessage struct {
 	Content    string
 	TokenCount int
 	CreatedAt  string
+	Scope      string
 }
 
 // FactEntry is a normalized fact produced by extraction/compression.
@@ -47,6 +72,8 @@ type FactEntry struct {
 	Topic      string  `json:"topic"`
 	Category   string  `json:"category"`
 	Importance float64 `json:"importance"`
+	// Scope is set by the caller, never by the LLM; empty means shared.
+	Scope string `json:"-"`
 }
 
 // ExtractionResult is the LLM extraction output.
@@ -64,6 +91,7 @@ type CompressionResult struct {
 type ProfileEntry struct {
 	Content  string `json:"content"`
 	Category string `json:"category"`
+	Scope    string `json:"-"` // empty means shared
 }
 
 // ProfileResult is the LLM profile-update output.
@@ -73,10 +101,10 @@ type ProfileResult struct {
 
 // MemoryStats is a compact snapshot used by status reporting.
 type MemoryStats struct {
-	Tier1Count       int
-	Tier2ActiveCount int
-	Tier2Archived    int
-	EventPending     int
-	EventCompressed  int
-	BufferMessages   int
+	Tier1Count       int `json:"tier1Count"`
+	Tier2ActiveCount int `json:"tier2ActiveCount"`
+	Tier2Archived    int `json:"tier2Archived"`
+	EventPending     int `json:"eventPending"`
+	EventCompressed  int `json:"eventCompressed"`
+	BufferMessages   int `json:"bufferMessages"`
 }
@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+const (
+	// vectorIndexOversample is how many index hits are fetched per wanted
+	// candidate, leaving room for the project and scope filters.
+	vectorIndexOversample = 4
+	// vectorIndexMaxFetch caps the hits looked up in SQLite at once; a
+	// search that needs more falls back to the full scan.
+	vectorIndexMaxFetch = 8192
+)
+
+// vectorIndexMinSize is the number of embeddings below which a full scan is
+// as fast as the index and exact. A variable so tests can index small stores.
+var vectorIndexMinSize = 512
+
+// vectorIndexState holds the engine's ANN index over tier-2 embeddings. It
+// is built from SQLite in the background when the store is opened and kept
+// up to date as embeddings are written and memories archived or restored;
+// changes made while it is being built are queued and replayed onto it.
+type vectorIndexState struct {
+	mu       sync.Mutex
+	index    *hnswIndex
+	building bool
+	pending  []func(*hnswIndex)
+}
+
+// StartVectorIndex builds the embedding index in the background. Vector
+// search scans every embedding until the index is ready.
+func (e *Engine) StartVectorIndex() {
+	go func() {
+		if err := e.buildVectorIndex(); err != nil {
+			log.Printf("[memory] vector index build failed: %v", err)
+		}
+	}()
+}
+
+// buildVectorIndex indexes every active tier-2 embedding of the dimension
+// most recently written.
+func (e *Engine) buildVectorIndex() error {
+	s := &e.vectors
+	s.mu.Lock()
+	if s.building || s.index != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.building = true
+	s.mu.Unlock()
+
+	index, err := e.loadVectorIndex()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.building = false
+	if err != nil {
+		s.pending = nil
+		return err
+	}
+	for _, apply := range s.pending {
+		apply(index)
+	}
+	s.pending = nil
+	s.index = index
+	log.Printf("[memory] vector index ready: %d embeddings", index.Len())
+	return nil
+}
+
+func (e *Engine) loadVectorIndex() (*hnswIndex, error) {
+	rows, err := e.db.Query(`
+		SELECT id, embedding
+		FROM memories
+		WHERE tier = 2
+		  AND is_archived = 0
+		  AND embedding IS NOT NULL
+		  AND embedding_dim > 0
+		  AND embedding_stale = 0
+		ORDER BY embedding_updated_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query vector index rows: %w", err)
+	}
+	defer rows.Close()
+
+	var index *hnswIndex
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, fmt.Errorf("scan vector index row: %w", err)
+		}
+		vec, err := DecodeVector(blob)
+		if err != nil {
+			continue
+		}
+		if index == nil {
+			index = newHNSWIndex(len(vec))
+		}
+		// Embeddings of another dimension (an older model) are only found
+		// by the full scan.
+		_ = index.Add(id, vec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate vector index rows: %w", err)
+	}
+	if index == nil {
+		index = newHNSWIndex(0)
+	}
+	return index, nil
+}
+
+// readyVectorIndex returns the index, or nil while it is not built.
+func (e *Engine) readyVectorIndex() *hnswIndex {
+	e.vectors.mu.Lock()
+	defer e.vectors.mu.Unlock()
+	return e.vectors.index
+}
+
+// updateVectorIndex applies fn to the index now, or once it is built.
+func (e *Engine) updateVectorIndex(fn func(*hnswIndex)) {
+	s := &e.vectors
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.index != nil:
+		fn(s.index)
+	case s.building:
+		s.pending = append(s.pending, fn)
+	}
+}
+
+func (e *Engine) indexVector(memoryID int64, vector []float32) {
+	e.updateVectorIndex(func(index *hnswIndex) {
+		if err := index.Add(memoryID, vector); err != nil {
+			index.Remove(memoryID)
+		}
+	})
+}
+
+func (e *Engine) unindexVector(memoryID int64) {
+	e.updateVectorIndex(func(index *hnswIndex) {
+		index.Remove(memoryID)
+	})
+}
+
+// reindexMemory indexes the stored embedding of a restored memory.
+func (e *Engine) reindexMemory(memoryID int64) {
+	if e.readyVectorIndex() == nil && !e.vectorIndexBuilding() {
+		return
+	}
+	var blob []byte
+	if err := e.db.QueryRow(`
+		SELECT embedding FROM memories
+		WHERE id = ? AND tier = 2 AND is_archived = 0 AND embedding IS NOT NULL AND embedding_dim > 0 AND embedding_stale = 0
+	`, memoryID).Scan(&blob); err != nil {
+		return
+	}
+	if vec, err := DecodeVector(blob); err == nil {
+		e.indexVector(memoryID, vec)
+	}
+}
+
+func (e *Engine) vectorIndexBuilding() bool {
+	e.vectors.mu.Lock()
+	defer e.vectors.mu.Unlock()
+	return e.vectors.building
+}
+
+// indexedVectorRows looks up the embeddings nearest to queryVector through
+// the index and returns their rows that pass the project and scope filters,
+// fetching more hits until limit rows pass. ok is false when the index
+// cannot answer (not built, too small, other dimension, filters too
+// narrow) and the caller should scan every row.
+func (e *Engine) indexedVectorRows(queryVector []float32, project string, visible []string, limit int) (rows []memoryWithEmbedding, ok bool, err error) {
+	index := e.readyVectorIndex()
+	if index == nil || index.Dim() != len(queryVector) {
+		return nil, false, nil
+	}
+	size := index.Len()
+	if size < vectorIndexMinSize {
+		return nil, false, nil
+	}
+
+	for k := limit * vectorIndexOversample; k <= vectorIndexMaxFetch; k *= vectorIndexOversample {
+		hits, err := index.Search(queryVector, k, k)
+		if err != nil {
+			return nil, false, nil
+		}
+		ids := make([]int64, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.ID
+		}
+		rows, err := e.queryVectorRowsByID(ids, project, visible)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(rows) >= limit || k >= size {
+			return rows, true, nil
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+const (
+	PathPrefix = "/hooks/"
+
+	VerifyHMACSHA256 = "hmac-sha256"
+	VerifyToken      = "token"
+
+	DefaultHMACHeader  = "X-Hub-Signature-256"
+	DefaultTokenHeader = "X-Webhook-Token"
+
+	maxBodyBytes = 1 << 20 // 1MB
+
+	// maxPendingDeliveries is how many deliveries may wait for an endpoint's
+	// running trigger before further ones are refused with 429.
+	maxPendingDeliveries = 16
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Event is the data passed to a prompt template.
+type Event struct {
+	Name    string
+	Header  http.Header
+	Payload any
+}
+
+type endpoint struct {
+	cfg    config.WebhookEndpoint
+	verify string
+	header string
+	tmpl   *template.Template
+
+	// Deliveries share the endpoint's session, so they run one at a time,
+	// in order, on a worker started by the first one.
+	queue  chan string
+	worker sync.Once
+}
+
+type Service struct {
+	endpoints map[string]*endpoint
+	// OnTrigger runs a delivery's prompt. ctx is cancelled when Shutdown
+	// gives up waiting for the run.
+	OnTrigger func(ctx context.Context, ep config.WebhookEndpoint, prompt string) (string, error)
+	wg        sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// mu guards stopped and the endpoint queues, which Shutdown closes.
+	mu      sync.Mutex
+	stopped bool
+}
+
+func NewService(endpoints []config.WebhookEndpoint) (*Service, error) {
+	s := &Service{endpoints: make(map[string]*endpoint, len(endpoints))}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, cfg := range endpoints {
+		name := strings.TrimSpace(cfg.Name)
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("webhook %q: name must match %s", cfg.Name, validName.String())
+		}
+		if _, dup := s.endpoints[name]; dup {
+			return nil, fmt.Errorf("webhook %q: duplicate name", name)
+		}
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("webhook %q: secret is required", name)
+		}
+
+		verify := strings.ToLower(strings.TrimSpace(cfg.Verify))
+		header := strings.TrimSpace(cfg.SignatureHeader)
+		switch verify {
+		case "", VerifyHMACSHA256:
+			verify = VerifyHMACSHA256
+			if header == "" {
+				header = DefaultHMACHeader
+			}
+		case VerifyToken:
+			if header == "" {
+				header = DefaultTokenHeader
+			}
+		default:
+			return nil, fmt.Errorf("webhook %q: unknown verify mode %q", name, cfg.Verify)
+		}
+
+		if strings.TrimSpace(cfg.Prompt) == "" {
+			return nil, fmt.Errorf("webhook %q: prompt is required", name)
+		}
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(cfg.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q: parse prompt template: %w", name, err)
+		}
+
+		cfg.Name = name
+		s.endpoints[name] = &endpoint{
+			cfg:    cfg,
+			verify: verify,
+			header: header,
+			tmpl:   tmpl,
+			queue:  make(chan string, maxPendingDeliveries),
+		}
+	}
+	return s, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+}
+
+func (s *Service) Handler() http.Handler {
+	return http.HandlerFunc(s.handle)
+}
+
+func (s *Service) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, PathPrefix)
+	ep, ok := s.endpoints[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body failed", http.StatusBadRequest)
+		return
+	}
+
+	if !ep.verifyRequest(r.Header, body) {
+		log.Printf("[webhook] %s: rejected request with invalid signature", name)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var payload any
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	prompt, err := ep.render(Event{Name: name, Header: r.Header, Payload: payload})
+	if err != nil {
+		log.Printf("[webhook] %s: render prompt error: %v", name, err)
+		http.Error(w, "render prompt failed", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if s.OnTrigger == nil || strings.TrimSpace(prompt) == "" {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	s.wg.Add(1)
+	select {
+	case ep.queue <- prompt:
+	default:
+		s.mu.Unlock()
+		s.wg.Done()
+		log.Printf("[webhook] %s: %d deliveries pending, rejecting request", name, maxPendingDeliveries)
+		http.Error(w, "too many pending deliveries", http.StatusTooManyRequests)
+		return
+	}
+	ep.worker.Do(func() { go s.work(ep) })
+	s.mu.Unlock()
+
+	// Acknowledge immediately: senders like GitHub time out long before an agent run finishes.
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// work runs an endpoint's queued deliveries one after another until Shutdown
+// closes the queue. Deliveries still queued at that point are dropped.
+func (s *Service) work(ep *endpoint) {
+	for prompt := range ep.queue {
+		if s.isStopped() {
+			log.Printf("[webhook] %s: shutting down, dropping queued delivery", ep.cfg.Name)
+		} else if _, err := s.OnTrigger(s.ctx, ep.cfg, prompt); err != nil {
+			log.Printf("[webhook] %s: trigger error: %v", ep.cfg.Name, err)
+		}
+		s.wg.Done()
+	}
+}
+
+func (s *Service) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// Wait blocks until all queued and in-flight triggers have finished.
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+// Shutdown refuses new deliveries, drops queued ones and waits for running
+// triggers. If ctx is done first, it cancels their context and returns
+// ctx.Err() once they have returned.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		for _, ep := range s.endpoints {
+			close(ep.queue)
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	defer s.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (e *endpoint) verifyRequest(h http.Header, body []byte) bool {
+	got := strings.TrimSpace(h.Get(e.header))
+	if got == "" && e.verify == VerifyToken {
+		got = strings.TrimSpace(h.Get("Authorization"))
+	}
+	if got == "" {
+		return false
+	}
+
+	switch e.verify {
+	case VerifyToken:
+		got = strings.TrimSpace(strings.TrimPrefix(got, "Bearer "))
+		return subtle.ConstantTimeCompare([]byte(got), []byte(e.cfg.Secret)) == 1
+	default:
+		got = strings.TrimPrefix(got, "sha256=")
+		sig, err := hex.DecodeString(got)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(e.cfg.Secret))
+		mac.Write(body)
+		return hmac.Equal(sig, mac.Sum(nil))
+	}
+}
+
+func (e *endpoint) render(ev Event) (string, error) {
+	var sb strings.Builder
+	if err := e.tmpl.Execute(&sb, ev); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stellarlinkco/myclaw/internal/config"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type triggerCall struct {
+	ep     config.WebhookEndpoint
+	prompt string
+}
+
+func newTestService(t *testing.T, endpoints ...config.WebhookEndpoint) (*Service, chan triggerCall) {
+	t.Helper()
+	svc, err := NewService(endpoints)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	calls := make(chan triggerCall, 4)
+	svc.OnTrigger = func(ctx context.Context, ep config.WebhookEndpoint, prompt string) (string, error) {
+		calls <- triggerCall{ep: ep, prompt: prompt}
+		return "ok", nil
+	}
+	return svc, calls
+}
+
+func post(svc *Service, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	rec := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewService_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		ep   config.WebhookEndpoint
+	}{
+		{"empty name", config.WebhookEndpoint{Secret: "s", Prompt: "p"}},
+		{"bad name", config.WebhookEndpoint{Name: "a/b", Secret: "s", Prompt: "p"}},
+		{"no secret", config.WebhookEndpoint{Name: "a", Prompt: "p"}},
+		{"no prompt", config.WebhookEndpoint{Name: "a", Secret: "s"}},
+		{"bad verify", config.WebhookEndpoint{Name: "a", Secret: "s", Prompt: "p", Verify: "md5"}},
+		{"bad template", config.WebhookEndpoint{Name: "a", Secret: "s", Prompt: "{{.Payload"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewService([]config.WebhookEndpoint{tt.ep}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	dup := config.WebhookEndpoint{Name: "a", Secret: "s", Prompt: "p"}
+	if _, err := NewService([]config.WebhookEndpoint{dup, dup}); err == nil {
+		t.Fatal("expected duplicate name error")
+	}
+}
+
+func TestHandler_HMACRendersAndTriggers(t *testing.T) {
+	svc, calls := newTestService(t, config.WebhookEndpoint{
+		Name:    "github",
+		Secret:  "topsecret",
+		Prompt:  `{{.Header.Get "X-GitHub-Event"}} on {{.Payload.repository.name}} ({{json .Payload.labels}})`,
+		Channel: "telegram",
+		To:      "42",
+	})
+
+	body := `{"repository":{"name":"myclaw"},"labels":["bug"]}`
+	rec := post(svc, "/hooks/github", body, http.Header{
+		"X-Hub-Signature-256": {sign("topsecret", body)},
+		"X-Github-Event":      {"push"},
+	})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+
+	select {
+	case call := <-calls:
+		if call.prompt != `push on myclaw (["bug"])` {
+			t.Errorf("prompt = %q", call.prompt)
+		}
+		if call.ep.Channel != "telegram" || call.ep.To != "42" {
+			t.Errorf("endpoint = %+v", call.ep)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for trigger")
+	}
+	svc.Wait()
+}
+
+func TestHandler_RejectsBadSignature(t *testing.T) {
+	svc, calls := newTestService(t, config.WebhookEndpoint{Name: "gh", Secret: "topsecret", Prompt: "x"})
+
+	body := `{"a":1}`
+	for _, sig := range []string{"", "sha256=deadbeef", sign("wrong", body), "not-hex"} {
+		h := http.Header{}
+		if sig != "" {
+			h.Set("X-Hub-Signature-256", sig)
+		}
+		rec := post(svc, "/hooks/gh", body, h)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("sig %q: status = %d, want %d", sig, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	select {
+	case call := <-calls:
+		t.Fatalf("unexpected trigger: %+v", call)
+	default:
+	}
+}
+
+func TestHandler_TokenVerify(t *testing.T) {
+	svc, calls := newTestService(t, config.WebhookEndpoint{
+		Name:   "grafana",
+		Secret: "tok",
+		Verify: VerifyToken,
+		Prompt: "alert: {{.Payload.title}}",
+	})
+
+	if rec := post(svc, "/hooks/grafana", `{"title":"CPU"}`, http.Header{"X-Webhook-Token": {"nope"}}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token status = %d", rec.Code)
+	}
+	if rec := post(svc, "/hooks/grafana", `{"title":"CPU"}`, http.Header{"Authorization": {"Bearer tok"}}); rec.Code != http.StatusAccepted {
+		t.Fatalf("bearer status = %d", rec.Code)
+	}
+	if rec := post(svc, "/hooks/grafana", `{"title":"Disk"}`, http.Header{"X-Webhook-Token": {"tok"}}); rec.Code != http.StatusAccepted {
+		t.Fatalf("header status = %d", rec.Code)
+	}
+	svc.Wait()
+
+	got := []string{(<-calls).prompt, (<-calls).prompt}
+	if strings.Join(got, ",") != "alert: CPU,alert: Disk" && strings.Join(got, ",") != "alert: Disk,alert: CPU" {
+		t.Errorf("prompts = %v", got)
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	svc, _ := newTestService(t, config.WebhookEndpoint{Name: "gh", Secret: "s", Prompt: "x"})
+
+	req := httptest.NewRequest(http.MethodGet, "/hooks/gh", nil)
+	rec := httptest.NewRecorder()
+	svc.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	if rec := post(svc, "/hooks/unknown", "{}", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	body := "not json"
+	if rec := post(svc, "/hooks/gh", body, http.Header{"X-Hub-Signature-256": {sign("s", body)}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid json status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandler_SerializesAndBoundsDeliveries(t *testing.T) {
+	svc, err := NewService([]config.WebhookEndpoint{{Name: "gh", Secret: "s", Prompt: "{{.Payload.n}}"}})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	started := make(chan string, maxPendingDeliveries+1)
+	release := make(chan struct{})
+	var running, overlapped int32
+	svc.OnTrigger = func(ctx context.Context, ep config.WebhookEndpoint, prompt string) (string, error) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		started <- prompt
+		<-release
+		atomic.AddInt32(&running, -1)
+		return "ok", nil
+	}
+	deliver := func(n int) int {
+		body := fmt.Sprintf(`{"n":%d}`, n)
+		return post(svc, "/hooks/gh", body, http.Header{"X-Hub-Signature-256": {sign("s", body)}}).Code
+	}
+
+	if code := deliver(0); code != http.StatusAccepted {
+		t.Fatalf("first delivery status = %d", code)
+	}
+	<-started // the worker holds delivery 0; the queue is empty again
+	for i := 1; i <= maxPendingDeliveries; i++ {
+		if code := deliver(i); code != http.StatusAccepted {
+			t.Fatalf("delivery %d status = %d, want %d", i, code, http.StatusAccepted)
+		}
+	}
+	if code := deliver(maxPendingDeliveries + 1); code != http.StatusTooManyRequests {
+		t.Fatalf("overflow status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	close(release)
+	svc.Wait()
+	for i := 1; i <= maxPendingDeliveries; i++ {
+		if got := <-started; got != strconv.Itoa(i) {
+			t.Fatalf("delivery %d ran as %q, want in order", i, got)
+		}
+	}
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Fatal("deliveries to one webhook ran concurrently")
+	}
+}
+
+func TestService_ShutdownDropsQueuedAndCancelsRunning(t *testing.T) {
+	svc, err := NewService([]config.WebhookEndpoint{{Name: "gh", Secret: "s", Prompt: "{{.Payload.n}}"}})
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	started := make(chan string, 4)
+	var cancelled atomic.Bool
+	svc.OnTrigger = func(ctx context.Context, ep config.WebhookEndpoint, prompt string) (string, error) {
+		started <- prompt
+		<-ctx.Done()
+		cancelled.Store(true)
+		return "", ctx.Err()
+	}
+	deliver := func(n int) int {
+		body := fmt.Sprintf(`{"n":%d}`, n)
+		return post(svc, "/hooks/gh", body, http.Header{"X-Hub-Signature-256": {sign("s", body)}}).Code
+	}
+
+	if code := deliver(0); code != http.StatusAccepted {
+		t.Fatalf("first delivery status = %d", code)
+	}
+	<-started
+	if code := deliver(1); code != http.StatusAccepted {
+		t.Fatalf("queued delivery status = %d", code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := svc.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown error = %v, want deadline exceeded", err)
+	}
+	if !cancelled.Load() {
+		t.Error("running trigger was not cancelled")
+	}
+	select {
+	case prompt := <-started:
+		t.Errorf("queued delivery %q ran after shutdown", prompt)
+	default:
+	}
+	if code := deliver(2); code != http.StatusServiceUnavailable {
+		t.Errorf("delivery after shutdown status = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestService_ShutdownWaitsForRunning(t *testing.T) {
+	svc, calls := newTestService(t, config.WebhookEndpoint{Name: "gh", Secret: "s", Prompt: "hi"})
+	if rec := post(svc, "/hooks/gh", "{}", http.Header{"X-Hub-Signature-256": {sign("s", "{}")}}); rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	<-calls
+	if err := svc.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}